@@ -1,50 +1,187 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/dasler-fw/bookcrossing/internal/cache"
 	"github.com/dasler-fw/bookcrossing/internal/config"
+	"github.com/dasler-fw/bookcrossing/internal/events"
+	"github.com/dasler-fw/bookcrossing/internal/health"
+	"github.com/dasler-fw/bookcrossing/internal/idempotency"
+	"github.com/dasler-fw/bookcrossing/internal/jwtutil"
+	"github.com/dasler-fw/bookcrossing/internal/mail"
+	"github.com/dasler-fw/bookcrossing/internal/metrics"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
 	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/ratelimit"
 	"github.com/dasler-fw/bookcrossing/internal/repository"
 	"github.com/dasler-fw/bookcrossing/internal/services"
 	"github.com/dasler-fw/bookcrossing/internal/transport"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
 func main() {
-	log := config.InitLogger()
-
-	config.SetEnv(log)
-
-	db := config.Connect(log)
-	redes:= config.ConnectRedis()
-	if err := db.AutoMigrate(
-		&models.User{},
-		&models.Book{},
-		&models.Genre{},
-		&models.Exchange{},
-		&models.Review{},
-	); err != nil {
-		log.Error("failed to migrate database", "error", err)
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(2)
+	}
+
+	log := config.InitLogger(cfg.LogLevel)
+	slog.SetDefault(log)
+
+	jwtutil.Configure(cfg.JWT.Secret, cfg.JWT.TTL)
+
+	shutdownTracing, err := config.InitTracing(context.Background(), cfg.Tracing, log)
+	if err != nil {
+		log.Error("failed to init tracing", "error", err)
+		os.Exit(1)
+	}
+
+	geocoder, err := config.NewGeocoder(cfg.Geo, log)
+	if err != nil {
+		log.Error("failed to init geocoder", "error", err)
 		os.Exit(1)
 	}
 
-	log.Info("migrations completed")
+	db := config.Connect(cfg.Database, log)
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		log.Error("failed to register gorm metrics", "error", err)
+		os.Exit(1)
+	}
+	if err := db.Use(tracing.NewPlugin(tracing.WithoutMetrics(), tracing.WithoutQueryVariables())); err != nil {
+		log.Error("failed to register gorm tracing", "error", err)
+		os.Exit(1)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		prometheus.MustRegister(collectors.NewDBStatsCollector(sqlDB, cfg.Database.Driver))
+	}
+	backend := newCacheBackend(cfg, log)
+
+	// фоновые задачи останавливаются отменой workersCtx при завершении
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	// миграции идут в фоне: пока база не готова, /readyz и маршруты API отвечают 503
+	migrations := health.NewFlag("migrations pending")
+	// сбой миграций при доступной базе сам не пройдёт: процесс завершается с ошибкой,
+	// чтобы оркестратор его перезапустил, а не держал под, отвечающий 503
+	migrationsFailed := make(chan error, 1)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		err := config.Migrate(workersCtx, db, log,
+			&models.User{},
+			&models.Book{},
+			&models.Genre{},
+			&models.Exchange{},
+			&models.Review{},
+			&models.ReviewRevision{},
+			&models.ReviewReply{},
+			&models.ContentReport{},
+			&models.UserToken{},
+			&models.BookRating{},
+			&models.UserRating{},
+			&models.DataMigration{},
+		)
+		// агрегаты рейтингов появились позже отзывов: их нужно один раз
+		// заполнить по уже существующим отзывам
+		if err == nil {
+			err = repository.BackfillRatings(workersCtx, db)
+		}
+		// пользователи, зарегистрированные до подтверждения email
+		if err == nil {
+			err = repository.MigrateLegacyUsers(workersCtx, db, log)
+		}
+		// у жанров, созданных до появления slug, он пустой
+		if err == nil {
+			err = repository.NewGenreRepository(db, log).BackfillSlugs(workersCtx)
+		}
+		if err == nil {
+			err = repository.EnsureBookSearchIndexes(workersCtx, db)
+		}
+		// координаты пользователей, зарегистрированных до геокодера или геокодированных по адресу
+		if err == nil {
+			err = repository.ResetAddressLocations(workersCtx, db)
+		}
+		if err == nil {
+			err = services.BackfillUserLocations(workersCtx, repository.NewUserRepository(db, log), geocoder, log)
+		}
+		if err != nil {
+			migrations.Fail(err)
+			if workersCtx.Err() == nil {
+				migrationsFailed <- err
+			}
+			return
+		}
+		migrations.Done()
+
+		// данные удалённых аккаунтов стираются после срока ожидания; колонки нужны из миграций
+		services.RunAccountPurge(workersCtx, repository.NewUserRepository(db, log),
+			cfg.Account.DeletionGrace, cfg.Account.PurgeInterval, log)
+	}()
+
+	checker := health.NewChecker(2 * time.Second)
+	checker.Add(cfg.Database.Driver, true, health.DatabaseCheck(db))
+	checker.Add("migrations", true, migrations.Check)
+
+	// Redis — только кэш: его недоступность переводит сервис в degraded, а не unavailable
+	var redisMonitor *health.Monitor
+	if backend.redis != nil {
+		redisMonitor = health.NewMonitor(health.RedisCheck(backend.redis), 5*time.Second)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			redisMonitor.Run(workersCtx)
+		}()
+		checker.Add("redis", false, health.RedisCheck(backend.redis))
+	}
 
 	reviewRepo := repository.NewReviewRepository(db, log)
 	exchangeRepo := repository.NewExchangeRepository(db, log)
 	bookRepo := repository.NewBookRepository(db, log)
 	userRepo := repository.NewUserRepository(db, log)
 	genreRepo := repository.NewGenreRepository(db, log)
+	tokenRepo := repository.NewTokenRepository(db, log)
+	moderationRepo := repository.NewModerationRepository(db, log)
 
-	exchangeService := services.NewExchangeService(exchangeRepo, bookRepo, log)
-	reviewService := services.NewReviewService(reviewRepo)
-	bookService := services.NewServiceBook(bookRepo, log)
-	userService := services.NewServiceUser(db, userRepo, bookRepo, log)
-	genreService := services.NewGenreService(genreRepo)
+	eventBroker := backend.broker
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		eventBroker.Run(workersCtx)
+	}()
 
-	httpServer := gin.Default()
+	// письма отправляются фоновой очередью, чтобы handler не ждал SMTP
+	mailQueue := mail.NewQueue(config.NewMailer(cfg.Mail, log), log, mail.QueueConfig{})
+	mailQueue.Start()
+
+	exchangeService := services.NewExchangeService(exchangeRepo, bookRepo, eventBroker, mailQueue, log)
+	moderationService := services.NewModerationService(moderationRepo, reviewRepo, bookRepo, userRepo,
+		config.NewModerationChecker(cfg.Moderation, log), cfg.Moderation.ReportThreshold, log)
+	reviewService := services.NewReviewService(reviewRepo, exchangeRepo, userRepo, bookRepo, moderationService)
+	bookService := services.NewServiceBook(bookRepo, cfg.AI, moderationService, geocoder, log)
+	userService := services.NewServiceUser(db, userRepo, bookRepo, tokenRepo, mailQueue, eventBroker, backend.loginGuard, geocoder, cfg.AppBaseURL, cfg.Account.DeletionGrace, log)
+	genreService := services.NewGenreService(genreRepo, userRepo)
+
+	// токены удалённых аккаунтов отклоняются, хотя ещё не истекли
+	middleware.SetAccountCheck(userRepo.Exists)
+
+	// вместо gin.Default(): access-лог и recovery пишутся через slog в RegisterRoutes
+	httpServer := gin.New()
 
 	transport.RegisterRoutes(
 		httpServer,
@@ -54,15 +191,120 @@ func main() {
 		genreService,
 		reviewService,
 		userService,
-		redes,
+		moderationService,
+		backend.cache,
+		eventBroker,
+		backend.limiter,
+		backend.idempotency,
+		checker,
+		migrations.Check,
+		redisMonitor,
 	)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+	srv := config.NewHTTPServer(cfg.Server, httpServer)
+	// SSE-потоки не завершаются сами — закрываем их вместе с брокером
+	srv.RegisterOnShutdown(stopWorkers)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Info("http server started", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
 
-	if err := httpServer.Run(":" + port); err != nil {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	exitCode := 0
+	select {
+	case sig := <-signals:
+		log.Info("shutdown signal received", "signal", sig.String())
+	case err := <-serverErr:
 		log.Error("не удалось запустить сервер", slog.Any("error", err))
+		exitCode = 1
+	case err := <-migrationsFailed:
+		log.Error("migrations failed, shutting down", "error", err)
+		exitCode = 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+
+	// 1. перестаём принимать соединения и дожидаемся текущих запросов
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Error("http server shutdown failed", "error", err)
+		exitCode = 1
+	}
+
+	// 2. останавливаем фоновые задачи
+	stopWorkers()
+	workers.Wait()
+	if err := mailQueue.Stop(ctx); err != nil {
+		log.Error("mail queue shutdown failed", "error", err)
+	}
+
+	// 3. закрываем пул базы и клиент Redis
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			log.Error("failed to close database pool", "error", err)
+		}
+	}
+	if backend.redis != nil {
+		if err := backend.redis.Close(); err != nil {
+			log.Error("failed to close redis client", "error", err)
+		}
+	}
+
+	// 4. отправляем оставшиеся спаны
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error("tracing shutdown failed", "error", err)
+	}
+
+	cancel()
+	log.Info("server stopped")
+	os.Exit(exitCode)
+}
+
+// cacheBackend — реализации кэша, событий, лимитов и ключей идемпотентности для выбранного CACHE_DRIVER
+type cacheBackend struct {
+	// redis — nil в режиме memory
+	redis  *redis.Client
+	cache  cache.Cache
+	broker interface {
+		events.Broker
+		Run(ctx context.Context)
+	}
+	limiter     ratelimit.Limiter
+	loginGuard  ratelimit.LoginGuard
+	idempotency idempotency.Store
+}
+
+func newCacheBackend(cfg *config.Config, log *slog.Logger) cacheBackend {
+	if cfg.CacheBackend() == config.CacheMemory {
+		// один процесс: кэш, события SSE и счётчики лимитов живут в памяти
+		log.Info("in-process cache enabled, redis is not used")
+		return cacheBackend{
+			cache:       cache.NewMemoryCache(),
+			broker:      events.NewMemoryBroker(),
+			limiter:     ratelimit.NewMemoryLimiter(),
+			loginGuard:  ratelimit.NewMemoryLoginGuard(ratelimit.DefaultLockoutPolicy),
+			idempotency: idempotency.NewMemoryStore(),
+		}
+	}
+
+	rdb := config.ConnectRedis(cfg.Redis, log)
+	if err := redisotel.InstrumentTracing(rdb); err != nil {
+		log.Error("failed to instrument redis tracing", "error", err)
+	}
+
+	return cacheBackend{
+		redis: rdb,
+		cache: cache.NewRedisCache(rdb),
+		// события для SSE раздаются между репликами через Redis pub/sub
+		broker:      events.NewRedisBroker(rdb, log),
+		limiter:     ratelimit.NewRedisLimiter(rdb, log),
+		loginGuard:  ratelimit.NewRedisLoginGuard(rdb, ratelimit.DefaultLockoutPolicy, log),
+		idempotency: idempotency.NewRedisStore(rdb, log),
 	}
 }
@@ -0,0 +1,16 @@
+// Package cache — кэш ответов: Redis в продакшене, память процесса в режиме одного бинарника.
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMiss — ключа нет в кэше или срок его жизни истёк
+var ErrMiss = errors.New("cache: miss")
+
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
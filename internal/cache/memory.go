@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultMaxEntries ограничивает память: при переполнении сначала удаляются
+// просроченные ключи, затем — произвольные
+const defaultMaxEntries = 10000
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryCache хранит значения в памяти процесса; подходит только для одной реплики
+type MemoryCache struct {
+	mu         sync.Mutex
+	entries    map[string]memoryEntry
+	maxEntries int
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		entries:    make(map[string]memoryEntry),
+		maxEntries: defaultMaxEntries,
+	}
+}
+
+func (c *MemoryCache) Get(_ context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, ErrMiss
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, ErrMiss
+	}
+	return entry.value, nil
+}
+
+func (c *MemoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+
+	// копируем, чтобы вызывающий код не изменил значение в кэше
+	stored := make([]byte, len(value))
+	copy(stored, value)
+	c.entries[key] = memoryEntry{value: stored, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (c *MemoryCache) evict() {
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	for key := range c.entries {
+		if len(c.entries) < c.maxEntries {
+			return
+		}
+		delete(c.entries, key)
+	}
+}
@@ -0,0 +1,474 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config — вся конфигурация приложения.
+//
+// Значения берутся по возрастанию приоритета:
+// значения по умолчанию → файл (dotenv) → переменные окружения → флаги командной строки.
+// Файл задаётся флагом -config или CONFIG_FILE; без них читается ./.env, если он есть.
+// Каждой переменной соответствует флаг: DB_HOST → -db-host.
+type Config struct {
+	LogLevel string `env:"LOG_LEVEL"`
+	// AppBaseURL — адрес фронтенда для ссылок в письмах
+	AppBaseURL string `env:"APP_BASE_URL"`
+	// CacheDriver — redis или memory (кэш, события, лимиты в памяти процесса);
+	// по умолчанию memory для DB_DRIVER=sqlite и redis для postgres
+	CacheDriver string `env:"CACHE_DRIVER"`
+
+	Server     ServerConfig
+	Database   DatabaseConfig
+	Redis      RedisConfig
+	JWT        JWTConfig
+	Mail       MailConfig
+	AI         AIConfig
+	Moderation ModerationConfig
+	Geo        GeoConfig
+	Account    AccountConfig
+	Tracing    TracingConfig
+}
+
+// ServerConfig — параметры HTTP-сервера
+type ServerConfig struct {
+	Port              int           `env:"PORT"`
+	ReadTimeout       time.Duration `env:"HTTP_READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `env:"HTTP_READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `env:"HTTP_IDLE_TIMEOUT"`
+	MaxHeaderBytes    int           `env:"HTTP_MAX_HEADER_BYTES"`
+	// сколько ждём завершения запросов и фоновых задач при остановке
+	ShutdownTimeout time.Duration `env:"HTTP_SHUTDOWN_TIMEOUT"`
+}
+
+func (c ServerConfig) Addr() string {
+	return ":" + strconv.Itoa(c.Port)
+}
+
+// Драйверы базы данных
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Драйверы кэша
+const (
+	CacheRedis  = "redis"
+	CacheMemory = "memory"
+)
+
+type DatabaseConfig struct {
+	// Driver — postgres или sqlite (один файл, для локальной разработки и демо)
+	Driver string `env:"DB_DRIVER"`
+	// Path — файл базы для sqlite
+	Path            string        `env:"DB_PATH"`
+	Host            string        `env:"DB_HOST"`
+	Port            int           `env:"DB_PORT"`
+	User            string        `env:"DB_USER"`
+	Password        string        `env:"DB_PASS"`
+	Name            string        `env:"DB_NAME"`
+	SSLMode         string        `env:"DB_SSLMODE"`
+	ConnectTimeout  time.Duration `env:"DB_CONNECT_TIMEOUT"`
+	MaxOpenConns    int           `env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `env:"DB_CONN_MAX_LIFETIME"`
+}
+
+// DSN — строка подключения к Postgres
+func (c DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%v user=%v password=%v dbname=%v port=%v sslmode=%v connect_timeout=%d",
+		c.Host, c.User, c.Password, c.Name, c.Port, c.SSLMode, int(c.ConnectTimeout.Seconds()))
+}
+
+type RedisConfig struct {
+	Host         string        `env:"REDIS_HOST"`
+	Port         int           `env:"REDIS_PORT"`
+	Password     string        `env:"REDIS_PASSWORD"`
+	DB           int           `env:"REDIS_DB"`
+	PoolSize     int           `env:"REDIS_POOL_SIZE"`
+	MinIdleConns int           `env:"REDIS_MIN_IDLE_CONNS"`
+	DialTimeout  time.Duration `env:"REDIS_DIAL_TIMEOUT"`
+	ReadTimeout  time.Duration `env:"REDIS_READ_TIMEOUT"`
+	WriteTimeout time.Duration `env:"REDIS_WRITE_TIMEOUT"`
+}
+
+func (c RedisConfig) Addr() string {
+	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+}
+
+type JWTConfig struct {
+	Secret string        `env:"SUPER_SECRET_KEY"`
+	TTL    time.Duration `env:"JWT_TTL"`
+}
+
+// MailConfig — SMTP; без Host письма только пишутся в лог
+type MailConfig struct {
+	Host     string `env:"SMTP_HOST"`
+	Port     int    `env:"SMTP_PORT"`
+	Username string `env:"SMTP_USER"`
+	Password string `env:"SMTP_PASS"`
+	From     string `env:"MAIL_FROM"`
+}
+
+// AIConfig — внешний сервис для кратких описаний книг; без ключа используется локальный фолбэк
+type AIConfig struct {
+	APIKey  string        `env:"GROK_API_KEY"`
+	URL     string        `env:"GROK_API_URL"`
+	Timeout time.Duration `env:"GROK_TIMEOUT"`
+}
+
+// ModerationConfig — проверка отзывов и описаний книг. Локальный словарь работает всегда,
+// AI-классификатор — только при заданном ключе
+type ModerationConfig struct {
+	// ExtraWords — дополнительные запрещённые слова через запятую; "слово*" — по префиксу
+	ExtraWords string        `env:"MODERATION_EXTRA_WORDS"`
+	AIKey      string        `env:"MODERATION_AI_KEY"`
+	AIURL      string        `env:"MODERATION_AI_URL"`
+	AITimeout  time.Duration `env:"MODERATION_AI_TIMEOUT"`
+	// ReportThreshold — после стольких жалоб контент скрывается до решения модератора
+	ReportThreshold int `env:"MODERATION_REPORT_THRESHOLD"`
+}
+
+// GeoConfig — геокодирование городов и адресов для поиска книг рядом
+type GeoConfig struct {
+	// Provider — gazetteer (встроенный офлайн-справочник) или none
+	Provider string `env:"GEO_PROVIDER"`
+	// GazetteerFile — CSV с дополнительными местами "название;широта;долгота;синонимы"
+	GazetteerFile string `env:"GEO_GAZETTEER_FILE"`
+}
+
+// AccountConfig — удаление аккаунтов: персональные данные стираются
+// через DeletionGrace после запроса на удаление
+type AccountConfig struct {
+	DeletionGrace time.Duration `env:"ACCOUNT_DELETION_GRACE"`
+	// PurgeInterval — как часто фоновая задача ищет аккаунты с истёкшим сроком
+	PurgeInterval time.Duration `env:"ACCOUNT_PURGE_INTERVAL"`
+}
+
+type TracingConfig struct {
+	// otlp, stdout или none; пусто — otlp при заданном OTLPEndpoint, иначе none
+	Exporter     string `env:"OTEL_TRACES_EXPORTER"`
+	OTLPEndpoint string `env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName  string `env:"OTEL_SERVICE_NAME"`
+}
+
+const minSecretLength = 32
+
+// CacheBackend — выбранный кэш с учётом значения по умолчанию для драйвера базы
+func (c *Config) CacheBackend() string {
+	if c.CacheDriver != "" {
+		return c.CacheDriver
+	}
+	if c.Database.Driver == DriverSQLite {
+		return CacheMemory
+	}
+	return CacheRedis
+}
+
+// Default возвращает конфигурацию со значениями по умолчанию
+func Default() Config {
+	return Config{
+		LogLevel:   "info",
+		AppBaseURL: "http://localhost:8080",
+		Server: ServerConfig{
+			Port:              8080,
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Driver:          DriverPostgres,
+			Path:            "bookcrossing.db",
+			Host:            "localhost",
+			Port:            5432,
+			SSLMode:         "disable",
+			ConnectTimeout:  5 * time.Second,
+			MaxOpenConns:    50,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		Redis: RedisConfig{
+			Host:         "localhost",
+			Port:         6379,
+			PoolSize:     50,
+			MinIdleConns: 10,
+			DialTimeout:  5 * time.Second,
+			ReadTimeout:  3 * time.Second,
+			WriteTimeout: 3 * time.Second,
+		},
+		JWT: JWTConfig{
+			TTL: 24 * time.Hour,
+		},
+		Mail: MailConfig{
+			Port: 587,
+			From: "no-reply@bookcrossing.local",
+		},
+		AI: AIConfig{
+			URL:     "https://api.grok.ai/v1/completions",
+			Timeout: 5 * time.Second,
+		},
+		Moderation: ModerationConfig{
+			AIURL:           "https://api.openai.com/v1/moderations",
+			AITimeout:       3 * time.Second,
+			ReportThreshold: 3,
+		},
+		Geo: GeoConfig{
+			Provider: "gazetteer",
+		},
+		Account: AccountConfig{
+			DeletionGrace: 30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Tracing: TracingConfig{
+			ServiceName: "bookcrossing",
+		},
+	}
+}
+
+// Load собирает конфигурацию из файла, окружения и флагов и проверяет её.
+// args — аргументы командной строки без имени программы.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+	fields := envFields(&cfg)
+
+	fs := flag.NewFlagSet("bookcrossing", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "путь к dotenv-файлу с конфигурацией")
+	flagValues := make(map[string]*string, len(fields))
+	for _, f := range fields {
+		flagValues[f.key] = fs.String(flagName(f.key), "", "переопределяет $"+f.key)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	fileValues, err := readConfigFile(*configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	explicitFlags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicitFlags[f.Name] = true })
+
+	var errs []error
+	for _, f := range fields {
+		raw, ok := fileValues[f.key]
+		if v, set := os.LookupEnv(f.key); set {
+			raw, ok = v, true
+		}
+		if explicitFlags[flagName(f.key)] {
+			raw, ok = *flagValues[f.key], true
+		}
+		// пустое значение означает «по умолчанию», как в .env.example
+		if !ok || raw == "" {
+			continue
+		}
+		if err := setField(f.value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.key, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	cfg.Database.Driver = strings.ToLower(cfg.Database.Driver)
+	cfg.CacheDriver = strings.ToLower(cfg.CacheDriver)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate проверяет конфигурацию целиком и возвращает все найденные ошибки
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "LOG_LEVEL: unknown level %q", c.LogLevel)
+	}
+	base, err := url.Parse(c.AppBaseURL)
+	check(err == nil && base.Scheme != "" && base.Host != "", "APP_BASE_URL: must be an absolute URL")
+
+	s := c.Server
+	check(s.Port > 0 && s.Port <= 65535, "PORT: must be between 1 and 65535")
+	check(s.ReadTimeout > 0, "HTTP_READ_TIMEOUT: must be positive")
+	check(s.ReadHeaderTimeout > 0 && s.ReadHeaderTimeout <= s.ReadTimeout, "HTTP_READ_HEADER_TIMEOUT: must be positive and not exceed HTTP_READ_TIMEOUT")
+	check(s.WriteTimeout > 0, "HTTP_WRITE_TIMEOUT: must be positive")
+	check(s.IdleTimeout > 0, "HTTP_IDLE_TIMEOUT: must be positive")
+	check(s.MaxHeaderBytes > 0, "HTTP_MAX_HEADER_BYTES: must be positive")
+	check(s.ShutdownTimeout > 0, "HTTP_SHUTDOWN_TIMEOUT: must be positive")
+
+	d := c.Database
+	switch d.Driver {
+	case DriverPostgres:
+		check(d.Host != "", "DB_HOST: required")
+		check(d.User != "", "DB_USER: required")
+		check(d.Name != "", "DB_NAME: required")
+		check(d.Port > 0 && d.Port <= 65535, "DB_PORT: must be between 1 and 65535")
+		check(d.ConnectTimeout >= time.Second, "DB_CONNECT_TIMEOUT: must be at least 1s")
+	case DriverSQLite:
+		check(d.Path != "", "DB_PATH: required for sqlite")
+	default:
+		check(false, "DB_DRIVER: unknown driver %q", d.Driver)
+	}
+	check(d.MaxOpenConns > 0, "DB_MAX_OPEN_CONNS: must be positive")
+	check(d.MaxIdleConns >= 0 && d.MaxIdleConns <= d.MaxOpenConns, "DB_MAX_IDLE_CONNS: must be between 0 and DB_MAX_OPEN_CONNS")
+	check(d.ConnMaxLifetime > 0, "DB_CONN_MAX_LIFETIME: must be positive")
+
+	switch c.CacheBackend() {
+	case CacheRedis:
+		r := c.Redis
+		check(r.Host != "", "REDIS_HOST: required")
+		check(r.Port > 0 && r.Port <= 65535, "REDIS_PORT: must be between 1 and 65535")
+		// пробелы по краям почти всегда — ошибка копирования в .env
+		check(r.Password == strings.TrimSpace(r.Password), "REDIS_PASSWORD: must not have leading or trailing spaces")
+		check(r.DB >= 0 && r.DB <= 15, "REDIS_DB: must be between 0 and 15")
+		check(r.PoolSize > 0, "REDIS_POOL_SIZE: must be positive")
+		check(r.MinIdleConns >= 0 && r.MinIdleConns <= r.PoolSize, "REDIS_MIN_IDLE_CONNS: must be between 0 and REDIS_POOL_SIZE")
+		check(r.DialTimeout > 0 && r.ReadTimeout > 0 && r.WriteTimeout > 0, "REDIS_*_TIMEOUT: must be positive")
+	case CacheMemory:
+	default:
+		check(false, "CACHE_DRIVER: unknown driver %q", c.CacheDriver)
+	}
+
+	// пустой ключ раньше молча подписывал токены пустой строкой
+	check(len(c.JWT.Secret) >= minSecretLength, "SUPER_SECRET_KEY: must be at least %d bytes", minSecretLength)
+	check(c.JWT.TTL > 0, "JWT_TTL: must be positive")
+
+	if c.Mail.Host != "" {
+		check(c.Mail.Port > 0 && c.Mail.Port <= 65535, "SMTP_PORT: must be between 1 and 65535")
+		check(c.Mail.From != "", "MAIL_FROM: required when SMTP_HOST is set")
+	}
+
+	check(c.AI.Timeout > 0, "GROK_TIMEOUT: must be positive")
+	if c.AI.APIKey != "" {
+		_, err := url.ParseRequestURI(c.AI.URL)
+		check(err == nil, "GROK_API_URL: must be a valid URL")
+	}
+
+	check(c.Moderation.AITimeout > 0, "MODERATION_AI_TIMEOUT: must be positive")
+	check(c.Moderation.ReportThreshold > 0, "MODERATION_REPORT_THRESHOLD: must be positive")
+	if c.Moderation.AIKey != "" {
+		_, err := url.ParseRequestURI(c.Moderation.AIURL)
+		check(err == nil, "MODERATION_AI_URL: must be a valid URL")
+	}
+
+	switch strings.ToLower(c.Geo.Provider) {
+	case "gazetteer":
+	case "none":
+		check(c.Geo.GazetteerFile == "", "GEO_GAZETTEER_FILE: requires GEO_PROVIDER=gazetteer")
+	default:
+		check(false, "GEO_PROVIDER: unknown provider %q", c.Geo.Provider)
+	}
+
+	// 0 — стирать при ближайшем запуске задачи
+	check(c.Account.DeletionGrace >= 0, "ACCOUNT_DELETION_GRACE: must not be negative")
+	check(c.Account.PurgeInterval > 0, "ACCOUNT_PURGE_INTERVAL: must be positive")
+
+	switch strings.ToLower(c.Tracing.Exporter) {
+	case "", "otlp", "stdout", "none":
+	default:
+		check(false, "OTEL_TRACES_EXPORTER: unknown exporter %q", c.Tracing.Exporter)
+	}
+
+	return errors.Join(errs...)
+}
+
+// readConfigFile читает dotenv-файл. Явно указанный файл обязан существовать,
+// а ./.env — необязателен: в контейнерах конфигурация приходит через окружение.
+func readConfigFile(path string) (map[string]string, error) {
+	if path == "" {
+		values, err := godotenv.Read(".env")
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return values, err
+	}
+
+	values, err := godotenv.Read(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+type envField struct {
+	key   string
+	value reflect.Value
+}
+
+// envFields обходит структуру (включая вложенные) и собирает поля с тегом env
+func envFields(cfg *Config) []envField {
+	var fields []envField
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if key := t.Field(i).Tag.Get("env"); key != "" {
+				fields = append(fields, envField{key: key, value: v.Field(i)})
+				continue
+			}
+			if v.Field(i).Kind() == reflect.Struct {
+				walk(v.Field(i))
+			}
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem())
+	return fields
+}
+
+func setField(v reflect.Value, raw string) error {
+	if v.Kind() == reflect.String {
+		// строки не обрезаем: пароль с пробелом на конце отловит Validate
+		v.SetString(raw)
+		return nil
+	}
+	raw = strings.TrimSpace(raw)
+
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(int64(n))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// flagName: DB_MAX_OPEN_CONNS → db-max-open-conns
+func flagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
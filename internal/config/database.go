@@ -1,55 +1,38 @@
 package config
 
 import (
-	"fmt"
+	"context"
+	"database/sql/driver"
+	"errors"
 	"log/slog"
+	"net"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
+	sqlitedriver "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func Connect(logger *slog.Logger) *gorm.DB {
-	dbHost := os.Getenv("DB_HOST")
-	dbUser := os.Getenv("DB_USER")
-	dbPass := os.Getenv("DB_PASS")
-	dbName := os.Getenv("DB_NAME")
-	dbPort := os.Getenv("DB_PORT")
-	dbMode := os.Getenv("DB_SSLMODE")
-
-	if dbMode == "" {
-		dbMode = "disable"
+// Connect настраивает пул соединений с базой (Postgres или SQLite).
+// Соединение устанавливается лениво: если база ещё не поднялась,
+// сервер всё равно стартует, а /readyz покажет, что база недоступна.
+func Connect(cfg DatabaseConfig, logger *slog.Logger) *gorm.DB {
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case DriverSQLite:
+		dialector = sqliteDialector(cfg.Path)
+	default:
+		dialector = postgres.Open(cfg.DSN())
 	}
 
-	// Add short connect_timeout to fail-fast per attempt
-	dsn := fmt.Sprintf("host=%v user=%v password=%v dbname=%v port=%v sslmode=%v connect_timeout=%d",
-		dbHost, dbUser, dbPass, dbName, dbPort, dbMode, 5)
-
-	// Retry connection with backoff to handle DNS/startup races
-	var (
-		db  *gorm.DB
-		err error
-	)
-	maxAttempts := 12 // ~1m total
-	backoff := 2 * time.Second
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
-		if err == nil {
-			break
-		}
-		logger.Warn("database connect attempt failed", "attempt", attempt, "error", err)
-		time.Sleep(backoff)
-		// Exponential backoff but cap at 10s
-		if backoff < 10*time.Second {
-			backoff *= 2
-			if backoff > 10*time.Second {
-				backoff = 10 * time.Second
-			}
-		}
-	}
+	db, err := gorm.Open(dialector, &gorm.Config{DisableAutomaticPing: true})
 	if err != nil {
-		logger.Error("failed to connect to database after retries", "error", err)
+		// сюда попадаем только при некорректной конфигурации, а не при недоступной базе
+		logger.Error("failed to configure database", "driver", cfg.Driver, "error", err)
 		os.Exit(1)
 	}
 
@@ -61,11 +44,88 @@ func Connect(logger *slog.Logger) *gorm.DB {
 	}
 
 	// Настройка пула соединений
-	sqlDB.SetMaxOpenConns(50)                 // максимум открытых соединений
-	sqlDB.SetMaxIdleConns(25)                 // сколько соединений может простаивать
-	sqlDB.SetConnMaxLifetime(time.Minute * 5) // максимальное время жизни соединения
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)       // максимум открытых соединений
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)       // сколько соединений может простаивать
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime) // максимальное время жизни соединения
 
-	logger.Info("connected to database with connection pool configured")
+	if cfg.Driver == DriverSQLite {
+		logger.Info("sqlite database configured", "path", cfg.Path)
+	} else {
+		logger.Info("database connection pool configured", "host", cfg.Host, "max_open_conns", cfg.MaxOpenConns)
+	}
 
 	return db
 }
+
+var registerSQLiteFuncs sync.Once
+
+// sqliteDialector открывает файл SQLite в режиме WAL: читатели не блокируют писателя,
+// а при конкурентной записи соединение ждёт busy_timeout вместо ошибки SQLITE_BUSY.
+func sqliteDialector(path string) gorm.Dialector {
+	registerSQLiteFuncs.Do(func() {
+		// встроенный lower() в SQLite понимает только ASCII;
+		// поиск через LOWER(...) LIKE должен работать и для кириллицы, как в Postgres
+		sqlitedriver.MustRegisterDeterministicScalarFunction("lower", 1,
+			func(_ *sqlitedriver.FunctionContext, args []driver.Value) (driver.Value, error) {
+				switch v := args[0].(type) {
+				case string:
+					return strings.ToLower(v), nil
+				case []byte:
+					return strings.ToLower(string(v)), nil
+				default:
+					return v, nil
+				}
+			})
+	})
+
+	dsn := path + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"
+	return sqlite.Open(dsn)
+}
+
+// Migrate выполняет AutoMigrate, повторяя попытки с backoff, пока база недоступна.
+// Ошибка при доступной базе (например, в схеме) сама не пройдёт — она возвращается сразу,
+// как и отмена ctx.
+func Migrate(ctx context.Context, db *gorm.DB, logger *slog.Logger, models ...interface{}) error {
+	backoff := 2 * time.Second
+	for attempt := 1; ; attempt++ {
+		err := db.WithContext(ctx).AutoMigrate(models...)
+		if err == nil {
+			logger.Info("migrations completed")
+			return nil
+		}
+		if !connectionError(ctx, db, err) {
+			logger.Error("migration failed", "attempt", attempt, "error", err)
+			return err
+		}
+		logger.Warn("migration attempt failed, database is unavailable", "attempt", attempt, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		// Exponential backoff but cap at 30s
+		if backoff < 30*time.Second {
+			backoff *= 2
+			if backoff > 30*time.Second {
+				backoff = 30 * time.Second
+			}
+		}
+	}
+}
+
+// connectionError — ошибка из-за недоступной базы, а не из-за самой миграции.
+// Драйверы Postgres и SQLite оборачивают сетевые ошибки по-разному,
+// поэтому кроме типа ошибки проверяется, отвечает ли база на ping.
+func connectionError(ctx context.Context, db *gorm.DB, err error) bool {
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.As(err, &netErr) {
+		return true
+	}
+	sqlDB, dbErr := db.DB()
+	if dbErr != nil {
+		return true
+	}
+	return sqlDB.PingContext(ctx) != nil
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/dasler-fw/bookcrossing/internal/geo"
+)
+
+// NewGeocoder возвращает геокодер из GeoConfig; nil при GEO_PROVIDER=none —
+// тогда координаты не заполняются и поиск рядом ничего не находит
+func NewGeocoder(cfg GeoConfig, logger *slog.Logger) (geo.Geocoder, error) {
+	if strings.ToLower(cfg.Provider) == "none" {
+		return nil, nil
+	}
+
+	gazetteer := geo.NewGazetteer()
+	if cfg.GazetteerFile != "" {
+		f, err := os.Open(cfg.GazetteerFile)
+		if err != nil {
+			return nil, fmt.Errorf("GEO_GAZETTEER_FILE: %w", err)
+		}
+		defer f.Close()
+		if err := gazetteer.Load(f); err != nil {
+			return nil, fmt.Errorf("GEO_GAZETTEER_FILE: %w", err)
+		}
+	}
+	logger.Info("geocoder configured", "provider", "gazetteer", "places", gazetteer.Len())
+	return gazetteer, nil
+}
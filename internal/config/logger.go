@@ -1,15 +1,18 @@
 package config
 
 import (
+	"context"
 	"log/slog"
 	"os"
 	"strings"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
-func InitLogger() *slog.Logger {
+func InitLogger(logLevel string) *slog.Logger {
 	level := slog.LevelInfo
 
-	switch strings.ToLower(os.Getenv("LOG_LEVEL")) {
+	switch strings.ToLower(logLevel) {
 	case "debug":
 		level = slog.LevelDebug
 	case "warn":
@@ -24,5 +27,29 @@ func InitLogger() *slog.Logger {
 		Level: level,
 	})
 
-	return slog.New(handler)
+	return slog.New(traceHandler{handler})
+}
+
+// traceHandler добавляет trace_id и span_id активного спана,
+// если запись сделана через *Context-методы логгера.
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{h.Handler.WithGroup(name)}
 }
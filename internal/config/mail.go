@@ -0,0 +1,26 @@
+package config
+
+import (
+	"log/slog"
+
+	"github.com/dasler-fw/bookcrossing/internal/mail"
+)
+
+// NewMailer выбирает реализацию отправки писем.
+// Без SMTP_HOST письма только пишутся в лог.
+func NewMailer(cfg MailConfig, logger *slog.Logger) mail.Mailer {
+	if cfg.Host == "" {
+		logger.Warn("SMTP_HOST is not set, emails will be written to log")
+		return mail.NewLogMailer(logger)
+	}
+
+	logger.Info("smtp mailer configured", "host", cfg.Host, "port", cfg.Port)
+
+	return mail.NewSMTPMailer(mail.SMTPConfig{
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		From:     cfg.From,
+	})
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/dasler-fw/bookcrossing/internal/moderation"
+)
+
+// NewModerationChecker собирает цепочку проверок: словарь всегда, AI — при заданном ключе
+func NewModerationChecker(cfg ModerationConfig, logger *slog.Logger) moderation.Checker {
+	words := append([]string(nil), moderation.DefaultWords...)
+	if cfg.ExtraWords != "" {
+		words = append(words, strings.Split(cfg.ExtraWords, ",")...)
+	}
+
+	chain := moderation.NewChain(logger).Add("wordlist", moderation.NewWordlistChecker(words))
+	if cfg.AIKey != "" {
+		logger.Info("ai moderation configured", "url", cfg.AIURL)
+		chain.Add("ai", moderation.NewAIChecker(cfg.AIURL, cfg.AIKey, cfg.AITimeout))
+	}
+	return chain
+}
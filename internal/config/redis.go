@@ -2,45 +2,36 @@ package config
 
 import (
 	"context"
-	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-// ConnectRedis создаёт клиент Redis и проверяет соединение
-func ConnectRedis() *redis.Client {
-	// Берём хост и порт из переменных окружения
-	host := os.Getenv("REDIS_HOST")
-	if host == "" {
-		host = "localhost"
-	}
-
-	port := os.Getenv("REDIS_PORT")
-	if port == "" {
-		port = "6379"
-	}
-
-	addr := fmt.Sprintf("%s:%s", host, port)
+// ConnectRedis создаёт клиент Redis и проверяет соединение.
+// Redis используется как кэш, поэтому при недоступности сервер
+// продолжает работу в degraded-режиме, а клиент переподключится сам.
+func ConnectRedis(cfg RedisConfig, logger *slog.Logger) *redis.Client {
+	addr := cfg.Addr()
 
 	rdb := redis.NewClient(&redis.Options{
 		Addr:         addr,
-		Password:     "",       // если нет пароля
-		DB:           0,        // используем базу 0
-		DialTimeout:  5 * time.Second,
-		ReadTimeout:  3 * time.Second,
-		WriteTimeout: 3 * time.Second,
-		PoolSize:     50,
-		MinIdleConns: 10,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		DialTimeout:  cfg.DialTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		PoolSize:     cfg.PoolSize,
+		MinIdleConns: cfg.MinIdleConns,
 	})
 
-	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 	if err := rdb.Ping(ctx).Err(); err != nil {
-		log.Fatal("Redis connection failed:", err)
+		logger.Warn("redis is unavailable, running in degraded mode", "addr", addr, "error", err)
+		return rdb
 	}
 
-	log.Println("Redis connected at", addr)
+	logger.Info("redis connected", "addr", addr)
 	return rdb
 }
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const defaultServiceName = "bookcrossing"
+
+// InitTracing настраивает глобальный TracerProvider.
+//
+// Экспортер выбирается через OTEL_TRACES_EXPORTER:
+//   - otlp   — OTLP/HTTP на OTEL_EXPORTER_OTLP_ENDPOINT;
+//   - stdout — спаны печатаются в консоль (для локальной отладки);
+//   - none   — трейсинг выключен.
+//
+// По умолчанию используется otlp, если задан OTEL_EXPORTER_OTLP_ENDPOINT, иначе none.
+// Возвращаемую функцию нужно вызвать при завершении, чтобы отправить оставшиеся спаны.
+func InitTracing(ctx context.Context, cfg TracingConfig, logger *slog.Logger) (func(context.Context) error, error) {
+	exporterName := strings.ToLower(cfg.Exporter)
+	if exporterName == "" {
+		exporterName = "none"
+		if cfg.OTLPEndpoint != "" {
+			exporterName = "otlp"
+		}
+	}
+
+	// заголовки traceparent/baggage нужны даже без экспорта — чтобы не рвать чужие трейсы
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch exporterName {
+	case "none":
+		logger.Info("tracing disabled")
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q", exporterName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s trace exporter: %w", exporterName, err)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("create trace resource: %w", err)
+	}
+
+	// семплер настраивается стандартными OTEL_TRACES_SAMPLER / OTEL_TRACES_SAMPLER_ARG
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	logger.Info("tracing enabled", "exporter", exporterName, "service", serviceName)
+	return provider.Shutdown, nil
+}
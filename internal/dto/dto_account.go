@@ -0,0 +1,104 @@
+package dto
+
+import "time"
+
+// DeleteAccountRequest — удаление аккаунта подтверждается паролем
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type DeleteAccountResponse struct {
+	CancelledExchanges int `json:"cancelled_exchanges"`
+	// PurgeAfter — после этого момента персональные данные стираются безвозвратно
+	PurgeAfter time.Time `json:"purge_after"`
+}
+
+// UserDataExport — всё, что сервис хранит о пользователе
+type UserDataExport struct {
+	ExportedAt      time.Time        `json:"exported_at"`
+	Profile         ExportProfile    `json:"profile"`
+	Books           []ExportBook     `json:"books"`
+	Exchanges       []ExportExchange `json:"exchanges"`
+	ReviewsWritten  []ExportReview   `json:"reviews_written"`
+	ReviewsReceived []ExportReview   `json:"reviews_received"`
+	ReviewReplies   []ExportReply    `json:"review_replies"`
+	Reports         []ExportReport   `json:"reports"`
+	Tokens          []ExportToken    `json:"tokens"`
+}
+
+type ExportProfile struct {
+	ID              uint           `json:"id"`
+	Name            string         `json:"name"`
+	Email           string         `json:"email"`
+	City            string         `json:"city"`
+	Address         string         `json:"address"`
+	Lat             *float64       `json:"lat"`
+	Lon             *float64       `json:"lon"`
+	Language        string         `json:"language"`
+	Role            string         `json:"role"`
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`
+	Visibility      UserVisibility `json:"visibility"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+type ExportBook struct {
+	ID               uint      `json:"id"`
+	Title            string    `json:"title"`
+	Author           string    `json:"author"`
+	Description      string    `json:"description"`
+	Status           string    `json:"status"`
+	ModerationStatus string    `json:"moderation_status"`
+	Location         string    `json:"location"`
+	Lat              *float64  `json:"lat"`
+	Lon              *float64  `json:"lon"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type ExportExchange struct {
+	ID              uint       `json:"id"`
+	InitiatorID     uint       `json:"initiator_id"`
+	RecipientID     uint       `json:"recipient_id"`
+	InitiatorBookID uint       `json:"initiator_book_id"`
+	RecipientBookID uint       `json:"recipient_book_id"`
+	Status          string     `json:"status"`
+	CreatedAt       time.Time  `json:"created_at"`
+	CompletedAt     *time.Time `json:"completed_at"`
+}
+
+type ExportReview struct {
+	ID               uint       `json:"id"`
+	ExchangeID       *uint      `json:"exchange_id"`
+	AuthorID         uint       `json:"author_id"`
+	TargetUserID     uint       `json:"target_user_id"`
+	TargetBookID     uint       `json:"target_book_id"`
+	Text             string     `json:"text"`
+	Rating           int        `json:"rating"`
+	ModerationStatus string     `json:"moderation_status"`
+	CreatedAt        time.Time  `json:"created_at"`
+	EditedAt         *time.Time `json:"edited_at"`
+}
+
+type ExportReply struct {
+	ID        uint      `json:"id"`
+	ReviewID  uint      `json:"review_id"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ExportReport struct {
+	ID          uint      `json:"id"`
+	ContentType string    `json:"content_type"`
+	ContentID   uint      `json:"content_id"`
+	Reason      string    `json:"reason"`
+	Resolved    bool      `json:"resolved"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ExportToken — одноразовые токены из писем; сами токены не хранятся
+type ExportToken struct {
+	Purpose   string     `json:"purpose"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
@@ -6,8 +6,12 @@ type CreateBookRequest struct {
 	Description string `json:"description"`
 	AISummary   string `json:"ai_summary"`
 	GenreIDs    []uint `json:"genre_ids"` // для привязки жанров
+	// Location — город или адрес, где забрать книгу; по умолчанию — город владельца
+	Location string `json:"location"`
 }
 
 type UpdateBookRequest struct {
 	Description *string `json:"description"`
+	// Location — пустая строка возвращает книгу к городу владельца
+	Location *string `json:"location"`
 }
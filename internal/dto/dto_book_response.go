@@ -9,8 +9,10 @@ type UserPublicResponse struct {
 }
 
 type GenreResponse struct {
-	ID   uint   `json:"id"`
-	Name string `json:"name"`
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	NameEn string `json:"name_en"`
+	Slug   string `json:"slug"`
 }
 
 type BookResponse struct {
@@ -20,15 +22,28 @@ type BookResponse struct {
 	Description string            `json:"description"`
 	AISummary   string            `json:"ai_summary"`
 	Status      string            `json:"status"`
+	ModerationStatus string       `json:"moderation_status,omitempty"`
+	Location    string            `json:"location,omitempty"`
+	// DistanceKm — расстояние до точки near, только в поиске рядом
+	DistanceKm  *float64          `json:"distance_km,omitempty"`
 	CreatedAt   time.Time         `json:"created_at"`
+	Version     uint              `json:"version"`
 	Owner       UserPublicResponse `json:"owner"`
 	Genres      []GenreResponse   `json:"genres"`
+	Rating      RatingResponse    `json:"rating"`
 }
 
 type BookListResponse struct {
-	Data       []BookResponse `json:"data"`
-	Page       int            `json:"page"`
-	Limit      int            `json:"limit"`
-	Total      int            `json:"total"`
-	TotalPages int            `json:"total_pages"`
+	Data  []BookResponse `json:"data"`
+	Page  int            `json:"page,omitempty"`
+	Limit int            `json:"limit"`
+	// Total и TotalPages отсутствуют при count=none
+	Total      *int `json:"total,omitempty"`
+	TotalPages *int `json:"total_pages,omitempty"`
+	// TotalEstimated — total взят из оценки планировщика (count=estimate)
+	TotalEstimated bool `json:"total_estimated,omitempty"`
+	// NextCursor — передайте в cursor, чтобы получить следующую страницу; пусто на последней
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Facets есть, только если запрошены параметром facets
+	Facets map[string][]FacetValue `json:"facets,omitempty"`
 }
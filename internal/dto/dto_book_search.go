@@ -1,26 +1,156 @@
 package dto
 
+import (
+	"strings"
+
+	"github.com/dasler-fw/bookcrossing/internal/geo"
+)
+
 type BookListQuery struct {
-	// Фильтры
+	// Фильтры; genre_id включает поджанры
 	GenreID *uint  `form:"genre_id"`
 	City    string `form:"city"`
 	Author  string `form:"author"`
 	Status  string `form:"status"`
 	Title   string `form:"title"`
 
+	// Near — "lat,lon": только книги в радиусе RadiusKm (по умолчанию DefaultRadiusKm).
+	// Координаты книги — из её location, иначе из города владельца.
+	// city вместе с radius_km ищет вокруг этого города, а не по подстроке.
+	Near     string  `form:"near"`
+	RadiusKm float64 `form:"radius_km"`
+	// Origin — разобранный Near; заполняется в Normalize или геокодером из city
+	Origin *geo.Point `form:"-"`
+
 	// Пагинация
 	Page  int `form:"page"`
 	Limit int `form:"limit"`
 
 	// Сортировка
-	// sort_by: created_at | title
+	// sort_by: created_at | title | rating | reviews | distance (по умолчанию при поиске рядом)
 	// sort_order: asc | desc
+	// Page — для OFFSET-пагинации; глубокие страницы быстрее листать курсором
 	SortBy    string `form:"sort_by"`
 	SortOrder string `form:"sort_order"`
+
+	// Cursor — next_cursor из предыдущего ответа; с ним page не учитывается
+	Cursor string `form:"cursor"`
+	// Count — exact | estimate | none; по умолчанию exact без курсора и none с курсором
+	Count string `form:"count"`
+
+	// Facets — через запятую: genre,city,status,author
+	Facets string `form:"facets"`
+}
+
+// Режимы подсчёта total
+const (
+	CountExact    = "exact"
+	CountEstimate = "estimate"
+	CountNone     = "none"
+)
+
+// Normalize подставляет значения по умолчанию и разбирает near;
+// неизвестный count — ErrInvalidInput, неверные near и radius_km — ErrInvalidLocation
+func (q *BookListQuery) Normalize() error {
+	if q.Page <= 0 {
+		q.Page = DefaultPage
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+
+	q.Near = strings.TrimSpace(q.Near)
+	if q.Near != "" {
+		p, err := geo.ParsePoint(q.Near)
+		if err != nil {
+			return ErrInvalidLocation
+		}
+		q.Origin = &p
+	}
+	if q.RadiusKm < 0 || q.RadiusKm > MaxRadiusKm {
+		return ErrInvalidLocation
+	}
+	nearby := q.Origin != nil || q.RadiusKm > 0
+	if nearby && q.RadiusKm == 0 {
+		q.RadiusKm = DefaultRadiusKm
+	}
+	if q.RadiusKm > 0 && q.Origin == nil && strings.TrimSpace(q.City) == "" {
+		return ErrInvalidLocation
+	}
+
+	q.SortBy = strings.ToLower(strings.TrimSpace(q.SortBy))
+	q.SortOrder = strings.ToLower(strings.TrimSpace(q.SortOrder))
+	if q.SortBy == "" {
+		q.SortBy = "created_at"
+		if nearby {
+			q.SortBy = "distance"
+		}
+	}
+	if q.SortOrder == "" {
+		q.SortOrder = "desc"
+		if q.SortBy == "distance" {
+			q.SortOrder = "asc"
+		}
+	}
+
+	q.Cursor = strings.TrimSpace(q.Cursor)
+	q.Count = strings.ToLower(strings.TrimSpace(q.Count))
+	switch q.Count {
+	case "":
+		q.Count = CountExact
+		if q.Cursor != "" {
+			q.Count = CountNone
+		}
+	case CountExact, CountEstimate, CountNone:
+	default:
+		return ErrInvalidInput
+	}
+	return nil
+}
+
+// Фасеты, которые можно запросить в facets
+const (
+	FacetGenre  = "genre"
+	FacetCity   = "city"
+	FacetStatus = "status"
+	FacetAuthor = "author"
+)
+
+// FacetValue — значение фильтра и число книг с ним. Для жанра Value — id, Label — название.
+type FacetValue struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// FacetFields разбирает параметр facets; повторы отбрасываются, неизвестное имя — ErrInvalidInput
+func (q BookListQuery) FacetFields() ([]string, error) {
+	var fields []string
+	seen := map[string]bool{}
+	for _, f := range strings.Split(q.Facets, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" || seen[f] {
+			continue
+		}
+		switch f {
+		case FacetGenre, FacetCity, FacetStatus, FacetAuthor:
+		default:
+			return nil, ErrInvalidInput
+		}
+		seen[f] = true
+		fields = append(fields, f)
+	}
+	return fields, nil
 }
 
 const (
 	DefaultPage  = 1
 	DefaultLimit = 10
 	MaxLimit     = 100
+
+	DefaultRadiusKm = 25
+	MaxRadiusKm     = 500
 )
@@ -18,4 +18,8 @@ type ExchangeResponse struct {
 	CompletedAt     *time.Time `json:"completed_at,omitempty"`
 	CreatedAt       time.Time  `json:"created_at"`
 	UpdatedAt       time.Time  `json:"updated_at"`
+	Version         uint       `json:"version"`
+	// Initiator и Recipient — участники с учётом настроек видимости; есть не во всех ответах
+	Initiator *UserResponse `json:"initiator,omitempty"`
+	Recipient *UserResponse `json:"recipient,omitempty"`
 }
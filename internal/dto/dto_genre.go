@@ -1,5 +1,30 @@
 package dto
 
 type GenreCreateRequest struct {
-	Name string `json:"name"`
+	Name   string `json:"name"`
+	NameEn string `json:"name_en"`
+	// Slug можно не передавать — он строится из названия
+	Slug     string `json:"slug"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+// GenreMergeRequest — жанры-дубликаты, которые сливаются в жанр из пути
+type GenreMergeRequest struct {
+	SourceIDs []uint `json:"source_ids" binding:"required,min=1"`
+}
+
+type GenreMergeResponse struct {
+	TargetID  uint   `json:"target_id"`
+	MergedIDs []uint `json:"merged_ids"`
+	// BooksMoved — книги, получившие целевой жанр вместо дубликата
+	BooksMoved int64 `json:"books_moved"`
+}
+
+// GenreNode — жанр в дереве GET /genres/tree
+type GenreNode struct {
+	ID       uint        `json:"id"`
+	Name     string      `json:"name"`
+	NameEn   string      `json:"name_en"`
+	Slug     string      `json:"slug"`
+	Children []GenreNode `json:"children"`
 }
@@ -0,0 +1,21 @@
+package dto
+
+import "time"
+
+type ReportRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// ModerationQueueItem — отзыв или описание книги, ожидающие решения модератора
+type ModerationQueueItem struct {
+	ContentType string    `json:"content_type"`
+	ContentID   uint      `json:"content_id"`
+	Status      string    `json:"status"`
+	AuthorID    uint      `json:"author_id"`
+	Text        string    `json:"text"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// Reports — открытые жалобы пользователей
+	Reports int `json:"reports"`
+	// Reasons — причины автоматической проверки и жалоб
+	Reasons []string `json:"reasons"`
+}
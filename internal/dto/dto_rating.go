@@ -0,0 +1,26 @@
+package dto
+
+// RatingResponse — средняя оценка, число отзывов и распределение по звёздам
+type RatingResponse struct {
+	Average      float64            `json:"average"`
+	Count        int64              `json:"count"`
+	Distribution RatingDistribution `json:"distribution"`
+}
+
+// RatingDistribution — сколько отзывов с каждой оценкой
+type RatingDistribution struct {
+	One   int64 `json:"1"`
+	Two   int64 `json:"2"`
+	Three int64 `json:"3"`
+	Four  int64 `json:"4"`
+	Five  int64 `json:"5"`
+}
+
+// ReputationResponse — репутация пользователя.
+// Score от 0 до 100; доли считаются от завершённых обменов и отменённых самим пользователем
+type ReputationResponse struct {
+	Score            float64 `json:"score"`
+	CompletionRate   float64 `json:"completion_rate"`
+	CancellationRate float64 `json:"cancellation_rate"`
+	ExchangesCount   int64   `json:"exchanges_count"`
+}
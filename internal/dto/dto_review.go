@@ -1,8 +1,49 @@
 package dto
 
+import "strings"
+
+// CreateReviewRequest — отзыв по итогам завершённого обмена.
+// TargetUserID и TargetBookID можно не передавать: по умолчанию это
+// второй участник обмена и книга, которую автор от него получил
 type CreateReviewRequest struct {
+	ExchangeID   uint   `json:"exchange_id" binding:"required"`
 	TargetUserID uint   `json:"target_user_id"`
 	TargetBookID uint   `json:"target_book_id"`
 	Text         string `json:"text"`
 	Rating       int    `json:"rating"`
 }
+
+// UpdateReviewRequest — правка отзыва автором; незаданные поля не меняются
+type UpdateReviewRequest struct {
+	Text   *string `json:"text"`
+	Rating *int    `json:"rating"`
+}
+
+type ReviewReplyRequest struct {
+	Text string `json:"text" binding:"required"`
+}
+
+type ReviewListQuery struct {
+	Page  int `form:"page"`
+	Limit int `form:"limit"`
+
+	// sort_by: created_at | rating
+	// sort_order: asc | desc
+	SortBy    string `form:"sort_by"`
+	SortOrder string `form:"sort_order"`
+}
+
+// Normalize подставляет значения по умолчанию и ограничивает limit
+func (q *ReviewListQuery) Normalize() {
+	if q.Page <= 0 {
+		q.Page = DefaultPage
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+	q.SortBy = strings.ToLower(strings.TrimSpace(q.SortBy))
+	q.SortOrder = strings.ToLower(strings.TrimSpace(q.SortOrder))
+}
@@ -1,11 +1,14 @@
 package dto
 
+import "time"
+
 type UserCreateRequest struct {
 	Name     string `json:"name"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
 	City     string `json:"city"`
 	Address  string `json:"address"`
+	Language string `json:"language"` // ru | en, по умолчанию ru
 }
 
 type UserUpdateRequest struct {
@@ -14,17 +17,68 @@ type UserUpdateRequest struct {
 	Password *string `json:"password"`
 	City     *string `json:"city"`
 	Address  *string `json:"address"`
+	Language *string `json:"language"`
+	// Visibility — кому видны email, адрес и город; не переданные поля не меняются
+	Visibility *UserVisibilityUpdate `json:"visibility"`
+}
+
+// UserVisibility — уровни видимости полей профиля: public | partners | private
+type UserVisibility struct {
+	Email   string `json:"email"`
+	Address string `json:"address"`
+	City    string `json:"city"`
+}
+
+type UserVisibilityUpdate struct {
+	Email   *string `json:"email"`
+	Address *string `json:"address"`
+	City    *string `json:"city"`
+}
+
+// UserResponse — пользователь глазами зрителя: скрытые настройками видимости поля пустые.
+// Language, EmailVerifiedAt и Visibility видит только сам пользователь.
+type UserResponse struct {
+	ID              uint            `json:"id"`
+	Name            string          `json:"name"`
+	Email           string          `json:"email,omitempty"`
+	City            string          `json:"city,omitempty"`
+	Address         string          `json:"address,omitempty"`
+	Language        string          `json:"language,omitempty"`
+	EmailVerifiedAt *time.Time      `json:"email_verified_at,omitempty"`
+	Visibility      *UserVisibility `json:"visibility,omitempty"`
+	Version         uint            `json:"version"`
 }
 
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// ClientIP заполняет обработчик: блокировка после неудачных входов действует
+	// на пару аккаунт + IP, чтобы чужие попытки не закрывали вход владельцу
+	ClientIP string `json:"-"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
 }
 
 type UserProfileResponse struct {
-	ID                       uint   `json:"id"`
-	Name                     string `json:"name"`
-	City                     string `json:"city"`
-	BooksCount               int64  `json:"books_count"`
-	SuccessfulExchangesCount int64  `json:"successful_exchanges_count"`
+	ID                       uint               `json:"id"`
+	Name                     string             `json:"name"`
+	City                     string             `json:"city,omitempty"`
+	Email                    string             `json:"email,omitempty"`   // по настройкам видимости
+	Address                  string             `json:"address,omitempty"` // по настройкам видимости
+	BooksCount               int64              `json:"books_count"`
+	SuccessfulExchangesCount int64              `json:"successful_exchanges_count"`
+	Rating                   RatingResponse     `json:"rating"`
+	Reputation               ReputationResponse `json:"reputation"`
+	Version                  uint               `json:"version"`
 }
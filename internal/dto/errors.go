@@ -1,6 +1,9 @@
 package dto
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
 	// Book repository errors
@@ -9,6 +12,9 @@ var (
 	ErrBookUpdateFailed = errors.New("error updating book in db")
 	ErrBookDeleteFailed = errors.New("error deleting book in db")
 	ErrorBookNotFound = errors.New("err not found")
+	ErrInvalidCursor  = errors.New("invalid or outdated cursor")
+	// ErrInvalidLocation — near не в формате lat,lon или radius_km вне допустимого диапазона
+	ErrInvalidLocation = errors.New("invalid near or radius_km")
 
 	// Exchange repository errors
 	ErrExchangeCreateFailed   = errors.New("error create exchange in db")
@@ -21,6 +27,10 @@ var (
 	ErrNotFound     = errors.New("resource not found")
 	ErrConflict     = errors.New("resource already exists")
 	ErrInvalidInput = errors.New("invalid input")
+	ErrGenreParentNotFound = errors.New("parent genre not found")
+	ErrGenreHasChildren    = errors.New("genre has subgenres")
+	ErrGenreMergeInvalid   = errors.New("cannot merge a genre into itself or its subgenre")
+	ErrAdminOnly           = errors.New("admin role required")
 
 	// Review repository errors
 	ErrReviewCreateFail = errors.New("failed to create review")
@@ -53,6 +63,17 @@ var (
 	ErrInvalidRating         = errors.New("rating must be between 1 and 5")
 	ErrSelfReviewForbidden   = errors.New("cannot leave review to yourself")
 	ErrReviewDeleteForbidden = errors.New("you are not allowed to delete this review")
+	ErrReviewExchangeNotCompleted = errors.New("exchange is not completed")
+	ErrReviewNotParticipant       = errors.New("you did not take part in this exchange")
+	ErrReviewTargetMismatch       = errors.New("review target must be the other participant of the exchange and the book received from them")
+	ErrReviewTargetNotFound       = errors.New("review target not found")
+	ErrReviewAlreadyExists        = errors.New("you have already reviewed this exchange")
+	ErrReviewEditForbidden        = errors.New("you are not allowed to edit this review")
+	ErrReviewEditWindowClosed     = errors.New("review can no longer be edited")
+	ErrReviewReplyForbidden       = errors.New("only the reviewed user can reply to this review")
+	ErrReviewReplyExists          = errors.New("review already has a reply")
+	ErrReviewReplyTextLength      = errors.New("reply text must be between 1 and 500 characters")
+	ErrReviewReplyFlagged         = errors.New("reply did not pass moderation")
 
 	ErrEmailAlreadyUsed        = errors.New("email already in use")
 	ErrInvalidCredentials      = errors.New("invalid credentials")
@@ -62,4 +83,40 @@ var (
 	ErrUserListFailed          = errors.New("failed to list users")
 	ErrUserProfileStatsFailed  = errors.New("failed to calculate user profile stats")
 	ErrUserPasswordHashFailed  = errors.New("failed to hash password")
+	ErrUnsupportedLanguage     = errors.New("unsupported language")
+	ErrInvalidEmail            = errors.New("invalid email")
+	ErrInvalidVisibility       = errors.New("visibility must be public, partners or private")
+	ErrPasswordTooShort        = errors.New("password must be at least 8 characters")
+	ErrEmailNotVerified        = errors.New("email is not verified")
+	ErrEmailAlreadyVerified    = errors.New("email is already verified")
+
+	ErrAccountLocked           = errors.New("too many failed login attempts, account is temporarily locked")
+
+	// Moderation errors
+	ErrReportAlreadyExists  = errors.New("you have already reported this content")
+	ErrReportOwnContent     = errors.New("cannot report your own content")
+	ErrReportReasonRequired = errors.New("report reason must be between 1 and 500 characters")
+	ErrContentNotFound      = errors.New("content not found")
+	ErrModeratorOnly        = errors.New("moderator role required")
+
+	// Optimistic concurrency: If-Match не совпал или строку успели изменить
+	ErrVersionConflict = errors.New("resource was modified by another request")
+
+	// Token errors
+	ErrTokenCreateFailed = errors.New("failed to create token")
+	ErrTokenInvalid      = errors.New("token is invalid or expired")
 )
+
+// AccountLockedError возвращается из Login, пока действует блокировка.
+// errors.Is(err, ErrAccountLocked) == true.
+type AccountLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *AccountLockedError) Error() string {
+	return ErrAccountLocked.Error()
+}
+
+func (e *AccountLockedError) Is(target error) bool {
+	return target == ErrAccountLocked
+}
@@ -0,0 +1,88 @@
+// Package etag строит ETag по версии ресурса и проверяет условные заголовки.
+//
+// ETag имеет вид "<версия>.<хэш тела>": хэш меняется и тогда, когда в ответ
+// попали изменившиеся связанные данные (например, имя владельца книги),
+// поэтому If-None-Match не отдаст устаревший ответ. If-Match сравнивает
+// только версию — её же клиент может взять из поля version и прислать как "3".
+package etag
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+)
+
+// Compute возвращает сильный ETag для тела ответа ресурса с версией version
+func Compute(version uint, body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + strconv.FormatUint(uint64(version), 10) + "." + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// NoneMatch сообщает, совпадает ли tag с заголовком If-None-Match
+// (слабое сравнение, как требует RFC 9110 для If-None-Match)
+func NoneMatch(header, tag string) bool {
+	for _, t := range splitTags(header) {
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+type ifMatchKey struct{}
+
+// WithIfMatch кладёт заголовок If-Match в контекст запроса;
+// сервисы проверяют его через Check перед изменением ресурса
+func WithIfMatch(ctx context.Context, header string) context.Context {
+	if header == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, ifMatchKey{}, header)
+}
+
+// Check сверяет текущую версию ресурса с If-Match из контекста.
+// Без заголовка проверка не выполняется. Слабые ETag не совпадают никогда.
+func Check(ctx context.Context, current uint) error {
+	header, ok := ctx.Value(ifMatchKey{}).(string)
+	if !ok {
+		return nil
+	}
+
+	for _, t := range splitTags(header) {
+		if t == "*" {
+			return nil
+		}
+		if v, ok := version(t); ok && v == current {
+			return nil
+		}
+	}
+	return dto.ErrVersionConflict
+}
+
+// version достаёт версию из "3" или "3.abcdef"
+func version(tag string) (uint, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	v, _, _ := strings.Cut(tag[1:len(tag)-1], ".")
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(n), true
+}
+
+func splitTags(header string) []string {
+	parts := strings.Split(header, ",")
+	tags := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			tags = append(tags, p)
+		}
+	}
+	return tags
+}
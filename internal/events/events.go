@@ -0,0 +1,85 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Типы событий, которые получают подключённые клиенты. Пока это только смены статуса
+// обменов: сообщений между пользователями в сервисе нет, а отдельных уведомлений
+// ещё никто не публикует — их типы появятся вместе с источником
+const (
+	TypeExchangeCreated   = "exchange.created"
+	TypeExchangeAccepted  = "exchange.accepted"
+	TypeExchangeCompleted = "exchange.completed"
+	TypeExchangeCancelled = "exchange.cancelled"
+)
+
+// Event — одно событие для конкретного пользователя.
+// ID заполняется брокером при публикации и используется для Last-Event-ID.
+type Event struct {
+	ID        string          `json:"id"`
+	UserID    uint            `json:"user_id"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Publisher публикует события для пользователей
+type Publisher interface {
+	Publish(ctx context.Context, userID uint, eventType string, payload any) error
+}
+
+// Subscriber отдаёт поток событий пользователя.
+// Если lastEventID не пустой, сначала возвращаются пропущенные события.
+type Subscriber interface {
+	Subscribe(ctx context.Context, userID uint, lastEventID string) (<-chan Event, error)
+}
+
+// Broker объединяет публикацию и подписку
+type Broker interface {
+	Publisher
+	Subscriber
+}
+
+// compareIDs сравнивает ID в формате Redis Stream ("<ms>-<seq>").
+// Возвращает -1, 0 или 1.
+func compareIDs(a, b string) int {
+	aMs, aSeq := splitID(a)
+	bMs, bSeq := splitID(b)
+
+	switch {
+	case aMs < bMs:
+		return -1
+	case aMs > bMs:
+		return 1
+	case aSeq < bSeq:
+		return -1
+	case aSeq > bSeq:
+		return 1
+	}
+	return 0
+}
+
+func splitID(id string) (uint64, uint64) {
+	ms, seq, _ := strings.Cut(id, "-")
+	msVal, _ := strconv.ParseUint(ms, 10, 64)
+	seqVal, _ := strconv.ParseUint(seq, 10, 64)
+	return msVal, seqVal
+}
+
+// ValidID проверяет, что Last-Event-ID пришёл в ожидаемом формате
+func ValidID(id string) bool {
+	ms, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return false
+	}
+	if _, err := strconv.ParseUint(ms, 10, 64); err != nil {
+		return false
+	}
+	_, err := strconv.ParseUint(seq, 10, 64)
+	return err == nil
+}
@@ -0,0 +1,128 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// буфер подписчика; медленный клиент отключается и переподключается с Last-Event-ID
+const subscriberBuffer = 64
+
+var ErrBrokerClosed = errors.New("events: broker is closed")
+
+type subscriber struct {
+	ch chan Event
+}
+
+// hub — локальные подписчики этой реплики; общий для Redis- и in-memory брокера
+type hub struct {
+	mu     sync.RWMutex
+	subs   map[uint]map[*subscriber]struct{}
+	closed bool
+}
+
+func newHub() hub {
+	return hub{subs: make(map[uint]map[*subscriber]struct{})}
+}
+
+func (b *hub) dispatch(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subs[event.UserID] {
+		select {
+		case s.ch <- event:
+		default:
+			// клиент не успевает читать — отключаем, он догонит по Last-Event-ID
+			delete(b.subs[event.UserID], s)
+			close(s.ch)
+		}
+	}
+}
+
+func (b *hub) closeAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for userID, subs := range b.subs {
+		for s := range subs {
+			close(s.ch)
+		}
+		delete(b.subs, userID)
+	}
+}
+
+func (b *hub) register(userID uint) (*subscriber, error) {
+	s := &subscriber{ch: make(chan Event, subscriberBuffer)}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, ErrBrokerClosed
+	}
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[*subscriber]struct{})
+	}
+	b.subs[userID][s] = struct{}{}
+	b.mu.Unlock()
+
+	return s, nil
+}
+
+func (b *hub) unregister(userID uint, s *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[userID][s]; !ok {
+		return
+	}
+	delete(b.subs[userID], s)
+	if len(b.subs[userID]) == 0 {
+		delete(b.subs, userID)
+	}
+	close(s.ch)
+}
+
+// stream отдаёт сначала пропущенные события, затем новые, без повторов
+func (b *hub) stream(ctx context.Context, userID uint, s *subscriber, lastEventID string, missed []Event) <-chan Event {
+	out := make(chan Event, subscriberBuffer)
+	go func() {
+		defer close(out)
+		defer b.unregister(userID, s)
+
+		lastID := lastEventID
+		for _, event := range missed {
+			select {
+			case out <- event:
+				lastID = event.ID
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-s.ch:
+				if !ok {
+					return
+				}
+				// пропускаем то, что уже отдали из истории
+				if lastID != "" && compareIDs(event.ID, lastID) <= 0 {
+					continue
+				}
+				select {
+				case out <- event:
+					lastID = event.ID
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
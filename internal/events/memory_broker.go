@@ -0,0 +1,95 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryBroker — брокер в памяти процесса для режима одного бинарника (без Redis).
+// Хранит последние streamMaxLen событий на пользователя для Last-Event-ID;
+// история теряется при перезапуске, события не раздаются другим репликам.
+type MemoryBroker struct {
+	hub
+
+	historyMu sync.Mutex
+	history   map[uint][]Event
+	lastMs    uint64
+	lastSeq   uint64
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		hub:     newHub(),
+		history: make(map[uint][]Event),
+	}
+}
+
+func (b *MemoryBroker) Publish(_ context.Context, userID uint, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	b.historyMu.Lock()
+	event := Event{
+		ID:        b.nextID(now),
+		UserID:    userID,
+		Type:      eventType,
+		Data:      data,
+		CreatedAt: now,
+	}
+	list := append(b.history[userID], event)
+	if len(list) > streamMaxLen {
+		list = list[len(list)-streamMaxLen:]
+	}
+	b.history[userID] = list
+	// раздаём под тем же мьютексом, чтобы подписчики получали события в порядке ID
+	b.dispatch(event)
+	b.historyMu.Unlock()
+
+	return nil
+}
+
+// nextID выдаёт ID в формате Redis Stream, чтобы Last-Event-ID был одинаковым в обоих режимах
+func (b *MemoryBroker) nextID(now time.Time) string {
+	ms := uint64(now.UnixMilli())
+	if ms > b.lastMs {
+		b.lastMs, b.lastSeq = ms, 0
+	} else {
+		b.lastSeq++
+	}
+	return fmt.Sprintf("%d-%d", b.lastMs, b.lastSeq)
+}
+
+// Run ждёт отмены ctx и закрывает подписки — как RedisBroker.Run
+func (b *MemoryBroker) Run(ctx context.Context) {
+	<-ctx.Done()
+	b.closeAll()
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, userID uint, lastEventID string) (<-chan Event, error) {
+	// под historyMu новые события не публикуются — история и подписка согласованы
+	b.historyMu.Lock()
+	s, err := b.register(userID)
+	if err != nil {
+		b.historyMu.Unlock()
+		return nil, err
+	}
+
+	var missed []Event
+	if lastEventID != "" {
+		for _, event := range b.history[userID] {
+			if compareIDs(event.ID, lastEventID) > 0 {
+				missed = append(missed, event)
+			}
+		}
+	}
+	b.historyMu.Unlock()
+
+	return b.stream(ctx, userID, s, lastEventID, missed), nil
+}
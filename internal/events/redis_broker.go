@@ -0,0 +1,155 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// канал pub/sub, через который реплики раздают события друг другу
+	pubSubChannel = "events"
+	// сколько последних событий храним на пользователя для Last-Event-ID
+	streamMaxLen = 500
+	streamTTL    = 24 * time.Hour
+)
+
+// RedisBroker хранит историю событий в Redis Stream на пользователя
+// и раздаёт новые события всем репликам через Redis pub/sub.
+type RedisBroker struct {
+	hub
+	rdb *redis.Client
+	log *slog.Logger
+}
+
+func NewRedisBroker(rdb *redis.Client, log *slog.Logger) *RedisBroker {
+	return &RedisBroker{
+		hub: newHub(),
+		rdb: rdb,
+		log: log,
+	}
+}
+
+func streamKey(userID uint) string {
+	return fmt.Sprintf("events:user:%d", userID)
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, userID uint, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	key := streamKey(userID)
+
+	id, err := b.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: streamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"type":       eventType,
+			"data":       string(data),
+			"created_at": now.Format(time.RFC3339Nano),
+		},
+	}).Result()
+	if err != nil {
+		b.log.Error("ошибка записи события в stream", "user_id", userID, "type", eventType, "err", err)
+		return err
+	}
+	b.rdb.Expire(ctx, key, streamTTL)
+
+	msg, err := json.Marshal(Event{
+		ID:        id,
+		UserID:    userID,
+		Type:      eventType,
+		Data:      data,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := b.rdb.Publish(ctx, pubSubChannel, msg).Err(); err != nil {
+		b.log.Error("ошибка публикации события", "user_id", userID, "type", eventType, "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// Run слушает pub/sub канал и раздаёт события локальным подписчикам.
+// Блокируется до отмены ctx; при выходе закрывает все подписки,
+// чтобы открытые SSE-соединения завершились.
+func (b *RedisBroker) Run(ctx context.Context) {
+	sub := b.rdb.Subscribe(ctx, pubSubChannel)
+	defer sub.Close()
+	defer b.closeAll()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			var event Event
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				b.log.Warn("некорректное событие в pub/sub", "err", err)
+				continue
+			}
+			b.dispatch(event)
+		}
+	}
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, userID uint, lastEventID string) (<-chan Event, error) {
+	// Регистрируемся до чтения истории, чтобы не потерять события между ними
+	s, err := b.register(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var missed []Event
+	if lastEventID != "" {
+		missed, err = b.history(ctx, userID, lastEventID)
+		if err != nil {
+			b.unregister(userID, s)
+			return nil, err
+		}
+	}
+
+	return b.stream(ctx, userID, s, lastEventID, missed), nil
+}
+
+func (b *RedisBroker) history(ctx context.Context, userID uint, lastEventID string) ([]Event, error) {
+	msgs, err := b.rdb.XRangeN(ctx, streamKey(userID), "("+lastEventID, "+", streamMaxLen).Result()
+	if err != nil {
+		b.log.Error("ошибка чтения истории событий", "user_id", userID, "err", err)
+		return nil, err
+	}
+
+	list := make([]Event, 0, len(msgs))
+	for _, m := range msgs {
+		event := Event{ID: m.ID, UserID: userID}
+		if v, ok := m.Values["type"].(string); ok {
+			event.Type = v
+		}
+		if v, ok := m.Values["data"].(string); ok {
+			event.Data = json.RawMessage(v)
+		}
+		if v, ok := m.Values["created_at"].(string); ok {
+			event.CreatedAt, _ = time.Parse(time.RFC3339Nano, v)
+		}
+		list = append(list, event)
+	}
+
+	return list, nil
+}
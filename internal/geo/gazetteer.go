@@ -0,0 +1,199 @@
+package geo
+
+import (
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//go:embed gazetteer.csv
+var defaultGazetteer string
+
+// Gazetteer — офлайн-геокодер по справочнику населённых пунктов.
+// Понимает "г. Москва", адреса через запятую ("ул. Ленина, 5, Химки")
+// и опечатки в одну-две буквы.
+type Gazetteer struct {
+	places map[string]Point
+}
+
+// NewGazetteer — справочник городов России и соседних стран, встроенный в бинарник
+func NewGazetteer() *Gazetteer {
+	g := &Gazetteer{places: map[string]Point{}}
+	if err := g.Load(strings.NewReader(defaultGazetteer)); err != nil {
+		panic("geo: broken embedded gazetteer: " + err.Error())
+	}
+	return g
+}
+
+// Load добавляет места из CSV "название;широта;долгота;синоним|синоним".
+// Строки с # — комментарии; совпадающие названия перезаписываются.
+func (g *Gazetteer) Load(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.Comma = ';'
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) < 3 {
+			return fmt.Errorf("line %d: expected name;lat;lon", line)
+		}
+		lat, errLat := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		lon, errLon := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		p := Point{Lat: lat, Lon: lon}
+		if errLat != nil || errLon != nil || !p.Valid() {
+			return fmt.Errorf("line %d: invalid coordinates", line)
+		}
+
+		names := []string{record[0]}
+		if len(record) > 3 && record[3] != "" {
+			names = append(names, strings.Split(record[3], "|")...)
+		}
+		for _, name := range names {
+			if key := normalizePlace(name); key != "" {
+				g.places[key] = p
+			}
+		}
+	}
+}
+
+// Len — число названий в справочнике вместе с синонимами
+func (g *Gazetteer) Len() int {
+	return len(g.places)
+}
+
+// Geocode ищет части запроса через запятую: сначала точное совпадение, затем с опечаткой.
+// Побеждает первая по порядку часть, поэтому уточнённый адрес важнее города после него.
+func (g *Gazetteer) Geocode(_ context.Context, query string) (Point, error) {
+	parts := strings.Split(query, ",")
+	keys := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if key := normalizePlace(part); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		if p, ok := g.places[key]; ok {
+			return p, nil
+		}
+	}
+	for _, key := range keys {
+		if p, ok := g.fuzzy(key); ok {
+			return p, nil
+		}
+	}
+	return Point{}, ErrNotFound
+}
+
+// fuzzy находит единственное ближайшее по Левенштейну название;
+// если ближайших несколько и они в разных местах, запрос неоднозначен
+func (g *Gazetteer) fuzzy(key string) (Point, bool) {
+	runes := []rune(key)
+	maxDist := typoBudget(len(runes))
+	if maxDist == 0 {
+		return Point{}, false
+	}
+
+	best, found, ambiguous := maxDist+1, Point{}, false
+	for name, p := range g.places {
+		nameRunes := []rune(name)
+		if abs(len(nameRunes)-len(runes)) > maxDist {
+			continue
+		}
+		d := levenshtein(runes, nameRunes)
+		switch {
+		case d < best:
+			best, found, ambiguous = d, p, false
+		case d == best && p != found:
+			ambiguous = true
+		}
+	}
+	return found, best <= maxDist && !ambiguous
+}
+
+// typoBudget — сколько опечаток допускается в названии такой длины
+func typoBudget(n int) int {
+	switch {
+	case n < 5:
+		return 0
+	case n < 9:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// placePrefixes — сокращения перед названием, которые не участвуют в поиске
+var placePrefixes = map[string]bool{
+	"г": true, "гор": true, "город": true, "city": true,
+	"пгт": true, "пос": true, "посёлок": true, "поселок": true,
+}
+
+// normalizePlace приводит название к ключу: нижний регистр, ё → е,
+// без пунктуации, индексов и сокращений вроде "г."
+func normalizePlace(s string) string {
+	s = strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(s, "ё", "е"), "Ё", "Е"))
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	kept := words[:0]
+	for _, w := range words {
+		if placePrefixes[w] || isDigits(w) {
+			continue
+		}
+		kept = append(kept, w)
+	}
+	return strings.Join(kept, " ")
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
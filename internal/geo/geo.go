@@ -0,0 +1,90 @@
+// Package geo — координаты, расстояния и геокодирование городов и адресов
+package geo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound — геокодер не знает такого места
+var ErrNotFound = errors.New("location not found")
+
+// Geocoder превращает город или адрес в координаты
+type Geocoder interface {
+	Geocode(ctx context.Context, query string) (Point, error)
+}
+
+// EarthRadiusKm — средний радиус Земли
+const EarthRadiusKm = 6371.0
+
+// KmPerDegree — длина градуса широты (и долготы на экваторе)
+const KmPerDegree = EarthRadiusKm * math.Pi / 180
+
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180
+}
+
+func (p Point) String() string {
+	return strconv.FormatFloat(p.Lat, 'f', -1, 64) + "," + strconv.FormatFloat(p.Lon, 'f', -1, 64)
+}
+
+// ParsePoint разбирает "lat,lon"
+func ParsePoint(s string) (Point, error) {
+	latStr, lonStr, ok := strings.Cut(s, ",")
+	if !ok {
+		return Point{}, errors.New("expected lat,lon")
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+	if err != nil {
+		return Point{}, errors.New("invalid latitude")
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(lonStr), 64)
+	if err != nil {
+		return Point{}, errors.New("invalid longitude")
+	}
+	p := Point{Lat: lat, Lon: lon}
+	if !p.Valid() {
+		return Point{}, errors.New("coordinates out of range")
+	}
+	return p, nil
+}
+
+// Distance — расстояние по большому кругу в километрах
+func Distance(a, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat, dLon := lat2-lat1, radians(b.Lon-a.Lon)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// KmPerDegreeLon — длина градуса долготы на широте lat
+func KmPerDegreeLon(lat float64) float64 {
+	return KmPerDegree * math.Cos(radians(lat))
+}
+
+// BoundingBox — прямоугольник, содержащий круг радиуса radiusKm вокруг p.
+// Переход через 180-й меридиан не учитывается — границы просто обрезаются.
+func BoundingBox(p Point, radiusKm float64) (minLat, maxLat, minLon, maxLon float64) {
+	dLat := radiusKm / KmPerDegree
+	minLat, maxLat = math.Max(-90, p.Lat-dLat), math.Min(90, p.Lat+dLat)
+
+	// у полюса градус долготы вырождается — берём всю долготу
+	perLon := KmPerDegreeLon(math.Max(math.Abs(minLat), math.Abs(maxLat)))
+	if perLon < 1e-6 {
+		return minLat, maxLat, -180, 180
+	}
+	dLon := radiusKm / perLon
+	return minLat, maxLat, math.Max(-180, p.Lon-dLon), math.Min(180, p.Lon+dLon)
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
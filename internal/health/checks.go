@@ -0,0 +1,111 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// DatabaseCheck пингует пул соединений GORM
+func DatabaseCheck(db *gorm.DB) CheckFunc {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// RedisCheck пингует Redis
+func RedisCheck(rdb *redis.Client) CheckFunc {
+	return func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	}
+}
+
+// Flag — состояние фоновой операции (например, миграций) для readiness
+type Flag struct {
+	mu  sync.RWMutex
+	err error
+	ok  bool
+}
+
+// NewFlag создаёт флаг, который до Done возвращает pending
+func NewFlag(pending string) *Flag {
+	return &Flag{err: pendingError(pending)}
+}
+
+// pendingError — операция ещё идёт, в отличие от ошибки, переданной в Fail
+type pendingError string
+
+func (e pendingError) Error() string { return string(e) }
+
+// IsPending сообщает, что Check флага вернул ошибку только потому, что операция не закончилась
+func IsPending(err error) bool {
+	var p pendingError
+	return errors.As(err, &p)
+}
+
+func (f *Flag) Done() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ok, f.err = true, nil
+}
+
+func (f *Flag) Fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ok, f.err = false, err
+}
+
+func (f *Flag) Check(context.Context) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.ok {
+		return nil
+	}
+	return f.err
+}
+
+// Monitor периодически проверяет зависимость и кэширует результат,
+// чтобы горячие пути (кэш списка пользователей) не ждали таймаутов.
+type Monitor struct {
+	fn        CheckFunc
+	interval  time.Duration
+	available atomic.Bool
+}
+
+func NewMonitor(fn CheckFunc, interval time.Duration) *Monitor {
+	m := &Monitor{fn: fn, interval: interval}
+	m.available.Store(true)
+	return m
+}
+
+// Available — была ли зависимость доступна при последней проверке
+func (m *Monitor) Available() bool {
+	return m.available.Load()
+}
+
+// Run проверяет зависимость до отмены ctx
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, m.interval)
+		m.available.Store(m.fn(checkCtx) == nil)
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
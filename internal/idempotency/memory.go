@@ -0,0 +1,88 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultMaxEntries ограничивает память: при переполнении сначала удаляются
+// просроченные ключи, затем — произвольные
+const defaultMaxEntries = 10000
+
+type memoryEntry struct {
+	rec       Record
+	expiresAt time.Time
+}
+
+// MemoryStore хранит ключи в памяти процесса; подходит только для одной реплики
+type MemoryStore struct {
+	mu         sync.Mutex
+	entries    map[string]memoryEntry
+	maxEntries int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries:    make(map[string]memoryEntry),
+		maxEntries: defaultMaxEntries,
+	}
+}
+
+func (s *MemoryStore) Reserve(_ context.Context, key, fingerprint, owner string, lockTTL time.Duration) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok && time.Now().Before(entry.expiresAt) {
+		return entry.rec, false, nil
+	}
+
+	rec := Record{Fingerprint: fingerprint, Owner: owner}
+	s.put(key, rec, lockTTL)
+	return rec, true, nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, key string, rec Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// копируем, чтобы вызывающий код не изменил сохранённое тело
+	body := make([]byte, len(rec.Body))
+	copy(body, rec.Body)
+	rec.Body = body
+
+	s.put(key, rec, ttl)
+	return nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, key, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok && entry.rec.Owner == owner && !entry.rec.Completed() {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+func (s *MemoryStore) put(key string, rec Record, ttl time.Duration) {
+	if _, exists := s.entries[key]; !exists && len(s.entries) >= s.maxEntries {
+		s.evict()
+	}
+	s.entries[key] = memoryEntry{rec: rec, expiresAt: time.Now().Add(ttl)}
+}
+
+func (s *MemoryStore) evict() {
+	now := time.Now()
+	for key, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+	for key := range s.entries {
+		if len(s.entries) < s.maxEntries {
+			return
+		}
+		delete(s.entries, key)
+	}
+}
@@ -0,0 +1,89 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/logging"
+	"github.com/redis/go-redis/v9"
+)
+
+type RedisStore struct {
+	rdb *redis.Client
+	log *slog.Logger
+}
+
+func NewRedisStore(rdb *redis.Client, log *slog.Logger) *RedisStore {
+	return &RedisStore{rdb: rdb, log: log}
+}
+
+func redisKey(key string) string {
+	return "idempotency:" + key
+}
+
+func (s *RedisStore) Reserve(ctx context.Context, key, fingerprint, owner string, lockTTL time.Duration) (Record, bool, error) {
+	pending, err := json.Marshal(Record{Fingerprint: fingerprint, Owner: owner})
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	// ключ может истечь между SETNX и GET — тогда пробуем занять его ещё раз
+	for attempt := 0; attempt < 2; attempt++ {
+		ok, err := s.rdb.SetNX(ctx, redisKey(key), pending, lockTTL).Result()
+		// недоступность хранилища логирует middleware вместе с запросом
+		if err != nil {
+			return Record{}, false, err
+		}
+		if ok {
+			return Record{Fingerprint: fingerprint, Owner: owner}, true, nil
+		}
+
+		data, err := s.rdb.Get(ctx, redisKey(key)).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return Record{}, false, err
+		}
+
+		var rec Record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return Record{}, false, err
+		}
+		return rec, false, nil
+	}
+
+	return Record{}, false, ErrInProgress
+}
+
+func (s *RedisStore) Save(ctx context.Context, key string, rec Record, ttl time.Duration) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := s.rdb.Set(ctx, redisKey(key), data, ttl).Err(); err != nil {
+		logging.From(ctx, s.log).ErrorContext(ctx, "failed to save idempotent response", "err", err)
+		return err
+	}
+	return nil
+}
+
+// releaseScript удаляет ключ, только если запись всё ещё принадлежит владельцу (compare-and-delete)
+var releaseScript = redis.NewScript(`
+local data = redis.call('GET', KEYS[1])
+if not data then
+	return 0
+end
+local ok, rec = pcall(cjson.decode, data)
+if ok and rec.owner == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func (s *RedisStore) Release(ctx context.Context, key, owner string) error {
+	return releaseScript.Run(ctx, s.rdb, []string{redisKey(key)}, owner).Err()
+}
@@ -0,0 +1,40 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrInProgress — запрос с этим ключом ещё выполняется
+var ErrInProgress = errors.New("request with this idempotency key is in progress")
+
+// Record — сохранённый результат первого запроса с ключом.
+// Fingerprint — хэш метода, пути и тела: по нему отличаем повтор от другого запроса.
+type Record struct {
+	Fingerprint string `json:"fingerprint"`
+	// 0 — запрос ещё выполняется
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Location    string `json:"location,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+	// Owner — случайный токен запроса, занявшего ключ; у сохранённого ответа пусто
+	Owner string `json:"owner,omitempty"`
+}
+
+func (r Record) Completed() bool {
+	return r.Status != 0
+}
+
+// Store хранит ключи идемпотентности
+type Store interface {
+	// Reserve атомарно занимает ключ под выполняющийся запрос owner на lockTTL.
+	// Если ключ уже занят, возвращает его запись и reserved=false.
+	Reserve(ctx context.Context, key, fingerprint, owner string, lockTTL time.Duration) (rec Record, reserved bool, err error)
+	// Save сохраняет ответ на ttl
+	Save(ctx context.Context, key string, rec Record, ttl time.Duration) error
+	// Release освобождает ключ, если запрос не удался и его можно повторить.
+	// Ключ удаляется, только если его всё ещё держит owner: после истечения lockTTL
+	// он мог достаться другому запросу
+	Release(ctx context.Context, key, owner string) error
+}
@@ -1,40 +1,73 @@
 package jwtutil
 
 import (
-	"os"
+	"errors"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func getSecret()[]byte{
-	return []byte(os.Getenv("SUPER_SECRET_KEY"))
+// ErrNoSecret — ключ подписи не задан: лучше не выдавать токены вовсе,
+// чем подписывать их пустой строкой
+var ErrNoSecret = errors.New("jwt secret is not configured")
+
+type settings struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+var current atomic.Pointer[settings]
+
+// Configure задаёт ключ подписи и время жизни токенов; вызывается один раз при старте
+func Configure(secret string, ttl time.Duration) {
+	current.Store(&settings{secret: []byte(secret), ttl: ttl})
 }
+
+func getSettings() (*settings, error) {
+	s := current.Load()
+	if s == nil || len(s.secret) == 0 {
+		return nil, ErrNoSecret
+	}
+	return s, nil
+}
+
 type Claims struct {
 	UserID uint `json:"user_id"`
 	jwt.RegisteredClaims
 }
 
 func GenerateToken(userID uint) (string, error) {
+	cfg, err := getSettings()
+	if err != nil {
+		return "", err
+	}
+
 	claims := Claims{
 		UserID: userID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(cfg.ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(getSecret())
+	return token.SignedString(cfg.secret)
 }
 
 func ParseToken(tokenStr string) (*Claims, error) {
+	cfg, err := getSettings()
+	if err != nil {
+		return nil, err
+	}
+
 	token, err := jwt.ParseWithClaims(
 		tokenStr,
 		&Claims{},
 		func(token *jwt.Token) (interface{}, error) {
-			return getSecret(), nil
+			return cfg.secret, nil
 		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
 	)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,31 @@
+// Package logging хранит логгер запроса в context.Context,
+// чтобы сервисы и репозитории писали request_id и user_id без явной передачи.
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+type loggerKey struct{}
+
+// WithLogger кладёт логгер в контекст.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// From возвращает логгер запроса; если его нет — fallback, а если нет и его — slog.Default().
+func From(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	if fallback != nil {
+		return fallback
+	}
+	return slog.Default()
+}
+
+// With добавляет атрибуты к логгеру запроса и возвращает новый контекст.
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, From(ctx, nil).With(args...))
+}
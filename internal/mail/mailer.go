@@ -0,0 +1,39 @@
+package mail
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Message — готовое к отправке письмо
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма. Реализации: SMTPMailer, LogMailer, Queue.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer ничего не отправляет, а только пишет в лог адресата и тему.
+// Тело не логируется: в нём ссылки с токенами подтверждения и сброса пароля.
+// Используется локально и когда SMTP не настроен.
+type LogMailer struct {
+	log *slog.Logger
+}
+
+func NewLogMailer(log *slog.Logger) *LogMailer {
+	return &LogMailer{log: log}
+}
+
+func (m *LogMailer) Send(_ context.Context, msg Message) error {
+	m.log.Info("email (log mailer)", "to", msg.To, "subject", msg.Subject)
+	return nil
+}
+
+// NopMailer молча отбрасывает письма
+type NopMailer struct{}
+
+func (NopMailer) Send(context.Context, Message) error { return nil }
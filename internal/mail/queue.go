@@ -0,0 +1,156 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull   = errors.New("mail: queue is full")
+	ErrQueueClosed = errors.New("mail: queue is closed")
+)
+
+type QueueConfig struct {
+	Workers     int
+	Size        int
+	MaxAttempts int
+	// задержка перед первой повторной попыткой, дальше удваивается
+	Backoff time.Duration
+	// таймаут одной попытки отправки
+	SendTimeout time.Duration
+}
+
+// Queue — фоновая очередь писем с повторными попытками.
+// Сама реализует Mailer: Send только ставит письмо в очередь и не блокирует handler.
+type Queue struct {
+	mailer Mailer
+	log    *slog.Logger
+	cfg    QueueConfig
+
+	jobs chan Message
+	wg   sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+	stop   chan struct{}
+}
+
+func NewQueue(mailer Mailer, log *slog.Logger, cfg QueueConfig) *Queue {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if cfg.Size <= 0 {
+		cfg.Size = 256
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 2 * time.Second
+	}
+	if cfg.SendTimeout <= 0 {
+		cfg.SendTimeout = 15 * time.Second
+	}
+
+	return &Queue{
+		mailer: mailer,
+		log:    log,
+		cfg:    cfg,
+		jobs:   make(chan Message, cfg.Size),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Start запускает воркеры
+func (q *Queue) Start() {
+	for i := 0; i < q.cfg.Workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+}
+
+// Send ставит письмо в очередь
+func (q *Queue) Send(_ context.Context, msg Message) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.jobs <- msg:
+		return nil
+	default:
+		q.log.Error("mail queue is full, message dropped", "to", msg.To, "subject", msg.Subject)
+		return ErrQueueFull
+	}
+}
+
+// Stop перестаёт принимать письма и ждёт, пока воркеры разберут очередь.
+// Если ctx истёк раньше, незавершённые повторы прерываются.
+func (q *Queue) Stop(ctx context.Context) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.jobs)
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		close(q.stop)
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+
+	for msg := range q.jobs {
+		q.deliver(msg)
+	}
+}
+
+func (q *Queue) deliver(msg Message) {
+	backoff := q.cfg.Backoff
+
+	for attempt := 1; attempt <= q.cfg.MaxAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), q.cfg.SendTimeout)
+		err := q.mailer.Send(ctx, msg)
+		cancel()
+
+		if err == nil {
+			return
+		}
+
+		q.log.Warn("email send attempt failed", "to", msg.To, "attempt", attempt, "error", err)
+		if attempt == q.cfg.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-q.stop:
+			q.log.Error("email dropped on shutdown", "to", msg.To, "subject", msg.Subject)
+			return
+		}
+		backoff *= 2
+	}
+
+	q.log.Error("email send failed after retries", "to", msg.To, "subject", msg.Subject)
+}
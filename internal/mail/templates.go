@@ -0,0 +1,68 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Поддерживаемые языки писем
+const (
+	LangRU = "ru"
+	LangEN = "en"
+
+	DefaultLanguage = LangRU
+)
+
+// Имена шаблонов
+const (
+	TemplateVerifyEmail     = "verify_email"
+	TemplatePasswordReset   = "password_reset"
+	TemplateExchangeCreated = "exchange_created"
+)
+
+//go:embed templates/*/*.tmpl
+var templateFS embed.FS
+
+var templates = map[string]*template.Template{
+	LangRU: template.Must(template.ParseFS(templateFS, "templates/ru/*.tmpl")),
+	LangEN: template.Must(template.ParseFS(templateFS, "templates/en/*.tmpl")),
+}
+
+// NormalizeLanguage приводит язык пользователя к поддерживаемому,
+// неизвестные значения превращаются в DefaultLanguage.
+func NormalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if _, ok := templates[lang]; ok {
+		return lang
+	}
+	return DefaultLanguage
+}
+
+// IsSupportedLanguage сообщает, есть ли шаблоны для языка
+func IsSupportedLanguage(lang string) bool {
+	_, ok := templates[strings.ToLower(strings.TrimSpace(lang))]
+	return ok
+}
+
+// Render собирает письмо из шаблона name на языке lang.
+// Каждый файл шаблона определяет блоки "<name>_subject" и "<name>_body".
+func Render(lang, name, to string, data any) (Message, error) {
+	tmpl := templates[NormalizeLanguage(lang)]
+
+	var subject, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&subject, name+"_subject", data); err != nil {
+		return Message{}, fmt.Errorf("mail: render %s subject: %w", name, err)
+	}
+	if err := tmpl.ExecuteTemplate(&body, name+"_body", data); err != nil {
+		return Message{}, fmt.Errorf("mail: render %s body: %w", name, err)
+	}
+
+	return Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    strings.TrimSpace(body.String()) + "\n",
+	}, nil
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "bookcrossing"
+
+var (
+	// HTTPRequestDuration — латентность запросов по шаблону маршрута и статусу
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// DBQueryDuration — время запросов GORM по операции и таблице
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "GORM query latency by operation and table.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation", "table", "status"})
+
+	// CacheRequests — обращения к кэшу: hit | miss | error
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups by cache name and result (hit, miss, error).",
+	}, []string{"cache", "result"})
+
+	// ExchangeEvents — переходы обменов: created | accepted | completed | cancelled
+	ExchangeEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "exchanges_total",
+		Help:      "Exchange state transitions.",
+	}, []string{"event"})
+
+	// AISummaryFallbacks — сколько раз вместо AI-резюме использовано локальное
+	AISummaryFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ai_summary_fallbacks_total",
+		Help:      "AI summary generations that fell back to the local summary, by reason.",
+	}, []string{"reason"})
+
+	// ModerationChecks — проверки текста модерацией: checker — wordlist | ai, result — clean | flagged | error
+	ModerationChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "moderation_checks_total",
+		Help:      "Moderation checks of user content, by checker and result.",
+	}, []string{"checker", "result"})
+)
+
+// Имена кэшей для CacheRequests
+const (
+	CacheUsersList  = "users_list"
+	CacheBookFacets = "book_facets"
+)
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deprecated помечает ответы устаревших маршрутов заголовками
+// Deprecation (RFC 9745) и Sunset (RFC 8594), а Link ведёт на документацию
+// с новыми путями. Кто ещё ходит по старым путям, видно в метриках по route.
+func Deprecated(since, sunset time.Time, docsURL string) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(since.Unix(), 10)
+	sunsetValue := sunset.UTC().Format(http.TimeFormat)
+	link := "<" + docsURL + `>; rel="deprecation"`
+
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		h.Set("Deprecation", deprecation)
+		h.Set("Sunset", sunsetValue)
+		h.Add("Link", link)
+		c.Next()
+	}
+}
@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/idempotency"
+	"github.com/dasler-fw/bookcrossing/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// повторный ответ помечается этим заголовком
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	idempotencyTTL = 24 * time.Hour
+	// сколько держим ключ за выполняющимся запросом; если процесс упал,
+	// ключ освободится сам
+	idempotencyLockTTL   = time.Minute
+	maxIdempotencyKeyLen = 255
+	// тело читается целиком до хендлера, поэтому его размер ограничен; API принимает только JSON
+	maxIdempotentBodyBytes = 1 << 20
+)
+
+// Idempotency сохраняет ответ на первый POST/PUT/PATCH с заголовком Idempotency-Key
+// и возвращает его же на повторы с тем же ключом и телом. Тот же ключ с другим
+// телом — 422, повтор, пока первый запрос ещё выполняется, — 409.
+// Ключи разделены по пользователю (для анонимных запросов — по IP).
+// Ответы 5xx, 409, 412 и 429 не сохраняются: они зависят от момента запроса,
+// и его можно повторить с тем же ключом.
+// Если хранилище недоступно, запрос выполняется без гарантии идемпотентности.
+func Idempotency(store idempotency.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			c.Next()
+			return
+		}
+
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if !validIdempotencyKey(key) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid Idempotency-Key"})
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			body, err = io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodyBytes))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body is too large"})
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		ctx := c.Request.Context()
+		storeKey := ByUserOrIP(c) + ":" + key
+		fingerprint := requestFingerprint(c.Request, body)
+
+		owner, err := newIdempotencyOwner()
+		if err != nil {
+			logging.From(ctx, nil).WarnContext(ctx, "idempotency owner token is not generated, request is not deduplicated", "err", err)
+			c.Next()
+			return
+		}
+		rec, reserved, err := store.Reserve(ctx, storeKey, fingerprint, owner, idempotencyLockTTL)
+		if errors.Is(err, idempotency.ErrInProgress) {
+			rec, reserved, err = idempotency.Record{Fingerprint: fingerprint}, false, nil
+		}
+		if err != nil {
+			logging.From(ctx, nil).WarnContext(ctx, "idempotency store unavailable, request is not deduplicated", "err", err)
+			c.Next()
+			return
+		}
+
+		if !reserved {
+			switch {
+			case rec.Fingerprint != fingerprint:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+			case !rec.Completed():
+				c.Header("Retry-After", "1")
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "request with this Idempotency-Key is still in progress"})
+			default:
+				c.Header(IdempotentReplayedHeader, "true")
+				if rec.Location != "" {
+					c.Header("Location", rec.Location)
+				}
+				c.Data(rec.Status, rec.ContentType, rec.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+
+		// клиент мог отключиться, а результат сохранить всё равно нужно
+		storeCtx := context.WithoutCancel(ctx)
+		saved := false
+		defer func() {
+			// ответ не сохранён или паника в хендлере — освобождаем ключ, чтобы запрос можно было повторить
+			if !saved {
+				_ = store.Release(storeCtx, storeKey, owner)
+			}
+		}()
+
+		c.Next()
+
+		status := w.Status()
+		if !storableStatus(status) {
+			return
+		}
+
+		err = store.Save(storeCtx, storeKey, idempotency.Record{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: w.Header().Get("Content-Type"),
+			Location:    w.Header().Get("Location"),
+			Body:        w.body.Bytes(),
+		}, idempotencyTTL)
+		saved = err == nil
+	}
+}
+
+// storableStatus — ответы, которые можно отдавать на повторы. Конфликт версий,
+// несовпавший If-Match и превышенный лимит через секунду могут смениться успехом
+func storableStatus(status int) bool {
+	switch status {
+	case http.StatusConflict, http.StatusPreconditionFailed, http.StatusTooManyRequests:
+		return false
+	}
+	return status < http.StatusInternalServerError
+}
+
+// validIdempotencyKey — непустая строка печатных ASCII-символов разумной длины
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newIdempotencyOwner — случайный токен, которым запрос помечает занятый ключ
+func newIdempotencyOwner() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter дублирует тело ответа в буфер
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
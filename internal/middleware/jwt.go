@@ -1,13 +1,27 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
 	"github.com/dasler-fw/bookcrossing/internal/jwtutil"
+	"github.com/dasler-fw/bookcrossing/internal/logging"
 	"github.com/gin-gonic/gin"
 )
 
+// AccountCheck сообщает, что аккаунт из токена существует и не удалён
+type AccountCheck func(ctx context.Context, userID uint) (bool, error)
+
+var accountCheck AccountCheck
+
+// SetAccountCheck включает в JWTAuth проверку аккаунта: токен удалённого пользователя
+// подписан верно и ещё не истёк, но приниматься не должен.
+// Вызывается один раз при старте, до того как сервер начнёт принимать запросы
+func SetAccountCheck(check AccountCheck) {
+	accountCheck = check
+}
+
 func JWTAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		auth := c.GetHeader("Authorization")
@@ -28,8 +42,37 @@ func JWTAuth() gin.HandlerFunc {
 			return
 		}
 
+		if accountCheck != nil {
+			ctx := c.Request.Context()
+			exists, err := accountCheck(ctx, claims.UserID)
+			if err != nil {
+				logging.From(ctx, nil).ErrorContext(ctx, "не удалось проверить аккаунт из токена", "user_id", claims.UserID, "err", err)
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "service unavailable"})
+				return
+			}
+			if !exists {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+				return
+			}
+		}
+
 		c.Set("user_id", claims.UserID)
+		// логи сервисов и репозиториев по этому запросу будут содержать user_id
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", claims.UserID))
 
 		c.Next()
 	}
 }
+
+// OptionalJWTAuth — для публичных маршрутов, где ответ зависит от того, кто смотрит:
+// без заголовка запрос идёт анонимно (user_id не выставлен), с заголовком — как JWTAuth
+func OptionalJWTAuth() gin.HandlerFunc {
+	auth := JWTAuth()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		auth(c)
+	}
+}
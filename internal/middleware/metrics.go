@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics пишет латентность запросов по шаблону маршрута (/books/:id),
+// а не по фактическому пути, чтобы не плодить метрики на каждый id.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		metrics.HTTPRequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
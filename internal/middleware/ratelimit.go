@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/jwtutil"
+	"github.com/dasler-fw/bookcrossing/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitRule — одно ограничение: не больше Limit запросов за Window на ключ.
+// Если Key вернул пустую строку, правило к запросу не применяется.
+type RateLimitRule struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+	Key    func(c *gin.Context) string
+}
+
+// ByIP — ключ по адресу клиента
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser — ключ по авторизованному пользователю.
+// Работает и до JWTAuth: токен разбирается, но не проверяется на обязательность.
+func ByUser(c *gin.Context) string {
+	if id := c.GetUint("user_id"); id != 0 {
+		return "user:" + strconv.FormatUint(uint64(id), 10)
+	}
+
+	parts := strings.Split(c.GetHeader("Authorization"), " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return ""
+	}
+	claims, err := jwtutil.ParseToken(parts[1])
+	if err != nil {
+		return ""
+	}
+	return "user:" + strconv.FormatUint(uint64(claims.UserID), 10)
+}
+
+// ByUserOrIP — пользователь, а для анонимных запросов — IP
+func ByUserOrIP(c *gin.Context) string {
+	if key := ByUser(c); key != "" {
+		return key
+	}
+	return ByIP(c)
+}
+
+// ByAccount — ключ по полю email из JSON-тела (для login/forgot-password).
+// Тело восстанавливается, чтобы handler мог прочитать его ещё раз.
+func ByAccount(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<16))
+	if err != nil {
+		return ""
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var payload struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+
+	email := strings.ToLower(strings.TrimSpace(payload.Email))
+	if email == "" {
+		return ""
+	}
+	return "account:" + email
+}
+
+// ByAccountAndIP — email из тела вместе с адресом клиента: попытки с чужого адреса
+// не расходуют лимит владельца аккаунта
+func ByAccountAndIP(c *gin.Context) string {
+	key := ByAccount(c)
+	if key == "" {
+		return ""
+	}
+	return key + "|" + ByIP(c)
+}
+
+// RateLimit проверяет все правила и выставляет заголовки X-RateLimit-*
+// по самому строгому из них. При превышении — 429 и Retry-After.
+// Если лимитер недоступен, запрос пропускается.
+func RateLimit(limiter ratelimit.Limiter, rules ...RateLimitRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var tightest *ratelimit.Result
+
+		for _, rule := range rules {
+			key := rule.Key(c)
+			if key == "" {
+				continue
+			}
+
+			res, err := limiter.Allow(c.Request.Context(), rule.Name+":"+key, rule.Limit, rule.Window)
+			if err != nil {
+				continue
+			}
+
+			if !res.Allowed {
+				setRateLimitHeaders(c, res)
+				c.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+				return
+			}
+
+			if tightest == nil || res.Remaining < tightest.Remaining {
+				r := res
+				tightest = &r
+			}
+		}
+
+		if tightest != nil {
+			setRateLimitHeaders(c, *tightest)
+		}
+
+		c.Next()
+	}
+}
+
+func setRateLimitHeaders(c *gin.Context, res ratelimit.Result) {
+	c.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dasler-fw/bookcrossing/internal/health"
+	"github.com/gin-gonic/gin"
+)
+
+// Ready отвечает 503, пока check возвращает ошибку. Миграции идут в фоне после
+// старта сервера: до их окончания API не обслуживается, а /healthz и /readyz,
+// зарегистрированные раньше, продолжают отвечать. Retry-After и «service is starting»
+// — только пока миграции идут; после сбоя процесс завершается, и ждать нечего
+func Ready(check func(context.Context) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := check(c.Request.Context())
+		switch {
+		case err == nil:
+			c.Next()
+		case health.IsPending(err):
+			c.Header("Retry-After", "5")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "service is starting, try again later"})
+		default:
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "service is unavailable"})
+		}
+	}
+}
@@ -10,7 +10,25 @@ type Book struct {
 	AISummary   string `json:"aisummary"`
 	Status      string `json:"status" gorm:"enum:available,reserved"`
 	UserID      uint   `json:"user_id"`
+	// Version растёт при каждом изменении; по нему строится ETag
+	Version uint `json:"version" gorm:"not null;default:1"`
+	// ModerationStatus описания: approved | held | rejected;
+	// пока описание не одобрено, оно и AI-резюме не показываются
+	ModerationStatus string `json:"moderation_status" gorm:"size:16;not null;default:approved"`
+	// Location — где забрать книгу, если не в городе владельца; Lat и Lon геокодируются из него
+	Location string   `json:"location"`
+	Lat      *float64 `json:"-"`
+	Lon      *float64 `json:"-"`
 
 	User   *User   `json:"user" gorm:"foreignKey:UserID"`
 	Genres []Genre `json:"genres" gorm:"many2many:book_genres"`
+	// Rating — агрегаты отзывов; nil, пока отзывов не было
+	Rating *BookRating `json:"rating,omitempty" gorm:"foreignKey:BookID"`
+}
+
+func (b *Book) BeforeCreate(*gorm.DB) error {
+	if b.Version == 0 {
+		b.Version = 1
+	}
+	return nil
 }
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// DataMigration — отметка о выполненной разовой миграции данных.
+// Пока отметки нет, миграция повторяется при каждом запуске
+type DataMigration struct {
+	Name      string    `gorm:"primaryKey;size:64"`
+	AppliedAt time.Time `gorm:"not null"`
+}
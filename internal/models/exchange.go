@@ -14,6 +14,11 @@ type Exchange struct {
 	RecipientBookID uint       `json:"recipient_book_id"`
 	Status          string     `json:"status" gorm:"enum:pending,accepted,completed,cancelled"`
 	CompletedAt     *time.Time `json:"completed_at"`
+	// CancelledBy — кто отменил обмен (в том числе удалением аккаунта); nil у обменов,
+	// отменённых до появления поля. В репутацию идут только свои отмены
+	CancelledBy *uint `json:"cancelled_by"`
+	// Version растёт при каждом изменении; по нему строится ETag
+	Version uint `json:"version" gorm:"not null;default:1"`
 
 	Initiator *User `json:"initiator" gorm:"foreignKey:InitiatorID"`
 	Recipient *User `json:"recipient" gorm:"foreignKey:RecipientID"`
@@ -21,3 +26,10 @@ type Exchange struct {
 	InitiatorBook *Book `json:"initiator_book" gorm:"foreignKey:InitiatorBookID"`
 	RecipientBook *Book `json:"recipient_book" gorm:"foreignKey:RecipientBookID"`
 }
+
+func (e *Exchange) BeforeCreate(*gorm.DB) error {
+	if e.Version == 0 {
+		e.Version = 1
+	}
+	return nil
+}
@@ -1,9 +1,41 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"github.com/dasler-fw/bookcrossing/internal/slug"
+	"gorm.io/gorm"
+)
 
+// Genre — узел дерева жанров ("Художественная литература > Фантастика > Киберпанк").
+// Name — название на русском, NameEn — на английском.
 type Genre struct {
 	gorm.Model
-	Name  string `json:"name"`
-	Books []Book `json:"books" gorm:"many2many:book_genres"`
+	Name   string `json:"name"`
+	NameEn string `json:"name_en"`
+	// Slug уникален среди неудалённых жанров; у жанров, созданных до его появления,
+	// заполняется при миграции
+	Slug     string `json:"slug" gorm:"size:100;uniqueIndex:idx_genres_slug,where:deleted_at IS NULL"`
+	ParentID *uint  `json:"parent_id" gorm:"index"`
+
+	Parent   *Genre  `json:"-" gorm:"foreignKey:ParentID"`
+	Children []Genre `json:"children,omitempty" gorm:"foreignKey:ParentID"`
+	Books    []Book  `json:"books" gorm:"many2many:book_genres"`
+}
+
+func (g *Genre) BeforeCreate(*gorm.DB) error {
+	// репозиторий подбирает свободный slug сам; здесь — запасной вариант для прямых вставок
+	if g.Slug == "" {
+		g.Slug = g.BaseSlug()
+	}
+	return nil
+}
+
+// BaseSlug — slug из английского названия, а без него — из русского
+func (g *Genre) BaseSlug() string {
+	if s := slug.Make(g.NameEn); s != "" {
+		return s
+	}
+	if s := slug.Make(g.Name); s != "" {
+		return s
+	}
+	return "genre"
 }
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// RatingSummary — материализованные агрегаты по отзывам. Обновляются
+// в одной транзакции с отзывом, поэтому их не нужно пересчитывать при чтении
+type RatingSummary struct {
+	ReviewsCount int64   `json:"reviews_count" gorm:"not null;default:0"`
+	RatingSum    int64   `json:"-" gorm:"not null;default:0"`
+	RatingAvg    float64 `json:"rating_avg" gorm:"not null;default:0;index"`
+	Stars1       int64   `json:"stars_1" gorm:"column:stars_1;not null;default:0"`
+	Stars2       int64   `json:"stars_2" gorm:"column:stars_2;not null;default:0"`
+	Stars3       int64   `json:"stars_3" gorm:"column:stars_3;not null;default:0"`
+	Stars4       int64   `json:"stars_4" gorm:"column:stars_4;not null;default:0"`
+	Stars5       int64   `json:"stars_5" gorm:"column:stars_5;not null;default:0"`
+}
+
+// BookRating — агрегаты отзывов о книге
+type BookRating struct {
+	BookID        uint `json:"-" gorm:"primaryKey;autoIncrement:false"`
+	RatingSummary `gorm:"embedded"`
+	UpdatedAt     time.Time `json:"-"`
+}
+
+// UserRating — агрегаты отзывов о пользователе
+type UserRating struct {
+	UserID        uint `json:"-" gorm:"primaryKey;autoIncrement:false"`
+	RatingSummary `gorm:"embedded"`
+	UpdatedAt     time.Time `json:"-"`
+}
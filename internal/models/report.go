@@ -0,0 +1,21 @@
+package models
+
+import "gorm.io/gorm"
+
+// Типы контента, на который можно пожаловаться
+const (
+	ContentReview = "review"
+	ContentBook   = "book"
+)
+
+// ContentReport — жалоба пользователя на отзыв или описание книги.
+// Открытые жалобы закрываются решением модератора.
+type ContentReport struct {
+	gorm.Model
+	ContentType string `json:"content_type" gorm:"size:16;not null;uniqueIndex:idx_reports_content_reporter,priority:1"`
+	ContentID   uint   `json:"content_id" gorm:"not null;uniqueIndex:idx_reports_content_reporter,priority:2"`
+	ReporterID  uint   `json:"reporter_id" gorm:"not null;uniqueIndex:idx_reports_content_reporter,priority:3"`
+	Reason      string `json:"reason"`
+	// Resolved — модератор принял решение по контенту
+	Resolved bool `json:"resolved" gorm:"not null;default:false;index"`
+}
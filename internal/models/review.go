@@ -1,18 +1,52 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type Review struct {
 	gorm.Model
-	AuthorID     uint   `json:"author_id"`
+	AuthorID     uint   `json:"author_id" gorm:"uniqueIndex:idx_reviews_exchange_author,priority:2,where:deleted_at IS NULL"`
 	TargetUserID uint   `json:"target_user_id"`
 	TargetBookID uint   `json:"target_book_id"`
 	Text         string `json:"text"`
 	Rating       int    `json:"rating"`
+	// ExchangeID — завершённый обмен, по итогам которого оставлен отзыв;
+	// у старых отзывов, написанных до привязки к обменам, пусто.
+	// Один автор — один отзыв на обмен (удалённые не в счёт)
+	ExchangeID *uint `json:"exchange_id" gorm:"uniqueIndex:idx_reviews_exchange_author,priority:1,where:deleted_at IS NULL"`
+
+	Author     *User     `json:"author" gorm:"foreignKey:AuthorID"`
+	TargetUser *User     `json:"target_user" gorm:"foreignKey:TargetUserID"`
+	TargetBook *Book     `json:"target_book" gorm:"foreignKey:TargetBookID"`
+	Exchange   *Exchange `json:"exchange,omitempty" gorm:"foreignKey:ExchangeID"`
+	// ModerationStatus — approved | held | rejected; в списках и рейтингах
+	// учитываются только одобренные отзывы
+	ModerationStatus string `json:"moderation_status" gorm:"size:16;not null;default:approved;index"`
+	// EditedAt — время последней правки; nil, если отзыв не меняли
+	EditedAt *time.Time   `json:"edited_at"`
+	Reply    *ReviewReply `json:"reply,omitempty" gorm:"foreignKey:ReviewID"`
+}
 
-	Author     *User `json:"author" gorm:"foreignKey:AuthorID"`
-	TargetUser *User `json:"target_user" gorm:"foreignKey:TargetUserID"`
-	TargetBook *Book `json:"target_book" gorm:"foreignKey:TargetBookID"`
+// ReviewRevision — прежняя редакция отзыва; пишется при каждой правке
+type ReviewRevision struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	ReviewID uint   `json:"review_id" gorm:"not null;index"`
+	Text     string `json:"text"`
+	Rating   int    `json:"rating"`
+	// ModerationStatus — статус, который был у этого текста; скрытые
+	// редакции видят только автор и модераторы
+	ModerationStatus string `json:"-" gorm:"size:16;not null;default:approved"`
+	// ReplacedAt — когда эту редакцию заменили новой
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// ReviewReply — публичный ответ пользователя, о котором отзыв; один на отзыв
+type ReviewReply struct {
+	gorm.Model
+	ReviewID uint   `json:"review_id" gorm:"not null;uniqueIndex"`
+	AuthorID uint   `json:"author_id"`
+	Text     string `json:"text"`
 }
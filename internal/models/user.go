@@ -19,4 +19,55 @@ type User struct {
 	PasswordHash string `json:"-"`
 	City         string `json:"city"`
 	Address      string `json:"address"`
+	// Lat, Lon — координаты города от геокодера (адрес не геокодируется); nil, если город не найден
+	Lat *float64 `json:"-"`
+	Lon *float64 `json:"-"`
+	// Язык писем: ru | en
+	Language string `json:"language" gorm:"size:2;not null;default:ru"`
+	// nil, пока пользователь не подтвердил email
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// Version растёт при каждом изменении; по нему строится ETag
+	Version uint `json:"version" gorm:"not null;default:1"`
+	// Role — user, moderator или admin; модераторы и админы назначаются вручную в базе
+	Role string `json:"-" gorm:"size:16;not null;default:user"`
+
+	// Видимость полей профиля для других пользователей: public | partners | private.
+	// partners — участники принятого обмена с этим пользователем
+	EmailVisibility   string `json:"-" gorm:"size:16;not null;default:private"`
+	AddressVisibility string `json:"-" gorm:"size:16;not null;default:partners"`
+	CityVisibility    string `json:"-" gorm:"size:16;not null;default:public"`
+
+	// PurgedAt — когда у удалённого аккаунта стёрты персональные данные.
+	// Строка остаётся, чтобы обмены и отзывы других пользователей не потеряли ссылки
+	PurgedAt *time.Time `json:"-"`
+}
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	// RoleAdmin управляет справочниками (слияние жанров) и может всё, что модератор
+	RoleAdmin = "admin"
+)
+
+const (
+	VisibilityPublic   = "public"
+	VisibilityPartners = "partners"
+	VisibilityPrivate  = "private"
+)
+
+func (u *User) BeforeCreate(*gorm.DB) error {
+	if u.Version == 0 {
+		u.Version = 1
+	}
+	// gorm не подставляет default тега в структуру, а ответы строятся из неё
+	if u.EmailVisibility == "" {
+		u.EmailVisibility = VisibilityPrivate
+	}
+	if u.AddressVisibility == "" {
+		u.AddressVisibility = VisibilityPartners
+	}
+	if u.CityVisibility == "" {
+		u.CityVisibility = VisibilityPublic
+	}
+	return nil
 }
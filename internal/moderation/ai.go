@@ -0,0 +1,86 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// AIChecker — внешний классификатор с API в формате OpenAI Moderation:
+// POST {"input": "..."} → {"results": [{"flagged": true, "categories": {"harassment": true}}]}
+type AIChecker struct {
+	url     string
+	apiKey  string
+	timeout time.Duration
+	client  *http.Client
+}
+
+func NewAIChecker(url, apiKey string, timeout time.Duration) *AIChecker {
+	return &AIChecker{
+		url:     url,
+		apiKey:  apiKey,
+		timeout: timeout,
+		client:  &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
+	}
+}
+
+type aiModerationResponse struct {
+	Results []struct {
+		Flagged    bool            `json:"flagged"`
+		Categories map[string]bool `json:"categories"`
+	} `json:"results"`
+}
+
+func (c *AIChecker) Check(ctx context.Context, text string) (Verdict, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	body, _ := json.Marshal(map[string]string{"input": text})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1024))
+		return Verdict{}, fmt.Errorf("moderation api: unexpected status %d", resp.StatusCode)
+	}
+
+	var result aiModerationResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&result); err != nil {
+		return Verdict{}, fmt.Errorf("moderation api: decode response: %w", err)
+	}
+
+	var v Verdict
+	for _, r := range result.Results {
+		if !r.Flagged {
+			continue
+		}
+		v.Flagged = true
+		for category, hit := range r.Categories {
+			if hit {
+				v.Reasons = append(v.Reasons, "ai:"+category)
+			}
+		}
+	}
+	sort.Strings(v.Reasons)
+	if v.Flagged && len(v.Reasons) == 0 {
+		v.Reasons = []string{"ai"}
+	}
+	return v, nil
+}
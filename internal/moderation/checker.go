@@ -0,0 +1,97 @@
+// Package moderation проверяет пользовательский текст (отзывы, описания книг)
+// перед публикацией. По умолчанию работает локальный словарь с регулярными
+// выражениями для персональных данных; AI-классификатор подключается ключом.
+package moderation
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/dasler-fw/bookcrossing/internal/logging"
+	"github.com/dasler-fw/bookcrossing/internal/metrics"
+)
+
+// Статусы модерации контента
+const (
+	// StatusApproved — опубликован
+	StatusApproved = "approved"
+	// StatusHeld — скрыт до решения модератора: сработала проверка или набралось жалоб
+	StatusHeld = "held"
+	// StatusRejected — скрыт модератором
+	StatusRejected = "rejected"
+)
+
+// Verdict — результат проверки. Reasons — коды причин: profanity, email, phone, card, ai:<категория>
+type Verdict struct {
+	Flagged bool
+	Reasons []string
+}
+
+// Status — статус, с которым контент сохраняется после проверки
+func (v Verdict) Status() string {
+	if v.Flagged {
+		return StatusHeld
+	}
+	return StatusApproved
+}
+
+// NextStatus — статус контента после создания или правки с текущим статусом current.
+// Сработавшая проверка скрывает контент. Правка отклонённого модератором контента
+// возвращает его на проверку, а не публикует сразу; скрытый остаётся скрытым до решения.
+func NextStatus(current string, v Verdict) string {
+	if v.Flagged || current == StatusRejected {
+		return StatusHeld
+	}
+	if current == "" {
+		return StatusApproved
+	}
+	return current
+}
+
+// Checker проверяет текст. Реализации: WordlistChecker, AIChecker, Chain.
+type Checker interface {
+	Check(ctx context.Context, text string) (Verdict, error)
+}
+
+// Chain запускает проверки по очереди и объединяет причины.
+// Ошибка проверки (например, недоступен AI) не блокирует публикацию:
+// она пишется в лог, а решение принимают остальные проверки.
+type Chain struct {
+	checkers []namedChecker
+	log      *slog.Logger
+}
+
+type namedChecker struct {
+	name string
+	Checker
+}
+
+func NewChain(log *slog.Logger) *Chain {
+	return &Chain{log: log}
+}
+
+// Add добавляет проверку; name попадает в логи и метрики
+func (c *Chain) Add(name string, checker Checker) *Chain {
+	c.checkers = append(c.checkers, namedChecker{name: name, Checker: checker})
+	return c
+}
+
+func (c *Chain) Check(ctx context.Context, text string) (Verdict, error) {
+	var result Verdict
+	for _, checker := range c.checkers {
+		v, err := checker.Check(ctx, text)
+		if err != nil {
+			metrics.ModerationChecks.WithLabelValues(checker.name, "error").Inc()
+			logging.From(ctx, c.log).WarnContext(ctx, "moderation check failed, skipping", "checker", checker.name, "err", err)
+			continue
+		}
+		if !v.Flagged {
+			metrics.ModerationChecks.WithLabelValues(checker.name, "clean").Inc()
+			continue
+		}
+		metrics.ModerationChecks.WithLabelValues(checker.name, "flagged").Inc()
+		result.Flagged = true
+		result.Reasons = append(result.Reasons, v.Reasons...)
+	}
+	return result, nil
+}
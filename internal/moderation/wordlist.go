@@ -0,0 +1,111 @@
+package moderation
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// DefaultWords — базовый словарь нецензурной лексики. "слово*" совпадает по префиксу,
+// остальные — целым словом. Словарь дополняется через MODERATION_EXTRA_WORDS.
+// Слов, которые бывают фамилиями и частью названий книг (dick — Филип К. Дик, «Моби Дик»),
+// здесь нет: без контекста их не отличить от ругани.
+var DefaultWords = []string{
+	"хуй*", "хуе*", "хуи*", "пизд*", "ебат*", "ебан*", "ебал*", "ебу*", "выеб*", "заеб*",
+	"бля", "блядь*", "бляд*", "сука", "суки", "сучк*", "мудак*", "мудил*", "гандон*", "пидор*", "пидар*",
+	"fuck*", "shit*", "bitch*", "cunt*", "asshole*", "motherfuck*",
+}
+
+var (
+	emailRe = regexp.MustCompile(`[\p{L}\d._%+-]+@[\p{L}\d-]+(?:\.[\p{L}\d-]+)+`)
+	// телефоны: +7/8 и одиннадцать цифр с разделителями или международный формат
+	phoneRe = regexp.MustCompile(`(?:\+7|\b8)[\s(-]*\d{3}[\s)-]*\d{3}[\s-]*\d{2}[\s-]*\d{2}\b|\+\d{1,3}[\s(-]*\d{2,4}[\s)-]*\d{3}[\s-]*\d{2,4}[\s-]*\d{0,4}\b`)
+	cardRe  = regexp.MustCompile(`\b\d{4}[ -]?\d{4}[ -]?\d{4}[ -]?\d{4}\b`)
+)
+
+// WordlistChecker ищет запрещённые слова и персональные данные (email, телефоны,
+// номера карт). Работает локально и не возвращает ошибок.
+type WordlistChecker struct {
+	words    map[string]struct{}
+	prefixes []string
+}
+
+func NewWordlistChecker(words []string) *WordlistChecker {
+	c := &WordlistChecker{words: map[string]struct{}{}}
+	for _, w := range words {
+		w = normalizeWord(strings.TrimSpace(w))
+		switch {
+		case w == "" || w == "*":
+		case strings.HasSuffix(w, "*"):
+			c.prefixes = append(c.prefixes, strings.TrimSuffix(w, "*"))
+		default:
+			c.words[w] = struct{}{}
+		}
+	}
+	return c
+}
+
+func (c *WordlistChecker) Check(_ context.Context, text string) (Verdict, error) {
+	var v Verdict
+	if c.hasBadWord(text) {
+		v.Reasons = append(v.Reasons, "profanity")
+	}
+	if emailRe.MatchString(text) {
+		v.Reasons = append(v.Reasons, "email")
+	}
+	if phoneRe.MatchString(text) {
+		v.Reasons = append(v.Reasons, "phone")
+	}
+	for _, m := range cardRe.FindAllString(text, -1) {
+		if luhnValid(m) {
+			v.Reasons = append(v.Reasons, "card")
+			break
+		}
+	}
+	v.Flagged = len(v.Reasons) > 0
+	return v, nil
+}
+
+func (c *WordlistChecker) hasBadWord(text string) bool {
+	words := strings.FieldsFunc(normalizeWord(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, w := range words {
+		if _, ok := c.words[w]; ok {
+			return true
+		}
+		for _, p := range c.prefixes {
+			if strings.HasPrefix(w, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeWord приводит к нижнему регистру и заменяет ё на е
+func normalizeWord(s string) string {
+	return strings.ReplaceAll(strings.ToLower(s), "ё", "е")
+}
+
+// luhnValid отсекает случайные 16-значные числа, которые не могут быть номером карты
+func luhnValid(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		ch := number[i]
+		if ch < '0' || ch > '9' {
+			continue
+		}
+		d := int(ch - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,21 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Result — решение лимитера для одного запроса
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// через сколько освободится самый старый слот окна
+	// (для Retry-After и X-RateLimit-Reset)
+	RetryAfter time.Duration
+}
+
+// Limiter считает запросы в скользящем окне по произвольному ключу
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error)
+}
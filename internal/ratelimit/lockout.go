@@ -0,0 +1,176 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LockoutPolicy — прогрессивная блокировка входа.
+// После Threshold неудачных попыток аккаунт блокируется на BaseLock,
+// каждая следующая неудача удваивает блокировку, но не больше MaxLock.
+type LockoutPolicy struct {
+	Threshold int
+	BaseLock  time.Duration
+	MaxLock   time.Duration
+	// сколько помним неудачные попытки
+	FailureTTL time.Duration
+}
+
+var DefaultLockoutPolicy = LockoutPolicy{
+	Threshold:  5,
+	BaseLock:   time.Minute,
+	MaxLock:    time.Hour,
+	FailureTTL: 24 * time.Hour,
+}
+
+// lockFor считает длительность блокировки после failures неудач
+func (p LockoutPolicy) lockFor(failures int) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+	lock := p.BaseLock
+	for i := p.Threshold; i < failures && lock < p.MaxLock; i++ {
+		lock *= 2
+	}
+	if lock > p.MaxLock {
+		lock = p.MaxLock
+	}
+	return lock
+}
+
+// LoginGuard отслеживает неудачные входы по ключу; сервис пользователей передаёт пару email + IP
+type LoginGuard interface {
+	// Locked возвращает оставшееся время блокировки (0 — не заблокирован)
+	Locked(ctx context.Context, account string) (time.Duration, error)
+	// Fail регистрирует неудачу и возвращает наложенную блокировку
+	Fail(ctx context.Context, account string) (time.Duration, error)
+	// Reset сбрасывает счётчик после успешного входа
+	Reset(ctx context.Context, account string) error
+}
+
+func normalizeAccount(account string) string {
+	return strings.ToLower(strings.TrimSpace(account))
+}
+
+type RedisLoginGuard struct {
+	rdb    *redis.Client
+	policy LockoutPolicy
+	log    *slog.Logger
+}
+
+func NewRedisLoginGuard(rdb *redis.Client, policy LockoutPolicy, log *slog.Logger) *RedisLoginGuard {
+	return &RedisLoginGuard{rdb: rdb, policy: policy, log: log}
+}
+
+func failKey(account string) string { return "login:fail:" + normalizeAccount(account) }
+func lockKey(account string) string { return "login:lock:" + normalizeAccount(account) }
+
+func (g *RedisLoginGuard) Locked(ctx context.Context, account string) (time.Duration, error) {
+	ttl, err := g.rdb.PTTL(ctx, lockKey(account)).Result()
+	if err != nil {
+		g.log.Error("login guard unavailable", "err", err)
+		return 0, err
+	}
+	// -2: ключа нет, -1: ключ без TTL (не должно случаться)
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (g *RedisLoginGuard) Fail(ctx context.Context, account string) (time.Duration, error) {
+	pipe := g.rdb.TxPipeline()
+	incr := pipe.Incr(ctx, failKey(account))
+	pipe.Expire(ctx, failKey(account), g.policy.FailureTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		g.log.Error("login guard unavailable", "err", err)
+		return 0, err
+	}
+
+	lock := g.policy.lockFor(int(incr.Val()))
+	if lock == 0 {
+		return 0, nil
+	}
+
+	if err := g.rdb.Set(ctx, lockKey(account), 1, lock).Err(); err != nil {
+		g.log.Error("login guard unavailable", "err", err)
+		return 0, err
+	}
+	return lock, nil
+}
+
+func (g *RedisLoginGuard) Reset(ctx context.Context, account string) error {
+	err := g.rdb.Del(ctx, failKey(account), lockKey(account)).Err()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		g.log.Error("login guard unavailable", "err", err)
+		return err
+	}
+	return nil
+}
+
+type memoryAttempts struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// MemoryLoginGuard — LoginGuard в памяти процесса
+type MemoryLoginGuard struct {
+	mu       sync.Mutex
+	policy   LockoutPolicy
+	accounts map[string]*memoryAttempts
+}
+
+func NewMemoryLoginGuard(policy LockoutPolicy) *MemoryLoginGuard {
+	return &MemoryLoginGuard{policy: policy, accounts: make(map[string]*memoryAttempts)}
+}
+
+func (g *MemoryLoginGuard) Locked(_ context.Context, account string) (time.Duration, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	a, ok := g.accounts[normalizeAccount(account)]
+	if !ok {
+		return 0, nil
+	}
+	if left := time.Until(a.lockedUntil); left > 0 {
+		return left, nil
+	}
+	return 0, nil
+}
+
+func (g *MemoryLoginGuard) Fail(_ context.Context, account string) (time.Duration, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := normalizeAccount(account)
+	now := time.Now()
+
+	a, ok := g.accounts[key]
+	if !ok || now.Sub(a.lastFailure) > g.policy.FailureTTL {
+		a = &memoryAttempts{}
+		g.accounts[key] = a
+	}
+	a.failures++
+	a.lastFailure = now
+
+	lock := g.policy.lockFor(a.failures)
+	if lock > 0 {
+		a.lockedUntil = now.Add(lock)
+	}
+	return lock, nil
+}
+
+func (g *MemoryLoginGuard) Reset(_ context.Context, account string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.accounts, normalizeAccount(account))
+	return nil
+}
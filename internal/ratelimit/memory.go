@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryLimiter — скользящее окно в памяти процесса.
+// Подходит для тестов и запуска в одну реплику без Redis.
+type MemoryLimiter struct {
+	mu   sync.Mutex
+	hits map[string][]time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{hits: make(map[string][]time.Time)}
+}
+
+func (l *MemoryLimiter) Allow(_ context.Context, key string, limit int, window time.Duration) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-window)
+
+	hits := l.hits[key]
+	i := 0
+	for i < len(hits) && !hits[i].After(cutoff) {
+		i++
+	}
+	hits = hits[i:]
+
+	if len(hits) >= limit {
+		l.hits[key] = hits
+		return Result{
+			Allowed:    false,
+			Limit:      limit,
+			Remaining:  0,
+			RetryAfter: hits[0].Add(window).Sub(now),
+		}, nil
+	}
+
+	hits = append(hits, now)
+	l.hits[key] = hits
+
+	return Result{
+		Allowed:    true,
+		Limit:      limit,
+		Remaining:  limit - len(hits),
+		RetryAfter: hits[0].Add(window).Sub(now),
+	}, nil
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindow — скользящее окно на sorted set: score = время запроса в мс.
+// Возвращает {allowed, remaining, reset_ms}: reset — через сколько освободится
+// самый старый слот окна.
+var slidingWindow = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local member = ARGV[4]
+
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+local count = redis.call('ZCARD', key)
+
+local allowed = 0
+local remaining = 0
+if count < limit then
+	redis.call('ZADD', key, now, member)
+	redis.call('PEXPIRE', key, window)
+	allowed = 1
+	remaining = limit - count - 1
+end
+
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+local reset = window
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, remaining, reset}
+`)
+
+type RedisLimiter struct {
+	rdb *redis.Client
+	log *slog.Logger
+}
+
+func NewRedisLimiter(rdb *redis.Client, log *slog.Logger) *RedisLimiter {
+	return &RedisLimiter{rdb: rdb, log: log}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	now := time.Now().UnixMilli()
+	member := fmt.Sprintf("%d-%d", now, rand.Uint64())
+
+	res, err := slidingWindow.Run(ctx, l.rdb, []string{"ratelimit:" + key},
+		now, window.Milliseconds(), limit, member).Int64Slice()
+	if err != nil {
+		l.log.Error("rate limiter unavailable", "key", key, "err", err)
+		return Result{}, err
+	}
+
+	return Result{
+		Allowed:    res[0] == 1,
+		Limit:      limit,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+)
+
+// BookPage — страница поиска книг
+type BookPage struct {
+	Books []models.Book
+	// Total — число найденных книг; nil при count=none
+	Total *int64
+	// TotalEstimated — Total взят из оценки планировщика, а не из COUNT
+	TotalEstimated bool
+	// NextCursor — курсор следующей страницы; пустой, если страница последняя
+	NextCursor string
+	// Distances — расстояние в км от точки near до книги по id; только в поиске рядом
+	Distances map[uint]float64
+}
+
+// bookCursor — позиция последней книги страницы: значение поля сортировки и id.
+// Клиенту отдаётся как непрозрачный base64-токен.
+type bookCursor struct {
+	SortBy    string          `json:"s"`
+	SortOrder string          `json:"o"`
+	Value     json.RawMessage `json:"v"`
+	ID        uint            `json:"id"`
+	// Near — точка поиска рядом: расстояния из курсора верны только для неё
+	Near string `json:"n,omitempty"`
+}
+
+// distanceSq нужен только для сортировки по расстоянию
+func encodeBookCursor(sortBy, sortOrder, near string, b models.Book, distanceSq float64) string {
+	var value any
+	switch sortBy {
+	case "title":
+		value = b.Title
+	case "rating":
+		value = 0.0
+		if b.Rating != nil {
+			value = b.Rating.RatingAvg
+		}
+	case "reviews":
+		value = int64(0)
+		if b.Rating != nil {
+			value = b.Rating.ReviewsCount
+		}
+	case "distance":
+		value = distanceSq
+	default:
+		value = b.CreatedAt
+	}
+	raw, _ := json.Marshal(value)
+	token, _ := json.Marshal(bookCursor{SortBy: sortBy, SortOrder: sortOrder, Value: raw, ID: b.ID, Near: near})
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// decodeBookCursor проверяет, что курсор выдан для той же сортировки и точки near,
+// и возвращает значение поля сортировки в типе колонки
+func decodeBookCursor(token, sortBy, sortOrder, near string) (any, uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, 0, dto.ErrInvalidCursor
+	}
+	var c bookCursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == 0 {
+		return nil, 0, dto.ErrInvalidCursor
+	}
+	if c.SortBy != sortBy || c.SortOrder != sortOrder || c.Near != near {
+		return nil, 0, dto.ErrInvalidCursor
+	}
+
+	var value any
+	switch sortBy {
+	case "title":
+		var v string
+		err = json.Unmarshal(c.Value, &v)
+		value = v
+	case "rating", "distance":
+		var v float64
+		err = json.Unmarshal(c.Value, &v)
+		value = v
+	case "reviews":
+		var v int64
+		err = json.Unmarshal(c.Value, &v)
+		value = v
+	default:
+		var v time.Time
+		err = json.Unmarshal(c.Value, &v)
+		value = v
+	}
+	if err != nil {
+		return nil, 0, dto.ErrInvalidCursor
+	}
+	return value, c.ID, nil
+}
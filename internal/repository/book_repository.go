@@ -1,25 +1,35 @@
 package repository
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/geo"
+	"github.com/dasler-fw/bookcrossing/internal/logging"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"gorm.io/gorm"
 )
 
 type BookRepository interface {
-	Create(req *models.Book) error
-	GetByID(id uint) (*models.Book, error)
-	Update(book *models.Book) error
-	Delete(id uint) error
-	Search(query dto.BookListQuery) ([]models.Book, int64, error)
-	AttachGenres(bookID uint, genreIDs []uint) error
-	GetByUserID(userID uint, status string) ([]models.Book, error)
-	GetAvailable(city string) ([]models.Book, error)
+	Create(ctx context.Context, req *models.Book) error
+	GetByID(ctx context.Context, id uint) (*models.Book, error)
+	Update(ctx context.Context, book *models.Book) error
+	Delete(ctx context.Context, id uint) error
+	// Search отдаёт страницу по page или по курсору из query.Cursor;
+	// total считается в режиме query.Count
+	Search(ctx context.Context, query dto.BookListQuery) (*BookPage, error)
+	AttachGenres(ctx context.Context, bookID uint, genreIDs []uint) error
+	// Facets считает книги по значениям полей fields под теми же фильтрами, что и Search
+	Facets(ctx context.Context, query dto.BookListQuery, fields []string) (map[string][]dto.FacetValue, error)
+	GetByUserID(ctx context.Context, userID uint, status string) ([]models.Book, error)
+	GetAvailable(ctx context.Context, city string) ([]models.Book, error)
 }
 
 type bookRepository struct {
@@ -34,19 +44,19 @@ func NewBookRepository(db *gorm.DB, log *slog.Logger) BookRepository {
 	}
 }
 
-func (r *bookRepository) Create(req *models.Book) error {
+func (r *bookRepository) Create(ctx context.Context, req *models.Book) error {
 	if req == nil {
-		r.log.Error("error in Create function book_repository.go")
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in Create function book_repository.go")
 		return dto.ErrBookCreateFailed
 	}
 
-	return r.db.Create(req).Error
+	return r.db.WithContext(ctx).Create(req).Error
 }
 
 
-func (r *bookRepository) GetByID(id uint) (*models.Book, error) {
+func (r *bookRepository) GetByID(ctx context.Context, id uint) (*models.Book, error) {
 	var book models.Book
-	err := r.db.Preload("User").Preload("Genres").First(&book, id).Error
+	err := r.db.WithContext(ctx).Preload("User").Preload("Genres").Preload("Rating").First(&book, id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, dto.ErrorBookNotFound
@@ -56,66 +66,56 @@ func (r *bookRepository) GetByID(id uint) (*models.Book, error) {
 	return &book, nil
 }
 
-func (r *bookRepository) GetList() ([]models.Book, error) {
+func (r *bookRepository) GetList(ctx context.Context) ([]models.Book, error) {
 	var list []models.Book
-	if err := r.db.Preload("Genres").Find(&list).Error; err != nil {
-		r.log.Error("error in List function book_repository.go")
+	if err := r.db.WithContext(ctx).Preload("Genres").Find(&list).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in List function book_repository.go")
 		return nil, err
 	}
 
 	return list, nil
 }
 
-func (r *bookRepository) Update(book *models.Book) error {
+func (r *bookRepository) Update(ctx context.Context, book *models.Book) error {
 	if book == nil {
-		r.log.Error("error in Update function book_repository.go")
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in Update function book_repository.go")
 		return dto.ErrBookUpdateFailed
 	}
 
-	return r.db.Save(book).Error
+	return updateVersioned(r.db.WithContext(ctx), book, &book.Version)
 }
 
-func (r *bookRepository) Delete(id uint) error {
-	if err := r.db.Delete(&models.Book{}, id).Error; err != nil {
-		r.log.Error("error in Delete function book_repository.go")
+func (r *bookRepository) Delete(ctx context.Context, id uint) error {
+	if err := r.db.WithContext(ctx).Delete(&models.Book{}, id).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in Delete function book_repository.go")
 		return dto.ErrBookDeleteFailed
 	}
 
 	return nil
 }
 
-func (r *bookRepository) Search(query dto.BookListQuery) ([]models.Book, int64, error) {
-	db := r.db.Model(&models.Book{})
-
-	if query.GenreID != nil {
-		db = db.Joins("JOIN book_genres bg ON bg.book_id = books.id").
-			Where("bg.genre_id = ?", *query.GenreID)
-	}
-
-	if query.City != "" {
-		db = db.Joins("JOIN users u ON u.id = books.user_id").
-			Where("u.city ILIKE ?", "%"+query.City+"%")
-	}
-
-	if query.Author != "" {
-		db = db.Where("books.author ILIKE ?", "%"+query.Author+"%")
-	}
-
-	if query.Title != "" {
-		db = db.Where("books.title ILIKE ?", "%"+query.Title+"%")
-	}
-
-	if query.Status != "" {
-		db = db.Where("books.status = ?", query.Status)
+func (r *bookRepository) Search(ctx context.Context, query dto.BookListQuery) (*BookPage, error) {
+	db, err := r.searchScope(ctx, query)
+	if err != nil {
+		return nil, err
 	}
 
-	var total int64
-	countQuery := db.Session(&gorm.Session{}).
-		Select("COUNT(DISTINCT books.id)")
-
-	if err := countQuery.Scan(&total).Error; err != nil {
-		r.log.Error("ошибка считывании книг", "err", err)
-		return nil, 0, err
+	page := &BookPage{}
+	switch query.Count {
+	case dto.CountNone:
+	case dto.CountEstimate:
+		total, estimated, err := r.estimateTotal(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		page.Total, page.TotalEstimated = &total, estimated
+	default:
+		total, err := countDistinctBooks(db)
+		if err != nil {
+			logging.From(ctx, r.log).ErrorContext(ctx, "ошибка считывании книг", "err", err)
+			return nil, err
+		}
+		page.Total = &total
 	}
 
 	sortBy := strings.ToLower(strings.TrimSpace(query.SortBy))
@@ -124,59 +124,297 @@ func (r *bookRepository) Search(query dto.BookListQuery) ([]models.Book, int64,
 	validSortFields := map[string]string{
 		"title":      "books.title",
 		"created_at": "books.created_at",
+		// у книг без отзывов строки в book_ratings нет — считаем нулём
+		"rating":  "COALESCE(br.rating_avg, 0)",
+		"reviews": "COALESCE(br.reviews_count, 0)",
+	}
+
+	distance, near := "", ""
+	if query.Origin != nil {
+		distance, near = distanceSqExpr(*query.Origin), query.Origin.String()
+		validSortFields["distance"] = distance
 	}
 
 	sortField, ok := validSortFields[sortBy]
 	if !ok {
-		sortField = "books.created_at"
+		sortBy, sortField = "created_at", "books.created_at"
 	}
-
-	validOrders := map[string]string{
-		"asc":  "ASC",
-		"desc": "DESC",
+	if sortBy == "rating" || sortBy == "reviews" {
+		db = db.Joins("LEFT JOIN book_ratings br ON br.book_id = books.id")
 	}
 
-	order, ok := validOrders[sortOrder]
-	if !ok {
-		order = "DESC"
+	order, cmp := "DESC", "<"
+	if sortOrder == "asc" {
+		order, cmp = "ASC", ">"
+	} else {
+		sortOrder = "desc"
 	}
 
 	offset := (query.Page - 1) * query.Limit
+	if query.Cursor != "" {
+		// keyset: строки строго после последней книги прошлой страницы в порядке (поле, id)
+		value, lastID, err := decodeBookCursor(query.Cursor, sortBy, sortOrder, near)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where(fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND books.id %[2]s ?))", sortField, cmp), value, value, lastID)
+		offset = 0
+	}
+
+	// при равных значениях (например, у книг без отзывов) порядок задаёт id,
+	// иначе страницы могут пересекаться
+	orderBy := sortField + " " + order + ", books.id " + order
 
 	var books []models.Book
 
+	// лишняя строка показывает, есть ли следующая страница
 	subQuery := db.Session(&gorm.Session{}).
 		Select("books.id", sortField).
 		Distinct("books.id", sortField).
-		Order(sortField + " " + order).
-		Limit(query.Limit).
+		Order(orderBy).
+		Limit(query.Limit + 1).
 		Offset(offset)
 
 	if err := db.Where("books.id IN (SELECT books.id FROM (?) AS sorted_books)", subQuery).
 		Preload("Genres").
 		Preload("User").
-		Order(sortField + " " + order).
+		Preload("Rating").
+		Order(orderBy).
 		Find(&books).Error; err != nil {
-		r.log.Error("ошибка при поиске книг", "err", err)
-		return nil, 0, err
+		logging.From(ctx, r.log).ErrorContext(ctx, "ошибка при поиске книг", "err", err)
+		return nil, err
+	}
+
+	var distancesSq map[uint]float64
+	if distance != "" {
+		if distancesSq, err = r.distancesSq(ctx, books, distance); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(books) > query.Limit {
+		books = books[:query.Limit]
+		last := books[len(books)-1]
+		page.NextCursor = encodeBookCursor(sortBy, sortOrder, near, last, distancesSq[last.ID])
+	}
+	page.Books = books
+	if distancesSq != nil {
+		page.Distances = make(map[uint]float64, len(books))
+		for _, b := range books {
+			page.Distances[b.ID] = math.Sqrt(distancesSq[b.ID])
+		}
+	}
+	return page, nil
+}
+
+// distancesSq считает тем же выражением, что и сортировка, квадраты расстояний до найденных книг.
+// Отдельным запросом, потому что у модели Book нет колонки для вычисляемого поля.
+func (r *bookRepository) distancesSq(ctx context.Context, books []models.Book, distance string) (map[uint]float64, error) {
+	ids := make([]uint, 0, len(books))
+	for _, b := range books {
+		ids = append(ids, b.ID)
+	}
+
+	var rows []struct {
+		ID         uint
+		DistanceSq float64
+	}
+	if len(ids) > 0 {
+		if err := r.db.WithContext(ctx).Table("books").
+			Joins(joinPublicOwner("LEFT JOIN", "gu")).
+			Where("books.id IN ?", ids).
+			Select("books.id AS id, " + distance + " AS distance_sq").
+			Scan(&rows).Error; err != nil {
+			logging.From(ctx, r.log).ErrorContext(ctx, "ошибка расчёта расстояний до книг", "err", err)
+			return nil, err
+		}
+	}
+
+	distances := make(map[uint]float64, len(rows))
+	for _, row := range rows {
+		distances[row.ID] = row.DistanceSq
+	}
+	return distances, nil
+}
+
+// EnsureBookSearchIndexes создаёт индексы (поле сортировки, id) для курсорной пагинации.
+// CreatedAt приходит из gorm.Model, поэтому индекс нельзя объявить тегом.
+func EnsureBookSearchIndexes(ctx context.Context, db *gorm.DB) error {
+	for _, stmt := range []string{
+		"CREATE INDEX IF NOT EXISTS idx_books_created_at_id ON books (created_at, id)",
+		"CREATE INDEX IF NOT EXISTS idx_books_title_id ON books (title, id)",
+	} {
+		if err := db.WithContext(ctx).Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func countDistinctBooks(db *gorm.DB) (int64, error) {
+	var total int64
+	err := db.Session(&gorm.Session{}).Select("COUNT(DISTINCT books.id)").Scan(&total).Error
+	return total, err
+}
+
+// estimateTotal берёт число строк из плана запроса Postgres — это не требует
+// обходить все подходящие книги. Другие базы считают точно.
+func (r *bookRepository) estimateTotal(ctx context.Context, db *gorm.DB) (int64, bool, error) {
+	if db.Dialector.Name() != "postgres" {
+		total, err := countDistinctBooks(db)
+		return total, false, err
 	}
 
-	return books, total, nil
+	var ids []uint
+	stmt := db.Session(&gorm.Session{DryRun: true}).Distinct("books.id").Find(&ids).Statement
+
+	// SQL уже с плейсхолдерами Postgres ($1…), поэтому идёт в пул напрямую, минуя Raw
+	var plan string
+	if err := stmt.ConnPool.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+stmt.SQL.String(), stmt.Vars...).Scan(&plan); err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "ошибка оценки числа книг", "err", err)
+		return 0, false, err
+	}
+	var parsed []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(plan), &parsed); err != nil {
+		return 0, false, fmt.Errorf("parse query plan: %w", err)
+	}
+	if len(parsed) == 0 {
+		return 0, false, errors.New("parse query plan: empty plan")
+	}
+	return int64(parsed[0].Plan.Rows), true, nil
 }
 
-func (r *bookRepository) AttachGenres(bookID uint, genreIDs []uint) error {
+// searchScope — книги под фильтрами BookListQuery, общие для страницы и фасетов
+func (r *bookRepository) searchScope(ctx context.Context, query dto.BookListQuery) (*gorm.DB, error) {
+	db := r.db.WithContext(ctx).Model(&models.Book{})
+
+	if query.GenreID != nil {
+		// жанр подходит вместе со всеми поджанрами
+		genreIDs, err := genreSubtree(r.db.WithContext(ctx), *query.GenreID)
+		if err != nil {
+			logging.From(ctx, r.log).ErrorContext(ctx, "ошибка поиска поджанров", "genre_id", *query.GenreID, "err", err)
+			return nil, err
+		}
+		db = db.Where("books.id IN (?)",
+			r.db.Table("book_genres").Select("book_id").Where("genre_id IN ?", genreIDs))
+	}
+
+	if query.City != "" {
+		db = db.Joins(joinPublicOwner("JOIN", "u")).
+			Where(containsFold("u.city"), likePattern(query.City))
+	}
+
+	if query.Author != "" {
+		db = db.Where(containsFold("books.author"), likePattern(query.Author))
+	}
+
+	if query.Title != "" {
+		db = db.Where(containsFold("books.title"), likePattern(query.Title))
+	}
+
+	if query.Status != "" {
+		db = db.Where("books.status = ?", query.Status)
+	}
+
+	if query.Origin != nil {
+		// координаты книги, а если их нет — владельца; книги без координат не попадают.
+		// Прямоугольник — дешёвая предварительная проверка, круг — точная граница.
+		minLat, maxLat, minLon, maxLon := geo.BoundingBox(*query.Origin, query.RadiusKm)
+		db = db.Joins(joinPublicOwner("LEFT JOIN", "gu")).
+			Where(bookLat+" BETWEEN ? AND ?", minLat, maxLat).
+			Where(bookLon+" BETWEEN ? AND ?", minLon, maxLon).
+			Where(distanceSqExpr(*query.Origin)+" <= ?", query.RadiusKm*query.RadiusKm)
+	}
+
+	return db, nil
+}
+
+// координаты книги для поиска рядом: своя точка выдачи или город владельца (join users gu)
+const (
+	bookLat = "COALESCE(books.lat, gu.lat)"
+	bookLon = "COALESCE(books.lon, gu.lon)"
+)
+
+// joinPublicOwner присоединяет владельца книги как alias, только если его город открыт всем.
+// Иначе закрытый город можно было бы узнать фильтром ?city=, фасетом или по distance_km:
+// координаты пользователя геокодируются из города.
+func joinPublicOwner(join, alias string) string {
+	return fmt.Sprintf("%[1]s users %[2]s ON %[2]s.id = books.user_id AND %[2]s.city_visibility = '%[3]s'",
+		join, alias, models.VisibilityPublic)
+}
+
+// distanceSqExpr — квадрат расстояния в км от книги до p в равнопромежуточной проекции.
+// На радиусах до MaxRadiusKm погрешность против расстояния по большому кругу — доли процента,
+// зато выражение из одной арифметики работает и в Postgres, и в SQLite.
+// Числа подставляются литералами: выражение попадает в SELECT и ORDER BY подзапроса.
+func distanceSqExpr(p geo.Point) string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	return fmt.Sprintf("(((%[1]s) - (%[3]s)) * %[5]s * ((%[1]s) - (%[3]s)) * %[5]s + ((%[2]s) - (%[4]s)) * %[6]s * ((%[2]s) - (%[4]s)) * %[6]s)",
+		bookLat, bookLon, f(p.Lat), f(p.Lon), f(geo.KmPerDegree), f(geo.KmPerDegreeLon(p.Lat)))
+}
+
+// facetLimit — сколько самых частых значений отдаётся в каждом фасете
+const facetLimit = 20
+
+func (r *bookRepository) Facets(ctx context.Context, query dto.BookListQuery, fields []string) (map[string][]dto.FacetValue, error) {
+	db, err := r.searchScope(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	facets := make(map[string][]dto.FacetValue, len(fields))
+	for _, field := range fields {
+		q := db.Session(&gorm.Session{})
+		switch field {
+		case dto.FacetGenre:
+			q = q.Joins("JOIN book_genres fbg ON fbg.book_id = books.id").
+				Joins("JOIN genres fg ON fg.id = fbg.genre_id AND fg.deleted_at IS NULL").
+				Select("CAST(fg.id AS VARCHAR(20)) AS value, fg.name AS label, COUNT(DISTINCT books.id) AS count").
+				Group("fg.id, fg.name")
+		case dto.FacetCity:
+			q = q.Joins(joinPublicOwner("JOIN", "fu")).
+				Where("fu.city <> ''").
+				Select("fu.city AS value, COUNT(DISTINCT books.id) AS count").
+				Group("fu.city")
+		case dto.FacetStatus:
+			q = q.Select("books.status AS value, COUNT(DISTINCT books.id) AS count").
+				Group("books.status")
+		case dto.FacetAuthor:
+			q = q.Where("books.author <> ''").
+				Select("books.author AS value, COUNT(DISTINCT books.id) AS count").
+				Group("books.author")
+		default:
+			return nil, dto.ErrInvalidInput
+		}
+
+		values := []dto.FacetValue{}
+		if err := q.Order("COUNT(DISTINCT books.id) DESC, value").Limit(facetLimit).Scan(&values).Error; err != nil {
+			logging.From(ctx, r.log).ErrorContext(ctx, "ошибка подсчёта фасета", "facet", field, "err", err)
+			return nil, err
+		}
+		facets[field] = values
+	}
+
+	return facets, nil
+}
+
+func (r *bookRepository) AttachGenres(ctx context.Context, bookID uint, genreIDs []uint) error {
 	var book models.Book
-	if err := r.db.First(&book, bookID).Error; err != nil {
+	if err := r.db.WithContext(ctx).First(&book, bookID).Error; err != nil {
 		return err
 	}
 
 	var genres []models.Genre
-	if err := r.db.Where("id IN ?", genreIDs).Find(&genres).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("id IN ?", genreIDs).Find(&genres).Error; err != nil {
 		return err
 	}
 
 	// Привязываем жанры к книге
-	if err := r.db.Model(&book).Association("Genres").Replace(genres); err != nil {
+	if err := r.db.WithContext(ctx).Model(&book).Association("Genres").Replace(genres); err != nil {
 		return err
 	}
 
@@ -184,10 +422,10 @@ func (r *bookRepository) AttachGenres(bookID uint, genreIDs []uint) error {
 }
 
 
-func (r *bookRepository) GetByUserID(userID uint, status string) ([]models.Book, error) {
+func (r *bookRepository) GetByUserID(ctx context.Context, userID uint, status string) ([]models.Book, error) {
 	var books []models.Book
 
-	db := r.db.Model(&models.Book{}).Where("user_id = ?", userID)
+	db := r.db.WithContext(ctx).Model(&models.Book{}).Where("user_id = ?", userID)
 
 	if status != "" {
 		db = db.Where("status = ?", strings.TrimSpace(status))
@@ -195,34 +433,47 @@ func (r *bookRepository) GetByUserID(userID uint, status string) ([]models.Book,
 
 	if err := db.Preload("Genres").
 		Preload("User").
+		Preload("Rating").
 		Order("created_at DESC").
 		Find(&books).Error; err != nil {
-		r.log.Error("Ошибка в функции GetByUserID book_repository.go", "err", err)
+		logging.From(ctx, r.log).ErrorContext(ctx, "Ошибка в функции GetByUserID book_repository.go", "err", err)
 		return nil, err
 	}
 
 	return books, nil
 }
 
-func (r *bookRepository) GetAvailable(city string) ([]models.Book, error) {
+func (r *bookRepository) GetAvailable(ctx context.Context, city string) ([]models.Book, error) {
 	var books []models.Book
 
-	db := r.db.Model(&models.Book{}).
+	db := r.db.WithContext(ctx).Model(&models.Book{}).
 		Where("books.status = ?", "available")
 
 	city = strings.TrimSpace(city)
 	if city != "" {
-		db = db.Joins("JOIN users u ON u.id = books.user_id").
-			Where("u.city ILIKE ?", "%"+city+"%")
+		db = db.Joins(joinPublicOwner("JOIN", "u")).
+			Where(containsFold("u.city"), likePattern(city))
 	}
 
 	if err := db.Preload("Genres").
 		Preload("User").
-		Order("created_at DESC").
+		Preload("Rating").
+		Order("books.created_at DESC").
 		Find(&books).Error; err != nil {
-		r.log.Error("Ошибка в функции GetAvailable book_repository.go", "err", err)
+		logging.From(ctx, r.log).ErrorContext(ctx, "Ошибка в функции GetAvailable book_repository.go", "err", err)
 		return nil, err
 	}
 
 	return books, nil
 }
+
+// containsFold — регистронезависимый поиск подстроки.
+// ILIKE есть только в Postgres; LOWER(...) LIKE работает и в SQLite
+// (там lower() заменён на Unicode-версию, см. config.Connect).
+func containsFold(column string) string {
+	return "LOWER(" + column + ") LIKE ?"
+}
+
+func likePattern(s string) string {
+	return "%" + strings.ToLower(s) + "%"
+}
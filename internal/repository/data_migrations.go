@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// runDataMigration выполняет fn один раз: отметка пишется в той же транзакции,
+// поэтому упавшая миграция повторится при следующем запуске
+func runDataMigration(ctx context.Context, db *gorm.DB, name string, fn func(tx *gorm.DB) error) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.DataMigration{}).Where("name = ?", name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		// вторая реплика могла успеть раньше — это не ошибка
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.DataMigration{Name: name, AppliedAt: time.Now()}).Error
+	})
+}
+
+// MigrateLegacyUsers готовит пользователей, зарегистрированных до подтверждения email:
+// приводит email к нижнему регистру, как это делают регистрация и вход, и считает
+// подтверждёнными тех, кому письмо с подтверждением никогда не отправлялось
+func MigrateLegacyUsers(ctx context.Context, db *gorm.DB, log *slog.Logger) error {
+	return runDataMigration(ctx, db, "legacy_users_email", func(tx *gorm.DB) error {
+		if err := normalizeUserEmails(tx, log); err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE users SET email_verified_at = created_at
+			WHERE email_verified_at IS NULL AND purged_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM user_tokens WHERE user_tokens.user_id = users.id AND user_tokens.purpose = ?)`,
+			models.TokenPurposeVerifyEmail).Error
+	})
+}
+
+func normalizeUserEmails(tx *gorm.DB, log *slog.Logger) error {
+	var users []models.User
+	if err := tx.Select("id", "email").Where("email <> LOWER(TRIM(email))").Find(&users).Error; err != nil {
+		return err
+	}
+	for _, u := range users {
+		email := strings.ToLower(strings.TrimSpace(u.Email))
+		var taken int64
+		if err := tx.Model(&models.User{}).Where("email = ? AND id <> ?", email, u.ID).Count(&taken).Error; err != nil {
+			return err
+		}
+		// два аккаунта отличаются только регистром — решать вручную
+		if taken > 0 {
+			log.Warn("email не приведён к нижнему регистру: адрес занят другим аккаунтом", "id", u.ID)
+			continue
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", u.ID).UpdateColumn("email", email).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillRatings пересчитывает агрегаты рейтингов по отзывам, написанным до их появления.
+// Отметка ставится только после успешного пересчёта, поэтому сбой не оставит таблицы пустыми
+func BackfillRatings(ctx context.Context, db *gorm.DB) error {
+	return runDataMigration(ctx, db, "rating_aggregates", rebuildRatings)
+}
+
+// ResetAddressLocations стирает координаты пользователей, которые раньше геокодировались
+// по адресу: BackfillUserLocations заново заполнит их по городу
+func ResetAddressLocations(ctx context.Context, db *gorm.DB) error {
+	return runDataMigration(ctx, db, "user_locations_city_level", func(tx *gorm.DB) error {
+		return tx.Model(&models.User{}).Where("lat IS NOT NULL OR lon IS NOT NULL").
+			Updates(map[string]any{"lat": nil, "lon": nil}).Error
+	})
+}
@@ -1,22 +1,24 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"time"
 
 	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/logging"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"gorm.io/gorm"
 )
 
 type ExchangeRepository interface {
-	CreateExchange(req *models.Exchange) error
-	CompleteExchange(req *models.Exchange) error
-	CancelExchange(req *models.Exchange) error
-	Update(req *models.Exchange) error
-	GetByID(id uint) (*models.Exchange, error)
-	GetAll() ([]models.Exchange, error)
+	CreateExchange(ctx context.Context, req *models.Exchange) error
+	CompleteExchange(ctx context.Context, req *models.Exchange) error
+	CancelExchange(ctx context.Context, req *models.Exchange) error
+	Update(ctx context.Context, req *models.Exchange) error
+	GetByID(ctx context.Context, id uint) (*models.Exchange, error)
+	GetAll(ctx context.Context) ([]models.Exchange, error)
 }
 
 type exchangeRepository struct {
@@ -31,36 +33,42 @@ func NewExchangeRepository(db *gorm.DB, log *slog.Logger) ExchangeRepository {
 	}
 }
 
-func (r *exchangeRepository) CancelExchange(req *models.Exchange) error {
+func (r *exchangeRepository) CancelExchange(ctx context.Context, req *models.Exchange) error {
 	if req == nil {
-		r.log.Error("error in CancelExchange function exchange_repository.go")
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in CancelExchange function exchange_repository.go")
 		return dto.ErrExchangeCancelFailed
 	}
 
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Model(&models.Book{}).Where("id = ?", req.InitiatorBookID).Update("status", "available").Error; err != nil {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Book{}).Where("id = ?", req.InitiatorBookID).Updates(map[string]interface{}{
+			"status":  "available",
+			"version": bumpVersion,
+		}).Error; err != nil {
 			return err
 		}
-		if err := tx.Model(&models.Book{}).Where("id = ?", req.RecipientBookID).Update("status", "available").Error; err != nil {
+		if err := tx.Model(&models.Book{}).Where("id = ?", req.RecipientBookID).Updates(map[string]interface{}{
+			"status":  "available",
+			"version": bumpVersion,
+		}).Error; err != nil {
 			return err
 		}
 
 		req.Status = "cancelled"
 		req.CompletedAt = nil
-		if err := tx.Save(req).Error; err != nil {
-			r.log.Error("error in CancelExchange function exchange_repository.go", "error", err)
+		if err := updateVersioned(tx, req, &req.Version); err != nil {
+			logging.From(ctx, r.log).ErrorContext(ctx, "error in CancelExchange function exchange_repository.go", "error", err)
 			return err
 		}
 		return nil
 	})
 }
-func (r *exchangeRepository) CompleteExchange(req *models.Exchange) error {
+func (r *exchangeRepository) CompleteExchange(ctx context.Context, req *models.Exchange) error {
 	if req == nil {
-		r.log.Error("error in CompleteExchange function exchange_repository.go")
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in CompleteExchange function exchange_repository.go")
 		return dto.ErrExchangeCompleteFailed
 	}
 
-	return r.db.Transaction(func(tx *gorm.DB) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if req.CompletedAt == nil {
 			completedAt := time.Now()
 			req.CompletedAt = &completedAt
@@ -69,6 +77,7 @@ func (r *exchangeRepository) CompleteExchange(req *models.Exchange) error {
 		if err := tx.Model(&models.Book{}).Where("id = ?", req.InitiatorBookID).Updates(map[string]interface{}{
 			"status":  "available",
 			"user_id": req.RecipientID,
+			"version": bumpVersion,
 		}).Error; err != nil {
 			return err
 		}
@@ -76,11 +85,12 @@ func (r *exchangeRepository) CompleteExchange(req *models.Exchange) error {
 		if err := tx.Model(&models.Book{}).Where("id = ?", req.RecipientBookID).Updates(map[string]interface{}{
 			"status":  "available",
 			"user_id": req.InitiatorID,
+			"version": bumpVersion,
 		}).Error; err != nil {
 			return err
 		}
 
-		if err := tx.Save(req).Error; err != nil {
+		if err := updateVersioned(tx, req, &req.Version); err != nil {
 			return err
 		}
 
@@ -88,62 +98,68 @@ func (r *exchangeRepository) CompleteExchange(req *models.Exchange) error {
 	})
 }
 
-func (r *exchangeRepository) GetByID(id uint) (*models.Exchange, error) {
+func (r *exchangeRepository) GetByID(ctx context.Context, id uint) (*models.Exchange, error) {
 	if id == 0 {
-		r.log.Error("error in GetByID function exchange_repository.go")
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in GetByID function exchange_repository.go")
 		return nil, dto.ErrExchangeGetFailed
 	}
 
 	var exchange models.Exchange
-	if err := r.db.Where("id = ?", id).First(&exchange).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&exchange).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			r.log.Error("error in GetByID function exchange_repository.go", "error", err)
+			logging.From(ctx, r.log).ErrorContext(ctx, "error in GetByID function exchange_repository.go", "error", err)
 			return nil, dto.ErrExchangeGetFailed
 		}
 
-		r.log.Error("error in GetByID function exchange_repository.go", "error", err)
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in GetByID function exchange_repository.go", "error", err)
 		return nil, dto.ErrExchangeGetFailed
 	}
 
 	return &exchange, nil
 }
 
-func (r *exchangeRepository) CreateExchange(req *models.Exchange) error {
+func (r *exchangeRepository) CreateExchange(ctx context.Context, req *models.Exchange) error {
 	if req == nil {
-		r.log.Error("error in Create function exchange_repository.go")
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in Create function exchange_repository.go")
 		return dto.ErrExchangeCreateFailed
 	}
 
-	return r.db.Transaction(func(tx *gorm.DB) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(req).Error; err != nil {
-			r.log.Error("error in CreateExchange function exchange_repository.go", "error", err)
+			logging.From(ctx, r.log).ErrorContext(ctx, "error in CreateExchange function exchange_repository.go", "error", err)
 			return err
 		}
-		if err := tx.Model(&models.Book{}).Where("id = ?", req.InitiatorBookID).Update("status", "reserved").Error; err != nil {
-			r.log.Error("error in CreateExchange function exchange_repository.go", "error", err)
+		if err := tx.Model(&models.Book{}).Where("id = ?", req.InitiatorBookID).Updates(map[string]interface{}{
+			"status":  "reserved",
+			"version": bumpVersion,
+		}).Error; err != nil {
+			logging.From(ctx, r.log).ErrorContext(ctx, "error in CreateExchange function exchange_repository.go", "error", err)
 			return err
 		}
-		if err := tx.Model(&models.Book{}).Where("id = ?", req.RecipientBookID).Update("status", "reserved").Error; err != nil {
-			r.log.Error("error in CreateExchange function exchange_repository.go", "error", err)
+		if err := tx.Model(&models.Book{}).Where("id = ?", req.RecipientBookID).Updates(map[string]interface{}{
+			"status":  "reserved",
+			"version": bumpVersion,
+		}).Error; err != nil {
+			logging.From(ctx, r.log).ErrorContext(ctx, "error in CreateExchange function exchange_repository.go", "error", err)
 			return err
 		}
 		return nil
 	})
 }
 
-func (r *exchangeRepository) Update(req *models.Exchange) error {
+func (r *exchangeRepository) Update(ctx context.Context, req *models.Exchange) error {
 	if req == nil {
-		r.log.Error("error in Update function book_repository.go")
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in Update function book_repository.go")
 		return dto.ErrExchangeUpdateFailed
 	}
 
-	return r.db.Save(req).Error
+	return updateVersioned(r.db.WithContext(ctx), req, &req.Version)
 }
 
-func (r *exchangeRepository) GetAll() ([]models.Exchange, error) {
+func (r *exchangeRepository) GetAll(ctx context.Context) ([]models.Exchange, error) {
 	var exchanges []models.Exchange
-	if err := r.db.Find(&exchanges).Error; err != nil {
-		r.log.Error("error in GetAll function exchange_repository.go", "error", err)
+	if err := r.db.WithContext(ctx).Find(&exchanges).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in GetAll function exchange_repository.go", "error", err)
 		return nil, errors.New("error get exchanges from db")
 	}
 	return exchanges, nil
@@ -1,20 +1,31 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"log/slog"
+	"strconv"
 
 	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/logging"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"gorm.io/gorm"
 )
 
 type GenreRepository interface {
-	Create(req *models.Genre) error
-	GetByID(id uint) (*models.Genre, error)
-	GetByName(name string) (*models.Genre, error)
-	List() ([]models.Genre, error)
-	Delete(id uint) error
+	// Create проверяет родителя и уникальность названия среди соседей;
+	// пустой Slug подбирается из названия
+	Create(ctx context.Context, req *models.Genre) error
+	GetByID(ctx context.Context, id uint) (*models.Genre, error)
+	GetBySlug(ctx context.Context, slug string) (*models.Genre, error)
+	GetByName(ctx context.Context, name string) (*models.Genre, error)
+	List(ctx context.Context) ([]models.Genre, error)
+	Delete(ctx context.Context, id uint) error
+	// Merge переносит книги и поджанры sourceIDs в targetID и удаляет дубликаты;
+	// возвращает число книг, получивших целевой жанр
+	Merge(ctx context.Context, targetID uint, sourceIDs []uint) (int64, error)
+	// BackfillSlugs заполняет slug у жанров, созданных до его появления
+	BackfillSlugs(ctx context.Context) error
 }
 
 type genreRepository struct {
@@ -29,59 +40,119 @@ func NewGenreRepository(db *gorm.DB, log *slog.Logger) GenreRepository {
 	}
 }
 
-func (r *genreRepository) Create(req *models.Genre) error {
+func (r *genreRepository) Create(ctx context.Context, req *models.Genre) error {
 	if req == nil {
-		r.log.Error("genre is nil in Create")
+		logging.From(ctx, r.log).ErrorContext(ctx, "genre is nil in Create")
 		return dto.ErrInvalidInput
 	}
-	if existing, _ := r.GetByName(req.Name); existing != nil {
-		return dto.ErrConflict
-	}
-	return r.db.Create(req).Error
-}
 
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		siblings := tx.Model(&models.Genre{}).Where("name = ?", req.Name)
+		if req.ParentID != nil {
+			var parents int64
+			if err := tx.Model(&models.Genre{}).Where("id = ?", *req.ParentID).Count(&parents).Error; err != nil {
+				return err
+			}
+			if parents == 0 {
+				return dto.ErrGenreParentNotFound
+			}
+			siblings = siblings.Where("parent_id = ?", *req.ParentID)
+		} else {
+			siblings = siblings.Where("parent_id IS NULL")
+		}
+
+		var same int64
+		if err := siblings.Count(&same).Error; err != nil {
+			return err
+		}
+		if same > 0 {
+			return dto.ErrConflict
+		}
+
+		if req.Slug != "" {
+			taken, err := slugTaken(tx, req.Slug)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return dto.ErrConflict
+			}
+		} else {
+			slug, err := freeSlug(tx, req.BaseSlug())
+			if err != nil {
+				return err
+			}
+			req.Slug = slug
+		}
+
+		return tx.Create(req).Error
+	})
+}
 
-func (r *genreRepository) GetByID(id uint) (*models.Genre, error) {
+func (r *genreRepository) GetByID(ctx context.Context, id uint) (*models.Genre, error) {
 	var genre models.Genre
-	err := r.db.First(&genre, id).Error
+	err := r.db.WithContext(ctx).First(&genre, id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, dto.ErrBookGetFailed
+			return nil, dto.ErrNotFound
 		}
 		return nil, dto.ErrAISummaryFailed
 	}
 	return &genre, nil
 }
 
-func (r *genreRepository) GetByName(name string) (*models.Genre, error) {
+func (r *genreRepository) GetBySlug(ctx context.Context, slug string) (*models.Genre, error) {
 	var genre models.Genre
 
-	if err := r.db.Where("name = ?", name).First(&genre).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&genre).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, dto.ErrNotFound
 		}
-		r.log.Error("error in GetByName genre", "name", name, "err", err)
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in GetBySlug genre", "slug", slug, "err", err)
 		return nil, err
 	}
 
 	return &genre, nil
 }
 
-func (r *genreRepository) List() ([]models.Genre, error) {
+func (r *genreRepository) GetByName(ctx context.Context, name string) (*models.Genre, error) {
+	var genre models.Genre
+
+	if err := r.db.WithContext(ctx).Where("name = ?", name).First(&genre).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, dto.ErrNotFound
+		}
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in GetByName genre", "name", name, "err", err)
+		return nil, err
+	}
+
+	return &genre, nil
+}
+
+func (r *genreRepository) List(ctx context.Context) ([]models.Genre, error) {
 	var genres []models.Genre
 
-	if err := r.db.Find(&genres).Error; err != nil {
-		r.log.Error("error in List genre", "err", err)
+	if err := r.db.WithContext(ctx).Order("id").Find(&genres).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in List genre", "err", err)
 		return nil, err
 	}
 
 	return genres, nil
 }
 
-func (r *genreRepository) Delete(id uint) error {
-	res := r.db.Delete(&models.Genre{}, id)
+func (r *genreRepository) Delete(ctx context.Context, id uint) error {
+	var children int64
+	if err := r.db.WithContext(ctx).Model(&models.Genre{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in Delete genre", "id", id, "err", err)
+		return err
+	}
+	if children > 0 {
+		return dto.ErrGenreHasChildren
+	}
+
+	res := r.db.WithContext(ctx).Delete(&models.Genre{}, id)
 	if res.Error != nil {
-		r.log.Error("error in Delete genre", "id", id, "err", res.Error)
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in Delete genre", "id", id, "err", res.Error)
 		return res.Error
 	}
 	if res.RowsAffected == 0 {
@@ -90,3 +161,99 @@ func (r *genreRepository) Delete(id uint) error {
 	return nil
 }
 
+func (r *genreRepository) Merge(ctx context.Context, targetID uint, sourceIDs []uint) (int64, error) {
+	var moved int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var found int64
+		ids := append([]uint{targetID}, sourceIDs...)
+		if err := tx.Model(&models.Genre{}).Where("id IN ?", ids).Count(&found).Error; err != nil {
+			return err
+		}
+		if found != int64(len(ids)) {
+			return dto.ErrNotFound
+		}
+
+		// цель внутри поддерева дубликата дала бы цикл после переноса поджанров
+		subtree, err := genreSubtree(tx, sourceIDs...)
+		if err != nil {
+			return err
+		}
+		for _, id := range subtree {
+			if id == targetID {
+				return dto.ErrGenreMergeInvalid
+			}
+		}
+
+		if err := tx.Model(&models.Genre{}).Where("parent_id IN ?", sourceIDs).
+			Update("parent_id", targetID).Error; err != nil {
+			return err
+		}
+
+		// книги, у которых целевой жанр уже есть, второй строки не получают
+		res := tx.Exec(`INSERT INTO book_genres (book_id, genre_id)
+			SELECT DISTINCT book_id, ? FROM book_genres
+			WHERE genre_id IN ? AND book_id NOT IN (SELECT book_id FROM book_genres WHERE genre_id = ?)`,
+			targetID, sourceIDs, targetID)
+		if res.Error != nil {
+			return res.Error
+		}
+		moved = res.RowsAffected
+
+		if err := tx.Exec("DELETE FROM book_genres WHERE genre_id IN ?", sourceIDs).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Genre{}, sourceIDs).Error
+	})
+	if err != nil && !errors.Is(err, dto.ErrNotFound) && !errors.Is(err, dto.ErrGenreMergeInvalid) {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in Merge genre", "target_id", targetID, "source_ids", sourceIDs, "err", err)
+	}
+	return moved, err
+}
+
+func (r *genreRepository) BackfillSlugs(ctx context.Context) error {
+	var genres []models.Genre
+	if err := r.db.WithContext(ctx).Where("slug IS NULL OR slug = ''").Order("id").Find(&genres).Error; err != nil {
+		return err
+	}
+	for _, g := range genres {
+		slug, err := freeSlug(r.db.WithContext(ctx), g.BaseSlug())
+		if err != nil {
+			return err
+		}
+		if err := r.db.WithContext(ctx).Model(&models.Genre{}).Where("id = ?", g.ID).
+			Update("slug", slug).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func slugTaken(db *gorm.DB, slug string) (bool, error) {
+	var n int64
+	err := db.Model(&models.Genre{}).Where("slug = ?", slug).Count(&n).Error
+	return n > 0, err
+}
+
+// freeSlug возвращает base или base-2, base-3… — первый не занятый
+func freeSlug(db *gorm.DB, base string) (string, error) {
+	candidate := base
+	for i := 2; ; i++ {
+		taken, err := slugTaken(db, candidate)
+		if err != nil || !taken {
+			return candidate, err
+		}
+		candidate = base + "-" + strconv.Itoa(i)
+	}
+}
+
+// genreSubtree возвращает ids и id всех их поджанров на любой глубине
+func genreSubtree(db *gorm.DB, ids ...uint) ([]uint, error) {
+	var subtree []uint
+	err := db.Raw(`WITH RECURSIVE subtree(id) AS (
+			SELECT id FROM genres WHERE id IN ? AND deleted_at IS NULL
+			UNION
+			SELECT g.id FROM genres g JOIN subtree s ON g.parent_id = s.id WHERE g.deleted_at IS NULL
+		)
+		SELECT id FROM subtree`, ids).Scan(&subtree).Error
+	return subtree, err
+}
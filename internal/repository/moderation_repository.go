@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/logging"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/moderation"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ModerationRepository interface {
+	// CreateReport сохраняет жалобу; повторная жалоба того же пользователя — dto.ErrReportAlreadyExists
+	CreateReport(ctx context.Context, report *models.ContentReport) error
+	// SaveSystemReport записывает причины автоматической проверки (ReporterID = 0),
+	// перезаписывая прежние
+	SaveSystemReport(ctx context.Context, contentType string, contentID uint, reason string) error
+	CountOpenUserReports(ctx context.Context, contentType string, contentID uint) (int64, error)
+	OpenReports(ctx context.Context, contentType string, ids []uint) ([]models.ContentReport, error)
+	ResolveReports(ctx context.Context, contentType string, contentID uint) error
+	// SetReviewStatus меняет статус отзыва, только если он равен from, и пересчитывает рейтинги
+	SetReviewStatus(ctx context.Context, id uint, from, to string) error
+	SetBookStatus(ctx context.Context, id uint, from, to string) error
+	// QueueReviews и QueueBooks — скрытый контент и контент с открытыми жалобами, старые первыми
+	QueueReviews(ctx context.Context, limit int) ([]models.Review, error)
+	QueueBooks(ctx context.Context, limit int) ([]models.Book, error)
+}
+
+type moderationRepository struct {
+	db  *gorm.DB
+	log *slog.Logger
+}
+
+func NewModerationRepository(db *gorm.DB, log *slog.Logger) ModerationRepository {
+	return &moderationRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *moderationRepository) CreateReport(ctx context.Context, report *models.ContentReport) error {
+	res := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(report)
+	if res.Error != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in CreateReport", "content_type", report.ContentType, "content_id", report.ContentID, "err", res.Error)
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return dto.ErrReportAlreadyExists
+	}
+	return nil
+}
+
+func (r *moderationRepository) SaveSystemReport(ctx context.Context, contentType string, contentID uint, reason string) error {
+	report := &models.ContentReport{ContentType: contentType, ContentID: contentID, Reason: reason}
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "content_type"}, {Name: "content_id"}, {Name: "reporter_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason", "resolved", "updated_at"}),
+	}).Create(report).Error
+	if err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in SaveSystemReport", "content_type", contentType, "content_id", contentID, "err", err)
+	}
+	return err
+}
+
+func (r *moderationRepository) CountOpenUserReports(ctx context.Context, contentType string, contentID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.ContentReport{}).
+		Where("content_type = ? AND content_id = ? AND reporter_id <> 0 AND resolved = ?", contentType, contentID, false).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *moderationRepository) OpenReports(ctx context.Context, contentType string, ids []uint) ([]models.ContentReport, error) {
+	var list []models.ContentReport
+	if len(ids) == 0 {
+		return list, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("content_type = ? AND content_id IN ? AND resolved = ?", contentType, ids, false).
+		Order("created_at").
+		Find(&list).Error
+	return list, err
+}
+
+func (r *moderationRepository) ResolveReports(ctx context.Context, contentType string, contentID uint) error {
+	return r.db.WithContext(ctx).Model(&models.ContentReport{}).
+		Where("content_type = ? AND content_id = ? AND resolved = ?", contentType, contentID, false).
+		Update("resolved", true).Error
+}
+
+func (r *moderationRepository) SetReviewStatus(ctx context.Context, id uint, from, to string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var review models.Review
+		if err := tx.First(&review, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return dto.ErrReviewNotFound
+			}
+			return err
+		}
+		if review.ModerationStatus != from {
+			return dto.ErrVersionConflict
+		}
+
+		res := tx.Model(&review).
+			Where("moderation_status = ?", from).
+			Update("moderation_status", to)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return dto.ErrVersionConflict
+		}
+
+		before := review
+		before.ModerationStatus = from
+		if countsInRating(&before) == countsInRating(&review) {
+			return nil
+		}
+		if countsInRating(&before) {
+			return applyReviewRating(tx, &before, -1)
+		}
+		return applyReviewRating(tx, &review, 1)
+	})
+}
+
+func (r *moderationRepository) SetBookStatus(ctx context.Context, id uint, from, to string) error {
+	// версия растёт, чтобы правка владельца, начатая до решения, не вернула статус
+	res := r.db.WithContext(ctx).Model(&models.Book{}).
+		Where("id = ? AND moderation_status = ?", id, from).
+		Updates(map[string]any{"moderation_status": to, "version": bumpVersion})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return dto.ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *moderationRepository) QueueReviews(ctx context.Context, limit int) ([]models.Review, error) {
+	var list []models.Review
+	err := r.db.WithContext(ctx).
+		Where("moderation_status = ? OR id IN (?)", moderation.StatusHeld, r.openReportIDs(models.ContentReview)).
+		Order("updated_at, id").
+		Limit(limit).
+		Find(&list).Error
+	return list, err
+}
+
+func (r *moderationRepository) QueueBooks(ctx context.Context, limit int) ([]models.Book, error) {
+	var list []models.Book
+	err := r.db.WithContext(ctx).
+		Where("moderation_status = ? OR id IN (?)", moderation.StatusHeld, r.openReportIDs(models.ContentBook)).
+		Order("updated_at, id").
+		Limit(limit).
+		Find(&list).Error
+	return list, err
+}
+
+func (r *moderationRepository) openReportIDs(contentType string) *gorm.DB {
+	return r.db.Model(&models.ContentReport{}).
+		Select("content_id").
+		Where("content_type = ? AND resolved = ?", contentType, false)
+}
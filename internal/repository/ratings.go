@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/moderation"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// applyReviewRating добавляет (sign = 1) или вычитает (sign = -1) оценку отзыва
+// из агрегатов пользователя и книги. Вызывается в транзакции вместе с записью отзыва.
+func applyReviewRating(tx *gorm.DB, review *models.Review, sign int64) error {
+	if !countsInRating(review) || review.Rating < 1 || review.Rating > 5 {
+		return nil
+	}
+	if review.TargetUserID != 0 {
+		row := &models.UserRating{UserID: review.TargetUserID, RatingSummary: firstRating(review.Rating)}
+		if err := adjustRating(tx, row, "user_ratings", "user_id", review.TargetUserID, review.Rating, sign); err != nil {
+			return err
+		}
+	}
+	if review.TargetBookID != 0 {
+		row := &models.BookRating{BookID: review.TargetBookID, RatingSummary: firstRating(review.Rating)}
+		if err := adjustRating(tx, row, "book_ratings", "book_id", review.TargetBookID, review.Rating, sign); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adjustRating сдвигает счётчики строки агрегатов атомарно, без чтения:
+// параллельные отзывы не теряют друг друга. row — строка для первого отзыва.
+func adjustRating(tx *gorm.DB, row any, table, key string, id uint, rating int, sign int64) error {
+	col := func(name string) string { return table + "." + name }
+	stars := fmt.Sprintf("stars_%d", rating)
+	delta := sign * int64(rating)
+
+	updates := map[string]any{
+		"reviews_count": gorm.Expr(col("reviews_count")+" + ?", sign),
+		"rating_sum":    gorm.Expr(col("rating_sum")+" + ?", delta),
+		"rating_avg": gorm.Expr("CASE WHEN "+col("reviews_count")+" + ? = 0 THEN 0 ELSE ("+
+			col("rating_sum")+" + ?) * 1.0 / ("+col("reviews_count")+" + ?) END", sign, delta, sign),
+		stars:        gorm.Expr(col(stars)+" + ?", sign),
+		"updated_at": time.Now(),
+	}
+
+	if sign < 0 {
+		return tx.Table(table).Where(key+" = ?", id).Updates(updates).Error
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: key}},
+		DoUpdates: clause.Assignments(updates),
+	}).Create(row).Error
+}
+
+// countsInRating — в рейтинг идут только одобренные отзывы
+// (пустой статус — отзыв, созданный до модерации)
+func countsInRating(review *models.Review) bool {
+	return review.ModerationStatus == "" || review.ModerationStatus == moderation.StatusApproved
+}
+
+func firstRating(rating int) models.RatingSummary {
+	s := models.RatingSummary{ReviewsCount: 1, RatingSum: int64(rating), RatingAvg: float64(rating)}
+	switch rating {
+	case 1:
+		s.Stars1 = 1
+	case 2:
+		s.Stars2 = 1
+	case 3:
+		s.Stars3 = 1
+	case 4:
+		s.Stars4 = 1
+	case 5:
+		s.Stars5 = 1
+	}
+	return s
+}
+
+// rebuildRatingsSQL пересчитывает агрегаты таблицы по всем отзывам;
+// отзывы о несуществующих книгах и пользователях (писались до проверки целей) пропускаются
+const rebuildRatingsSQL = `INSERT INTO %[1]s (%[2]s, reviews_count, rating_sum, rating_avg, stars_1, stars_2, stars_3, stars_4, stars_5, updated_at)
+SELECT %[3]s, COUNT(*), SUM(rating), AVG(rating * 1.0),
+	SUM(CASE WHEN rating = 1 THEN 1 ELSE 0 END),
+	SUM(CASE WHEN rating = 2 THEN 1 ELSE 0 END),
+	SUM(CASE WHEN rating = 3 THEN 1 ELSE 0 END),
+	SUM(CASE WHEN rating = 4 THEN 1 ELSE 0 END),
+	SUM(CASE WHEN rating = 5 THEN 1 ELSE 0 END),
+	?
+FROM reviews
+WHERE deleted_at IS NULL AND moderation_status = 'approved' AND rating BETWEEN 1 AND 5 AND %[3]s IN (SELECT id FROM %[4]s)
+GROUP BY %[3]s`
+
+func rebuildRatings(tx *gorm.DB) error {
+	now := time.Now()
+	for _, t := range []struct{ table, key, target, source string }{
+		{"user_ratings", "user_id", "target_user_id", "users"},
+		{"book_ratings", "book_id", "target_book_id", "books"},
+	} {
+		if err := tx.Exec("DELETE FROM " + t.table).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(fmt.Sprintf(rebuildRatingsSQL, t.table, t.key, t.target, t.source), now).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
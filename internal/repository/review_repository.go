@@ -1,20 +1,30 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 
 	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/logging"
 	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/moderation"
 	"gorm.io/gorm"
 )
 
 type ReviewRepository interface {
-	Create(req *models.Review) error
-	GetByID(id uint) (*models.Review, error)
-	Delete(id uint) error
-	GetByTargetUserID(id uint) ([]models.Review, error)
-	GetByTargetBookID(id uint) ([]models.Review, error)
+	Create(ctx context.Context, req *models.Review) error
+	GetByID(ctx context.Context, id uint) (*models.Review, error)
+	Delete(ctx context.Context, id uint) error
+	GetByTargetUserID(ctx context.Context, id uint, query dto.ReviewListQuery) ([]models.Review, int64, error)
+	GetByTargetBookID(ctx context.Context, id uint) ([]models.Review, error)
+	ExistsForExchange(ctx context.Context, exchangeID, authorID uint) (bool, error)
+	// RebuildRatings пересчитывает агрегаты рейтингов по всем отзывам
+	RebuildRatings(ctx context.Context) error
+	// Update сохраняет правку и кладёт previous в историю
+	Update(ctx context.Context, review *models.Review, previous models.Review) error
+	GetRevisions(ctx context.Context, reviewID uint) ([]models.ReviewRevision, error)
+	CreateReply(ctx context.Context, reply *models.ReviewReply) error
 }
 
 type reviewRepository struct {
@@ -29,59 +39,186 @@ func NewReviewRepository(db *gorm.DB, log *slog.Logger) ReviewRepository {
 	}
 }
 
-func (r *reviewRepository) Create(req *models.Review) error {
+func (r *reviewRepository) Create(ctx context.Context, req *models.Review) error {
 	if req == nil {
-		r.log.Error("error in create review")
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in create review")
 		return dto.ErrReviewCreateFail
 	}
-	return r.db.Create(req).Error
+	if req.ModerationStatus == "" {
+		req.ModerationStatus = moderation.StatusApproved
+	}
+	// агрегаты рейтинга меняются вместе с отзывом
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(req).Error; err != nil {
+			return err
+		}
+		return applyReviewRating(tx, req, 1)
+	})
 }
 
-func (r *reviewRepository) GetByID(id uint) (*models.Review, error) {
+func (r *reviewRepository) GetByID(ctx context.Context, id uint) (*models.Review, error) {
 	var review models.Review
 
-	err := r.db.First(&review, id).Error
+	err := r.db.WithContext(ctx).Preload("Reply").First(&review, id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, dto.ErrReviewNotFound
 		}
-		r.log.Error("error in GetByID review", "id", id, "err", err)
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in GetByID review", "id", id, "err", err)
 		return nil, err
 	}
 	return &review, nil
 }
 
-func (r *reviewRepository) Delete(id uint) error {
-	if err := r.db.Delete(&models.Review{}, id).Error; err != nil {
-		r.log.Error("error in Delete review")
+func (r *reviewRepository) Delete(ctx context.Context, id uint) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var review models.Review
+		if err := tx.First(&review, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		res := tx.Delete(&review)
+		// отзыв успели удалить параллельно — оценку уже вычли
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return applyReviewRating(tx, &review, -1)
+	})
+	if err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in Delete review", "id", id, "err", err)
 		return dto.ErrReviewDeleteFail
 	}
 
 	return nil
 }
 
-func (r *reviewRepository) GetByTargetUserID(id uint) ([]models.Review, error) {
+func (r *reviewRepository) GetByTargetUserID(ctx context.Context, id uint, query dto.ReviewListQuery) ([]models.Review, int64, error) {
+	db := r.db.WithContext(ctx).Model(&models.Review{}).
+		Where("target_user_id = ? AND moderation_status = ?", id, moderation.StatusApproved)
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in GetByTargetUserID count", "id", id, "err", err)
+		return nil, 0, err
+	}
+
+	sortField := "created_at"
+	if query.SortBy == "rating" {
+		sortField = "rating"
+	}
+	order := "DESC"
+	if query.SortOrder == "asc" {
+		order = "ASC"
+	}
+
 	var list []models.Review
-	if err := r.db.
-		Where("target_user_id = ?", id).
+	if err := db.
 		Preload("Author").
 		Preload("TargetBook").
+		Preload("Reply").
+		Order(sortField + " " + order + ", id " + order).
+		Limit(query.Limit).
+		Offset((query.Page - 1) * query.Limit).
 		Find(&list).Error; err != nil {
-		return nil, err
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in GetByTargetUserID", "id", id, "err", err)
+		return nil, 0, err
 	}
 
-	return list, nil
+	return list, total, nil
 }
 
-func (r *reviewRepository) GetByTargetBookID(id uint) ([]models.Review, error) {
+func (r *reviewRepository) GetByTargetBookID(ctx context.Context, id uint) ([]models.Review, error) {
 	var list []models.Review
-	if err := r.db.
-		Where("target_book_id = ?", id).
+	if err := r.db.WithContext(ctx).
+		Where("target_book_id = ? AND moderation_status = ?", id, moderation.StatusApproved).
 		Preload("Author").
 		Preload("TargetUser").
+		Preload("Reply").
 		Find(&list).Error; err != nil {
 		return nil, err
 	}
 
 	return list, nil
 }
+
+// ExistsForExchange сообщает, оставлял ли автор отзыв по этому обмену
+func (r *reviewRepository) ExistsForExchange(ctx context.Context, exchangeID, authorID uint) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.Review{}).
+		Where("exchange_id = ? AND author_id = ?", exchangeID, authorID).
+		Count(&count).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in ExistsForExchange review", "exchange_id", exchangeID, "err", err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *reviewRepository) RebuildRatings(ctx context.Context) error {
+	if err := r.db.WithContext(ctx).Transaction(rebuildRatings); err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in RebuildRatings", "err", err)
+		return err
+	}
+	return nil
+}
+
+func (r *reviewRepository) Update(ctx context.Context, review *models.Review, previous models.Review) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// условие на прежние текст, оценку и статус: параллельная правка или решение
+		// модератора не пройдут незамеченными, и агрегаты не поедут
+		res := tx.Model(review).
+			Where("text = ? AND rating = ? AND moderation_status = ?", previous.Text, previous.Rating, previous.ModerationStatus).
+			Select("text", "rating", "moderation_status", "edited_at", "updated_at").
+			Updates(review)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return dto.ErrVersionConflict
+		}
+
+		revision := &models.ReviewRevision{
+			ReviewID:         previous.ID,
+			Text:             previous.Text,
+			Rating:           previous.Rating,
+			ModerationStatus: previous.ModerationStatus,
+			ReplacedAt:       *review.EditedAt,
+		}
+		if err := tx.Create(revision).Error; err != nil {
+			return err
+		}
+
+		if previous.Rating == review.Rating && countsInRating(&previous) == countsInRating(review) {
+			return nil
+		}
+		if err := applyReviewRating(tx, &previous, -1); err != nil {
+			return err
+		}
+		return applyReviewRating(tx, review, 1)
+	})
+	if err != nil && !errors.Is(err, dto.ErrVersionConflict) {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in Update review", "id", review.ID, "err", err)
+	}
+	return err
+}
+
+func (r *reviewRepository) GetRevisions(ctx context.Context, reviewID uint) ([]models.ReviewRevision, error) {
+	var list []models.ReviewRevision
+	if err := r.db.WithContext(ctx).
+		Where("review_id = ?", reviewID).
+		Order("replaced_at DESC, id DESC").
+		Find(&list).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in GetRevisions review", "id", reviewID, "err", err)
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *reviewRepository) CreateReply(ctx context.Context, reply *models.ReviewReply) error {
+	if err := r.db.WithContext(ctx).Create(reply).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in CreateReply review", "review_id", reply.ReviewID, "err", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/logging"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"gorm.io/gorm"
+)
+
+type TokenRepository interface {
+	Create(ctx context.Context, token *models.UserToken) error
+	GetByHash(ctx context.Context, purpose string, hash string) (*models.UserToken, error)
+	MarkUsed(ctx context.Context, id uint) error
+	InvalidateForUser(ctx context.Context, userID uint, purpose string) error
+}
+
+type tokenRepository struct {
+	db  *gorm.DB
+	log *slog.Logger
+}
+
+func NewTokenRepository(db *gorm.DB, log *slog.Logger) TokenRepository {
+	return &tokenRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *tokenRepository) Create(ctx context.Context, token *models.UserToken) error {
+	if token == nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in Create function token_repository.go")
+		return dto.ErrTokenCreateFailed
+	}
+
+	if err := r.db.WithContext(ctx).Create(token).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in Create function token_repository.go", "err", err)
+		return dto.ErrTokenCreateFailed
+	}
+	return nil
+}
+
+func (r *tokenRepository) GetByHash(ctx context.Context, purpose string, hash string) (*models.UserToken, error) {
+	var token models.UserToken
+	err := r.db.WithContext(ctx).Where("purpose = ? AND token_hash = ?", purpose, hash).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, dto.ErrTokenInvalid
+		}
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in GetByHash function token_repository.go", "err", err)
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed атомарно помечает токен использованным.
+// Если токен уже использован параллельным запросом — ErrTokenInvalid.
+func (r *tokenRepository) MarkUsed(ctx context.Context, id uint) error {
+	res := r.db.WithContext(ctx).Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in MarkUsed function token_repository.go", "id", id, "err", res.Error)
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return dto.ErrTokenInvalid
+	}
+	return nil
+}
+
+// InvalidateForUser гасит все неиспользованные токены пользователя с данным назначением
+func (r *tokenRepository) InvalidateForUser(ctx context.Context, userID uint, purpose string) error {
+	if err := r.db.WithContext(ctx).Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "error in InvalidateForUser function token_repository.go", "user_id", userID, "err", err)
+		return err
+	}
+	return nil
+}
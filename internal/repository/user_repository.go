@@ -1,10 +1,14 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/logging"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"gorm.io/gorm"
 )
@@ -12,15 +16,48 @@ import (
 var ErrUserNotFound = errors.New("пользователь не найден")
 
 type UserRepository interface {
-	Create(user *models.User) error
-	GetByID(id uint) (*models.User, error)
-	Update(user *models.User) error
-	GetByEmail(email string) (*models.User, error)
-	ListUsers(limit int, lastID uint) ([]models.User, error)
-	Delete(id uint) error
-	GetUserExchanges(userID uint, status string) ([]models.Exchange, error)
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id uint) (*models.User, error)
+	// Exists — аккаунт есть и не удалён; проверяется на каждом запросе с JWT
+	Exists(ctx context.Context, id uint) (bool, error)
+	Update(ctx context.Context, user *models.User) error
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	ListUsers(ctx context.Context, limit int, lastID uint) ([]models.User, error)
+	Delete(ctx context.Context, id uint) error
+	GetUserExchanges(ctx context.Context, userID uint, status string) ([]models.Exchange, error)
+	// ListWithoutLocation — пользователи с городом или адресом, но без координат, по возрастанию id
+	ListWithoutLocation(ctx context.Context, limit int, lastID uint) ([]models.User, error)
+	// SetLocation сохраняет координаты, не меняя версию профиля
+	SetLocation(ctx context.Context, id uint, lat, lon *float64) error
+	// PartnerIDs — те из others, с кем у userID есть принятый обмен
+	PartnerIDs(ctx context.Context, userID uint, others []uint) ([]uint, error)
+	// DeleteAccount в одной транзакции отменяет открытые обмены пользователя, освобождает
+	// зарезервированные ими книги, снимает его книги и удаляет аккаунт (soft delete).
+	// Возвращает отменённые обмены
+	DeleteAccount(ctx context.Context, id uint) ([]models.Exchange, error)
+	// ListDeletedBefore — id аккаунтов, удалённых раньше before и ещё не стёртых
+	ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]uint, error)
+	// Purge стирает персональные данные удалённого аккаунта и написанные им тексты
+	Purge(ctx context.Context, id uint) error
+	// CollectData собирает всё, что хранится о пользователе, для выгрузки
+	CollectData(ctx context.Context, id uint) (*UserData, error)
 }
 
+// UserData — строки, связанные с пользователем
+type UserData struct {
+	User            models.User
+	Books           []models.Book
+	Exchanges       []models.Exchange
+	ReviewsWritten  []models.Review
+	ReviewsReceived []models.Review
+	Replies         []models.ReviewReply
+	Reports         []models.ContentReport
+	Tokens          []models.UserToken
+}
+
+// openExchangeStatuses — обмены, которые ещё держат книги в резерве
+var openExchangeStatuses = []string{"pending", "accepted"}
+
 type userRepository struct {
 	db  *gorm.DB
 	log *slog.Logger
@@ -33,19 +70,19 @@ func NewUserRepository(db *gorm.DB, log *slog.Logger) UserRepository {
 	}
 }
 
-func (r *userRepository) Create(user *models.User) error {
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 	if user == nil {
-		r.log.Error("ошибка создания профиля")
+		logging.From(ctx, r.log).ErrorContext(ctx, "ошибка создания профиля")
 		return dto.ErrUserCreateFailed
 	}
-	return r.db.Create(user).Error
+	return r.db.WithContext(ctx).Create(user).Error
 }
 
-func (r *userRepository) GetByID(id uint) (*models.User, error) {
+func (r *userRepository) GetByID(ctx context.Context, id uint) (*models.User, error) {
 	var user models.User
-	err := r.db.First(&user, id).Error
+	err := r.db.WithContext(ctx).First(&user, id).Error
 	if err != nil {
-		r.log.Error("ошибка получения пользователя", "id", id, "err", err)
+		logging.From(ctx, r.log).ErrorContext(ctx, "ошибка получения пользователя", "id", id, "err", err)
 
 		if err == gorm.ErrRecordNotFound {
 			return nil, ErrUserNotFound
@@ -55,20 +92,20 @@ func (r *userRepository) GetByID(id uint) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *userRepository) Update(user *models.User) error {
+func (r *userRepository) Update(ctx context.Context, user *models.User) error {
 	if user == nil || user.ID == 0 {
-		r.log.Error("ошибка обновления: пустой профиль или отсутствует ID")
+		logging.From(ctx, r.log).ErrorContext(ctx, "ошибка обновления: пустой профиль или отсутствует ID")
 		return dto.ErrUserUpdateFailed
 	}
 
-	return r.db.Save(user).Error
+	return updateVersioned(r.db.WithContext(ctx), user, &user.Version)
 
 }
 
-func (r *userRepository) GetByEmail(email string) (*models.User, error) {
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
-		r.log.Error("ошибка получения профиля по Email")
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "ошибка получения профиля по Email")
 
 		if err == gorm.ErrRecordNotFound {
 			return nil, ErrUserNotFound
@@ -78,10 +115,10 @@ func (r *userRepository) GetByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *userRepository) ListUsers(limit int, lastID uint) ([]models.User, error) {
+func (r *userRepository) ListUsers(ctx context.Context, limit int, lastID uint) ([]models.User, error) {
 	var users []models.User
 
-	q := r.db.
+	q := r.db.WithContext(ctx).
 		Table("users"). // явно указываем таблицу
 		Order("id ASC").
 		Limit(limit)
@@ -91,34 +128,265 @@ func (r *userRepository) ListUsers(limit int, lastID uint) ([]models.User, error
 	}
 
 	if err := q.Find(&users).Error; err != nil {
-		r.log.Error("ошибка получения пользователей", "err", err)
+		logging.From(ctx, r.log).ErrorContext(ctx, "ошибка получения пользователей", "err", err)
 		return nil, err
 	}
 
 	return users, nil
 }
 
-func (s *userRepository) GetUserExchanges(userID uint, status string) ([]models.Exchange, error) {
+func (r *userRepository) ListWithoutLocation(ctx context.Context, limit int, lastID uint) ([]models.User, error) {
+	var users []models.User
+
+	if err := r.db.WithContext(ctx).
+		Where("lat IS NULL AND (city <> '' OR address <> '') AND id > ?", lastID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&users).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "ошибка получения пользователей без координат", "err", err)
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *userRepository) Exists(ctx context.Context, id uint) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "ошибка проверки пользователя", "id", id, "err", err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *userRepository) SetLocation(ctx context.Context, id uint, lat, lon *float64) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).
+		UpdateColumns(map[string]any{"lat": lat, "lon": lon}).Error
+}
+
+func (r *userRepository) PartnerIDs(ctx context.Context, userID uint, others []uint) ([]uint, error) {
+	if len(others) == 0 {
+		return nil, nil
+	}
+
+	var ids []uint
+	if err := r.db.WithContext(ctx).Model(&models.Exchange{}).
+		Distinct("CASE WHEN initiator_id = ? THEN recipient_id ELSE initiator_id END", userID).
+		Where("status = ?", "accepted").
+		Where("(initiator_id = ? AND recipient_id IN ?) OR (recipient_id = ? AND initiator_id IN ?)",
+			userID, others, userID, others).
+		Scan(&ids).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "ошибка поиска партнёров по обмену", "id", userID, "err", err)
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (s *userRepository) GetUserExchanges(ctx context.Context, userID uint, status string) ([]models.Exchange, error) {
 	var exchanges []models.Exchange
 
-	q := s.db.Model(&models.Exchange{}).
+	q := s.db.WithContext(ctx).Model(&models.Exchange{}).
 		Where("initiator_id = ? OR recipient_id = ?", userID, userID)
 
 	if status != "" {
 		q = q.Where("status = ?", status)
 	}
 
-	if err := q.Order("created_at desc").Find(&exchanges).Error; err != nil {
+	if err := q.Preload("Initiator").Preload("Recipient").
+		Order("created_at desc").Find(&exchanges).Error; err != nil {
 		return nil, dto.ErrUserExchangesFailed
 	}
 
 	return exchanges, nil
 }
 
-func (r *userRepository) Delete(id uint) error {
-	if err := r.db.Delete(&models.User{}, id).Error; err != nil {
-		r.log.Error("ошибка удаления профиля")
+func (r *userRepository) Delete(ctx context.Context, id uint) error {
+	if err := r.db.WithContext(ctx).Delete(&models.User{}, id).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "ошибка удаления профиля")
 		return dto.ErrUserDeleteFailed
 	}
 	return nil
 }
+
+func (r *userRepository) DeleteAccount(ctx context.Context, id uint) ([]models.Exchange, error) {
+	var open []models.Exchange
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("(initiator_id = ? OR recipient_id = ?) AND status IN ?", id, id, openExchangeStatuses).
+			Find(&open).Error; err != nil {
+			return err
+		}
+
+		if len(open) > 0 {
+			exchangeIDs := make([]uint, 0, len(open))
+			bookIDs := make([]uint, 0, 2*len(open))
+			for _, e := range open {
+				exchangeIDs = append(exchangeIDs, e.ID)
+				bookIDs = append(bookIDs, e.InitiatorBookID, e.RecipientBookID)
+			}
+			if err := tx.Model(&models.Book{}).Where("id IN ? AND status = ?", bookIDs, "reserved").Updates(map[string]interface{}{
+				"status":  "available",
+				"version": bumpVersion,
+			}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Exchange{}).Where("id IN ?", exchangeIDs).Updates(map[string]interface{}{
+				"status":       "cancelled",
+				"cancelled_by": id,
+				"version":      bumpVersion,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		// книги уходят из поиска вместе с владельцем
+		if err := tx.Where("user_id = ?", id).Delete(&models.Book{}).Error; err != nil {
+			return err
+		}
+		// ссылки из писем (подтверждение, сброс пароля) больше не срабатывают
+		if err := tx.Where("user_id = ?", id).Delete(&models.UserToken{}).Error; err != nil {
+			return err
+		}
+
+		res := tx.Delete(&models.User{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, err
+		}
+		logging.From(ctx, r.log).ErrorContext(ctx, "ошибка удаления аккаунта", "id", id, "err", err)
+		return nil, dto.ErrUserDeleteFailed
+	}
+
+	for i := range open {
+		open[i].Status = "cancelled"
+		open[i].CancelledBy = &id
+		open[i].Version++
+	}
+	return open, nil
+}
+
+func (r *userRepository) ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	if err := r.db.WithContext(ctx).Unscoped().Model(&models.User{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ? AND purged_at IS NULL", before).
+		Order("id ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil {
+		logging.From(ctx, r.log).ErrorContext(ctx, "ошибка получения удалённых аккаунтов", "err", err)
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *userRepository) Purge(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&models.UserToken{}).Error; err != nil {
+			return err
+		}
+		if err := purgeBooks(tx, id); err != nil {
+			return err
+		}
+
+		// отзывы остаются в рейтингах, ответы и жалобы — в истории модерации, но без текста автора
+		if err := tx.Where("review_id IN (?)", tx.Unscoped().Model(&models.Review{}).Select("id").Where("author_id = ?", id)).
+			Delete(&models.ReviewRevision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Review{}).Where("author_id = ?", id).UpdateColumn("text", "").Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.ReviewReply{}).Where("author_id = ?", id).UpdateColumn("text", "").Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.ContentReport{}).Where("reporter_id = ?", id).UpdateColumn("reason", "").Error; err != nil {
+			return err
+		}
+
+		// отзывы и ответы ссылаются на безымянную строку
+		return tx.Unscoped().Model(&models.User{}).Where("id = ? AND deleted_at IS NOT NULL", id).UpdateColumns(map[string]interface{}{
+			"name":              "",
+			"email":             fmt.Sprintf("deleted-%d@deleted.invalid", id),
+			"password_hash":     "",
+			"city":              "",
+			"address":           "",
+			"lat":               nil,
+			"lon":               nil,
+			"email_verified_at": nil,
+			"purged_at":         time.Now(),
+		}).Error
+	})
+}
+
+// purgeBooks удаляет книги пользователя, на которые ничто не ссылается. Книги из обменов
+// и отзывов других людей остаются с названием и автором, но без описания и места встречи
+func purgeBooks(tx *gorm.DB, userID uint) error {
+	var unused []uint
+	if err := tx.Unscoped().Model(&models.Book{}).Where("user_id = ?", userID).
+		Where("id NOT IN (?)", tx.Unscoped().Model(&models.Exchange{}).Select("initiator_book_id")).
+		Where("id NOT IN (?)", tx.Unscoped().Model(&models.Exchange{}).Select("recipient_book_id")).
+		Where("id NOT IN (?)", tx.Unscoped().Model(&models.Review{}).Select("target_book_id")).
+		Pluck("id", &unused).Error; err != nil {
+		return err
+	}
+	if len(unused) > 0 {
+		if err := tx.Exec("DELETE FROM book_genres WHERE book_id IN ?", unused).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("book_id IN ?", unused).Delete(&models.BookRating{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("content_type = ? AND content_id IN ?", models.ContentBook, unused).
+			Delete(&models.ContentReport{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&models.Book{}, unused).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Unscoped().Model(&models.Book{}).Where("user_id = ?", userID).UpdateColumns(map[string]interface{}{
+		"description": "",
+		"ai_summary":  "",
+		"location":    "",
+		"lat":         nil,
+		"lon":         nil,
+	}).Error
+}
+
+func (r *userRepository) CollectData(ctx context.Context, id uint) (*UserData, error) {
+	user, err := r.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &UserData{User: *user}
+	db := r.db.WithContext(ctx)
+	for _, q := range []struct {
+		dest  any
+		where string
+		args  []any
+	}{
+		{&data.Books, "user_id = ?", []any{id}},
+		{&data.Exchanges, "initiator_id = ? OR recipient_id = ?", []any{id, id}},
+		{&data.ReviewsWritten, "author_id = ?", []any{id}},
+		{&data.ReviewsReceived, "target_user_id = ?", []any{id}},
+		{&data.Replies, "author_id = ?", []any{id}},
+		{&data.Reports, "reporter_id = ?", []any{id}},
+		{&data.Tokens, "user_id = ?", []any{id}},
+	} {
+		if err := db.Where(q.where, q.args...).Order("id ASC").Find(q.dest).Error; err != nil {
+			logging.From(ctx, r.log).ErrorContext(ctx, "ошибка выгрузки данных пользователя", "id", id, "err", err)
+			return nil, dto.ErrUserGetFailed
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// updateVersioned перезаписывает строку model, только если её версия в базе
+// всё ещё равна *version, и увеличивает версию. Связи не сохраняются:
+// в отличие от Save, чужие строки (владелец книги, жанры) не перезаписываются.
+// Если строку успели изменить, возвращает dto.ErrVersionConflict.
+func updateVersioned(db *gorm.DB, model any, version *uint) error {
+	expected := *version
+	*version = expected + 1
+
+	res := db.Model(model).
+		Where("version = ?", expected).
+		Select("*").
+		Omit(clause.Associations, "created_at").
+		Updates(model)
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = dto.ErrVersionConflict
+	}
+	if res.Error != nil {
+		*version = expected
+	}
+	return res.Error
+}
+
+// bumpVersion — выражение для Updates, когда строка меняется без загрузки модели
+var bumpVersion = gorm.Expr("version + 1")
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/events"
+	"github.com/dasler-fw/bookcrossing/internal/logging"
+	"github.com/dasler-fw/bookcrossing/internal/metrics"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const accountPurgeBatch = 100
+
+// DeleteUser отменяет открытые обмены пользователя, освобождает их книги и удаляет аккаунт.
+// Отзывы остаются и учитываются в рейтингах, но автор в них больше не показывается.
+// Персональные данные стираются через deletionGrace фоновой задачей RunAccountPurge
+func (s *userService) DeleteUser(ctx context.Context, id uint, password string) (*dto.DeleteAccountResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, dto.ErrInvalidCredentials
+	}
+
+	cancelled, err := s.userRepo.DeleteAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	metrics.ExchangeEvents.WithLabelValues("cancelled").Add(float64(len(cancelled)))
+	logging.From(ctx, s.log).InfoContext(ctx, "account deleted", "id", id, "cancelled_exchanges", len(cancelled))
+
+	// второй участник узнаёт об отмене так же, как при CancelExchange
+	for i := range cancelled {
+		counterparty := cancelled[i].InitiatorID
+		if counterparty == id {
+			counterparty = cancelled[i].RecipientID
+		}
+		publishExchangeEvent(ctx, s.publisher, s.log, &cancelled[i], events.TypeExchangeCancelled, counterparty)
+	}
+
+	return &dto.DeleteAccountResponse{
+		CancelledExchanges: len(cancelled),
+		PurgeAfter:         time.Now().Add(s.deletionGrace),
+	}, nil
+}
+
+func (s *userService) ExportData(ctx context.Context, id uint) (*dto.UserDataExport, error) {
+	data, err := s.userRepo.CollectData(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return newUserDataExport(data, time.Now()), nil
+}
+
+func newUserDataExport(data *repository.UserData, now time.Time) *dto.UserDataExport {
+	u := data.User
+	export := &dto.UserDataExport{
+		ExportedAt: now,
+		Profile: dto.ExportProfile{
+			ID:              u.ID,
+			Name:            u.Name,
+			Email:           u.Email,
+			City:            u.City,
+			Address:         u.Address,
+			Lat:             u.Lat,
+			Lon:             u.Lon,
+			Language:        u.Language,
+			Role:            u.Role,
+			EmailVerifiedAt: u.EmailVerifiedAt,
+			Visibility: dto.UserVisibility{
+				Email:   u.EmailVisibility,
+				Address: u.AddressVisibility,
+				City:    u.CityVisibility,
+			},
+			CreatedAt: u.CreatedAt,
+			UpdatedAt: u.UpdatedAt,
+		},
+		// пустые разделы выгружаются как [], а не null
+		Books:           make([]dto.ExportBook, 0, len(data.Books)),
+		Exchanges:       make([]dto.ExportExchange, 0, len(data.Exchanges)),
+		ReviewsWritten:  make([]dto.ExportReview, 0, len(data.ReviewsWritten)),
+		ReviewsReceived: make([]dto.ExportReview, 0, len(data.ReviewsReceived)),
+		ReviewReplies:   make([]dto.ExportReply, 0, len(data.Replies)),
+		Reports:         make([]dto.ExportReport, 0, len(data.Reports)),
+		Tokens:          make([]dto.ExportToken, 0, len(data.Tokens)),
+	}
+
+	for _, b := range data.Books {
+		export.Books = append(export.Books, dto.ExportBook{
+			ID:               b.ID,
+			Title:            b.Title,
+			Author:           b.Author,
+			Description:      b.Description,
+			Status:           b.Status,
+			ModerationStatus: b.ModerationStatus,
+			Location:         b.Location,
+			Lat:              b.Lat,
+			Lon:              b.Lon,
+			CreatedAt:        b.CreatedAt,
+		})
+	}
+	for _, e := range data.Exchanges {
+		export.Exchanges = append(export.Exchanges, dto.ExportExchange{
+			ID:              e.ID,
+			InitiatorID:     e.InitiatorID,
+			RecipientID:     e.RecipientID,
+			InitiatorBookID: e.InitiatorBookID,
+			RecipientBookID: e.RecipientBookID,
+			Status:          e.Status,
+			CreatedAt:       e.CreatedAt,
+			CompletedAt:     e.CompletedAt,
+		})
+	}
+	for _, list := range []struct {
+		from []models.Review
+		to   *[]dto.ExportReview
+	}{
+		{data.ReviewsWritten, &export.ReviewsWritten},
+		{data.ReviewsReceived, &export.ReviewsReceived},
+	} {
+		for _, r := range list.from {
+			*list.to = append(*list.to, dto.ExportReview{
+				ID:               r.ID,
+				ExchangeID:       r.ExchangeID,
+				AuthorID:         r.AuthorID,
+				TargetUserID:     r.TargetUserID,
+				TargetBookID:     r.TargetBookID,
+				Text:             r.Text,
+				Rating:           r.Rating,
+				ModerationStatus: r.ModerationStatus,
+				CreatedAt:        r.CreatedAt,
+				EditedAt:         r.EditedAt,
+			})
+		}
+	}
+	for _, r := range data.Replies {
+		export.ReviewReplies = append(export.ReviewReplies, dto.ExportReply{
+			ID:        r.ID,
+			ReviewID:  r.ReviewID,
+			Text:      r.Text,
+			CreatedAt: r.CreatedAt,
+		})
+	}
+	for _, r := range data.Reports {
+		export.Reports = append(export.Reports, dto.ExportReport{
+			ID:          r.ID,
+			ContentType: r.ContentType,
+			ContentID:   r.ContentID,
+			Reason:      r.Reason,
+			Resolved:    r.Resolved,
+			CreatedAt:   r.CreatedAt,
+		})
+	}
+	for _, t := range data.Tokens {
+		export.Tokens = append(export.Tokens, dto.ExportToken{
+			Purpose:   t.Purpose,
+			CreatedAt: t.CreatedAt,
+			ExpiresAt: t.ExpiresAt,
+			UsedAt:    t.UsedAt,
+		})
+	}
+	return export
+}
+
+// PurgeDeletedAccounts стирает персональные данные аккаунтов, удалённых больше grace назад
+func PurgeDeletedAccounts(ctx context.Context, users repository.UserRepository, grace time.Duration) (int, error) {
+	before := time.Now().Add(-grace)
+	purged := 0
+	for {
+		ids, err := users.ListDeletedBefore(ctx, before, accountPurgeBatch)
+		if err != nil {
+			return purged, err
+		}
+		for _, id := range ids {
+			if err := users.Purge(ctx, id); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+		if len(ids) < accountPurgeBatch {
+			return purged, nil
+		}
+	}
+}
+
+// RunAccountPurge вызывает PurgeDeletedAccounts сразу и затем каждые interval, пока не отменён ctx
+func RunAccountPurge(ctx context.Context, users repository.UserRepository, grace, interval time.Duration, log *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := PurgeDeletedAccounts(ctx, users, grace)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			log.Error("failed to purge deleted accounts", "error", err)
+		case purged > 0:
+			log.Info("deleted accounts purged", "count", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
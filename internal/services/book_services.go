@@ -1,54 +1,73 @@
 package services
 
 import (
+	"context"
 	"bytes"
 	"encoding/json"
 	"io"
 	"log/slog"
 	"net/http"
-	"os"
 	"strings"
-	"time"
 
+	"github.com/dasler-fw/bookcrossing/internal/config"
 	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/etag"
+	"github.com/dasler-fw/bookcrossing/internal/geo"
+	"github.com/dasler-fw/bookcrossing/internal/metrics"
 	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/moderation"
 	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type BookService interface {
-	CreateBook(userID uint, ras dto.CreateBookRequest) (*models.Book, error)
-	GetByID(id uint) (*models.Book, error)
-	Update(bookID uint, userID uint, req dto.UpdateBookRequest) (*models.Book, error)
-	Delete(bookID uint, userID uint) error
-	SearchBooks(query dto.BookListQuery) ([]models.Book, int64, error)
-	GetBooksByUserID(userID uint, status string) ([]models.Book, error)
-	GetAvailableBooks(city string) ([]models.Book, error)
+	CreateBook(ctx context.Context, userID uint, ras dto.CreateBookRequest) (*models.Book, error)
+	GetByID(ctx context.Context, id uint) (*models.Book, error)
+	Update(ctx context.Context, bookID uint, userID uint, req dto.UpdateBookRequest) (*models.Book, error)
+	Delete(ctx context.Context, bookID uint, userID uint) error
+	SearchBooks(ctx context.Context, query dto.BookListQuery) (*repository.BookPage, error)
+	// SearchFacets — счётчики по жанрам, городам, статусам и авторам под фильтрами query
+	SearchFacets(ctx context.Context, query dto.BookListQuery, fields []string) (map[string][]dto.FacetValue, error)
+	GetBooksByUserID(ctx context.Context, userID uint, status string) ([]models.Book, error)
+	GetAvailableBooks(ctx context.Context, city string) ([]models.Book, error)
 }
 
 type bookService struct {
-	bookRepo repository.BookRepository
-	log      *slog.Logger
+	bookRepo  repository.BookRepository
+	ai        config.AIConfig
+	moderator ContentModerator
+	geocoder  geo.Geocoder
+	log       *slog.Logger
 }
 
-func NewServiceBook(bookRepo repository.BookRepository, log *slog.Logger) BookService {
+// moderator может быть nil — тогда описания публикуются без проверки;
+// geocoder nil — location книг не геокодируется
+func NewServiceBook(bookRepo repository.BookRepository, ai config.AIConfig, moderator ContentModerator, geocoder geo.Geocoder, log *slog.Logger) BookService {
 	return &bookService{
-		bookRepo: bookRepo,
-		log:      log,
+		bookRepo:  bookRepo,
+		ai:        ai,
+		moderator: moderator,
+		geocoder:  geocoder,
+		log:       log,
 	}
 }
 
-func (s *bookService) CreateBook(userID uint, req dto.CreateBookRequest) (*models.Book, error) {
+func (s *bookService) CreateBook(ctx context.Context, userID uint, req dto.CreateBookRequest) (*models.Book, error) {
+	status, verdict := screenContent(ctx, s.moderator, "", req.Description)
 	book := &models.Book{
-		Title:       req.Title,
-		Author:      req.Author,
-		Description: req.Description,
-		Status:      "available",
-		UserID:      userID,
+		Title:            req.Title,
+		Author:           req.Author,
+		Description:      req.Description,
+		Status:           "available",
+		UserID:           userID,
+		ModerationStatus: status,
+		Location:         strings.TrimSpace(req.Location),
 	}
+	book.Lat, book.Lon = locate(ctx, s.geocoder, s.log, book.Location)
 
 	// Если AISummary пустой, генерируем через Grok AI
 	if req.AISummary == "" {
-		summary, err := GenerateAISummary(req.Description)
+		summary, err := GenerateAISummary(ctx, s.ai, req.Description)
 		if err != nil {
 			return nil, err
 		}
@@ -58,13 +77,14 @@ func (s *bookService) CreateBook(userID uint, req dto.CreateBookRequest) (*model
 	}
 
 	// Сохраняем книгу
-	if err := s.bookRepo.Create(book); err != nil {
+	if err := s.bookRepo.Create(ctx, book); err != nil {
 		return nil, err
 	}
+	flagContent(ctx, s.moderator, models.ContentBook, book.ID, verdict)
 
 	// Привязываем жанры
 	if len(req.GenreIDs) > 0 {
-		if err := s.bookRepo.AttachGenres(book.ID, req.GenreIDs); err != nil {
+		if err := s.bookRepo.AttachGenres(ctx, book.ID, req.GenreIDs); err != nil {
 			return nil, err
 		}
 	}
@@ -72,8 +92,8 @@ func (s *bookService) CreateBook(userID uint, req dto.CreateBookRequest) (*model
 	return book, nil
 }
 
-func (s *bookService) GetByID(id uint) (*models.Book, error) {
-	book, err := s.bookRepo.GetByID(id)
+func (s *bookService) GetByID(ctx context.Context, id uint) (*models.Book, error) {
+	book, err := s.bookRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -82,8 +102,8 @@ func (s *bookService) GetByID(id uint) (*models.Book, error) {
 }
 
 
-func (s *bookService) Update(bookID uint, userID uint, req dto.UpdateBookRequest) (*models.Book, error) {
-	book, err := s.bookRepo.GetByID(bookID)
+func (s *bookService) Update(ctx context.Context, bookID uint, userID uint, req dto.UpdateBookRequest) (*models.Book, error) {
+	book, err := s.bookRepo.GetByID(ctx, bookID)
 	if err != nil {
 		return nil, err
 	}
@@ -91,20 +111,30 @@ func (s *bookService) Update(bookID uint, userID uint, req dto.UpdateBookRequest
 	if book.UserID != userID {
 		return nil, dto.ErrBookForbidden
 	}
+	if err := etag.Check(ctx, book.Version); err != nil {
+		return nil, err
+	}
 
-	if req.Description != nil {
+	var verdict moderation.Verdict
+	if req.Description != nil && *req.Description != book.Description {
 		book.Description = *req.Description
+		book.ModerationStatus, verdict = screenContent(ctx, s.moderator, book.ModerationStatus, book.Description)
+	}
+	if req.Location != nil && strings.TrimSpace(*req.Location) != book.Location {
+		book.Location = strings.TrimSpace(*req.Location)
+		book.Lat, book.Lon = locate(ctx, s.geocoder, s.log, book.Location)
 	}
 
-	if err := s.bookRepo.Update(book); err != nil {
+	if err := s.bookRepo.Update(ctx, book); err != nil {
 		return nil, err
 	}
+	flagContent(ctx, s.moderator, models.ContentBook, book.ID, verdict)
 
 	return book, nil
 }
 
-func (s *bookService) Delete(bookID uint, userID uint) error {
-	book, err := s.bookRepo.GetByID(bookID)
+func (s *bookService) Delete(ctx context.Context, bookID uint, userID uint) error {
+	book, err := s.bookRepo.GetByID(ctx, bookID)
 	if err != nil {
 		return err
 	}
@@ -117,13 +147,20 @@ func (s *bookService) Delete(bookID uint, userID uint) error {
 		return dto.ErrBookInExchange
 	}
 
-	return s.bookRepo.Delete(bookID)
+	return s.bookRepo.Delete(ctx, bookID)
 }
 
-func GenerateAISummary(description string) (string, error) {
-	apiKey := os.Getenv("GROK_API_KEY")
+// aiClient — HTTP-клиент для Grok; otelhttp добавляет span на каждый вызов.
+// Таймаут задаётся через ctx из AIConfig.Timeout.
+var aiClient = &http.Client{
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+}
+
+func GenerateAISummary(ctx context.Context, cfg config.AIConfig, description string) (string, error) {
+	apiKey := cfg.APIKey
 	if strings.TrimSpace(apiKey) == "" {
 		// Нет ключа — используем локальный фолбэк
+		metrics.AISummaryFallbacks.WithLabelValues("no_api_key").Inc()
 		return localSummary(description), nil
 	}
 
@@ -131,18 +168,21 @@ func GenerateAISummary(description string) (string, error) {
 		"prompt": "Сделай краткое резюме книги: " + description,
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
 	body, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", "https://api.grok.ai/v1/completions", bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", cfg.URL, bytes.NewBuffer(body))
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := aiClient.Do(req)
 	if err != nil {
 		// Сетевые/TLS ошибки — фолбэк
+		metrics.AISummaryFallbacks.WithLabelValues("request_error").Inc()
 		return localSummary(description), nil
 	}
 	defer resp.Body.Close()
@@ -150,6 +190,7 @@ func GenerateAISummary(description string) (string, error) {
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		// Ошибка ответа API — фолбэк
 		_, _ = io.ReadAll(io.LimitReader(resp.Body, 1024))
+		metrics.AISummaryFallbacks.WithLabelValues("bad_status").Inc()
 		return localSummary(description), nil
 	}
 
@@ -157,6 +198,7 @@ func GenerateAISummary(description string) (string, error) {
 	dec := json.NewDecoder(io.LimitReader(resp.Body, 10*1024)) // limit 10KB
 	if err := dec.Decode(&result); err != nil {
 		// Ошибка парсинга — фолбэк
+		metrics.AISummaryFallbacks.WithLabelValues("decode_error").Inc()
 		return localSummary(description), nil
 	}
 
@@ -174,6 +216,7 @@ func GenerateAISummary(description string) (string, error) {
 	}
 
 	// Пустой ответ от AI — фолбэк
+	metrics.AISummaryFallbacks.WithLabelValues("empty_response").Inc()
 	return localSummary(description), nil
 }
 
@@ -190,36 +233,30 @@ func localSummary(description string) string {
 	return d
 }
 
-func (s *bookService) SearchBooks(query dto.BookListQuery) ([]models.Book, int64, error) {
-	if query.Page <= 0 {
-		query.Page = dto.DefaultPage
-	}
-
-	if query.Limit <= 0 {
-		query.Limit = dto.DefaultLimit
+func (s *bookService) SearchBooks(ctx context.Context, query dto.BookListQuery) (*repository.BookPage, error) {
+	if err := query.Normalize(); err != nil {
+		return nil, err
 	}
+	resolveOrigin(ctx, s.geocoder, s.log, &query)
 
-	if query.Limit > dto.MaxLimit {
-		query.Limit = dto.MaxLimit
-	}
-	query.SortBy = strings.ToLower(strings.TrimSpace(query.SortBy))
-	query.SortOrder = strings.ToLower(strings.TrimSpace(query.SortOrder))
+	return s.bookRepo.Search(ctx, query)
+}
 
-	if query.SortBy == "" {
-		query.SortBy = "created_at"
+func (s *bookService) SearchFacets(ctx context.Context, query dto.BookListQuery, fields []string) (map[string][]dto.FacetValue, error) {
+	if len(fields) == 0 {
+		return nil, nil
 	}
-
-	if query.SortOrder == "" {
-		query.SortOrder = "desc"
+	if err := query.Normalize(); err != nil {
+		return nil, err
 	}
-
-	return s.bookRepo.Search(query)
+	resolveOrigin(ctx, s.geocoder, s.log, &query)
+	return s.bookRepo.Facets(ctx, query, fields)
 }
 
-func (s *bookService) GetBooksByUserID(userID uint, status string) ([]models.Book, error) {
-	return s.bookRepo.GetByUserID(userID, status)
+func (s *bookService) GetBooksByUserID(ctx context.Context, userID uint, status string) ([]models.Book, error) {
+	return s.bookRepo.GetByUserID(ctx, userID, status)
 }
 
-func (s *bookService) GetAvailableBooks(city string) ([]models.Book, error) {
-	return s.bookRepo.GetAvailable(city)
+func (s *bookService) GetAvailableBooks(ctx context.Context, city string) ([]models.Book, error) {
+	return s.bookRepo.GetAvailable(ctx, city)
 }
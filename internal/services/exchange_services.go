@@ -1,49 +1,58 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 
 	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/etag"
+	"github.com/dasler-fw/bookcrossing/internal/events"
+	"github.com/dasler-fw/bookcrossing/internal/logging"
+	"github.com/dasler-fw/bookcrossing/internal/mail"
+	"github.com/dasler-fw/bookcrossing/internal/metrics"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/dasler-fw/bookcrossing/internal/repository"
 )
 
 type ExchangeService interface {
-	CreateExchange(req *dto.CreateExchangeRequest, actingUserID uint) (*models.Exchange, error)
-	AcceptExchange(exchangeID uint, actingUserID uint) error
-	CompleteExchange(exchangeID uint, actingUserID uint) error
-	CancelExchange(exchangeID uint, actingUserID uint) error
-	GetByID(exchangeID uint) (*models.Exchange, error)
-	GetAll() ([]models.Exchange, error)
+	CreateExchange(ctx context.Context, req *dto.CreateExchangeRequest, actingUserID uint) (*models.Exchange, error)
+	AcceptExchange(ctx context.Context, exchangeID uint, actingUserID uint) error
+	CompleteExchange(ctx context.Context, exchangeID uint, actingUserID uint) error
+	CancelExchange(ctx context.Context, exchangeID uint, actingUserID uint) error
+	GetByID(ctx context.Context, exchangeID uint) (*models.Exchange, error)
+	GetAll(ctx context.Context) ([]models.Exchange, error)
 }
 
 type exchangeService struct {
 	exchangeRepo repository.ExchangeRepository
 	bookRepo     repository.BookRepository
+	publisher    events.Publisher
+	mailer       mail.Mailer
 	log          *slog.Logger
 }
 
-func NewExchangeService(exchangeRepo repository.ExchangeRepository, bookRepo repository.BookRepository, log *slog.Logger) ExchangeService {
-	return &exchangeService{exchangeRepo: exchangeRepo, bookRepo: bookRepo, log: log}
+// publisher и mailer могут быть nil — тогда события и письма не отправляются
+func NewExchangeService(exchangeRepo repository.ExchangeRepository, bookRepo repository.BookRepository, publisher events.Publisher, mailer mail.Mailer, log *slog.Logger) ExchangeService {
+	return &exchangeService{exchangeRepo: exchangeRepo, bookRepo: bookRepo, publisher: publisher, mailer: mailer, log: log}
 }
 
-func (s *exchangeService) CancelExchange(exchangeID uint, actingUserID uint) error {
+func (s *exchangeService) CancelExchange(ctx context.Context, exchangeID uint, actingUserID uint) error {
 	if exchangeID == 0 {
-		s.log.Error("error in CancelExchange function exchange_services.go")
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CancelExchange function exchange_services.go")
 		return dto.ErrExchangeInvalidID
 	}
 
-	exchange, err := s.exchangeRepo.GetByID(exchangeID)
+	exchange, err := s.exchangeRepo.GetByID(ctx, exchangeID)
 	if err != nil {
-		s.log.Error("error in CancelExchange function exchange_services.go", "error", err)
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CancelExchange function exchange_services.go", "error", err)
 		return err
 	}
 
 	// todo: check if the current user is the initiator, after the auth handler is implemented
 
 	if exchange.Status != "pending" {
-		s.log.Error("error in CancelExchange function exchange_services.go", "error", errors.New("exchange is not pending"))
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CancelExchange function exchange_services.go", "error", errors.New("exchange is not pending"))
 		return dto.ErrExchangeNotPending
 	}
 
@@ -51,26 +60,36 @@ func (s *exchangeService) CancelExchange(exchangeID uint, actingUserID uint) err
 	if exchange.InitiatorID != actingUserID {
 		return errors.New("forbidden")
 	}
+	if err := etag.Check(ctx, exchange.Version); err != nil {
+		return err
+	}
+
+	exchange.CancelledBy = &actingUserID
+	if err := s.exchangeRepo.CancelExchange(ctx, exchange); err != nil {
+		return err
+	}
 
-	return s.exchangeRepo.CancelExchange(exchange)
+	metrics.ExchangeEvents.WithLabelValues("cancelled").Inc()
+	s.notify(ctx, exchange, events.TypeExchangeCancelled, exchange.InitiatorID, exchange.RecipientID)
+	return nil
 }
 
-func (s *exchangeService) CompleteExchange(exchangeID uint, actingUserID uint) error {
+func (s *exchangeService) CompleteExchange(ctx context.Context, exchangeID uint, actingUserID uint) error {
 	if exchangeID == 0 {
-		s.log.Error("error in CompleteExchange function exchange_services.go")
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CompleteExchange function exchange_services.go")
 		return dto.ErrExchangeInvalidID
 	}
 
-	exchange, err := s.exchangeRepo.GetByID(exchangeID)
+	exchange, err := s.exchangeRepo.GetByID(ctx, exchangeID)
 	if err != nil {
-		s.log.Error("error in CompleteExchange function exchange_services.go", "error", err)
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CompleteExchange function exchange_services.go", "error", err)
 		return err
 	}
 
 	// todo: check if the current user is the initiator or the recipient, after the auth handler is implemented
 
 	if exchange.Status != "accepted" {
-		s.log.Error("error in CompleteExchange function exchange_services.go", "error", errors.New("exchange is not accepted"))
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CompleteExchange function exchange_services.go", "error", errors.New("exchange is not accepted"))
 		return dto.ErrExchangeNotAccepted
 	}
 
@@ -78,26 +97,35 @@ func (s *exchangeService) CompleteExchange(exchangeID uint, actingUserID uint) e
 	if actingUserID != exchange.InitiatorID && actingUserID != exchange.RecipientID {
 		return errors.New("forbidden")
 	}
+	if err := etag.Check(ctx, exchange.Version); err != nil {
+		return err
+	}
+
+	if err := s.exchangeRepo.CompleteExchange(ctx, exchange); err != nil {
+		return err
+	}
 
-	return s.exchangeRepo.CompleteExchange(exchange)
+	metrics.ExchangeEvents.WithLabelValues("completed").Inc()
+	s.notify(ctx, exchange, events.TypeExchangeCompleted, exchange.InitiatorID, exchange.RecipientID)
+	return nil
 }
 
-func (s *exchangeService) AcceptExchange(exchangeID uint, actingUserID uint) error {
+func (s *exchangeService) AcceptExchange(ctx context.Context, exchangeID uint, actingUserID uint) error {
 	if exchangeID == 0 {
-		s.log.Error("error in AcceptExchange function exchange_services.go")
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in AcceptExchange function exchange_services.go")
 		return dto.ErrExchangeInvalidID
 	}
 
-	exchange, err := s.exchangeRepo.GetByID(exchangeID)
+	exchange, err := s.exchangeRepo.GetByID(ctx, exchangeID)
 	if err != nil {
-		s.log.Error("error in AcceptExchange function exchange_services.go", "error", err)
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in AcceptExchange function exchange_services.go", "error", err)
 		return err
 	}
 
 	// todo: check if the current user is the recipient, after the auth handler is implemented
 
 	if exchange.Status != "pending" {
-		s.log.Error("error in AcceptExchange function exchange_services.go", "error", errors.New("exchange is not pending"))
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in AcceptExchange function exchange_services.go", "error", errors.New("exchange is not pending"))
 		return dto.ErrExchangeNotPending
 	}
 
@@ -105,14 +133,23 @@ func (s *exchangeService) AcceptExchange(exchangeID uint, actingUserID uint) err
 	if exchange.RecipientID != actingUserID {
 		return errors.New("forbidden")
 	}
+	if err := etag.Check(ctx, exchange.Version); err != nil {
+		return err
+	}
 
 	exchange.Status = "accepted"
-	return s.exchangeRepo.Update(exchange)
+	if err := s.exchangeRepo.Update(ctx, exchange); err != nil {
+		return err
+	}
+
+	metrics.ExchangeEvents.WithLabelValues("accepted").Inc()
+	s.notify(ctx, exchange, events.TypeExchangeAccepted, exchange.InitiatorID, exchange.RecipientID)
+	return nil
 }
 
-func (s *exchangeService) CreateExchange(req *dto.CreateExchangeRequest, actingUserID uint) (*models.Exchange, error) {
+func (s *exchangeService) CreateExchange(ctx context.Context, req *dto.CreateExchangeRequest, actingUserID uint) (*models.Exchange, error) {
 	if req == nil {
-		s.log.Error("error in CreateExchange function exchange_services.go")
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CreateExchange function exchange_services.go")
 		return nil, dto.ErrExchangeInvalidID
 	}
 
@@ -125,89 +162,161 @@ func (s *exchangeService) CreateExchange(req *dto.CreateExchangeRequest, actingU
 		Status:          "pending",
 	}
 
-	initiatorBook, err := s.bookRepo.GetByID(req.InitiatorBookID)
+	initiatorBook, err := s.bookRepo.GetByID(ctx, req.InitiatorBookID)
 	if err != nil {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", err)
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CreateExchange function exchange_services.go", "error", err)
 		return nil, err
 	}
 
-	recipientBook, err := s.bookRepo.GetByID(req.RecipientBookID)
+	recipientBook, err := s.bookRepo.GetByID(ctx, req.RecipientBookID)
 	if err != nil {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", err)
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CreateExchange function exchange_services.go", "error", err)
 		return nil, err
 	}
 
-	if err := s.CheckIsTheSameUser(initiatorBook.UserID, recipientBook.UserID); err != nil {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", err)
+	if err := s.CheckIsTheSameUser(ctx, initiatorBook.UserID, recipientBook.UserID); err != nil {
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CreateExchange function exchange_services.go", "error", err)
 		return nil, err
 	}
 
-	if err := s.CheckInitiatorOwnsBook(actingUserID, initiatorBook); err != nil {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", err)
+	// Создавать обмены могут только пользователи с подтверждённым email
+	if initiatorBook.User == nil || initiatorBook.User.EmailVerifiedAt == nil {
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CreateExchange function exchange_services.go", "error", dto.ErrEmailNotVerified)
+		return nil, dto.ErrEmailNotVerified
+	}
+
+	if err := s.CheckInitiatorOwnsBook(ctx, actingUserID, initiatorBook); err != nil {
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CreateExchange function exchange_services.go", "error", err)
 		return nil, err
 	}
 
-	if err := s.CheckRecipientOwnsBook(req.RecipientID, recipientBook); err != nil {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", err)
+	if err := s.CheckRecipientOwnsBook(ctx, req.RecipientID, recipientBook); err != nil {
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CreateExchange function exchange_services.go", "error", err)
 		return nil, err
 	}
 
-	if err := s.CheckIsAvailable(initiatorBook, recipientBook); err != nil {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", err)
+	if err := s.CheckIsAvailable(ctx, initiatorBook, recipientBook); err != nil {
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CreateExchange function exchange_services.go", "error", err)
 		return nil, err
 	}
-	if err := s.exchangeRepo.CreateExchange(exchange); err != nil {
+	if err := s.exchangeRepo.CreateExchange(ctx, exchange); err != nil {
 		return nil, err
 	}
 
+	metrics.ExchangeEvents.WithLabelValues("created").Inc()
+	s.notify(ctx, exchange, events.TypeExchangeCreated, exchange.InitiatorID, exchange.RecipientID)
+	s.sendExchangeCreatedEmail(ctx, initiatorBook, recipientBook)
 	return exchange, nil
 }
 
-func (s *exchangeService) CheckInitiatorOwnsBook(initiatorID uint, initiatorBook *models.Book) error {
+// sendExchangeCreatedEmail ставит в очередь письмо получателю о новом предложении обмена
+func (s *exchangeService) sendExchangeCreatedEmail(ctx context.Context, initiatorBook, recipientBook *models.Book) {
+	if s.mailer == nil || recipientBook.User == nil || recipientBook.User.Email == "" {
+		return
+	}
+
+	recipient := recipientBook.User
+	initiatorName := ""
+	if initiatorBook.User != nil {
+		initiatorName = initiatorBook.User.Name
+	}
+
+	msg, err := mail.Render(recipient.Language, mail.TemplateExchangeCreated, recipient.Email, map[string]string{
+		"Name":          recipient.Name,
+		"InitiatorName": initiatorName,
+		"InitiatorBook": initiatorBook.Title,
+		"RecipientBook": recipientBook.Title,
+	})
+	if err != nil {
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in sendExchangeCreatedEmail function exchange_services.go", "error", err)
+		return
+	}
+
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in sendExchangeCreatedEmail function exchange_services.go", "error", err)
+	}
+}
+
+// notify отправляет событие об изменении обмена его участникам.
+// Ошибки публикации только логируются — обмен уже сохранён.
+func (s *exchangeService) notify(ctx context.Context, exchange *models.Exchange, eventType string, userIDs ...uint) {
+	publishExchangeEvent(ctx, s.publisher, s.log, exchange, eventType, userIDs...)
+}
+
+// publishExchangeEvent отправляет участникам событие об обмене; его же использует
+// удаление аккаунта, которое отменяет обмены в обход сервиса обменов
+func publishExchangeEvent(ctx context.Context, publisher events.Publisher, log *slog.Logger, exchange *models.Exchange, eventType string, userIDs ...uint) {
+	if publisher == nil {
+		return
+	}
+
+	// обмен уже сохранён — событие отправляем, даже если клиент отключился
+	ctx = context.WithoutCancel(ctx)
+
+	payload := dto.ExchangeResponse{
+		ID:              exchange.ID,
+		InitiatorID:     exchange.InitiatorID,
+		RecipientID:     exchange.RecipientID,
+		InitiatorBookID: exchange.InitiatorBookID,
+		RecipientBookID: exchange.RecipientBookID,
+		Status:          exchange.Status,
+		CompletedAt:     exchange.CompletedAt,
+		CreatedAt:       exchange.CreatedAt,
+		UpdatedAt:       exchange.UpdatedAt,
+	}
+
+	for _, userID := range userIDs {
+		if err := publisher.Publish(ctx, userID, eventType, payload); err != nil {
+			logging.From(ctx, log).ErrorContext(ctx, "error in notify function exchange_services.go", "user_id", userID, "error", err)
+		}
+	}
+}
+
+func (s *exchangeService) CheckInitiatorOwnsBook(ctx context.Context, initiatorID uint, initiatorBook *models.Book) error {
 	if initiatorID != initiatorBook.UserID {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", errors.New("initiator does not own the book"))
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CreateExchange function exchange_services.go", "error", errors.New("initiator does not own the book"))
 		return dto.ErrInitiatorNotOwner
 	}
 
 	return nil
 }
 
-func (s *exchangeService) CheckRecipientOwnsBook(recipientID uint, recipientBook *models.Book) error {
+func (s *exchangeService) CheckRecipientOwnsBook(ctx context.Context, recipientID uint, recipientBook *models.Book) error {
 	if recipientID != recipientBook.UserID {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", errors.New("recipient does not own the book"))
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CreateExchange function exchange_services.go", "error", errors.New("recipient does not own the book"))
 		return dto.ErrRecipientNotOwner
 	}
 
 	return nil
 }
 
-func (s *exchangeService) CheckIsTheSameUser(initiatorID uint, recipientID uint) error {
+func (s *exchangeService) CheckIsTheSameUser(ctx context.Context, initiatorID uint, recipientID uint) error {
 	if initiatorID == recipientID {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", errors.New("initiator and recipient book cannot be the same user"))
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CreateExchange function exchange_services.go", "error", errors.New("initiator and recipient book cannot be the same user"))
 		return errors.New("initiator and recipient book cannot be the same user")
 	}
 
 	return nil
 }
 
-func (s *exchangeService) CheckIsAvailable(initiatorBook *models.Book, recipientBook *models.Book) error {
+func (s *exchangeService) CheckIsAvailable(ctx context.Context, initiatorBook *models.Book, recipientBook *models.Book) error {
 	if initiatorBook.Status != "available" {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", errors.New("initiator book is unavailable"))
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CreateExchange function exchange_services.go", "error", errors.New("initiator book is unavailable"))
 		return dto.ErrUnavailable
 	}
 
 	if recipientBook.Status != "available" {
-		s.log.Error("error in CreateExchange function exchange_services.go", "error", errors.New("recipient book is unavailable"))
+		logging.From(ctx, s.log).ErrorContext(ctx, "error in CreateExchange function exchange_services.go", "error", errors.New("recipient book is unavailable"))
 		return dto.ErrRUnavailable
 	}
 
 	return nil
 }
 
-func (s *exchangeService) GetByID(exchangeID uint) (*models.Exchange, error) {
-	return s.exchangeRepo.GetByID(exchangeID)
+func (s *exchangeService) GetByID(ctx context.Context, exchangeID uint) (*models.Exchange, error) {
+	return s.exchangeRepo.GetByID(ctx, exchangeID)
 }
 
-func (s *exchangeService) GetAll() ([]models.Exchange, error) {
-	return s.exchangeRepo.GetAll()
+func (s *exchangeService) GetAll(ctx context.Context) ([]models.Exchange, error) {
+	return s.exchangeRepo.GetAll(ctx)
 }
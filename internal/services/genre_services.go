@@ -1,54 +1,146 @@
 package services
 
 import (
+	"context"
 	"strings"
 
 	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"github.com/dasler-fw/bookcrossing/internal/slug"
 )
 
 type GenreService interface {
-	Create(req dto.GenreCreateRequest) (*models.Genre, error)
-	GetByID(id uint) (*models.Genre, error)
-	List() ([]models.Genre, error)
-	Delete(id uint) error
+	Create(ctx context.Context, req dto.GenreCreateRequest) (*models.Genre, error)
+	GetByID(ctx context.Context, id uint) (*models.Genre, error)
+	GetBySlug(ctx context.Context, slug string) (*models.Genre, error)
+	List(ctx context.Context) ([]models.Genre, error)
+	// Tree — все жанры деревом, корни и дети по порядку создания
+	Tree(ctx context.Context) ([]dto.GenreNode, error)
+	Delete(ctx context.Context, id uint) error
+	// Merge сливает дубликаты в жанр targetID; доступно только админам
+	Merge(ctx context.Context, adminID, targetID uint, req dto.GenreMergeRequest) (*dto.GenreMergeResponse, error)
 }
 
 type genreService struct {
-	repo repository.GenreRepository
+	repo  repository.GenreRepository
+	users repository.UserRepository
 }
 
-func NewGenreService(repo repository.GenreRepository) GenreService {
-	return &genreService{repo: repo}
+func NewGenreService(repo repository.GenreRepository, users repository.UserRepository) GenreService {
+	return &genreService{repo: repo, users: users}
 }
 
-func (s *genreService) Create(req dto.GenreCreateRequest) (*models.Genre, error) {
+func (s *genreService) Create(ctx context.Context, req dto.GenreCreateRequest) (*models.Genre, error) {
 	name := strings.TrimSpace(req.Name)
 
 	if name == "" {
 		return nil, dto.ErrInvalidInput
 	}
 
+	genreSlug := strings.TrimSpace(req.Slug)
+	if genreSlug != "" && !slug.Valid(genreSlug) {
+		return nil, dto.ErrInvalidInput
+	}
+
 	genre := &models.Genre{
-		Name: name,
+		Name:     name,
+		NameEn:   strings.TrimSpace(req.NameEn),
+		Slug:     genreSlug,
+		ParentID: req.ParentID,
 	}
 
-	if err := s.repo.Create(genre); err != nil {
+	if err := s.repo.Create(ctx, genre); err != nil {
 		return nil, err
 	}
 
 	return genre, nil
 }
 
-func (s *genreService) GetByID(id uint) (*models.Genre, error) {
-	return s.repo.GetByID(id)
+func (s *genreService) GetByID(ctx context.Context, id uint) (*models.Genre, error) {
+	return s.repo.GetByID(ctx, id)
 }
 
-func (s *genreService) List() ([]models.Genre, error) {
-	return s.repo.List()
+func (s *genreService) GetBySlug(ctx context.Context, slug string) (*models.Genre, error) {
+	return s.repo.GetBySlug(ctx, slug)
 }
 
-func (s *genreService) Delete(id uint) error {
-	return s.repo.Delete(id)
+func (s *genreService) List(ctx context.Context) ([]models.Genre, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *genreService) Tree(ctx context.Context) ([]dto.GenreNode, error) {
+	genres, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	children := map[uint][]models.Genre{}
+	known := map[uint]bool{}
+	for _, g := range genres {
+		known[g.ID] = true
+	}
+	var roots []models.Genre
+	for _, g := range genres {
+		// родитель удалён — показываем жанр среди корней, чтобы он не пропал
+		if g.ParentID == nil || !known[*g.ParentID] {
+			roots = append(roots, g)
+			continue
+		}
+		children[*g.ParentID] = append(children[*g.ParentID], g)
+	}
+
+	var build func(list []models.Genre) []dto.GenreNode
+	build = func(list []models.Genre) []dto.GenreNode {
+		nodes := make([]dto.GenreNode, 0, len(list))
+		for _, g := range list {
+			nodes = append(nodes, dto.GenreNode{
+				ID:       g.ID,
+				Name:     g.Name,
+				NameEn:   g.NameEn,
+				Slug:     g.Slug,
+				Children: build(children[g.ID]),
+			})
+		}
+		return nodes
+	}
+	return build(roots), nil
+}
+
+func (s *genreService) Delete(ctx context.Context, id uint) error {
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *genreService) Merge(ctx context.Context, adminID, targetID uint, req dto.GenreMergeRequest) (*dto.GenreMergeResponse, error) {
+	user, err := s.users.GetByID(ctx, adminID)
+	if err != nil || user.Role != models.RoleAdmin {
+		return nil, dto.ErrAdminOnly
+	}
+
+	seen := map[uint]bool{}
+	sources := make([]uint, 0, len(req.SourceIDs))
+	for _, id := range req.SourceIDs {
+		if id == targetID {
+			return nil, dto.ErrGenreMergeInvalid
+		}
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		sources = append(sources, id)
+	}
+	if len(sources) == 0 {
+		return nil, dto.ErrInvalidInput
+	}
+
+	moved, err := s.repo.Merge(ctx, targetID, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.GenreMergeResponse{
+		TargetID:   targetID,
+		MergedIDs:  sources,
+		BooksMoved: moved,
+	}, nil
 }
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/geo"
+	"github.com/dasler-fw/bookcrossing/internal/logging"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+)
+
+// locate геокодирует непустые части ("адрес", "город") одним запросом — геокодер
+// предпочитает более раннюю. Ошибка геокодера не мешает сохранить профиль или книгу:
+// координаты просто остаются пустыми.
+func locate(ctx context.Context, geocoder geo.Geocoder, log *slog.Logger, parts ...string) (lat, lon *float64) {
+	var nonEmpty []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	if geocoder == nil || len(nonEmpty) == 0 {
+		return nil, nil
+	}
+
+	// сам запрос в лог не пишем: в нём может быть адрес
+	p, err := geocoder.Geocode(ctx, strings.Join(nonEmpty, ", "))
+	if err != nil {
+		if errors.Is(err, geo.ErrNotFound) {
+			logging.From(ctx, log).DebugContext(ctx, "место не найдено геокодером")
+		} else {
+			logging.From(ctx, log).WarnContext(ctx, "ошибка геокодирования", "err", err)
+		}
+		return nil, nil
+	}
+	return &p.Lat, &p.Lon
+}
+
+// locateUser — координаты пользователя только по городу. Адрес скрыт правилами
+// приватности, а по distance_km его книг можно было бы вычислить точку
+func locateUser(ctx context.Context, geocoder geo.Geocoder, log *slog.Logger, city string) (lat, lon *float64) {
+	return locate(ctx, geocoder, log, city)
+}
+
+// resolveOrigin превращает city + radius_km в точку поиска. Если город не найден,
+// поиск остаётся по подстроке города, а радиус не учитывается.
+func resolveOrigin(ctx context.Context, geocoder geo.Geocoder, log *slog.Logger, query *dto.BookListQuery) {
+	if query.Origin != nil || query.RadiusKm == 0 || query.City == "" {
+		return
+	}
+	lat, lon := locate(ctx, geocoder, log, query.City)
+	if lat == nil {
+		query.RadiusKm = 0
+		return
+	}
+	query.Origin = &geo.Point{Lat: *lat, Lon: *lon}
+	query.City = ""
+}
+
+// locationBackfillBatch — сколько пользователей геокодируется за один запрос к базе
+const locationBackfillBatch = 500
+
+// BackfillUserLocations заполняет координаты пользователей, зарегистрированных
+// до появления геокодера или геокодированных по адресу. Ненайденные места пропускаются и проверяются при следующем запуске.
+func BackfillUserLocations(ctx context.Context, users repository.UserRepository, geocoder geo.Geocoder, log *slog.Logger) error {
+	if geocoder == nil {
+		return nil
+	}
+
+	var lastID uint
+	located := 0
+	for {
+		batch, err := users.ListWithoutLocation(ctx, locationBackfillBatch, lastID)
+		if err != nil {
+			return err
+		}
+		for _, u := range batch {
+			lat, lon := locateUser(ctx, geocoder, log, u.City)
+			if lat == nil {
+				continue
+			}
+			if err := users.SetLocation(ctx, u.ID, lat, lon); err != nil {
+				return err
+			}
+			located++
+		}
+		if len(batch) < locationBackfillBatch {
+			break
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+
+	if located > 0 {
+		log.Info("user locations backfilled", "count", located)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/logging"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/moderation"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+)
+
+// ContentModerator проверяет текст перед сохранением и передаёт сработавшие
+// проверки в очередь модератора. Его используют сервисы отзывов и книг.
+type ContentModerator interface {
+	// Screen возвращает статус, с которым сохранить контент (current — статус до правки, "" при создании)
+	Screen(ctx context.Context, current, text string) (string, moderation.Verdict)
+	// Flag записывает причины сработавшей проверки для очереди модератора
+	Flag(ctx context.Context, contentType string, contentID uint, verdict moderation.Verdict)
+}
+
+// screenContent — статус для сохраняемого текста; без модератора контент публикуется сразу
+func screenContent(ctx context.Context, m ContentModerator, current, text string) (string, moderation.Verdict) {
+	if m == nil {
+		return moderation.NextStatus(current, moderation.Verdict{}), moderation.Verdict{}
+	}
+	return m.Screen(ctx, current, text)
+}
+
+func flagContent(ctx context.Context, m ContentModerator, contentType string, contentID uint, verdict moderation.Verdict) {
+	if m != nil {
+		m.Flag(ctx, contentType, contentID, verdict)
+	}
+}
+
+type ModerationService interface {
+	ContentModerator
+	Report(ctx context.Context, reporterID uint, contentType string, contentID uint, req dto.ReportRequest) error
+	Queue(ctx context.Context, moderatorID uint, limit int) ([]dto.ModerationQueueItem, error)
+	Approve(ctx context.Context, moderatorID uint, contentType string, contentID uint) error
+	Reject(ctx context.Context, moderatorID uint, contentType string, contentID uint) error
+}
+
+const (
+	maxReportReasonLen     = 500
+	defaultModerationQueue = 50
+)
+
+type moderationService struct {
+	repo    repository.ModerationRepository
+	reviews repository.ReviewRepository
+	books   repository.BookRepository
+	users   repository.UserRepository
+	checker moderation.Checker
+	// reportThreshold — после стольких открытых жалоб контент скрывается
+	reportThreshold int
+	log             *slog.Logger
+}
+
+func NewModerationService(
+	repo repository.ModerationRepository,
+	reviews repository.ReviewRepository,
+	books repository.BookRepository,
+	users repository.UserRepository,
+	checker moderation.Checker,
+	reportThreshold int,
+	log *slog.Logger,
+) ModerationService {
+	return &moderationService{
+		repo:            repo,
+		reviews:         reviews,
+		books:           books,
+		users:           users,
+		checker:         checker,
+		reportThreshold: reportThreshold,
+		log:             log,
+	}
+}
+
+func (s *moderationService) Screen(ctx context.Context, current, text string) (string, moderation.Verdict) {
+	verdict, err := s.checker.Check(ctx, text)
+	if err != nil {
+		// проверка недоступна — не блокируем публикацию, останутся жалобы
+		logging.From(ctx, s.log).WarnContext(ctx, "moderation check failed", "err", err)
+		verdict = moderation.Verdict{}
+	}
+	return moderation.NextStatus(current, verdict), verdict
+}
+
+func (s *moderationService) Flag(ctx context.Context, contentType string, contentID uint, verdict moderation.Verdict) {
+	if !verdict.Flagged {
+		return
+	}
+	// контент уже скрыт, без записи он просто не покажет причину в очереди
+	_ = s.repo.SaveSystemReport(ctx, contentType, contentID, "auto: "+strings.Join(verdict.Reasons, ", "))
+}
+
+// content — общие поля отзыва и книги, нужные модерации
+type content struct {
+	ownerID uint
+	status  string
+}
+
+func (s *moderationService) load(ctx context.Context, contentType string, id uint) (content, error) {
+	switch contentType {
+	case models.ContentReview:
+		review, err := s.reviews.GetByID(ctx, id)
+		if errors.Is(err, dto.ErrReviewNotFound) {
+			return content{}, dto.ErrContentNotFound
+		}
+		if err != nil {
+			return content{}, err
+		}
+		return content{ownerID: review.AuthorID, status: review.ModerationStatus}, nil
+	case models.ContentBook:
+		book, err := s.books.GetByID(ctx, id)
+		if errors.Is(err, dto.ErrorBookNotFound) {
+			return content{}, dto.ErrContentNotFound
+		}
+		if err != nil {
+			return content{}, err
+		}
+		return content{ownerID: book.UserID, status: book.ModerationStatus}, nil
+	default:
+		return content{}, dto.ErrContentNotFound
+	}
+}
+
+func (s *moderationService) setStatus(ctx context.Context, contentType string, id uint, from, to string) error {
+	if contentType == models.ContentReview {
+		return s.repo.SetReviewStatus(ctx, id, from, to)
+	}
+	return s.repo.SetBookStatus(ctx, id, from, to)
+}
+
+func (s *moderationService) Report(ctx context.Context, reporterID uint, contentType string, contentID uint, req dto.ReportRequest) error {
+	reason := strings.TrimSpace(req.Reason)
+	if length := len([]rune(reason)); length == 0 || length > maxReportReasonLen {
+		return dto.ErrReportReasonRequired
+	}
+
+	c, err := s.load(ctx, contentType, contentID)
+	if err != nil {
+		return err
+	}
+	if c.ownerID == reporterID {
+		return dto.ErrReportOwnContent
+	}
+
+	if err := s.repo.CreateReport(ctx, &models.ContentReport{
+		ContentType: contentType,
+		ContentID:   contentID,
+		ReporterID:  reporterID,
+		Reason:      reason,
+	}); err != nil {
+		return err
+	}
+
+	if c.status != moderation.StatusApproved {
+		return nil
+	}
+	count, err := s.repo.CountOpenUserReports(ctx, contentType, contentID)
+	if err != nil {
+		return err
+	}
+	if count < int64(s.reportThreshold) {
+		return nil
+	}
+	// жалоба уже сохранена; если статус успели сменить, решение за модератором
+	if err := s.setStatus(ctx, contentType, contentID, moderation.StatusApproved, moderation.StatusHeld); err != nil && !errors.Is(err, dto.ErrVersionConflict) {
+		return err
+	}
+	return nil
+}
+
+func (s *moderationService) requireModerator(ctx context.Context, userID uint) error {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return dto.ErrModeratorOnly
+	}
+	if user.Role != models.RoleModerator && user.Role != models.RoleAdmin {
+		return dto.ErrModeratorOnly
+	}
+	return nil
+}
+
+func (s *moderationService) Approve(ctx context.Context, moderatorID uint, contentType string, contentID uint) error {
+	return s.decide(ctx, moderatorID, contentType, contentID, moderation.StatusApproved)
+}
+
+func (s *moderationService) Reject(ctx context.Context, moderatorID uint, contentType string, contentID uint) error {
+	return s.decide(ctx, moderatorID, contentType, contentID, moderation.StatusRejected)
+}
+
+// decide выставляет решение модератора и закрывает жалобы на контент
+func (s *moderationService) decide(ctx context.Context, moderatorID uint, contentType string, contentID uint, status string) error {
+	if err := s.requireModerator(ctx, moderatorID); err != nil {
+		return err
+	}
+	c, err := s.load(ctx, contentType, contentID)
+	if err != nil {
+		return err
+	}
+	if c.status != status {
+		if err := s.setStatus(ctx, contentType, contentID, c.status, status); err != nil {
+			return err
+		}
+	}
+	logging.From(ctx, s.log).InfoContext(ctx, "moderation decision",
+		"content_type", contentType, "content_id", contentID, "status", status, "moderator_id", moderatorID)
+	return s.repo.ResolveReports(ctx, contentType, contentID)
+}
+
+func (s *moderationService) Queue(ctx context.Context, moderatorID uint, limit int) ([]dto.ModerationQueueItem, error) {
+	if err := s.requireModerator(ctx, moderatorID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultModerationQueue
+	}
+	if limit > dto.MaxLimit {
+		limit = dto.MaxLimit
+	}
+
+	reviews, err := s.repo.QueueReviews(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	books, err := s.repo.QueueBooks(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]dto.ModerationQueueItem, 0, len(reviews)+len(books))
+	reviewIDs := make([]uint, 0, len(reviews))
+	for _, r := range reviews {
+		reviewIDs = append(reviewIDs, r.ID)
+		items = append(items, dto.ModerationQueueItem{
+			ContentType: models.ContentReview, ContentID: r.ID, Status: r.ModerationStatus,
+			AuthorID: r.AuthorID, Text: r.Text, UpdatedAt: r.UpdatedAt,
+		})
+	}
+	bookIDs := make([]uint, 0, len(books))
+	for _, b := range books {
+		bookIDs = append(bookIDs, b.ID)
+		items = append(items, dto.ModerationQueueItem{
+			ContentType: models.ContentBook, ContentID: b.ID, Status: b.ModerationStatus,
+			AuthorID: b.UserID, Text: b.Description, UpdatedAt: b.UpdatedAt,
+		})
+	}
+
+	reports := map[string]map[uint][]models.ContentReport{}
+	for contentType, ids := range map[string][]uint{models.ContentReview: reviewIDs, models.ContentBook: bookIDs} {
+		list, err := s.repo.OpenReports(ctx, contentType, ids)
+		if err != nil {
+			return nil, err
+		}
+		reports[contentType] = map[uint][]models.ContentReport{}
+		for _, r := range list {
+			reports[contentType][r.ContentID] = append(reports[contentType][r.ContentID], r)
+		}
+	}
+	for i := range items {
+		items[i].Reasons = []string{}
+		for _, r := range reports[items[i].ContentType][items[i].ContentID] {
+			if r.ReporterID != 0 {
+				items[i].Reports++
+			}
+			items[i].Reasons = append(items[i].Reasons, r.Reason)
+		}
+	}
+
+	// дольше всех ждущие — первыми
+	sort.SliceStable(items, func(i, j int) bool { return items[i].UpdatedAt.Before(items[j].UpdatedAt) })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"math"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+)
+
+// Веса составляющих репутации
+const (
+	reputationRatingWeight     = 0.6
+	reputationCompletionWeight = 0.3
+	reputationCancelWeight     = 0.1
+
+	// сглаживание: пока отзывов мало, средняя тянется к нейтральной 3
+	ratingPriorCount = 5
+	ratingPriorValue = 3.0
+)
+
+// ExchangeCounts — закрытые обмены пользователя: завершённые и отменённые им самим.
+// Total — их сумма, от неё считаются доли
+type ExchangeCounts struct {
+	Total     int64
+	Completed int64
+	Cancelled int64
+}
+
+// NewRatingResponse переводит агрегаты из базы в ответ API
+func NewRatingResponse(s models.RatingSummary) dto.RatingResponse {
+	return dto.RatingResponse{
+		Average: math.Round(s.RatingAvg*100) / 100,
+		Count:   s.ReviewsCount,
+		Distribution: dto.RatingDistribution{
+			One:   s.Stars1,
+			Two:   s.Stars2,
+			Three: s.Stars3,
+			Four:  s.Stars4,
+			Five:  s.Stars5,
+		},
+	}
+}
+
+// ComputeReputation сводит оценки и историю обменов в балл от 0 до 100.
+// Оценка сглаживается к 3 звёздам, а доли обменов — к 1 завершённому из 2,
+// чтобы у новичка без истории балл был средним, а не нулевым.
+func ComputeReputation(rating models.RatingSummary, exchanges ExchangeCounts) dto.ReputationResponse {
+	avg := (float64(rating.RatingSum) + ratingPriorValue*ratingPriorCount) /
+		float64(rating.ReviewsCount+ratingPriorCount)
+	ratingScore := (avg - 1) / 4
+
+	completion := float64(exchanges.Completed+1) / float64(exchanges.Total+2)
+	cancellation := float64(exchanges.Cancelled) / float64(exchanges.Total+2)
+
+	score := 100 * (reputationRatingWeight*ratingScore +
+		reputationCompletionWeight*completion +
+		reputationCancelWeight*(1-cancellation))
+
+	resp := dto.ReputationResponse{
+		Score:          math.Round(score*10) / 10,
+		ExchangesCount: exchanges.Total,
+	}
+	if exchanges.Total > 0 {
+		resp.CompletionRate = roundRate(float64(exchanges.Completed) / float64(exchanges.Total))
+		resp.CancellationRate = roundRate(float64(exchanges.Cancelled) / float64(exchanges.Total))
+	}
+	return resp
+}
+
+func roundRate(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
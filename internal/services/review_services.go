@@ -1,30 +1,54 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/moderation"
 	"github.com/dasler-fw/bookcrossing/internal/repository"
 )
 
 type ReviewService interface {
-	Create(authorID uint, req dto.CreateReviewRequest) (*models.Review, error)
-	GetByUserID(userID uint) ([]models.Review, error)
-	GetByBookID(bookID uint) ([]models.Review, error)
-	Delete(reviewID uint, authorID uint) error
+	Create(ctx context.Context, authorID uint, req dto.CreateReviewRequest) (*models.Review, error)
+	GetByUserID(ctx context.Context, userID uint, query dto.ReviewListQuery) ([]models.Review, int64, error)
+	GetByBookID(ctx context.Context, bookID uint) ([]models.Review, error)
+	Delete(ctx context.Context, reviewID uint, authorID uint) error
+	Update(ctx context.Context, reviewID uint, authorID uint, req dto.UpdateReviewRequest) (*models.Review, error)
+	// GetRevisions — прежние редакции; viewerID 0 — аноним
+	GetRevisions(ctx context.Context, reviewID uint, viewerID uint) ([]models.ReviewRevision, error)
+	Reply(ctx context.Context, reviewID uint, userID uint, req dto.ReviewReplyRequest) (*models.ReviewReply, error)
 }
 
+const (
+	// reviewEditWindow — сколько после публикации автор может править отзыв
+	reviewEditWindow  = 7 * 24 * time.Hour
+	maxReviewReplyLen = 500
+)
+
 type reviewService struct {
-	repo repository.ReviewRepository
+	repo      repository.ReviewRepository
+	exchanges repository.ExchangeRepository
+	users     repository.UserRepository
+	books     repository.BookRepository
+	moderator ContentModerator
 }
 
-func NewReviewService(repo repository.ReviewRepository) ReviewService {
-	return &reviewService{repo: repo}
+// moderator может быть nil — тогда отзывы публикуются без проверки
+func NewReviewService(
+	repo repository.ReviewRepository,
+	exchanges repository.ExchangeRepository,
+	users repository.UserRepository,
+	books repository.BookRepository,
+	moderator ContentModerator,
+) ReviewService {
+	return &reviewService{repo: repo, exchanges: exchanges, users: users, books: books, moderator: moderator}
 }
 
-func (s *reviewService) Create(authorID uint, req dto.CreateReviewRequest) (*models.Review, error) {
+func (s *reviewService) Create(ctx context.Context, authorID uint, req dto.CreateReviewRequest) (*models.Review, error) {
 	trimmedText := strings.TrimSpace(req.Text)
 
 	length := len([]rune(trimmedText))
@@ -44,31 +68,88 @@ func (s *reviewService) Create(authorID uint, req dto.CreateReviewRequest) (*mod
 		return nil,dto.ErrSelfReviewForbidden
 	}
 
+	exchange, err := s.exchanges.GetByID(ctx, req.ExchangeID)
+	if err != nil {
+		return nil, err
+	}
+	if exchange.Status != "completed" {
+		return nil, dto.ErrReviewExchangeNotCompleted
+	}
+
+	// отзыв пишут о втором участнике и о книге, которую от него получили
+	var targetUserID, targetBookID uint
+	switch authorID {
+	case exchange.InitiatorID:
+		targetUserID, targetBookID = exchange.RecipientID, exchange.RecipientBookID
+	case exchange.RecipientID:
+		targetUserID, targetBookID = exchange.InitiatorID, exchange.InitiatorBookID
+	default:
+		return nil, dto.ErrReviewNotParticipant
+	}
+	if (req.TargetUserID != 0 && req.TargetUserID != targetUserID) ||
+		(req.TargetBookID != 0 && req.TargetBookID != targetBookID) {
+		return nil, dto.ErrReviewTargetMismatch
+	}
+
+	if err := s.checkTargets(ctx, targetUserID, targetBookID); err != nil {
+		return nil, err
+	}
+
+	exists, err := s.repo.ExistsForExchange(ctx, exchange.ID, authorID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, dto.ErrReviewAlreadyExists
+	}
+
+	status, verdict := screenContent(ctx, s.moderator, "", req.Text)
 	review := &models.Review{
-		AuthorID:     authorID,
-		TargetUserID: req.TargetUserID,
-		TargetBookID: req.TargetBookID,
-		Text:         req.Text,
-		Rating:       req.Rating,
+		AuthorID:         authorID,
+		TargetUserID:     targetUserID,
+		TargetBookID:     targetBookID,
+		ExchangeID:       &exchange.ID,
+		Text:             req.Text,
+		Rating:           req.Rating,
+		ModerationStatus: status,
 	}
 
-	if err:= s.repo.Create(review); err!= nil {
+	if err:= s.repo.Create(ctx, review); err!= nil {
 		return  nil, err
 	}
+	flagContent(ctx, s.moderator, models.ContentReview, review.ID, verdict)
 
 	return  review, nil
 }
 
-func (s *reviewService) GetByUserID(userID uint) ([]models.Review, error) {
-	return s.repo.GetByTargetUserID(userID)
+// checkTargets проверяет, что пользователь и книга ещё существуют
+func (s *reviewService) checkTargets(ctx context.Context, userID, bookID uint) error {
+	if _, err := s.users.GetByID(ctx, userID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return dto.ErrReviewTargetNotFound
+		}
+		return err
+	}
+	if _, err := s.books.GetByID(ctx, bookID); err != nil {
+		if errors.Is(err, dto.ErrorBookNotFound) {
+			return dto.ErrReviewTargetNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *reviewService) GetByUserID(ctx context.Context, userID uint, query dto.ReviewListQuery) ([]models.Review, int64, error) {
+	query.Normalize()
+	return s.repo.GetByTargetUserID(ctx, userID, query)
 }
 
-func (s *reviewService) GetByBookID(bookID uint) ([]models.Review, error) {
-	return s.repo.GetByTargetBookID(bookID)
+func (s *reviewService) GetByBookID(ctx context.Context, bookID uint) ([]models.Review, error) {
+	return s.repo.GetByTargetBookID(ctx, bookID)
 }
 
-func (s *reviewService) Delete(reviewID uint, authorID uint) error {
-	review, err := s.repo.GetByID(reviewID)
+func (s *reviewService) Delete(ctx context.Context, reviewID uint, authorID uint) error {
+	review, err := s.repo.GetByID(ctx, reviewID)
 	if err != nil {
 		return err
 	}
@@ -76,5 +157,118 @@ func (s *reviewService) Delete(reviewID uint, authorID uint) error {
 	if review.AuthorID != authorID {
 		return dto.ErrReviewDeleteForbidden
 	}
-	return s.repo.Delete(reviewID)
+	return s.repo.Delete(ctx, reviewID)
+}
+
+func (s *reviewService) Update(ctx context.Context, reviewID uint, authorID uint, req dto.UpdateReviewRequest) (*models.Review, error) {
+	review, err := s.repo.GetByID(ctx, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review.AuthorID != authorID {
+		return nil, dto.ErrReviewEditForbidden
+	}
+	if time.Since(review.CreatedAt) > reviewEditWindow {
+		return nil, dto.ErrReviewEditWindowClosed
+	}
+
+	previous := *review
+	if req.Text != nil {
+		text := strings.TrimSpace(*req.Text)
+		if length := len([]rune(text)); length < 10 || length > 150 {
+			return nil, dto.ErrReviewTextLength
+		}
+		review.Text = text
+	}
+	if req.Rating != nil {
+		if *req.Rating < 1 || *req.Rating > 5 {
+			return nil, dto.ErrInvalidRating
+		}
+		review.Rating = *req.Rating
+	}
+	// ничего не поменялось — новую редакцию не пишем
+	if review.Text == previous.Text && review.Rating == previous.Rating {
+		return review, nil
+	}
+
+	var verdict moderation.Verdict
+	if review.Text != previous.Text {
+		review.ModerationStatus, verdict = screenContent(ctx, s.moderator, previous.ModerationStatus, review.Text)
+	}
+
+	now := time.Now()
+	review.EditedAt = &now
+	if err := s.repo.Update(ctx, review, previous); err != nil {
+		return nil, err
+	}
+	flagContent(ctx, s.moderator, models.ContentReview, review.ID, verdict)
+	return review, nil
+}
+
+func (s *reviewService) GetRevisions(ctx context.Context, reviewID uint, viewerID uint) ([]models.ReviewRevision, error) {
+	review, err := s.repo.GetByID(ctx, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	privileged := s.canSeeHidden(ctx, review, viewerID)
+	// скрытый отзыв для остальных не существует
+	if review.ModerationStatus != moderation.StatusApproved && !privileged {
+		return nil, dto.ErrReviewNotFound
+	}
+
+	revisions, err := s.repo.GetRevisions(ctx, reviewID)
+	if err != nil || privileged {
+		return revisions, err
+	}
+	visible := make([]models.ReviewRevision, 0, len(revisions))
+	for _, r := range revisions {
+		if r.ModerationStatus == moderation.StatusApproved {
+			visible = append(visible, r)
+		}
+	}
+	return visible, nil
+}
+
+// canSeeHidden — автор и модераторы видят отзыв и его редакции до решения модерации
+func (s *reviewService) canSeeHidden(ctx context.Context, review *models.Review, viewerID uint) bool {
+	if viewerID == 0 {
+		return false
+	}
+	if viewerID == review.AuthorID {
+		return true
+	}
+	user, err := s.users.GetByID(ctx, viewerID)
+	if err != nil {
+		return false
+	}
+	return user.Role == models.RoleModerator || user.Role == models.RoleAdmin
+}
+
+func (s *reviewService) Reply(ctx context.Context, reviewID uint, userID uint, req dto.ReviewReplyRequest) (*models.ReviewReply, error) {
+	text := strings.TrimSpace(req.Text)
+	if length := len([]rune(text)); length == 0 || length > maxReviewReplyLen {
+		return nil, dto.ErrReviewReplyTextLength
+	}
+
+	review, err := s.repo.GetByID(ctx, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review.TargetUserID != userID {
+		return nil, dto.ErrReviewReplyForbidden
+	}
+	if review.Reply != nil {
+		return nil, dto.ErrReviewReplyExists
+	}
+
+	// ответ публикуется сразу, поэтому текст, не прошедший проверку, не сохраняем
+	if status, _ := screenContent(ctx, s.moderator, "", text); status != moderation.StatusApproved {
+		return nil, dto.ErrReviewReplyFlagged
+	}
+
+	reply := &models.ReviewReply{ReviewID: review.ID, AuthorID: userID, Text: text}
+	if err := s.repo.CreateReply(ctx, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
 }
@@ -1,51 +1,113 @@
 package services
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"log/slog"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/etag"
+	"github.com/dasler-fw/bookcrossing/internal/events"
+	"github.com/dasler-fw/bookcrossing/internal/geo"
+	"github.com/dasler-fw/bookcrossing/internal/logging"
 	"github.com/dasler-fw/bookcrossing/internal/jwtutil"
+	"github.com/dasler-fw/bookcrossing/internal/mail"
 	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/ratelimit"
 	"github.com/dasler-fw/bookcrossing/internal/repository"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type UserService interface {
-	Register(req dto.UserCreateRequest) (string, error)
-	Login(req dto.LoginRequest) (string, error)
-	GetUserByID(id uint) (*models.User, error)
-	UpdateUser(id uint, req dto.UserUpdateRequest) (*models.User, error)
-	ListUsers(limit int, lastID uint) ([]models.User, uint, error)
-	DeleteUser(id uint) error
-	GetProfile(userID uint) (*dto.UserProfileResponse, error)
-	GetUserExchanges(userID uint, status string) ([]models.Exchange, error)
+	Register(ctx context.Context, req dto.UserCreateRequest) (string, error)
+	Login(ctx context.Context, req dto.LoginRequest) (string, error)
+	GetUserByID(ctx context.Context, id uint) (*models.User, error)
+	UpdateUser(ctx context.Context, id uint, req dto.UserUpdateRequest) (*models.User, error)
+	ListUsers(ctx context.Context, limit int, lastID uint) ([]models.User, uint, error)
+	// DeleteUser удаляет аккаунт по запросу владельца, подтверждённому паролем
+	DeleteUser(ctx context.Context, id uint, password string) (*dto.DeleteAccountResponse, error)
+	// ExportData — выгрузка всех данных пользователя
+	ExportData(ctx context.Context, id uint) (*dto.UserDataExport, error)
+	// GetProfile — профиль userID глазами viewerID (0 — аноним)
+	GetProfile(ctx context.Context, viewerID, userID uint) (*dto.UserProfileResponse, error)
+	// GetUserExchanges — обмены пользователя вместе с участниками
+	GetUserExchanges(ctx context.Context, userID uint, status string) ([]models.Exchange, error)
+	// Relations — кем viewerID приходится каждому из userIDs; по нему скрываются поля профилей
+	Relations(ctx context.Context, viewerID uint, userIDs []uint) (map[uint]Relation, error)
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, userID uint) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, req dto.ResetPasswordRequest) error
 }
 
+const (
+	verifyEmailTokenTTL   = 24 * time.Hour
+	passwordResetTokenTTL = time.Hour
+	minPasswordLength     = 8
+)
+
 type userService struct {
-	db       *gorm.DB
-	userRepo repository.UserRepository
-	bookRepo repository.BookRepository
-	log      *slog.Logger
+	db        *gorm.DB
+	userRepo  repository.UserRepository
+	bookRepo  repository.BookRepository
+	tokenRepo repository.TokenRepository
+	mailer    mail.Mailer
+	publisher events.Publisher
+	guard     ratelimit.LoginGuard
+	geocoder  geo.Geocoder
+	// appBaseURL — адрес фронтенда для ссылок в письмах
+	appBaseURL string
+	// deletionGrace — через сколько после удаления аккаунта стираются его данные
+	deletionGrace time.Duration
+	log           *slog.Logger
 }
 
-func NewServiceUser(db *gorm.DB, userRepo repository.UserRepository, bookRepo repository.BookRepository, log *slog.Logger) UserService {
+// guard может быть nil — тогда блокировка после неудачных входов отключена;
+// geocoder nil — координаты пользователей не заполняются; publisher nil — участники
+// обменов, отменённых удалением аккаунта, не получают событий
+func NewServiceUser(db *gorm.DB, userRepo repository.UserRepository, bookRepo repository.BookRepository, tokenRepo repository.TokenRepository, mailer mail.Mailer, publisher events.Publisher, guard ratelimit.LoginGuard, geocoder geo.Geocoder, appBaseURL string, deletionGrace time.Duration, log *slog.Logger) UserService {
 	return &userService{
-		db:       db,
-		userRepo: userRepo,
-		bookRepo: bookRepo,
-		log:      log,
+		db:            db,
+		userRepo:      userRepo,
+		bookRepo:      bookRepo,
+		tokenRepo:     tokenRepo,
+		mailer:        mailer,
+		publisher:     publisher,
+		guard:         guard,
+		geocoder:      geocoder,
+		appBaseURL:    strings.TrimRight(appBaseURL, "/"),
+		deletionGrace: deletionGrace,
+		log:           log,
 	}
 }
 
-func (s *userService) Register(req dto.UserCreateRequest) (string, error) {
+func (s *userService) Register(ctx context.Context, req dto.UserCreateRequest) (string, error) {
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		return "", err
+	}
+	req.Email = email
+	if err := checkPassword(req.Password); err != nil {
+		return "", err
+	}
 
-	_, err := s.userRepo.GetByEmail(req.Email)
+	_, err = s.userRepo.GetByEmail(ctx, req.Email)
 	if err == nil {
 		return "", dto.ErrEmailAlreadyUsed
 	}
 
+	if req.Language != "" && !mail.IsSupportedLanguage(req.Language) {
+		return "", dto.ErrUnsupportedLanguage
+	}
+
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return "", err
@@ -57,18 +119,37 @@ func (s *userService) Register(req dto.UserCreateRequest) (string, error) {
 		PasswordHash: string(hash),
 		City:         req.City,
 		Address:      req.Address,
+		Language:     mail.NormalizeLanguage(req.Language),
 	}
+	user.Lat, user.Lon = locateUser(ctx, s.geocoder, s.log, user.City)
 
-	if err := s.userRepo.Create(user); err != nil {
+	if err := s.userRepo.Create(ctx, user); err != nil {
 		return "", err
 	}
 
+	// письмо не должно ломать регистрацию — его можно запросить повторно
+	if err := s.sendVerification(ctx, user); err != nil {
+		logging.From(ctx, s.log).ErrorContext(ctx, "не удалось отправить письмо подтверждения", "id", user.ID, "err", err)
+	}
+
 	return jwtutil.GenerateToken(user.ID)
 }
 
-func (s *userService) Login(req dto.LoginRequest) (string, error) {
-	user, err := s.userRepo.GetByEmail(req.Email)
+func (s *userService) Login(ctx context.Context, req dto.LoginRequest) (string, error) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	guardKey := loginGuardKey(email, req.ClientIP)
+
+	// Проверяем блокировку до bcrypt, чтобы перебор не нагружал CPU
+	if s.guard != nil {
+		if left, err := s.guard.Locked(ctx, guardKey); err == nil && left > 0 {
+			return "", &dto.AccountLockedError{RetryAfter: left}
+		}
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, email)
 	if err != nil {
+		// неизвестный email тоже считаем неудачей, чтобы не выдавать существование аккаунта
+		s.loginFailed(ctx, guardKey)
 		return "", dto.ErrInvalidCredentials
 	}
 
@@ -76,32 +157,75 @@ func (s *userService) Login(req dto.LoginRequest) (string, error) {
 		[]byte(user.PasswordHash),
 		[]byte(req.Password),
 	); err != nil {
+		s.loginFailed(ctx, guardKey)
 		return "", dto.ErrInvalidCredentials
 	}
 
+	if s.guard != nil {
+		_ = s.guard.Reset(ctx, guardKey)
+	}
+
 	return jwtutil.GenerateToken(user.ID)
 }
 
-func (s *userService) GetUserByID(id uint) (*models.User, error) {
-	user, err := s.userRepo.GetByID(id)
+func (s *userService) loginFailed(ctx context.Context, key string) {
+	if s.guard == nil {
+		return
+	}
+	if lock, err := s.guard.Fail(ctx, key); err == nil && lock > 0 {
+		// ключ не логируется: в нём email и IP
+		logging.From(ctx, s.log).WarnContext(ctx, "вход временно заблокирован после неудачных попыток", "lock", lock.String())
+	}
+}
+
+// loginGuardKey — счётчик неудач ведётся по аккаунту и IP: перебор с одного адреса
+// блокируется, а владелец с другого адреса продолжает входить
+func loginGuardKey(email, ip string) string {
+	if ip == "" {
+		return email
+	}
+	return email + "|" + ip
+}
+
+func (s *userService) GetUserByID(ctx context.Context, id uint) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, repository.ErrUserNotFound
 	}
 	return user, nil
 }
 
-func (s *userService) UpdateUser(id uint, req dto.UserUpdateRequest) (*models.User, error) {
-	user, err := s.userRepo.GetByID(id)
+func (s *userService) UpdateUser(ctx context.Context, id uint, req dto.UserUpdateRequest) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, repository.ErrUserNotFound
 	}
+	if err := etag.Check(ctx, user.Version); err != nil {
+		return nil, err
+	}
+	if req.Password != nil {
+		if err := checkPassword(*req.Password); err != nil {
+			return nil, err
+		}
+	}
 	if req.Name != nil {
 		user.Name = *req.Name
 	}
+	emailChanged := false
 	if req.Email != nil {
-		user.Email = *req.Email
+		email, err := normalizeEmail(*req.Email)
+		if err != nil {
+			return nil, err
+		}
+		if email != user.Email {
+			emailChanged = true
+			user.Email = email
+			// новый адрес нужно подтвердить заново
+			user.EmailVerifiedAt = nil
+		}
 	}
 
+	city := user.City
 	if req.City != nil {
 		user.City = *req.City
 	}
@@ -109,26 +233,50 @@ func (s *userService) UpdateUser(id uint, req dto.UserUpdateRequest) (*models.Us
 	if req.Address != nil {
 		user.Address = *req.Address
 	}
+	if user.City != city {
+		user.Lat, user.Lon = locateUser(ctx, s.geocoder, s.log, user.City)
+	}
+
+	if err := applyVisibility(user, req.Visibility); err != nil {
+		return nil, err
+	}
+
+	if req.Language != nil {
+		if !mail.IsSupportedLanguage(*req.Language) {
+			return nil, dto.ErrUnsupportedLanguage
+		}
+		user.Language = mail.NormalizeLanguage(*req.Language)
+	}
 	if req.Password != nil {
 		hash, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
 		if err != nil {
-			s.log.Error("ошибка хеширования пароля", "id", id, "err", err)
+			logging.From(ctx, s.log).ErrorContext(ctx, "ошибка хеширования пароля", "id", id, "err", err)
 			return nil, dto.ErrUserPasswordHashFailed
 		}
 		user.PasswordHash = string(hash)
 	}
-	if err := s.userRepo.Update(user); err != nil {
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		if errors.Is(err, dto.ErrVersionConflict) {
+			return nil, err
+		}
 		return nil, dto.ErrUserUpdateFailed
 	}
+
+	// как и при регистрации, письмо не ломает сохранение профиля
+	if emailChanged {
+		if err := s.sendVerification(ctx, user); err != nil {
+			logging.From(ctx, s.log).ErrorContext(ctx, "не удалось отправить письмо подтверждения", "id", user.ID, "err", err)
+		}
+	}
 	return user, nil
 }
 
-func (s *userService) ListUsers(limit int, lastID uint) ([]models.User, uint, error) {
+func (s *userService) ListUsers(ctx context.Context, limit int, lastID uint) ([]models.User, uint, error) {
     if limit <= 0 || limit > 1500000 {
         limit = 50
     }
 
-    users, err := s.userRepo.ListUsers(limit, lastID)
+    users, err := s.userRepo.ListUsers(ctx, limit, lastID)
     if err != nil {
         return nil, 0, err
     }
@@ -142,47 +290,270 @@ func (s *userService) ListUsers(limit int, lastID uint) ([]models.User, uint, er
 }
 
 
-func (s *userService) DeleteUser(id uint) error {
-    user, err := s.userRepo.GetByID(id)
-    if err != nil {
-        return err
-    }
-
-    return s.userRepo.Delete(user.ID) // передаём объект User
-}
-
-func (s *userService) GetProfile(userID uint) (*dto.UserProfileResponse, error) {
-	user, err := s.userRepo.GetByID(userID)
+func (s *userService) GetProfile(ctx context.Context, viewerID, userID uint) (*dto.UserProfileResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
 		return nil, repository.ErrUserNotFound
 	}
+	relations, err := s.Relations(ctx, viewerID, []uint{userID})
+	if err != nil {
+		return nil, dto.ErrUserProfileFailed
+	}
+	visible := RedactUser(*user, relations[userID])
 
-	books, err := s.bookRepo.GetByUserID(userID, "")
+	books, err := s.bookRepo.GetByUserID(ctx, userID, "")
 	if err != nil {
 		return nil, dto.ErrUserProfileFailed
 	}
 
-	var successfulExchanges int64
-	if err := s.db.Model(&models.Exchange{}).
-		Where("(initiator_id = ? OR recipient_id = ?) AND status = ?", userID, userID, "completed").
-		Count(&successfulExchanges).Error; err != nil {
+	var byStatus []struct {
+		Status string
+		Count  int64
+	}
+	// открытые обмены ещё ничем не закончились, а чужие отмены (в том числе удалением
+	// аккаунта второго участника) не зависят от пользователя — в репутацию они не идут
+	if err := s.db.WithContext(ctx).Model(&models.Exchange{}).
+		Select("status, COUNT(*) AS count").
+		Where("initiator_id = ? OR recipient_id = ?", userID, userID).
+		Where("status = ? OR (status = ? AND cancelled_by = ?)", "completed", "cancelled", userID).
+		Group("status").
+		Scan(&byStatus).Error; err != nil {
 		return nil, dto.ErrUserProfileStatsFailed
 	}
+	var exchanges ExchangeCounts
+	for _, row := range byStatus {
+		exchanges.Total += row.Count
+		switch row.Status {
+		case "completed":
+			exchanges.Completed = row.Count
+		case "cancelled":
+			exchanges.Cancelled = row.Count
+		}
+	}
+
+	// строки нет, пока о пользователе не писали отзывов
+	var rating models.UserRating
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Limit(1).Find(&rating).Error; err != nil {
+		return nil, dto.ErrUserProfileStatsFailed
+	}
+
 	return &dto.UserProfileResponse{
 		ID:                       user.ID,
 		Name:                     user.Name,
-		City:                     user.City,
+		City:                     visible.City,
+		Email:                    visible.Email,
+		Address:                  visible.Address,
 		BooksCount:               int64(len(books)),
-		SuccessfulExchangesCount: successfulExchanges,
+		SuccessfulExchangesCount: exchanges.Completed,
+		Rating:                   NewRatingResponse(rating.RatingSummary),
+		Reputation:               ComputeReputation(rating.RatingSummary, exchanges),
+		Version:                  user.Version,
 	}, nil
 }
 
 
-func (s *userService) GetUserExchanges(userID uint, status string) ([]models.Exchange, error) {
-	list, err := s.userRepo.GetUserExchanges(userID, status)
+func (s *userService) GetUserExchanges(ctx context.Context, userID uint, status string) ([]models.Exchange, error) {
+	list, err := s.userRepo.GetUserExchanges(ctx, userID, status)
 	if err != nil{
 		return nil, errors.New("not founds trade history")
 	}
 
 	return  list, err
 }
+
+func (s *userService) VerifyEmail(ctx context.Context, token string) error {
+	userToken, err := s.useToken(ctx, models.TokenPurposeVerifyEmail, token)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userToken.UserID)
+	if err != nil {
+		return repository.ErrUserNotFound
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return dto.ErrUserUpdateFailed
+	}
+
+	return nil
+}
+
+func (s *userService) ResendVerification(ctx context.Context, userID uint) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return repository.ErrUserNotFound
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return dto.ErrEmailAlreadyVerified
+	}
+
+	return s.sendVerification(ctx, user)
+}
+
+// ForgotPassword отправляет ссылку для сброса пароля.
+// Для неизвестного email ошибку не возвращаем, чтобы нельзя было перебирать адреса.
+func (s *userService) ForgotPassword(ctx context.Context, email string) error {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return nil
+	}
+
+	// действует только последняя выданная ссылка
+	if err := s.tokenRepo.InvalidateForUser(ctx, user.ID, models.TokenPurposePasswordReset); err != nil {
+		return err
+	}
+
+	token, expiresAt, err := s.issueToken(ctx, user.ID, models.TokenPurposePasswordReset, passwordResetTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.sendTokenEmail(ctx, user, mail.TemplatePasswordReset, "/reset-password", token, expiresAt)
+}
+
+func (s *userService) ResetPassword(ctx context.Context, req dto.ResetPasswordRequest) error {
+	if err := checkPassword(req.Password); err != nil {
+		return err
+	}
+
+	userToken, err := s.useToken(ctx, models.TokenPurposePasswordReset, req.Token)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userToken.UserID)
+	if err != nil {
+		return repository.ErrUserNotFound
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		logging.From(ctx, s.log).ErrorContext(ctx, "ошибка хеширования пароля", "id", user.ID, "err", err)
+		return dto.ErrUserPasswordHashFailed
+	}
+	user.PasswordHash = string(hash)
+
+	// пользователь получил письмо — значит, адрес настоящий
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return dto.ErrUserUpdateFailed
+	}
+
+	return s.tokenRepo.InvalidateForUser(ctx, user.ID, models.TokenPurposePasswordReset)
+}
+
+// checkPassword — одно правило для всех путей, где задаётся пароль
+func checkPassword(password string) error {
+	if len([]rune(password)) < minPasswordLength {
+		return dto.ErrPasswordTooShort
+	}
+	return nil
+}
+
+func (s *userService) sendVerification(ctx context.Context, user *models.User) error {
+	if err := s.tokenRepo.InvalidateForUser(ctx, user.ID, models.TokenPurposeVerifyEmail); err != nil {
+		return err
+	}
+
+	token, expiresAt, err := s.issueToken(ctx, user.ID, models.TokenPurposeVerifyEmail, verifyEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.sendTokenEmail(ctx, user, mail.TemplateVerifyEmail, "/verify-email", token, expiresAt)
+}
+
+// issueToken создаёт случайный токен и сохраняет его хэш
+func (s *userService) issueToken(ctx context.Context, userID uint, purpose string, ttl time.Duration) (string, time.Time, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(raw)
+	expiresAt := time.Now().Add(ttl)
+
+	if err := s.tokenRepo.Create(ctx, &models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// useToken проверяет токен и помечает его использованным
+func (s *userService) useToken(ctx context.Context, purpose string, token string) (*models.UserToken, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, dto.ErrTokenInvalid
+	}
+
+	userToken, err := s.tokenRepo.GetByHash(ctx, purpose, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if userToken.UsedAt != nil || time.Now().After(userToken.ExpiresAt) {
+		return nil, dto.ErrTokenInvalid
+	}
+
+	if err := s.tokenRepo.MarkUsed(ctx, userToken.ID); err != nil {
+		return nil, err
+	}
+
+	return userToken, nil
+}
+
+func (s *userService) sendTokenEmail(ctx context.Context, user *models.User, template string, path string, token string, expiresAt time.Time) error {
+	if s.mailer == nil {
+		return nil
+	}
+
+	msg, err := mail.Render(user.Language, template, user.Email, map[string]string{
+		"Name":      user.Name,
+		"Link":      s.appBaseURL + path + "?token=" + url.QueryEscape(token),
+		"ExpiresAt": expiresAt.UTC().Format("2006-01-02 15:04 MST"),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, msg)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeEmail приводит email к нижнему регистру и проверяет формат
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	addr, err := netmail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", dto.ErrInvalidEmail
+	}
+
+	return email, nil
+}
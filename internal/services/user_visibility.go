@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+)
+
+// Relation — кем зритель приходится владельцу профиля
+type Relation int
+
+const (
+	// RelationStranger — аноним или пользователь без принятого обмена с владельцем
+	RelationStranger Relation = iota
+	// RelationPartner — участник принятого обмена с владельцем
+	RelationPartner
+	RelationSelf
+)
+
+// fieldVisible: владелец видит всё, партнёр — public и partners, остальные — только public.
+// Пустой или неизвестный уровень считается private.
+func fieldVisible(level string, rel Relation) bool {
+	switch rel {
+	case RelationSelf:
+		return true
+	case RelationPartner:
+		return level == models.VisibilityPublic || level == models.VisibilityPartners
+	default:
+		return level == models.VisibilityPublic
+	}
+}
+
+func validVisibility(level string) bool {
+	switch level {
+	case models.VisibilityPublic, models.VisibilityPartners, models.VisibilityPrivate:
+		return true
+	}
+	return false
+}
+
+// NewUserResponse — профиль u глазами зрителя с отношением rel
+func NewUserResponse(u models.User, rel Relation) dto.UserResponse {
+	u = RedactUser(u, rel)
+	resp := dto.UserResponse{
+		ID:              u.ID,
+		Name:            u.Name,
+		Email:           u.Email,
+		City:            u.City,
+		Address:         u.Address,
+		Language:        u.Language,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		Version:         u.Version,
+	}
+	if rel == RelationSelf {
+		resp.Visibility = &dto.UserVisibility{
+			Email:   u.EmailVisibility,
+			Address: u.AddressVisibility,
+			City:    u.CityVisibility,
+		}
+	}
+	return resp
+}
+
+// RedactUser очищает поля, скрытые от зрителя, — для ответов, которые отдают модель целиком
+func RedactUser(u models.User, rel Relation) models.User {
+	if rel == RelationSelf {
+		return u
+	}
+	if !fieldVisible(u.EmailVisibility, rel) {
+		u.Email = ""
+	}
+	if !fieldVisible(u.AddressVisibility, rel) {
+		u.Address = ""
+	}
+	if !fieldVisible(u.CityVisibility, rel) {
+		u.City = ""
+	}
+	u.Language, u.EmailVerifiedAt = "", nil
+	return u
+}
+
+func (s *userService) Relations(ctx context.Context, viewerID uint, userIDs []uint) (map[uint]Relation, error) {
+	relations := make(map[uint]Relation, len(userIDs))
+	others := make([]uint, 0, len(userIDs))
+	for _, id := range userIDs {
+		if viewerID != 0 && id == viewerID {
+			relations[id] = RelationSelf
+			continue
+		}
+		relations[id] = RelationStranger
+		others = append(others, id)
+	}
+	if viewerID == 0 || len(others) == 0 {
+		return relations, nil
+	}
+
+	partners, err := s.userRepo.PartnerIDs(ctx, viewerID, others)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range partners {
+		relations[id] = RelationPartner
+	}
+	return relations, nil
+}
+
+// applyVisibility проверяет и применяет новые уровни видимости
+func applyVisibility(u *models.User, req *dto.UserVisibilityUpdate) error {
+	if req == nil {
+		return nil
+	}
+	for _, field := range []struct {
+		level  *string
+		target *string
+	}{
+		{req.Email, &u.EmailVisibility},
+		{req.Address, &u.AddressVisibility},
+		{req.City, &u.CityVisibility},
+	} {
+		if field.level == nil {
+			continue
+		}
+		if !validVisibility(*field.level) {
+			return dto.ErrInvalidVisibility
+		}
+		*field.target = *field.level
+	}
+	return nil
+}
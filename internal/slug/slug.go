@@ -0,0 +1,58 @@
+// Package slug строит URL-идентификаторы из названий: латиница в нижнем регистре,
+// цифры и дефисы. Кириллица транслитерируется.
+package slug
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// MaxLen — длина колонки slug в таблицах
+const MaxLen = 100
+
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+}
+
+var validRe = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+// Make возвращает slug для s; пустая строка — если в s нет ни букв, ни цифр
+func Make(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		var part string
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			part = string(r)
+		case translit[r] != "":
+			part = translit[r]
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' || r == '’':
+			// ъ, ь, апострофы и прочие алфавиты пропускаем, не разрывая слово
+			continue
+		default:
+			dash = b.Len() > 0
+			continue
+		}
+		if dash {
+			b.WriteByte('-')
+			dash = false
+		}
+		b.WriteString(part)
+	}
+	out := b.String()
+	if len(out) > MaxLen {
+		out = strings.TrimRight(out[:MaxLen], "-")
+	}
+	return out
+}
+
+// Valid сообщает, что s уже имеет вид slug
+func Valid(s string) bool {
+	return len(s) <= MaxLen && validRe.MatchString(s)
+}
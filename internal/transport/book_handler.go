@@ -1,27 +1,59 @@
 package transport
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/dasler-fw/bookcrossing/internal/cache"
 	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/metrics"
 	"github.com/dasler-fw/bookcrossing/internal/middleware"
 	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/moderation"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
 	"github.com/dasler-fw/bookcrossing/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
 type BookHandler struct {
 	service services.BookService
+	// Cache — кэш фасетов (Redis или память процесса); nil — без кэша
+	Cache cache.Cache
+	// CacheAvailable сообщает, жив ли Redis; если нет — кэш пропускается
+	CacheAvailable func() bool
 }
 
+// facetsCacheTTL — фасеты не сбрасываются при изменении книг, поэтому живут недолго
+const facetsCacheTTL = time.Minute
+
 func NewBookHandler(service services.BookService) *BookHandler {
 	return &BookHandler{service: service}
 }
 
-func (h *BookHandler) RegisterRoutes(r *gin.Engine) {
+func (h *BookHandler) RegisterRoutes(r gin.IRouter) {
+	books := r.Group("/books")
+	{
+		books.POST("", middleware.JWTAuth(), h.CreateBook)
+		books.GET("", h.Search)
+		books.GET("/available", h.GetAvailable)
+		books.GET("/:id", h.GetBookByID)
+		books.PATCH("/:id", middleware.JWTAuth(), h.UpdateBook)
+		books.DELETE("/:id", middleware.JWTAuth(), h.DeleteBook)
+	}
+	r.GET("/users/:id/books", h.GetByUserID)
+}
+
+// RegisterLegacyRoutes — пути без версии в том виде, в каком они были до /api/v1;
+// новые маршруты добавляются только в RegisterRoutes
+func (h *BookHandler) RegisterLegacyRoutes(r gin.IRouter) {
 	books := r.Group("/books")
 	{
 		books.POST("", middleware.JWTAuth(), h.CreateBook)
@@ -43,7 +75,7 @@ func (h *BookHandler) CreateBook(ctx *gin.Context) {
 
 	userID := ctx.GetUint("user_id")
 
-	book, err := h.service.CreateBook(userID, input)
+	book, err := h.service.CreateBook(ctx.Request.Context(), userID, input)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -59,13 +91,20 @@ func (h *BookHandler) GetBookByID(ctx *gin.Context) {
 		return
 	}
 
-	book, err := h.service.GetByID(uint(id))
+	book, err := h.service.GetByID(ctx.Request.Context(), uint(id))
 	if err != nil {
 		ctx.IndentedJSON(http.StatusNotFound, gin.H{"error": "book not found"})
 		return
 	}
 
-	ctx.IndentedJSON(http.StatusOK, book)
+	// маршрут публичный: владелец показывается как постороннему
+	if book.User != nil {
+		owner := services.RedactUser(*book.User, services.RelationStranger)
+		book.User = &owner
+	}
+	book.Description, book.AISummary = publicDescription(*book)
+
+	renderWithETag(ctx, http.StatusOK, book.Version, book)
 }
 
 func (h *BookHandler) UpdateBook(ctx *gin.Context) {
@@ -83,13 +122,17 @@ func (h *BookHandler) UpdateBook(ctx *gin.Context) {
 		return
 	}
 
-	book, err := h.service.Update(uint(bookID), userID, req)
+	book, err := h.service.Update(withIfMatch(ctx), uint(bookID), userID, req)
 	if err != nil {
+		if errors.Is(err, dto.ErrVersionConflict) {
+			ctx.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+			return
+		}
 		ctx.IndentedJSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
 	}
 
-	ctx.IndentedJSON(http.StatusOK, mapBookToResponse(*book))
+	renderWithETag(ctx, http.StatusOK, book.Version, mapBookToResponse(*book))
 }
 
 func (h *BookHandler) DeleteBook(ctx *gin.Context) {
@@ -101,7 +144,7 @@ func (h *BookHandler) DeleteBook(ctx *gin.Context) {
 
 	userID := ctx.GetUint("user_id")
 
-	if err := h.service.Delete(uint(bookID), userID); err != nil {
+	if err := h.service.Delete(ctx.Request.Context(), uint(bookID), userID); err != nil {
 		ctx.IndentedJSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
 	}
@@ -119,33 +162,111 @@ func (h *BookHandler) Search(ctx *gin.Context) {
 	query.Author = strings.TrimSpace(query.Author)
 	query.City = strings.TrimSpace(query.City)
 	query.Status = strings.TrimSpace(query.Status)
-	query.SortBy = strings.TrimSpace(query.SortBy)
-	query.SortOrder = strings.TrimSpace(query.SortOrder)
 	query.Title = strings.TrimSpace(query.Title)
+	if err := query.Normalize(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": searchQueryError(err)})
+		return
+	}
+
+	facetFields, err := query.FacetFields()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "facets: допустимы genre, city, status, author"})
+		return
+	}
+
+	page, err := h.service.SearchBooks(ctx.Request.Context(), query)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, dto.ErrInvalidCursor) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
 
-	books, total, err := h.service.SearchBooks(query)
+	facets, err := h.facets(ctx.Request.Context(), query, facetFields)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	respBooks := make([]dto.BookResponse, 0, len(books))
-	for _, b := range books {
-		respBooks = append(respBooks, mapBookToResponse(b))
+	respBooks := mapBookPage(page)
+
+	resp := dto.BookListResponse{
+		Data:           respBooks,
+		Limit:          query.Limit,
+		TotalEstimated: page.TotalEstimated,
+		NextCursor:     page.NextCursor,
+		Facets:         facets,
+	}
+	// с курсором номер страницы не имеет смысла
+	if query.Cursor == "" {
+		resp.Page = query.Page
+	}
+	if page.Total != nil {
+		total := int(*page.Total)
+		totalPages := int(math.Ceil(float64(total) / float64(query.Limit)))
+		resp.Total, resp.TotalPages = &total, &totalPages
 	}
 
-	totalPages := int(math.Ceil(float64(total) / float64(query.Limit)))
-	if query.Limit <= 0 {
-		totalPages = 0
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// facets считает фасеты через кэш; ключ — фильтры без пагинации и сортировки
+func (h *BookHandler) facets(ctx context.Context, query dto.BookListQuery, fields []string) (map[string][]dto.FacetValue, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	genreID := ""
+	if query.GenreID != nil {
+		genreID = strconv.FormatUint(uint64(*query.GenreID), 10)
+	}
+	radius := ""
+	if query.RadiusKm > 0 {
+		radius = strconv.FormatFloat(query.RadiusKm, 'f', -1, 64)
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		genreID,
+		strings.ToLower(query.City),
+		query.Near,
+		radius,
+		strings.ToLower(query.Author),
+		query.Status,
+		strings.ToLower(query.Title),
+		strings.Join(fields, ","),
+	}, "\x00")))
+	cacheKey := "books:facets:" + hex.EncodeToString(sum[:16])
+
+	useCache := h.Cache != nil && (h.CacheAvailable == nil || h.CacheAvailable())
+	if useCache {
+		cached, err := h.Cache.Get(ctx, cacheKey)
+		switch {
+		case err == nil:
+			var facets map[string][]dto.FacetValue
+			if json.Unmarshal(cached, &facets) == nil {
+				metrics.CacheRequests.WithLabelValues(metrics.CacheBookFacets, "hit").Inc()
+				return facets, nil
+			}
+			metrics.CacheRequests.WithLabelValues(metrics.CacheBookFacets, "error").Inc()
+		case errors.Is(err, cache.ErrMiss):
+			metrics.CacheRequests.WithLabelValues(metrics.CacheBookFacets, "miss").Inc()
+		default:
+			metrics.CacheRequests.WithLabelValues(metrics.CacheBookFacets, "error").Inc()
+		}
+	}
+
+	facets, err := h.service.SearchFacets(ctx, query, fields)
+	if err != nil {
+		return nil, err
 	}
 
-	ctx.JSON(http.StatusOK, dto.BookListResponse{
-		Data:       respBooks,
-		Page:       query.Page,
-		Limit:      query.Limit,
-		Total:      int(total),
-		TotalPages: totalPages,
-	})
+	if useCache {
+		if data, err := json.Marshal(facets); err == nil {
+			_ = h.Cache.Set(ctx, cacheKey, data, facetsCacheTTL)
+		}
+	}
+	return facets, nil
 }
 
 func mapBookToResponse(b models.Book) dto.BookResponse {
@@ -154,28 +275,52 @@ func mapBookToResponse(b models.Book) dto.BookResponse {
 		owner = dto.UserPublicResponse{
 			ID:   b.User.ID,
 			Name: b.User.Name,
-			City: b.User.City,
+			City: services.RedactUser(*b.User, services.RelationStranger).City,
 		}
 	}
 	genres := make([]dto.GenreResponse, 0, len(b.Genres))
+	rating := models.RatingSummary{}
+	if b.Rating != nil {
+		rating = b.Rating.RatingSummary
+	}
 
 	for _, g := range b.Genres {
-		genres = append(genres, dto.GenreResponse{ID: g.ID, Name: g.Name})
+		genres = append(genres, dto.GenreResponse{ID: g.ID, Name: g.Name, NameEn: g.NameEn, Slug: g.Slug})
 	}
 
+	description, summary := publicDescription(b)
+
 	return dto.BookResponse{
-		ID:          b.ID,
-		Title:       b.Title,
-		Author:      b.Author,
-		Description: b.Description,
-		AISummary:   b.AISummary,
-		Status:      b.Status,
-		CreatedAt:   b.CreatedAt,
-		Owner:       owner,
-		Genres:      genres,
+		ID:               b.ID,
+		Title:            b.Title,
+		Author:           b.Author,
+		Description:      description,
+		AISummary:        summary,
+		Status:           b.Status,
+		ModerationStatus: b.ModerationStatus,
+		Location:         b.Location,
+		CreatedAt:        b.CreatedAt,
+		Version:          b.Version,
+		Owner:            owner,
+		Genres:           genres,
+		Rating:           services.NewRatingResponse(rating),
 	}
 }
 
+// mapBookPage — книги страницы поиска; в поиске рядом с расстоянием, округлённым до 100 м
+func mapBookPage(page *repository.BookPage) []dto.BookResponse {
+	books := make([]dto.BookResponse, 0, len(page.Books))
+	for _, b := range page.Books {
+		resp := mapBookToResponse(b)
+		if km, ok := page.Distances[b.ID]; ok {
+			km = math.Round(km*10) / 10
+			resp.DistanceKm = &km
+		}
+		books = append(books, resp)
+	}
+	return books
+}
+
 func (h *BookHandler) GetByUserID(ctx *gin.Context) {
 	userIDStr := ctx.Param("id")
 	userID, err := strconv.ParseUint(userIDStr, 10, 64)
@@ -186,7 +331,7 @@ func (h *BookHandler) GetByUserID(ctx *gin.Context) {
 
 	status := ctx.Query("status")
 
-	books, err := h.service.GetBooksByUserID(uint(userID), status)
+	books, err := h.service.GetBooksByUserID(ctx.Request.Context(), uint(userID), status)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -200,10 +345,48 @@ func (h *BookHandler) GetByUserID(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, respBook)
 }
 
+// searchQueryError — текст ошибки разбора параметров поиска
+func searchQueryError(err error) string {
+	if errors.Is(err, dto.ErrInvalidLocation) {
+		return "near: ожидается lat,lon; radius_km — от 0 до 500, вместе с near или city"
+	}
+	return "count: допустимы exact, estimate, none"
+}
+
 func (h *BookHandler) GetAvailable(ctx *gin.Context) {
 	city := ctx.Query("city")
 
-	books, err := h.service.GetAvailableBooks(city)
+	if ctx.Query("near") != "" || ctx.Query("radius_km") != "" {
+		// поиск рядом: ближайшие MaxLimit доступных книг по расстоянию
+		query := dto.BookListQuery{
+			City:   strings.TrimSpace(city),
+			Near:   ctx.Query("near"),
+			Status: "available",
+			Limit:  dto.MaxLimit,
+			Count:  dto.CountNone,
+		}
+		if radius := ctx.Query("radius_km"); radius != "" {
+			var err error
+			if query.RadiusKm, err = strconv.ParseFloat(radius, 64); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": searchQueryError(dto.ErrInvalidLocation)})
+				return
+			}
+		}
+		if err := query.Normalize(); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": searchQueryError(err)})
+			return
+		}
+
+		page, err := h.service.SearchBooks(ctx.Request.Context(), query)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusOK, mapBookPage(page))
+		return
+	}
+
+	books, err := h.service.GetAvailableBooks(ctx.Request.Context(), city)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -216,3 +399,12 @@ func (h *BookHandler) GetAvailable(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, respBook)
 }
+
+// publicDescription — описание и AI-резюме, если их можно показать:
+// описание на модерации или отклонено — не показываем ни его, ни резюме по нему
+func publicDescription(b models.Book) (string, string) {
+	if b.ModerationStatus != "" && b.ModerationStatus != moderation.StatusApproved {
+		return "", ""
+	}
+	return b.Description, b.AISummary
+}
@@ -0,0 +1,36 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/dasler-fw/bookcrossing/internal/etag"
+	"github.com/gin-gonic/gin"
+)
+
+// renderWithETag отдаёт ресурс с заголовком ETag. Если клиент прислал
+// совпадающий If-None-Match, на чтение отвечаем 304 без тела.
+func renderWithETag(c *gin.Context, status int, version uint, obj any) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode response"})
+		return
+	}
+
+	tag := etag.Compute(version, data)
+	c.Header("ETag", tag)
+
+	if (c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead) &&
+		etag.NoneMatch(c.GetHeader("If-None-Match"), tag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(status, "application/json; charset=utf-8", data)
+}
+
+// withIfMatch передаёт заголовок If-Match сервису через контекст запроса
+func withIfMatch(c *gin.Context) context.Context {
+	return etag.WithIfMatch(c.Request.Context(), c.GetHeader("If-Match"))
+}
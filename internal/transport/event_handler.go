@@ -0,0 +1,92 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/events"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// heartbeatInterval — как часто шлём комментарий, чтобы прокси не рвали соединение
+const heartbeatInterval = 25 * time.Second
+
+type EventHandler struct {
+	subscriber events.Subscriber
+}
+
+func NewEventHandler(subscriber events.Subscriber) *EventHandler {
+	return &EventHandler{subscriber: subscriber}
+}
+
+func (h *EventHandler) RegisterEventRoutes(r gin.IRouter) {
+	r.GET("/events", middleware.JWTAuth(), h.Stream)
+}
+
+// Stream отдаёт события текущего пользователя через Server-Sent Events.
+// Для возобновления клиент передаёт заголовок Last-Event-ID
+// (или query-параметр last_event_id).
+func (h *EventHandler) Stream(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	if lastEventID != "" && !events.ValidID(lastEventID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Last-Event-ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	stream, err := h.subscriber.Subscribe(ctx, userID, lastEventID)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "event stream unavailable"})
+		return
+	}
+
+	// поток живёт дольше WriteTimeout сервера — снимаем дедлайн для этого соединения
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case event, ok := <-stream:
+			if !ok {
+				return
+			}
+			if err := writeSSE(c, event); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeSSE(c *gin.Context, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -19,7 +20,16 @@ func NewExchangeHandler(exchangeService services.ExchangeService) *ExchangeHandl
 	return &ExchangeHandler{exchangeService: exchangeService}
 }
 
-func (h *ExchangeHandler) RegisterExchangeRoutes(router *gin.Engine) {
+// Смена статуса обмена — действие, а не частичное обновление, поэтому POST
+func (h *ExchangeHandler) RegisterExchangeRoutes(router gin.IRouter) {
+	router.POST("/exchanges", middleware.JWTAuth(), h.CreateExchange)
+	router.POST("/exchanges/:id/accept", middleware.JWTAuth(), h.AcceptExchange)
+	router.POST("/exchanges/:id/complete", middleware.JWTAuth(), h.CompleteExchange)
+	router.POST("/exchanges/:id/cancel", middleware.JWTAuth(), h.CancelExchange)
+}
+
+// RegisterLegacyExchangeRoutes — старые пути до /api/v1, статус менялся через PUT
+func (h *ExchangeHandler) RegisterLegacyExchangeRoutes(router gin.IRouter) {
 	router.POST("/exchanges", middleware.JWTAuth(), h.CreateExchange)
 	router.PUT("/exchanges/:id/accept", middleware.JWTAuth(), h.AcceptExchange)
 	router.PUT("/exchanges/:id/complete", middleware.JWTAuth(), h.CompleteExchange)
@@ -34,7 +44,11 @@ func (h *ExchangeHandler) CancelExchange(c *gin.Context) {
 		return
 	}
 	actingUserID := c.GetUint("user_id")
-	if err := h.exchangeService.CancelExchange(uint(exchangeIDInt), actingUserID); err != nil {
+	if err := h.exchangeService.CancelExchange(withIfMatch(c), uint(exchangeIDInt), actingUserID); err != nil {
+		if errors.Is(err, dto.ErrVersionConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -51,7 +65,11 @@ func (h *ExchangeHandler) CompleteExchange(c *gin.Context) {
 	}
 
 	actingUserID := c.GetUint("user_id")
-	if err := h.exchangeService.CompleteExchange(uint(exchangeIDInt), actingUserID); err != nil {
+	if err := h.exchangeService.CompleteExchange(withIfMatch(c), uint(exchangeIDInt), actingUserID); err != nil {
+		if errors.Is(err, dto.ErrVersionConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -66,8 +84,12 @@ func (h *ExchangeHandler) CreateExchange(c *gin.Context) {
 		return
 	}
 	actingUserID := c.GetUint("user_id")
-	exchange, err := h.exchangeService.CreateExchange(&req, actingUserID)
+	exchange, err := h.exchangeService.CreateExchange(c.Request.Context(), &req, actingUserID)
 	if err != nil {
+		if errors.Is(err, dto.ErrEmailNotVerified) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -84,7 +106,11 @@ func (h *ExchangeHandler) AcceptExchange(c *gin.Context) {
 	}
 
 	actingUserID := c.GetUint("user_id")
-	if err := h.exchangeService.AcceptExchange(uint(exchangeIDInt), actingUserID); err != nil {
+	if err := h.exchangeService.AcceptExchange(withIfMatch(c), uint(exchangeIDInt), actingUserID); err != nil {
+		if errors.Is(err, dto.ErrVersionConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -98,17 +124,17 @@ func (h *ExchangeHandler) GetByID(c *gin.Context) {
 		return
 	}
 
-	exchange, err := h.exchangeService.GetByID(uint(exchangeID))
+	exchange, err := h.exchangeService.GetByID(c.Request.Context(), uint(exchangeID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, exchange)
+	c.JSON(http.StatusOK, mapExchangeToResponse(*exchange))
 }
 
 func (h *ExchangeHandler) GetAll(c *gin.Context) {
-	exchanges, err := h.exchangeService.GetAll()
+	exchanges, err := h.exchangeService.GetAll(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -133,5 +159,20 @@ func mapExchangeToResponse(e models.Exchange) dto.ExchangeResponse {
 		CompletedAt:     e.CompletedAt,
 		CreatedAt:       e.CreatedAt,
 		UpdatedAt:       e.UpdatedAt,
+		Version:         e.Version,
+	}
+}
+
+// mapExchangeWithParticipants добавляет участников, скрывая поля по отношению зрителя к каждому
+func mapExchangeWithParticipants(e models.Exchange, relations map[uint]services.Relation) dto.ExchangeResponse {
+	resp := mapExchangeToResponse(e)
+	if e.Initiator != nil {
+		u := services.NewUserResponse(*e.Initiator, relations[e.InitiatorID])
+		resp.Initiator = &u
+	}
+	if e.Recipient != nil {
+		u := services.NewUserResponse(*e.Recipient, relations[e.RecipientID])
+		resp.Recipient = &u
 	}
+	return resp
 }
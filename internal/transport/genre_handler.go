@@ -6,6 +6,8 @@ import (
 	"strconv"
 
 	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/dasler-fw/bookcrossing/internal/services"
 	"github.com/gin-gonic/gin"
 )
@@ -18,13 +20,27 @@ func NewGenreHandler(service services.GenreService) *GenreHandler {
 	return &GenreHandler{service: service}
 }
 
-func (h *GenreHandler) RegisterGenreRoutes(r *gin.Engine) {
+func (h *GenreHandler) RegisterGenreRoutes(r gin.IRouter) {
 	r.POST("/genres", h.Create)
 	r.GET("/genres", h.List)
+	r.GET("/genres/tree", h.Tree)
 	r.GET("/genres/:id", h.GetByID)
 	r.DELETE("/genres/:id", h.Delete)
 }
 
+// RegisterLegacyGenreRoutes — пути без версии в том виде, в каком они были до /api/v1
+func (h *GenreHandler) RegisterLegacyGenreRoutes(r gin.IRouter) {
+	r.POST("/genres", h.Create)
+	r.GET("/genres", h.List)
+	r.GET("/genres/:id", h.GetByID)
+	r.DELETE("/genres/:id", h.Delete)
+}
+
+// RegisterGenreAdminRoutes — операции над справочником только для админов, без алиаса до v1
+func (h *GenreHandler) RegisterGenreAdminRoutes(r gin.IRouter) {
+	r.POST("/genres/:id/merge", middleware.JWTAuth(), h.Merge)
+}
+
 func (h *GenreHandler) Create(c *gin.Context) {
 	var req dto.GenreCreateRequest
 
@@ -34,11 +50,12 @@ func (h *GenreHandler) Create(c *gin.Context) {
 		})
 		return
 	}
-	genre, err := h.service.Create(req)
+	genre, err := h.service.Create(c.Request.Context(), req)
 	if err != nil {
 		// map repository/service errors to HTTP codes
 		switch {
-		case errors.Is(err, dto.ErrInvalidInput):
+		case errors.Is(err, dto.ErrInvalidInput),
+			errors.Is(err, dto.ErrGenreParentNotFound):
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		case errors.Is(err, dto.ErrConflict):
@@ -55,7 +72,7 @@ func (h *GenreHandler) Create(c *gin.Context) {
 }
 
 func (h *GenreHandler) List(c *gin.Context) {
-	genres, err := h.service.List()
+	genres, err := h.service.List(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to get genres",
@@ -69,16 +86,21 @@ func (h *GenreHandler) List(c *gin.Context) {
 
 func (h *GenreHandler) GetByID(c *gin.Context) {
 	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
 
-	if err != nil || id <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid genre id",
-		})
-		return
+	// вместо id можно передать slug: /genres/science-fiction
+	var g *models.Genre
+	var err error
+	if id, convErr := strconv.Atoi(idStr); convErr == nil {
+		if id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid genre id",
+			})
+			return
+		}
+		g, err = h.service.GetByID(c.Request.Context(), uint(id))
+	} else {
+		g, err = h.service.GetBySlug(c.Request.Context(), idStr)
 	}
-
-	g, err := h.service.GetByID(uint(id))
 	if err != nil {
 		if errors.Is(err, dto.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "genre not found"})
@@ -108,11 +130,15 @@ func (h *GenreHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Delete(uint(id)); err != nil {
+	if err := h.service.Delete(c.Request.Context(), uint(id)); err != nil {
 		if errors.Is(err, dto.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "genre not found"})
 			return
 		}
+		if errors.Is(err, dto.ErrGenreHasChildren) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete genre"})
 		return
 	}
@@ -121,3 +147,51 @@ func (h *GenreHandler) Delete(c *gin.Context) {
 		"message": "genre deleted",
 	})
 }
+
+func (h *GenreHandler) Tree(c *gin.Context) {
+	tree, err := h.service.Tree(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get genres"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+func (h *GenreHandler) Merge(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid genre id"})
+		return
+	}
+
+	var req dto.GenreMergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	result, err := h.service.Merge(c.Request.Context(), userID.(uint), uint(id), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, dto.ErrInvalidInput),
+			errors.Is(err, dto.ErrGenreMergeInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, dto.ErrAdminOnly):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, dto.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "genre not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to merge genres"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,38 @@
+package transport
+
+import (
+	"net/http"
+
+	"github.com/dasler-fw/bookcrossing/internal/health"
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+func (h *HealthHandler) RegisterHealthRoutes(r *gin.Engine) {
+	r.GET("/healthz", h.Liveness)
+	r.GET("/readyz", h.Readiness)
+}
+
+// Liveness отвечает, пока процесс жив; зависимости не проверяются
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Readiness проверяет зависимости. degraded (упал только кэш) — всё ещё 200.
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	report := h.checker.Run(c.Request.Context())
+
+	status := http.StatusOK
+	if report.Status == health.StatusUnavailable {
+		status = http.StatusServiceUnavailable
+	}
+
+	c.JSON(status, report)
+}
@@ -0,0 +1,133 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ModerationHandler struct {
+	service services.ModerationService
+}
+
+func NewModerationHandler(service services.ModerationService) *ModerationHandler {
+	return &ModerationHandler{service: service}
+}
+
+func (h *ModerationHandler) RegisterModerationRoutes(r gin.IRouter) {
+	r.POST("/reviews/:id/report", middleware.JWTAuth(), h.report(models.ContentReview))
+	r.POST("/books/:id/report", middleware.JWTAuth(), h.report(models.ContentBook))
+
+	mod := r.Group("/moderation", middleware.JWTAuth())
+	mod.GET("/queue", h.Queue)
+	mod.POST("/reviews/:id/approve", h.decide(models.ContentReview, services.ModerationService.Approve))
+	mod.POST("/reviews/:id/reject", h.decide(models.ContentReview, services.ModerationService.Reject))
+	mod.POST("/books/:id/approve", h.decide(models.ContentBook, services.ModerationService.Approve))
+	mod.POST("/books/:id/reject", h.decide(models.ContentBook, services.ModerationService.Reject))
+}
+
+func (h *ModerationHandler) report(contentType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		contentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil || contentID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+
+		var req dto.ReportRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+
+		userID, ok := c.Get("user_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		if err := h.service.Report(c.Request.Context(), userID.(uint), contentType, uint(contentID), req); err != nil {
+			c.JSON(moderationErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "report accepted"})
+	}
+}
+
+func (h *ModerationHandler) Queue(c *gin.Context) {
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	items, err := h.service.Queue(c.Request.Context(), userID.(uint), limit)
+	if err != nil {
+		c.JSON(moderationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+// decide — общий хендлер approve/reject; action — метод сервиса с решением
+func (h *ModerationHandler) decide(
+	contentType string,
+	action func(services.ModerationService, context.Context, uint, string, uint) error,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		contentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil || contentID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+
+		userID, ok := c.Get("user_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		if err := action(h.service, c.Request.Context(), userID.(uint), contentType, uint(contentID)); err != nil {
+			c.JSON(moderationErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "decision saved"})
+	}
+}
+
+func moderationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dto.ErrReportOwnContent),
+		errors.Is(err, dto.ErrReportReasonRequired):
+		return http.StatusBadRequest
+	case errors.Is(err, dto.ErrModeratorOnly):
+		return http.StatusForbidden
+	case errors.Is(err, dto.ErrContentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, dto.ErrReportAlreadyExists),
+		errors.Is(err, dto.ErrVersionConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,391 @@
+package transport
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/health"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/openapi"
+	"github.com/gin-gonic/gin"
+)
+
+// Типы ниже описывают ответы, которые хендлеры собирают через gin.H.
+// Используются только для спецификации.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+type tokenResponse struct {
+	Token string `json:"token"`
+}
+
+type deletedResponse struct {
+	Deleted bool `json:"deleted"`
+}
+
+type statusResponse struct {
+	Status string `json:"status"`
+}
+
+type userListResponse struct {
+	Data []dto.UserResponse `json:"data"`
+	Meta struct {
+		Limit   int  `json:"limit"`
+		NextID  uint `json:"next_id"`
+		HasNext bool `json:"has_next"`
+	} `json:"meta"`
+}
+
+// apiOperation — описание одного маршрута из RegisterRoutes
+type apiOperation struct {
+	Method      string
+	Path        string
+	Tag         string
+	Summary     string
+	Description string
+	Auth        bool
+	Query       []openapi.Parameter
+	Body        any
+	Responses   map[int]any // nil — ответ без тела
+	// Conditional — чтение отдаёт ETag и понимает If-None-Match,
+	// изменение проверяет If-Match
+	Conditional bool
+
+	// У маршрутов, появившихся до v1, есть устаревший алиас без APIPrefix.
+	// LegacyMethod и LegacyPath задаются, если старый маршрут отличался.
+	LegacyMethod string
+	LegacyPath   string
+	// NoLegacy — маршрут появился уже в v1, алиаса без префикса нет
+	NoLegacy bool
+}
+
+func queryParam(name, typ, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: typ}}
+}
+
+// systemOperations — служебные маршруты без версии
+func systemOperations() []apiOperation {
+	return []apiOperation{
+		{Method: http.MethodGet, Path: "/healthz", Tag: "system", Summary: "Liveness-проверка",
+			Responses: map[int]any{200: statusResponse{}}},
+		{Method: http.MethodGet, Path: "/readyz", Tag: "system", Summary: "Readiness-проверка зависимостей",
+			Description: "degraded (недоступен только кэш) отдаётся с кодом 200",
+			Responses:   map[int]any{200: health.Report{}, 503: health.Report{}}},
+		{Method: http.MethodGet, Path: "/metrics", Tag: "system", Summary: "Метрики Prometheus (text/plain)",
+			Responses: map[int]any{200: nil}},
+		{Method: http.MethodGet, Path: "/openapi.json", Tag: "system", Summary: "Эта спецификация",
+			Responses: map[int]any{200: nil}},
+		{Method: http.MethodGet, Path: "/docs", Tag: "system", Summary: "Swagger UI",
+			Responses: map[int]any{200: nil}},
+	}
+}
+
+// apiOperations перечисляет маршруты API относительно APIPrefix.
+// TestOpenAPI_CoversAllRoutes падает, если маршрут есть в роутере, но не описан здесь.
+func apiOperations(doc *openapi.Document) []apiOperation {
+	errResp := errorResponse{}
+	return []apiOperation{
+		// книги
+		{Method: http.MethodPost, Path: "/books", Tag: "books", Summary: "Создать книгу", Auth: true,
+			Body:      dto.CreateBookRequest{},
+			Responses: map[int]any{201: dto.BookResponse{}, 500: errResp}},
+		{Method: http.MethodGet, Path: "/books", Tag: "books", Summary: "Поиск книг с фильтрами и пагинацией",
+			Description: "Для глубокого листания передавайте next_cursor из ответа в cursor: страница строится по ключу сортировки, а не через OFFSET, и не съезжает при добавлении книг. " +
+				"count=exact|estimate|none управляет подсчётом total; с курсором по умолчанию total не считается. " +
+				"facets=genre,city,status,author добавляет в ответ число книг по каждому значению под теми же фильтрами (до 20 самых частых). Фасеты кэшируются на минуту. " +
+				"near=lat,lon&radius_km= (по умолчанию 25, не больше 500) оставляет книги рядом и по умолчанию сортирует по расстоянию, distance_km — в каждой книге. " +
+				"city вместе с radius_km ищет вокруг этого города, в том числе в соседних.",
+			Query:     doc.QueryParams(dto.BookListQuery{}),
+			Responses: map[int]any{200: dto.BookListResponse{}, 400: errResp, 500: errResp}},
+		{Method: http.MethodGet, Path: "/books/available", Tag: "books", Summary: "Доступные для обмена книги",
+			Description: "С near или radius_km — до 100 ближайших книг по расстоянию",
+			Query: []openapi.Parameter{
+				queryParam("city", "string", "город владельца"),
+				queryParam("near", "string", "lat,lon — искать рядом с точкой"),
+				queryParam("radius_km", "number", "радиус поиска рядом, по умолчанию 25"),
+			},
+			Responses: map[int]any{200: []dto.BookResponse{}, 400: errResp, 500: errResp}},
+		{Method: http.MethodGet, Path: "/books/:id", Tag: "books", Summary: "Книга по id", Conditional: true,
+			Description: "В отличие от остальных маршрутов возвращает модель книги, а не BookResponse",
+			Responses:   map[int]any{200: models.Book{}, 400: errResp, 404: errResp}},
+		{Method: http.MethodPatch, Path: "/books/:id", Tag: "books", Summary: "Обновить описание или место выдачи книги", Auth: true, Conditional: true,
+			Body:      dto.UpdateBookRequest{},
+			Responses: map[int]any{200: dto.BookResponse{}, 400: errResp, 403: errResp}},
+		{Method: http.MethodDelete, Path: "/books/:id", Tag: "books", Summary: "Удалить книгу", Auth: true,
+			Responses: map[int]any{200: deletedResponse{}, 400: errResp, 403: errResp}},
+		{Method: http.MethodGet, Path: "/users/:id/books", Tag: "books", Summary: "Книги пользователя",
+			Query:     []openapi.Parameter{queryParam("status", "string", "available | reserved")},
+			Responses: map[int]any{200: []dto.BookResponse{}, 400: errResp, 500: errResp}},
+
+		// обмены
+		{Method: http.MethodPost, Path: "/exchanges", Tag: "exchanges", Summary: "Предложить обмен", Auth: true,
+			Body:      dto.CreateExchangeRequest{},
+			Responses: map[int]any{201: dto.ExchangeResponse{}, 400: errResp, 403: errResp, 500: errResp}},
+		{Method: http.MethodPost, Path: "/exchanges/:id/accept", LegacyMethod: http.MethodPut, Tag: "exchanges", Summary: "Принять обмен", Auth: true, Conditional: true,
+			Responses: map[int]any{200: messageResponse{}, 400: errResp, 500: errResp}},
+		{Method: http.MethodPost, Path: "/exchanges/:id/complete", LegacyMethod: http.MethodPut, Tag: "exchanges", Summary: "Завершить обмен", Auth: true, Conditional: true,
+			Responses: map[int]any{200: messageResponse{}, 400: errResp, 500: errResp}},
+		{Method: http.MethodPost, Path: "/exchanges/:id/cancel", LegacyMethod: http.MethodPut, Tag: "exchanges", Summary: "Отменить обмен", Auth: true, Conditional: true,
+			Responses: map[int]any{200: messageResponse{}, 400: errResp, 500: errResp}},
+
+		// жанры
+		{Method: http.MethodPost, Path: "/genres", Tag: "genres", Summary: "Создать жанр",
+			Description: "parent_id делает жанр поджанром; slug без указания строится из названия (name_en, иначе name).",
+			Body:        dto.GenreCreateRequest{},
+			Responses:   map[int]any{201: models.Genre{}, 400: errResp, 409: errResp, 500: errResp}},
+		{Method: http.MethodGet, Path: "/genres", Tag: "genres", Summary: "Список жанров",
+			Responses: map[int]any{200: []models.Genre{}, 500: errResp}},
+		{Method: http.MethodGet, Path: "/genres/tree", NoLegacy: true, Tag: "genres", Summary: "Дерево жанров",
+			Responses: map[int]any{200: []dto.GenreNode{}, 500: errResp}},
+		{Method: http.MethodGet, Path: "/genres/:id", Tag: "genres", Summary: "Жанр по id или slug",
+			Responses: map[int]any{200: models.Genre{}, 400: errResp, 404: errResp}},
+		{Method: http.MethodDelete, Path: "/genres/:id", Tag: "genres", Summary: "Удалить жанр",
+			Description: "Жанр с поджанрами удалить нельзя — сначала слейте или удалите их.",
+			Responses:   map[int]any{200: messageResponse{}, 400: errResp, 404: errResp, 409: errResp}},
+		{Method: http.MethodPost, Path: "/genres/:id/merge", NoLegacy: true, Tag: "genres", Summary: "Слить дубликаты в жанр", Auth: true,
+			Description: "Книги и поджанры дубликатов переходят к жанру из пути, дубликаты удаляются. Только для админов.",
+			Body:        dto.GenreMergeRequest{},
+			Responses:   map[int]any{200: dto.GenreMergeResponse{}, 400: errResp, 403: errResp, 404: errResp}},
+
+		// отзывы
+		{Method: http.MethodPost, Path: "/reviews", LegacyPath: "/review", Tag: "reviews", Summary: "Оставить отзыв", Auth: true,
+			Description: "Отзыв оставляет участник завершённого обмена о втором участнике и полученной от него книге, один на обмен.",
+			Body:        dto.CreateReviewRequest{},
+			Responses:   map[int]any{201: models.Review{}, 400: errResp, 403: errResp, 404: errResp, 409: errResp}},
+		{Method: http.MethodDelete, Path: "/reviews/:id", LegacyPath: "/review/:id", Tag: "reviews", Summary: "Удалить свой отзыв", Auth: true,
+			Responses: map[int]any{200: messageResponse{}, 400: errResp, 403: errResp}},
+		{Method: http.MethodPatch, Path: "/reviews/:id", NoLegacy: true, Tag: "reviews", Summary: "Изменить свой отзыв", Auth: true,
+			Description: "Править можно в течение 7 дней после публикации; прежняя редакция сохраняется в истории.",
+			Body:        dto.UpdateReviewRequest{},
+			Responses:   map[int]any{200: models.Review{}, 400: errResp, 403: errResp, 404: errResp, 409: errResp}},
+		{Method: http.MethodGet, Path: "/reviews/:id/revisions", NoLegacy: true, Tag: "reviews", Summary: "История правок отзыва",
+			Responses: map[int]any{200: []models.ReviewRevision{}, 400: errResp, 404: errResp}},
+		{Method: http.MethodPost, Path: "/reviews/:id/reply", NoLegacy: true, Tag: "reviews", Summary: "Ответить на отзыв о себе", Auth: true,
+			Description: "Ответ публичный, один на отзыв.",
+			Body:        dto.ReviewReplyRequest{},
+			Responses:   map[int]any{201: models.ReviewReply{}, 400: errResp, 403: errResp, 404: errResp, 409: errResp}},
+		{Method: http.MethodGet, Path: "/users/:id/reviews", LegacyPath: "/users/:id/review", Tag: "reviews", Summary: "Отзывы о пользователе",
+			Description: "Ответы пользователя встроены в отзывы (поле reply).",
+			Query:       doc.QueryParams(dto.ReviewListQuery{}),
+			Responses:   map[int]any{200: reviewListResponse{}, 400: errResp, 500: errResp}},
+		{Method: http.MethodGet, Path: "/books/:id/reviews", LegacyPath: "/book/:id/review", Tag: "reviews", Summary: "Отзывы о книге",
+			Responses: map[int]any{200: []models.Review{}, 400: errResp, 500: errResp}},
+
+		// пользователи
+		{Method: http.MethodPost, Path: "/users/register", Tag: "users", Summary: "Регистрация",
+			Body:      dto.UserCreateRequest{},
+			Responses: map[int]any{201: tokenResponse{}, 400: errResp, 409: errResp, 429: errResp}},
+		{Method: http.MethodPost, Path: "/users/login", Tag: "users", Summary: "Вход",
+			Body:      dto.LoginRequest{},
+			Responses: map[int]any{200: tokenResponse{}, 400: errResp, 401: errResp, 429: errResp}},
+		{Method: http.MethodPost, Path: "/users/verify-email", Tag: "users", Summary: "Подтвердить email",
+			Body:      dto.VerifyEmailRequest{},
+			Responses: map[int]any{200: messageResponse{}, 400: errResp}},
+		{Method: http.MethodPost, Path: "/users/verify-email/resend", Tag: "users", Summary: "Повторно отправить письмо", Auth: true,
+			Responses: map[int]any{202: messageResponse{}, 409: errResp}},
+		{Method: http.MethodPost, Path: "/users/forgot-password", Tag: "users", Summary: "Запросить сброс пароля",
+			Body:      dto.ForgotPasswordRequest{},
+			Responses: map[int]any{202: messageResponse{}, 400: errResp, 429: errResp}},
+		{Method: http.MethodPost, Path: "/users/reset-password", Tag: "users", Summary: "Сбросить пароль по токену",
+			Body:      dto.ResetPasswordRequest{},
+			Responses: map[int]any{200: messageResponse{}, 400: errResp}},
+		{Method: http.MethodGet, Path: "/users/:id", Tag: "users", Summary: "Профиль пользователя", Auth: true, Conditional: true,
+			Description: "Email, адрес и город показываются по настройкам видимости владельца: public — всем, partners — участникам принятого обмена с ним, private — только ему",
+			Responses:   map[int]any{200: dto.UserProfileResponse{}, 400: errResp, 404: errResp}},
+		{Method: http.MethodPatch, Path: "/users/:id", Tag: "users", Summary: "Обновить свой профиль", Auth: true, Conditional: true,
+			Body:      dto.UserUpdateRequest{},
+			Responses: map[int]any{200: dto.UserResponse{}, 400: errResp, 403: errResp, 404: errResp}},
+		{Method: http.MethodDelete, Path: "/users/:id", NoLegacy: true, Tag: "users", Summary: "Удалить свой аккаунт", Auth: true,
+			Description: "Открытые обмены отменяются, книги снимаются с обмена, отзывы остаются без автора; персональные данные стираются после purge_after",
+			Body:        dto.DeleteAccountRequest{},
+			Responses:   map[int]any{200: dto.DeleteAccountResponse{}, 400: errResp, 403: errResp, 404: errResp, 429: errResp}},
+		{Method: http.MethodGet, Path: "/users/:id/export", NoLegacy: true, Tag: "users", Summary: "Выгрузить свои данные", Auth: true,
+			Description: "По умолчанию ZIP (application/zip) с JSON-файлом на каждый раздел; format=json — те же разделы одним документом",
+			Query:       []openapi.Parameter{queryParam("format", "string", "zip | json, по умолчанию zip")},
+			Responses:   map[int]any{200: dto.UserDataExport{}, 400: errResp, 403: errResp, 404: errResp, 429: errResp}},
+		{Method: http.MethodGet, Path: "/users/:id/exchanges", Tag: "users", Summary: "История обменов пользователя", Auth: true,
+			Query:     []openapi.Parameter{queryParam("status", "string", "pending | accepted | completed | cancelled")},
+			Responses: map[int]any{200: []dto.ExchangeResponse{}, 400: errResp, 500: errResp}},
+		{Method: http.MethodGet, Path: "/users", Tag: "users", Summary: "Список пользователей (keyset-пагинация)",
+			Description: "Пользователи глазами анонима: только публичные поля",
+			Query: []openapi.Parameter{
+				queryParam("limit", "integer", "по умолчанию 50"),
+				queryParam("last_id", "integer", "next_id из предыдущей страницы"),
+				queryParam("nocache", "string", "1 — не использовать кэш"),
+			},
+			Responses: map[int]any{200: userListResponse{}, 500: errResp}},
+
+		// модерация
+		{Method: http.MethodPost, Path: "/reviews/:id/report", NoLegacy: true, Tag: "moderation", Summary: "Пожаловаться на отзыв", Auth: true,
+			Description: "Набрав порог жалоб, отзыв скрывается до решения модератора.",
+			Body:        dto.ReportRequest{},
+			Responses:   map[int]any{201: messageResponse{}, 400: errResp, 404: errResp, 409: errResp}},
+		{Method: http.MethodPost, Path: "/books/:id/report", NoLegacy: true, Tag: "moderation", Summary: "Пожаловаться на описание книги", Auth: true,
+			Description: "Набрав порог жалоб, описание скрывается до решения модератора.",
+			Body:        dto.ReportRequest{},
+			Responses:   map[int]any{201: messageResponse{}, 400: errResp, 404: errResp, 409: errResp}},
+		{Method: http.MethodGet, Path: "/moderation/queue", NoLegacy: true, Tag: "moderation", Summary: "Очередь модератора", Auth: true,
+			Description: "Скрытый контент и контент с открытыми жалобами, дольше всех ждущие — первыми. Только для модераторов.",
+			Query:       []openapi.Parameter{queryParam("limit", "integer", "по умолчанию 50, не больше 100")},
+			Responses:   map[int]any{200: []dto.ModerationQueueItem{}, 400: errResp, 403: errResp}},
+		{Method: http.MethodPost, Path: "/moderation/reviews/:id/approve", NoLegacy: true, Tag: "moderation", Summary: "Одобрить отзыв", Auth: true,
+			Responses: map[int]any{200: messageResponse{}, 400: errResp, 403: errResp, 404: errResp, 409: errResp}},
+		{Method: http.MethodPost, Path: "/moderation/reviews/:id/reject", NoLegacy: true, Tag: "moderation", Summary: "Отклонить отзыв", Auth: true,
+			Responses: map[int]any{200: messageResponse{}, 400: errResp, 403: errResp, 404: errResp, 409: errResp}},
+		{Method: http.MethodPost, Path: "/moderation/books/:id/approve", NoLegacy: true, Tag: "moderation", Summary: "Одобрить описание книги", Auth: true,
+			Responses: map[int]any{200: messageResponse{}, 400: errResp, 403: errResp, 404: errResp, 409: errResp}},
+		{Method: http.MethodPost, Path: "/moderation/books/:id/reject", NoLegacy: true, Tag: "moderation", Summary: "Отклонить описание книги", Auth: true,
+			Responses: map[int]any{200: messageResponse{}, 400: errResp, 403: errResp, 404: errResp, 409: errResp}},
+
+		// события
+		{Method: http.MethodGet, Path: "/events", Tag: "events", Summary: "Поток событий (Server-Sent Events)", Auth: true,
+			Description: "События смены статуса обменов: exchange.created, exchange.accepted, exchange.completed, exchange.cancelled. Для возобновления передайте заголовок Last-Event-ID",
+			Query:       []openapi.Parameter{queryParam("last_event_id", "string", "альтернатива заголовку Last-Event-ID")},
+			Responses:   map[int]any{200: nil, 400: errResp, 503: errResp}},
+	}
+}
+
+// BuildOpenAPI собирает спецификацию: служебные маршруты, маршруты под APIPrefix
+// и их устаревшие алиасы без версии (помечены deprecated)
+func BuildOpenAPI() *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:   "Bookcrossing API",
+		Version: "1.0.0",
+		Description: "Все маршруты, кроме служебных, ограничены общим лимитом запросов " +
+			"и при его превышении отвечают 429 с заголовком Retry-After.\n\n" +
+			"Пути без " + APIPrefix + " устарели: они отвечают с заголовками Deprecation и Sunset " +
+			"и будут удалены после " + legacySunset.Format("2006-01-02") + ".",
+	})
+
+	for _, op := range systemOperations() {
+		doc.Add(op.Method, op.Path, buildOperation(doc, op, op.Method))
+	}
+
+	for _, op := range apiOperations(doc) {
+		doc.Add(op.Method, APIPrefix+op.Path, buildOperation(doc, op, op.Method))
+		if op.NoLegacy {
+			continue
+		}
+
+		legacyMethod, legacyPath := op.Method, op.Path
+		if op.LegacyMethod != "" {
+			legacyMethod = op.LegacyMethod
+		}
+		if op.LegacyPath != "" {
+			legacyPath = op.LegacyPath
+		}
+		legacy := buildOperation(doc, op, legacyMethod)
+		legacy.Deprecated = true
+		legacy.Description = "Устаревший путь, используйте " + op.Method + " " + APIPrefix + op.Path
+		doc.Add(legacyMethod, legacyPath, legacy)
+	}
+
+	return doc
+}
+
+func buildOperation(doc *openapi.Document, op apiOperation, method string) *openapi.Operation {
+	operation := &openapi.Operation{
+		Tags:        []string{op.Tag},
+		Summary:     op.Summary,
+		Description: op.Description,
+		Parameters:  append([]openapi.Parameter(nil), op.Query...),
+		Responses:   map[string]*openapi.Response{},
+	}
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		// см. middleware.Idempotency
+		operation.Parameters = append(operation.Parameters, openapi.Parameter{
+			Name:        middleware.IdempotencyKeyHeader,
+			In:          "header",
+			Description: "повтор с тем же ключом и телом вернёт сохранённый ответ (24 часа)",
+			Schema:      &openapi.Schema{Type: "string"},
+		})
+		operation.Responses["409"] = &openapi.Response{
+			Description: "запрос с этим Idempotency-Key ещё выполняется",
+			Content:     doc.JSON(errorResponse{}),
+		}
+		operation.Responses["422"] = &openapi.Response{
+			Description: "Idempotency-Key уже использован с другим запросом",
+			Content:     doc.JSON(errorResponse{}),
+		}
+	}
+	if op.Body != nil {
+		operation.RequestBody = &openapi.RequestBody{Required: true, Content: doc.JSON(op.Body)}
+	}
+	if op.Conditional {
+		if method == http.MethodGet {
+			operation.Parameters = append(operation.Parameters, openapi.Parameter{
+				Name: "If-None-Match", In: "header", Schema: &openapi.Schema{Type: "string"},
+				Description: "ETag из предыдущего ответа; если ресурс не изменился — 304",
+			})
+			operation.Responses["304"] = &openapi.Response{Description: http.StatusText(http.StatusNotModified)}
+		} else {
+			operation.Parameters = append(operation.Parameters, openapi.Parameter{
+				Name: "If-Match", In: "header", Schema: &openapi.Schema{Type: "string"},
+				Description: "ETag или версия ресурса (\"3\"); при несовпадении — 412",
+			})
+			operation.Responses["412"] = &openapi.Response{
+				Description: "ресурс изменён другим запросом",
+				Content:     doc.JSON(errorResponse{}),
+			}
+		}
+	}
+	if op.Auth {
+		operation.Security = []map[string][]string{{openapi.BearerAuth: {}}}
+		operation.Responses[strconv.Itoa(http.StatusUnauthorized)] = &openapi.Response{
+			Description: http.StatusText(http.StatusUnauthorized),
+			Content:     doc.JSON(errorResponse{}),
+		}
+	}
+	for code, body := range op.Responses {
+		resp := &openapi.Response{Description: http.StatusText(code)}
+		if body != nil {
+			resp.Content = doc.JSON(body)
+		}
+		operation.Responses[strconv.Itoa(code)] = resp
+	}
+	return operation
+}
+
+// swaggerUIPage грузит Swagger UI с CDN, чтобы не тащить статику в бинарник
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Bookcrossing API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>`
+
+// RegisterOpenAPIRoutes отдаёт спецификацию на /openapi.json и Swagger UI на /docs
+func RegisterOpenAPIRoutes(r *gin.Engine) {
+	spec, err := json.Marshal(BuildOpenAPI())
+	if err != nil {
+		// спецификация собирается из статических типов — ошибка здесь означает баг
+		panic(err)
+	}
+
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", spec)
+	})
+	r.GET("/docs", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+	})
+}
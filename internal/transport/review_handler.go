@@ -1,11 +1,13 @@
 package transport
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/dasler-fw/bookcrossing/internal/services"
 	"github.com/gin-gonic/gin"
 	
@@ -19,7 +21,18 @@ func NewReviewHandler(service services.ReviewService) *ReviewHandler {
 	return &ReviewHandler{service: service}
 }
 
-func (h *ReviewHandler) RegisterReviewRoutes(r *gin.Engine) {
+func (h *ReviewHandler) RegisterReviewRoutes(r gin.IRouter) {
+	r.POST("/reviews", middleware.JWTAuth(), h.Create)
+	r.DELETE("/reviews/:id", middleware.JWTAuth(), h.Delete)
+	r.PATCH("/reviews/:id", middleware.JWTAuth(), h.Update)
+	r.GET("/reviews/:id/revisions", middleware.OptionalJWTAuth(), h.GetRevisions)
+	r.POST("/reviews/:id/reply", middleware.JWTAuth(), h.Reply)
+	r.GET("/users/:id/reviews", h.GetByUser)
+	r.GET("/books/:id/reviews", h.GetByBook)
+}
+
+// RegisterLegacyReviewRoutes — старые пути в единственном числе, до /api/v1
+func (h *ReviewHandler) RegisterLegacyReviewRoutes(r gin.IRouter) {
 	r.POST("/review",middleware.JWTAuth(), h.Create)
 	r.DELETE("/review/:id",middleware.JWTAuth(), h.Delete)
 	r.GET("/users/:id/review", h.GetByUser)
@@ -44,9 +57,9 @@ func (h *ReviewHandler) Create(c *gin.Context) {
 		return
 	}
 
-	rev, err := h.service.Create(authorID.(uint), req); 
+	rev, err := h.service.Create(c.Request.Context(), authorID.(uint), req); 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+		c.JSON(reviewErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -64,15 +77,31 @@ func (h *ReviewHandler) GetByUser(c *gin.Context) {
 		return
 	}
 
-	reviews, err := h.service.GetByUserID(uint(userID))
+	var query dto.ReviewListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid query parameters",
+		})
+		return
+	}
+	query.Normalize()
+
+	reviews, total, err := h.service.GetByUserID(c.Request.Context(), uint(userID), query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to get reviews",
 		})
 		return
 	}
+	redactReviewUsers(reviews)
 
-	c.JSON(http.StatusOK, reviews)
+	c.JSON(http.StatusOK, reviewListResponse{
+		Data:       reviews,
+		Page:       query.Page,
+		Limit:      query.Limit,
+		Total:      int(total),
+		TotalPages: int((total + int64(query.Limit) - 1) / int64(query.Limit)),
+	})
 }
 
 func (h *ReviewHandler) GetByBook(c *gin.Context) {
@@ -86,13 +115,14 @@ func (h *ReviewHandler) GetByBook(c *gin.Context) {
 		return
 	}
 
-	review, err := h.service.GetByBookID(uint(bookID))
+	review, err := h.service.GetByBookID(c.Request.Context(), uint(bookID))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to get review",
 		})
 		return
 	}
+	redactReviewUsers(review)
 
 	c.JSON(http.StatusOK, review)
 }
@@ -116,7 +146,7 @@ func (h *ReviewHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Delete(uint(reviewID), authorID.(uint)); err != nil {
+	if err := h.service.Delete(c.Request.Context(), uint(reviewID), authorID.(uint)); err != nil {
 		c.JSON(http.StatusForbidden, gin.H{
 			"error": err.Error(),
 		})
@@ -127,3 +157,127 @@ func (h *ReviewHandler) Delete(c *gin.Context) {
 		"message": "review deleted",
 	})
 }
+
+func (h *ReviewHandler) Update(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid review id"})
+		return
+	}
+
+	var req dto.UpdateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	authorID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	rev, err := h.service.Update(c.Request.Context(), uint(reviewID), authorID.(uint), req)
+	if err != nil {
+		c.JSON(reviewErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rev)
+}
+
+func (h *ReviewHandler) GetRevisions(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid review id"})
+		return
+	}
+
+	revisions, err := h.service.GetRevisions(c.Request.Context(), uint(reviewID), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(reviewErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+func (h *ReviewHandler) Reply(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid review id"})
+		return
+	}
+
+	var req dto.ReviewReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	reply, err := h.service.Reply(c.Request.Context(), uint(reviewID), userID.(uint), req)
+	if err != nil {
+		c.JSON(reviewErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, reply)
+}
+
+// reviewListResponse — страница отзывов о пользователе, ответы встроены в отзывы
+type reviewListResponse struct {
+	Data       []models.Review `json:"data"`
+	Page       int             `json:"page"`
+	Limit      int             `json:"limit"`
+	Total      int             `json:"total"`
+	TotalPages int             `json:"total_pages"`
+}
+
+func reviewErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, dto.ErrReviewTextLength),
+		errors.Is(err, dto.ErrInvalidRating),
+		errors.Is(err, dto.ErrSelfReviewForbidden),
+		errors.Is(err, dto.ErrReviewTargetMismatch),
+		errors.Is(err, dto.ErrReviewReplyTextLength):
+		return http.StatusBadRequest
+	case errors.Is(err, dto.ErrReviewNotParticipant),
+		errors.Is(err, dto.ErrReviewEditForbidden),
+		errors.Is(err, dto.ErrReviewEditWindowClosed),
+		errors.Is(err, dto.ErrReviewReplyForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, dto.ErrExchangeGetFailed),
+		errors.Is(err, dto.ErrReviewTargetNotFound),
+		errors.Is(err, dto.ErrReviewNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, dto.ErrReviewExchangeNotCompleted),
+		errors.Is(err, dto.ErrReviewAlreadyExists),
+		errors.Is(err, dto.ErrReviewReplyExists),
+		errors.Is(err, dto.ErrVersionConflict):
+		return http.StatusConflict
+	case errors.Is(err, dto.ErrReviewReplyFlagged):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// redactReviewUsers — отзывы публичны: автор и адресат показываются как посторонним
+func redactReviewUsers(reviews []models.Review) {
+	for i := range reviews {
+		if u := reviews[i].Author; u != nil {
+			author := services.RedactUser(*u, services.RelationStranger)
+			reviews[i].Author = &author
+		}
+		if u := reviews[i].TargetUser; u != nil {
+			target := services.RedactUser(*u, services.RelationStranger)
+			reviews[i].TargetUser = &target
+		}
+	}
+}
@@ -2,10 +2,27 @@ package transport
 
 import (
 	"log/slog"
+	"time"
 
+	"github.com/dasler-fw/bookcrossing/internal/cache"
+	"github.com/dasler-fw/bookcrossing/internal/events"
+	"github.com/dasler-fw/bookcrossing/internal/health"
+	"github.com/dasler-fw/bookcrossing/internal/idempotency"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/ratelimit"
 	"github.com/dasler-fw/bookcrossing/internal/services"
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// APIPrefix — префикс текущей версии API. Служебные маршруты
+// (/healthz, /readyz, /metrics, /openapi.json, /docs) живут без версии.
+const APIPrefix = "/api/v1"
+
+var (
+	legacyDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	legacySunset       = time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC)
 )
 
 func RegisterRoutes(
@@ -16,19 +33,80 @@ func RegisterRoutes(
 	genreService services.GenreService,
 	reviewService services.ReviewService,
 	userService services.UserService,
-	rdb *redis.Client,
+	moderationService services.ModerationService,
+	responseCache cache.Cache,
+	eventSubscriber events.Subscriber,
+	limiter ratelimit.Limiter,
+	idempotencyStore idempotency.Store,
+	checker *health.Checker,
+	ready health.CheckFunc,
+	redisMonitor *health.Monitor,
 ) {
+	// health-проверки, /metrics и документацию API регистрируем до access-лога, метрик и лимитера,
+	// чтобы опросы оркестратора и Prometheus не засоряли логи, статистику и не получали 429
+	router.Use(middleware.Recovery())
+	NewHealthHandler(checker).RegisterHealthRoutes(router)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	RegisterOpenAPIRoutes(router)
+
+	// span на каждый запрос; GORM, Redis и исходящие HTTP-вызовы становятся его детьми
+	router.Use(otelgin.Middleware("bookcrossing"))
+	router.Use(middleware.RequestID(log), middleware.AccessLog())
+	router.Use(middleware.Metrics())
+
+	// пока не закончились миграции, API отвечает 503 (ready == nil — база уже готова)
+	if ready != nil {
+		router.Use(middleware.Ready(ready))
+	}
+
+	// общий лимит: по пользователю, для анонимных запросов — по IP
+	if limiter != nil {
+		router.Use(middleware.RateLimit(limiter, middleware.RateLimitRule{
+			Name:   "global",
+			Limit:  300,
+			Window: time.Minute,
+			Key:    middleware.ByUserOrIP,
+		}))
+	}
+
+	// повторы POST/PUT/PATCH с тем же Idempotency-Key получают сохранённый ответ
+	if idempotencyStore != nil {
+		router.Use(middleware.Idempotency(idempotencyStore))
+	}
+
 	bookHandler := NewBookHandler(bookService)
 	exchangeHandler := NewExchangeHandler(exchangeService)
 	genreHandler := NewGenreHandler(genreService)
 	reviewHandler := NewReviewHandler(reviewService)
 	userHandler := NewUserHandler(userService)
-	// wire Redis client for handlers that use caching
-	userHandler.Redis = rdb
-
-	bookHandler.RegisterRoutes(router)
-	exchangeHandler.RegisterExchangeRoutes(router)
-	genreHandler.RegisterGenreRoutes(router)
-	reviewHandler.RegisterReviewRoutes(router)
-	userHandler.RegisterRoutes(router)
+	moderationHandler := NewModerationHandler(moderationService)
+	eventHandler := NewEventHandler(eventSubscriber)
+	// wire cache for handlers that use caching
+	userHandler.Cache = responseCache
+	bookHandler.Cache = responseCache
+	if redisMonitor != nil {
+		userHandler.CacheAvailable = redisMonitor.Available
+		bookHandler.CacheAvailable = redisMonitor.Available
+	}
+	userHandler.Limiter = limiter
+
+	api := router.Group(APIPrefix)
+	bookHandler.RegisterRoutes(api)
+	exchangeHandler.RegisterExchangeRoutes(api)
+	genreHandler.RegisterGenreRoutes(api)
+	genreHandler.RegisterGenreAdminRoutes(api)
+	reviewHandler.RegisterReviewRoutes(api)
+	userHandler.RegisterRoutes(api)
+	moderationHandler.RegisterModerationRoutes(api)
+	eventHandler.RegisterEventRoutes(api)
+
+	// старые пути без версии работают до legacySunset, чтобы клиенты успели переехать.
+	// Набор заморожен на момент появления v1: новые маршруты сюда не попадают
+	legacy := router.Group("", middleware.Deprecated(legacyDeprecatedAt, legacySunset, "/docs"))
+	bookHandler.RegisterLegacyRoutes(legacy)
+	exchangeHandler.RegisterLegacyExchangeRoutes(legacy)
+	genreHandler.RegisterLegacyGenreRoutes(legacy)
+	reviewHandler.RegisterLegacyReviewRoutes(legacy)
+	userHandler.RegisterLegacyRoutes(legacy)
+	eventHandler.RegisterEventRoutes(legacy)
 }
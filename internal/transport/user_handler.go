@@ -4,39 +4,58 @@ import (
 	// "context"
 	// "encoding/json"
 	// "fmt"
+	"archive/zip"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
 
 	// "time"
 
+	"github.com/dasler-fw/bookcrossing/internal/cache"
 	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/metrics"
 	"github.com/dasler-fw/bookcrossing/internal/middleware"
+	"github.com/dasler-fw/bookcrossing/internal/ratelimit"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
 	"github.com/dasler-fw/bookcrossing/internal/services"
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
 )
 
 type UserHandler struct {
 	userServ services.UserService
-	Redis    *redis.Client
+	// Cache — кэш списка пользователей (Redis или память процесса); nil — без кэша
+	Cache cache.Cache
+	// CacheAvailable сообщает, жив ли Redis; если нет — кэш пропускается
+	CacheAvailable func() bool
+	// Limiter ограничивает login/register/forgot-password; nil — без ограничений
+	Limiter ratelimit.Limiter
 }
 
 func NewUserHandler(userServ services.UserService) *UserHandler {
 	return &UserHandler{userServ: userServ}
 }
 
-func (h *UserHandler) RegisterRoutes(r *gin.Engine) {
+func (h *UserHandler) RegisterRoutes(r gin.IRouter) {
 	users := r.Group("/users")
 	{
-		users.POST("/register", h.Register)
-		users.POST("/login", h.Login)
+		users.POST("/register", h.limit(registerLimits...), h.Register)
+		users.POST("/login", h.limit(loginLimits...), h.Login)
+		users.POST("/verify-email", h.VerifyEmail)
+		users.POST("/verify-email/resend", middleware.JWTAuth(), h.ResendVerification)
+		users.POST("/forgot-password", h.limit(forgotPasswordLimits...), h.ForgotPassword)
+		users.POST("/reset-password", h.ResetPassword)
 		users.GET("/:id", middleware.JWTAuth(), h.GetProfile)
 		users.PATCH("/:id", middleware.JWTAuth(), h.UpdateProfile)
+		users.DELETE("/:id", middleware.JWTAuth(), h.limit(deleteAccountLimits...), h.DeleteAccount)
 		users.GET("/:id/exchanges", middleware.JWTAuth(), h.GetUserExchanges)
+		users.GET("/:id/export", middleware.JWTAuth(), h.limit(exportLimits...), h.ExportData)
 		// Collection endpoints
 		users.GET("", h.List)       // GET /users
 	
@@ -44,6 +63,54 @@ func (h *UserHandler) RegisterRoutes(r *gin.Engine) {
 
 }
 
+// RegisterLegacyRoutes — пути без версии в том виде, в каком они были до /api/v1:
+// удаление аккаунта и выгрузка данных есть только в v1
+func (h *UserHandler) RegisterLegacyRoutes(r gin.IRouter) {
+	users := r.Group("/users")
+	{
+		users.POST("/register", h.limit(registerLimits...), h.Register)
+		users.POST("/login", h.limit(loginLimits...), h.Login)
+		users.POST("/verify-email", h.VerifyEmail)
+		users.POST("/verify-email/resend", middleware.JWTAuth(), h.ResendVerification)
+		users.POST("/forgot-password", h.limit(forgotPasswordLimits...), h.ForgotPassword)
+		users.POST("/reset-password", h.ResetPassword)
+		users.GET("/:id", middleware.JWTAuth(), h.GetProfile)
+		users.PATCH("/:id", middleware.JWTAuth(), h.UpdateProfile)
+		users.GET("/:id/exchanges", middleware.JWTAuth(), h.GetUserExchanges)
+		users.GET("", h.List)
+	}
+}
+
+var (
+	loginLimits = []middleware.RateLimitRule{
+		{Name: "login-ip", Limit: 20, Window: time.Minute, Key: middleware.ByIP},
+		// по аккаунту и IP: иначе любой мог бы отправить 10 неверных паролей и не дать владельцу войти
+		{Name: "login-account", Limit: 10, Window: 15 * time.Minute, Key: middleware.ByAccountAndIP},
+	}
+	registerLimits = []middleware.RateLimitRule{
+		{Name: "register-ip", Limit: 5, Window: time.Hour, Key: middleware.ByIP},
+	}
+	forgotPasswordLimits = []middleware.RateLimitRule{
+		{Name: "forgot-ip", Limit: 10, Window: time.Hour, Key: middleware.ByIP},
+		{Name: "forgot-account", Limit: 3, Window: time.Hour, Key: middleware.ByAccount},
+	}
+	// удаление подтверждается паролем — ограничиваем подбор с украденным токеном
+	deleteAccountLimits = []middleware.RateLimitRule{
+		{Name: "delete-account-user", Limit: 5, Window: 15 * time.Minute, Key: middleware.ByUser},
+	}
+	// выгрузка читает все таблицы пользователя
+	exportLimits = []middleware.RateLimitRule{
+		{Name: "export-user", Limit: 5, Window: time.Hour, Key: middleware.ByUser},
+	}
+)
+
+func (h *UserHandler) limit(rules ...middleware.RateLimitRule) gin.HandlerFunc {
+	if h.Limiter == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return middleware.RateLimit(h.Limiter, rules...)
+}
+
 func (h *UserHandler) Register(c *gin.Context) {
 	var req dto.UserCreateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -51,7 +118,7 @@ func (h *UserHandler) Register(c *gin.Context) {
 		return
 	}
 
-	token, err := h.userServ.Register(req)
+	token, err := h.userServ.Register(c.Request.Context(), req)
 	if err != nil {
 		if err.Error() == "email уже используется" {
 			c.JSON(http.StatusConflict, gin.H{
@@ -59,6 +126,15 @@ func (h *UserHandler) Register(c *gin.Context) {
 			})
 			return
 		}
+		if errors.Is(err, dto.ErrEmailAlreadyUsed) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, dto.ErrUnsupportedLanguage) || errors.Is(err, dto.ErrInvalidEmail) ||
+			errors.Is(err, dto.ErrPasswordTooShort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "не удалось зарегистрировать пользователя",
@@ -78,9 +154,16 @@ func (h *UserHandler) Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
 		return
 	}
+	req.ClientIP = c.ClientIP()
 
-	token, err := h.userServ.Login(req)
+	token, err := h.userServ.Login(c.Request.Context(), req)
 	if err != nil {
+		var locked *dto.AccountLockedError
+		if errors.As(err, &locked) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
@@ -96,13 +179,13 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
-	profile, err := h.userServ.GetProfile(uint(id))
+	profile, err := h.userServ.GetProfile(c.Request.Context(), c.GetUint("user_id"), uint(id))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "пользователь не найден"})
 		return
 	}
 
-	c.JSON(http.StatusOK, profile)
+	renderWithETag(c, http.StatusOK, profile.Version, profile)
 }
 
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
@@ -124,22 +207,32 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректное тело запроса"})
 		return
 	}
-	if _, err := h.userServ.GetUserByID(uint(id)); err != nil {
+	if _, err := h.userServ.GetUserByID(c.Request.Context(), uint(id)); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "пользователь не найден",
 		})
 		return
 	}
 
-	 user1,  err := h.userServ.UpdateUser(uint(id), req) 
+	 user1,  err := h.userServ.UpdateUser(withIfMatch(c), uint(id), req) 
 	 	if err != nil {
+		if errors.Is(err, dto.ErrUnsupportedLanguage) || errors.Is(err, dto.ErrInvalidEmail) ||
+			errors.Is(err, dto.ErrInvalidVisibility) || errors.Is(err, dto.ErrPasswordTooShort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, dto.ErrVersionConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "не удалось обновить профиль пользователя",
 		})
 		return
 	}
+	h.invalidateUsersList(c.Request.Context())
 
-	c.JSON(http.StatusOK, user1)
+	renderWithETag(c, http.StatusOK, user1.Version, services.NewUserResponse(*user1, services.RelationSelf))
 
 }
 
@@ -152,13 +245,130 @@ func (h *UserHandler) GetUserExchanges(c *gin.Context) {
 
 	status := c.Query("status")
 
-	exchanges, err := h.userServ.GetUserExchanges(uint(id), status)
+	exchanges, err := h.userServ.GetUserExchanges(c.Request.Context(), uint(id), status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось получить историю обменов"})
+		return
+	}
+
+	var participants []uint
+	for _, e := range exchanges {
+		participants = append(participants, e.InitiatorID, e.RecipientID)
+	}
+	relations, err := h.userServ.Relations(c.Request.Context(), c.GetUint("user_id"), participants)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось получить историю обменов"})
 		return
 	}
 
-	c.JSON(http.StatusOK, exchanges)
+	resp := make([]dto.ExchangeResponse, 0, len(exchanges))
+	for _, e := range exchanges {
+		resp = append(resp, mapExchangeWithParticipants(e, relations))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteAccount — удаление своего аккаунта с подтверждением паролем
+func (h *UserHandler) DeleteAccount(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный идентификатор пользователя"})
+		return
+	}
+	if c.GetUint("user_id") != uint(id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "доступ запрещён: нельзя удалить чужой аккаунт"})
+		return
+	}
+
+	var req dto.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "нужно подтвердить удаление паролем"})
+		return
+	}
+
+	resp, err := h.userServ.DeleteUser(c.Request.Context(), uint(id), req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, dto.ErrInvalidCredentials):
+			c.JSON(http.StatusForbidden, gin.H{"error": "неверный пароль"})
+		case errors.Is(err, repository.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "пользователь не найден"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось удалить аккаунт"})
+		}
+		return
+	}
+	h.invalidateUsersList(c.Request.Context())
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ExportData отдаёт ZIP с JSON-файлами по разделам; ?format=json — одним документом
+func (h *UserHandler) ExportData(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректный идентификатор пользователя"})
+		return
+	}
+	if c.GetUint("user_id") != uint(id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "доступ запрещён: можно выгрузить только свои данные"})
+		return
+	}
+
+	export, err := h.userServ.ExportData(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "пользователь не найден"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось выгрузить данные"})
+		return
+	}
+
+	filename := fmt.Sprintf("bookcrossing-export-%d", id)
+	c.Header("Cache-Control", "no-store")
+	if c.Query("format") == "json" {
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		c.JSON(http.StatusOK, export)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := writeExportZip(&buf, export); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось выгрузить данные"})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// writeExportZip пишет каждый раздел выгрузки в отдельный файл
+func writeExportZip(w io.Writer, export *dto.UserDataExport) error {
+	zw := zip.NewWriter(w)
+	for _, f := range []struct {
+		name string
+		data any
+	}{
+		{"profile.json", export.Profile},
+		{"books.json", export.Books},
+		{"exchanges.json", export.Exchanges},
+		{"reviews_written.json", export.ReviewsWritten},
+		{"reviews_received.json", export.ReviewsReceived},
+		{"review_replies.json", export.ReviewReplies},
+		{"reports.json", export.Reports},
+		{"tokens.json", export.Tokens},
+	} {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: export.ExportedAt})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
 }
 
 func (h *UserHandler) List(c *gin.Context) {
@@ -169,27 +379,45 @@ func (h *UserHandler) List(c *gin.Context) {
 		lastID = uint(id)
 	}
 
-	ctx := context.Background()
-	cacheKey := fmt.Sprintf("users:%d:%d", lastID, limit)
+	ctx := c.Request.Context()
 	nocache := c.Query("nocache") == "1"
 
+	useCache := !nocache && h.Cache != nil && (h.CacheAvailable == nil || h.CacheAvailable())
+	// в кэше — список глазами анонима, без скрытых полей
+	var cacheKey string
+	if useCache {
+		cacheKey = fmt.Sprintf("users:public:%s:%d:%d", h.usersListGeneration(ctx), lastID, limit)
+	}
+
 	// 1️⃣ Проверяем кэш
-	if !nocache && h.Redis != nil {
-		if cached, err := h.Redis.Get(ctx, cacheKey).Result(); err == nil {
-			c.Data(200, "application/json", []byte(cached))
+	if useCache {
+		cached, err := h.Cache.Get(ctx, cacheKey)
+		switch {
+		case err == nil:
+			metrics.CacheRequests.WithLabelValues(metrics.CacheUsersList, "hit").Inc()
+			c.Data(200, "application/json", cached)
 			return
+		case errors.Is(err, cache.ErrMiss):
+			metrics.CacheRequests.WithLabelValues(metrics.CacheUsersList, "miss").Inc()
+		default:
+			metrics.CacheRequests.WithLabelValues(metrics.CacheUsersList, "error").Inc()
 		}
 	}
 
-	// 2️⃣ Если нет в кэше — запрос из Postgres
-	users, nextID, err := h.userServ.ListUsers(limit, lastID)
+	// 2️⃣ Если нет в кэше — запрос из базы
+	users, nextID, err := h.userServ.ListUsers(c.Request.Context(), limit, lastID)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
+	data := make([]dto.UserResponse, 0, len(users))
+	for _, u := range users {
+		data = append(data, services.NewUserResponse(u, services.RelationStranger))
+	}
+
 	resp := gin.H{
-		"data": users,
+		"data": data,
 		"meta": gin.H{
 			"limit":    limit,
 			"next_id":  nextID,
@@ -199,10 +427,110 @@ func (h *UserHandler) List(c *gin.Context) {
 
 	jsonData, _ := json.Marshal(resp)
 
-	// 3️⃣ Сохраняем в Redis на 5 минут (если кэш не отключён)
-	if !nocache && h.Redis != nil {
-		h.Redis.Set(ctx, cacheKey, jsonData, 5*time.Minute)
+	// 3️⃣ Сохраняем в кэш на 5 минут (если кэш не отключён)
+	if useCache {
+		_ = h.Cache.Set(ctx, cacheKey, jsonData, usersListTTL)
 	}
 
 	c.Data(200, "application/json", jsonData)
 }
+
+const (
+	usersListTTL = 5 * time.Minute
+	// usersListGenKey хранит поколение кэша списка: страницы лежат под ключами с поколением,
+	// и смена поколения сбрасывает их все — удалять по префиксу кэш не умеет.
+	// Ключ живёт дольше страниц, чтобы после его истечения не ожили старые страницы.
+	usersListGenKey = "users:public:gen"
+	usersListGenTTL = 24 * time.Hour
+)
+
+func (h *UserHandler) usersListGeneration(ctx context.Context) string {
+	gen, err := h.Cache.Get(ctx, usersListGenKey)
+	if err != nil {
+		return "0"
+	}
+	return string(gen)
+}
+
+// invalidateUsersList сбрасывает кэш списка после изменения профиля или удаления аккаунта:
+// иначе список ещё 5 минут показывал бы скрытые поля и удалённых пользователей
+func (h *UserHandler) invalidateUsersList(ctx context.Context) {
+	if h.Cache == nil {
+		return
+	}
+	gen := strconv.FormatInt(time.Now().UnixNano(), 10)
+	_ = h.Cache.Set(ctx, usersListGenKey, []byte(gen), usersListGenTTL)
+}
+
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var req dto.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if err := h.userServ.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+		if errors.Is(err, dto.ErrTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось подтвердить email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email подтверждён"})
+}
+
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	if err := h.userServ.ResendVerification(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, dto.ErrEmailAlreadyVerified) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось отправить письмо"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "письмо отправлено"})
+}
+
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var req dto.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if err := h.userServ.ForgotPassword(c.Request.Context(), req.Email); err != nil {
+		if errors.Is(err, dto.ErrInvalidEmail) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось отправить письмо"})
+		return
+	}
+
+	// одинаковый ответ для существующих и несуществующих адресов
+	c.JSON(http.StatusAccepted, gin.H{"message": "если адрес зарегистрирован, на него отправлена ссылка для сброса пароля"})
+}
+
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var req dto.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if err := h.userServ.ResetPassword(c.Request.Context(), req); err != nil {
+		if errors.Is(err, dto.ErrTokenInvalid) || errors.Is(err, dto.ErrPasswordTooShort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось сбросить пароль"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "пароль изменён"})
+}
@@ -1,8 +1,11 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -10,43 +13,43 @@ type BookRepositoryMock struct {
 	mock.Mock
 }
 
-func (m *BookRepositoryMock) Create(req *models.Book) error {
+func (m *BookRepositoryMock) Create(ctx context.Context, req *models.Book) error {
 	args := m.Called(req)
 	return args.Error(0)
 }
 
-func (m *BookRepositoryMock) GetByID(id uint) (*models.Book, error) {
+func (m *BookRepositoryMock) GetByID(ctx context.Context, id uint) (*models.Book, error) {
 	args := m.Called(id)
 	return args.Get(0).(*models.Book), args.Error(1)
 }
 
-func (m *BookRepositoryMock) Update(book *models.Book) error {
+func (m *BookRepositoryMock) Update(ctx context.Context, book *models.Book) error {
 	args := m.Called(book)
 	return args.Error(0)
 }
 
-func (m *BookRepositoryMock) Delete(id uint) error {
+func (m *BookRepositoryMock) Delete(ctx context.Context, id uint) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
-func (m *BookRepositoryMock) Search(query dto.BookListQuery) ([]models.Book, int64, error) {
+func (m *BookRepositoryMock) Search(ctx context.Context, query dto.BookListQuery) (*repository.BookPage, error) {
 	args := m.Called(query)
 
-	var books []models.Book
+	var page *repository.BookPage
 	if args.Get(0) != nil {
-		books = args.Get(0).([]models.Book)
+		page = args.Get(0).(*repository.BookPage)
 	}
 
-	return books, args.Get(1).(int64), args.Error(2)
+	return page, args.Error(1)
 }
 
-func (m *BookRepositoryMock) AttachGenres(bookID uint, genreIDs []uint) error {
+func (m *BookRepositoryMock) AttachGenres(ctx context.Context, bookID uint, genreIDs []uint) error {
 	args := m.Called(bookID, genreIDs)
 	return args.Error(0)
 }
 
-func (m *BookRepositoryMock) GetByUserID(userID uint, status string) ([]models.Book, error) {
+func (m *BookRepositoryMock) GetByUserID(ctx context.Context, userID uint, status string) ([]models.Book, error) {
 	args := m.Called(userID, status)
 
 	var books []models.Book
@@ -57,7 +60,7 @@ func (m *BookRepositoryMock) GetByUserID(userID uint, status string) ([]models.B
 	return books, args.Error(1)
 }
 
-func (m *BookRepositoryMock) GetAvailable(city string) ([]models.Book, error) {
+func (m *BookRepositoryMock) GetAvailable(ctx context.Context, city string) ([]models.Book, error) {
 	args := m.Called(city)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -65,3 +68,13 @@ func (m *BookRepositoryMock) GetAvailable(city string) ([]models.Book, error) {
 
 	return args.Get(0).([]models.Book), args.Error(1)
 }
+
+func (m *BookRepositoryMock) Facets(ctx context.Context, query dto.BookListQuery, fields []string) (map[string][]dto.FacetValue, error) {
+	args := m.Called(query, fields)
+
+	var facets map[string][]dto.FacetValue
+	if args.Get(0) != nil {
+		facets = args.Get(0).(map[string][]dto.FacetValue)
+	}
+	return facets, args.Error(1)
+}
@@ -1,8 +1,11 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -10,7 +13,7 @@ type BookServiceMock struct {
 	mock.Mock
 }
 
-func (m *BookServiceMock) CreateBook(userID uint, req dto.CreateBookRequest) (*models.Book, error) {
+func (m *BookServiceMock) CreateBook(ctx context.Context, userID uint, req dto.CreateBookRequest) (*models.Book, error) {
 	args := m.Called(userID, req) // передаём параметры в testify.Mock
 
 	// Проверяем, что первый аргумент возвращённый не nil
@@ -22,7 +25,17 @@ func (m *BookServiceMock) CreateBook(userID uint, req dto.CreateBookRequest) (*m
 	return book, args.Error(1) // второй аргумент — это ошибка
 }
 
-func (m *BookServiceMock) Update(bookID uint, userID uint, req dto.UpdateBookRequest) (*models.Book, error) {
+func (m *BookServiceMock) GetByID(ctx context.Context, id uint) (*models.Book, error) {
+	args := m.Called(id)
+
+	var book *models.Book
+	if args.Get(0) != nil {
+		book = args.Get(0).(*models.Book)
+	}
+	return book, args.Error(1)
+}
+
+func (m *BookServiceMock) Update(ctx context.Context, bookID uint, userID uint, req dto.UpdateBookRequest) (*models.Book, error) {
 	args := m.Called(bookID, userID, req)
 
 	var book *models.Book
@@ -32,18 +45,22 @@ func (m *BookServiceMock) Update(bookID uint, userID uint, req dto.UpdateBookReq
 	return book, args.Error(1)
 }
 
-func (m *BookServiceMock) Delete(bookID uint, userID uint) error {
+func (m *BookServiceMock) Delete(ctx context.Context, bookID uint, userID uint) error {
 	args := m.Called(bookID, userID)
 	return args.Error(0)
 }
 
-func (m *BookServiceMock) SearchBooks(query dto.BookListQuery) ([]models.Book, int64, error) {
+func (m *BookServiceMock) SearchBooks(ctx context.Context, query dto.BookListQuery) (*repository.BookPage, error) {
 	args := m.Called(query)
-	return args.Get(0).([]models.Book), args.Get(1).(int64), args.Error(2)
 
+	var page *repository.BookPage
+	if args.Get(0) != nil {
+		page = args.Get(0).(*repository.BookPage)
+	}
+	return page, args.Error(1)
 }
 
-func (m *BookServiceMock) GetBooksByUserID(userID uint, status string) ([]models.Book, error) {
+func (m *BookServiceMock) GetBooksByUserID(ctx context.Context, userID uint, status string) ([]models.Book, error) {
 	args := m.Called(userID, status)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -51,7 +68,7 @@ func (m *BookServiceMock) GetBooksByUserID(userID uint, status string) ([]models
 	return args.Get(0).([]models.Book), args.Error(1)
 }
 
-func (m *BookServiceMock) GetAvailableBooks(city string) ([]models.Book, error) {
+func (m *BookServiceMock) GetAvailableBooks(ctx context.Context, city string) ([]models.Book, error) {
 	args := m.Called(city)
 	var books []models.Book
 	if args.Get(0) != nil {
@@ -60,3 +77,13 @@ func (m *BookServiceMock) GetAvailableBooks(city string) ([]models.Book, error)
 
 	return books, args.Error(1)
 }
+
+func (m *BookServiceMock) SearchFacets(ctx context.Context, query dto.BookListQuery, fields []string) (map[string][]dto.FacetValue, error) {
+	args := m.Called(query, fields)
+
+	var facets map[string][]dto.FacetValue
+	if args.Get(0) != nil {
+		facets = args.Get(0).(map[string][]dto.FacetValue)
+	}
+	return facets, args.Error(1)
+}
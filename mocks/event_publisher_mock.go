@@ -0,0 +1,16 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type EventPublisherMock struct {
+	mock.Mock
+}
+
+func (m *EventPublisherMock) Publish(ctx context.Context, userID uint, eventType string, payload any) error {
+	args := m.Called(ctx, userID, eventType, payload)
+	return args.Error(0)
+}
@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/stretchr/testify/mock"
 )
@@ -9,30 +11,30 @@ type ExchangeRepositoryMock struct {
 	mock.Mock
 }
 
-func (m *ExchangeRepositoryMock) CreateExchange(req *models.Exchange) error {
+func (m *ExchangeRepositoryMock) CreateExchange(ctx context.Context, req *models.Exchange) error {
 	args := m.Called(req)
 	return args.Error(0)
 }
 
-func (m *ExchangeRepositoryMock) CompleteExchange(req *models.Exchange) error {
+func (m *ExchangeRepositoryMock) CompleteExchange(ctx context.Context, req *models.Exchange) error {
 	args := m.Called(req)
 	return args.Error(0)
 }
 
-func (m *ExchangeRepositoryMock) CancelExchange(req *models.Exchange) error {
+func (m *ExchangeRepositoryMock) CancelExchange(ctx context.Context, req *models.Exchange) error {
 	args := m.Called(req)
 	return args.Error(0)
 }
-func (m *ExchangeRepositoryMock) Update(req *models.Exchange) error {
+func (m *ExchangeRepositoryMock) Update(ctx context.Context, req *models.Exchange) error {
 	args := m.Called(req)
 	return args.Error(0)
 }
-func (m *ExchangeRepositoryMock) GetByID(id uint) (*models.Exchange, error) {
+func (m *ExchangeRepositoryMock) GetByID(ctx context.Context, id uint) (*models.Exchange, error) {
 	args := m.Called(id)
 	return args.Get(0).(*models.Exchange), args.Error(1)
 }
 
-func (m *ExchangeRepositoryMock) GetAll() ([]models.Exchange, error) {
+func (m *ExchangeRepositoryMock) GetAll(ctx context.Context) ([]models.Exchange, error) {
 	args := m.Called()
 
 	var exchs []models.Exchange
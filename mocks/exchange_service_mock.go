@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/stretchr/testify/mock"
@@ -10,7 +12,7 @@ type ExchangeServiceMock struct {
 	mock.Mock
 }
 
-func (m *ExchangeRepositoryMock) CreateExchangeService(req *dto.CreateExchangeRequest, actingUserID uint) (*models.Exchange, error) {
+func (m *ExchangeServiceMock) CreateExchange(ctx context.Context, req *dto.CreateExchangeRequest, actingUserID uint) (*models.Exchange, error) {
 	args := m.Called(req, actingUserID)
 	var exc *models.Exchange
 	if args.Get(0) != nil {
@@ -20,22 +22,22 @@ func (m *ExchangeRepositoryMock) CreateExchangeService(req *dto.CreateExchangeRe
 	return exc, args.Error(1)
 }
 
-func (m *ExchangeRepositoryMock) AcceptExchangeService(exchangeID uint, actingUserID uint) error {
+func (m *ExchangeServiceMock) AcceptExchange(ctx context.Context, exchangeID uint, actingUserID uint) error {
 	args := m.Called(exchangeID, actingUserID)
 	return args.Error(0)
 }
 
-func (m *ExchangeRepositoryMock) CompleteExchangeService(exchangeID uint, actingUserID uint) error {
+func (m *ExchangeServiceMock) CompleteExchange(ctx context.Context, exchangeID uint, actingUserID uint) error {
 	args := m.Called(exchangeID, actingUserID)
 	return args.Error(0)
 }
 
-func (m *ExchangeRepositoryMock) CancelExchangeService(exchangeID uint, actingUserID uint) error {
+func (m *ExchangeServiceMock) CancelExchange(ctx context.Context, exchangeID uint, actingUserID uint) error {
 	args := m.Called(exchangeID, actingUserID)
 	return args.Error(0)
 }
 
-func (m *ExchangeRepositoryMock) GetByIDService(exchangeID uint) (*models.Exchange, error) {
+func (m *ExchangeServiceMock) GetByID(ctx context.Context, exchangeID uint) (*models.Exchange, error) {
 	args := m.Called(exchangeID)
 
 	var exc *models.Exchange
@@ -46,7 +48,7 @@ func (m *ExchangeRepositoryMock) GetByIDService(exchangeID uint) (*models.Exchan
 	return exc, args.Error(1)
 }
 
-func (m *ExchangeRepositoryMock) GetAllService() ([]models.Exchange, error) {
+func (m *ExchangeServiceMock) GetAll(ctx context.Context) ([]models.Exchange, error) {
 	args := m.Called()
 	var exc []models.Exchange
 	if args.Get(0) != nil {
@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/stretchr/testify/mock"
 )
@@ -9,12 +11,12 @@ type GenreRepositoryMock struct {
 	mock.Mock
 }
 
-func (m *GenreRepositoryMock) Create(req *models.Genre) error {
+func (m *GenreRepositoryMock) Create(ctx context.Context, req *models.Genre) error {
 	args := m.Called(req)
 	return args.Error(0)
 }
 
-func (m *GenreRepositoryMock) GetByID(id uint) (*models.Genre, error) {
+func (m *GenreRepositoryMock) GetByID(ctx context.Context, id uint) (*models.Genre, error) {
 	args := m.Called(id)
 
 	var g *models.Genre
@@ -25,7 +27,7 @@ func (m *GenreRepositoryMock) GetByID(id uint) (*models.Genre, error) {
 	return g, args.Error(1)
 }
 
-func (m *GenreRepositoryMock) GetByName(name string) (*models.Genre, error) {
+func (m *GenreRepositoryMock) GetByName(ctx context.Context, name string) (*models.Genre, error) {
 	args := m.Called(name)
 	var g *models.Genre
 	if args.Get(0) != nil {
@@ -34,7 +36,7 @@ func (m *GenreRepositoryMock) GetByName(name string) (*models.Genre, error) {
 	return g, args.Error(1)
 }
 
-func (m *GenreRepositoryMock) List() ([]models.Genre, error) {
+func (m *GenreRepositoryMock) List(ctx context.Context) ([]models.Genre, error) {
 	args := m.Called()
 
 	var genres []models.Genre
@@ -45,7 +47,26 @@ func (m *GenreRepositoryMock) List() ([]models.Genre, error) {
 	return genres, args.Error(1)
 }
 
-func (m *GenreRepositoryMock) Delete(id uint) error {
+func (m *GenreRepositoryMock) Delete(ctx context.Context, id uint) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
+
+func (m *GenreRepositoryMock) GetBySlug(ctx context.Context, slug string) (*models.Genre, error) {
+	args := m.Called(slug)
+	var g *models.Genre
+	if args.Get(0) != nil {
+		g = args.Get(0).(*models.Genre)
+	}
+	return g, args.Error(1)
+}
+
+func (m *GenreRepositoryMock) Merge(ctx context.Context, targetID uint, sourceIDs []uint) (int64, error) {
+	args := m.Called(targetID, sourceIDs)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *GenreRepositoryMock) BackfillSlugs(ctx context.Context) error {
+	args := m.Called()
+	return args.Error(0)
+}
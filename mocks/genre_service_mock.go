@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/stretchr/testify/mock"
@@ -11,7 +13,7 @@ type GenreServiceMock struct {
 	mock.Mock
 }
 
-func (m *GenreServiceMock) Create(req dto.GenreCreateRequest) (*models.Genre, error) {
+func (m *GenreServiceMock) Create(ctx context.Context, req dto.GenreCreateRequest) (*models.Genre, error) {
 	args := m.Called(req)
 	var g *models.Genre
 
@@ -23,7 +25,7 @@ func (m *GenreServiceMock) Create(req dto.GenreCreateRequest) (*models.Genre, er
 }
 
 
-func (m *GenreServiceMock) GetByID(id uint) (*models.Genre, error) {
+func (m *GenreServiceMock) GetByID(ctx context.Context, id uint) (*models.Genre, error) {
 	args := m.Called(id)
 
 	var g *models.Genre
@@ -35,7 +37,7 @@ func (m *GenreServiceMock) GetByID(id uint) (*models.Genre, error) {
 }
 
 
-func (m *GenreServiceMock) List() ([]models.Genre, error) {
+func (m *GenreServiceMock) List(ctx context.Context) ([]models.Genre, error) {
 	args := m.Called()
 
 	var genres []models.Genre
@@ -46,9 +48,42 @@ func (m *GenreServiceMock) List() ([]models.Genre, error) {
 	return genres, args.Error(1)
 }
 
-func (m *GenreServiceMock) Delete(id uint) error {
+func (m *GenreServiceMock) Delete(ctx context.Context, id uint) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
 
+
+func (m *GenreServiceMock) GetBySlug(ctx context.Context, slug string) (*models.Genre, error) {
+	args := m.Called(slug)
+
+	var g *models.Genre
+	if args.Get(0) != nil {
+		g = args.Get(0).(*models.Genre)
+	}
+
+	return g, args.Error(1)
+}
+
+func (m *GenreServiceMock) Tree(ctx context.Context) ([]dto.GenreNode, error) {
+	args := m.Called()
+
+	var tree []dto.GenreNode
+	if args.Get(0) != nil {
+		tree = args.Get(0).([]dto.GenreNode)
+	}
+
+	return tree, args.Error(1)
+}
+
+func (m *GenreServiceMock) Merge(ctx context.Context, adminID, targetID uint, req dto.GenreMergeRequest) (*dto.GenreMergeResponse, error) {
+	args := m.Called(adminID, targetID, req)
+
+	var res *dto.GenreMergeResponse
+	if args.Get(0) != nil {
+		res = args.Get(0).(*dto.GenreMergeResponse)
+	}
+
+	return res, args.Error(1)
+}
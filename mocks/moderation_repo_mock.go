@@ -0,0 +1,72 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type ModerationRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *ModerationRepositoryMock) CreateReport(ctx context.Context, report *models.ContentReport) error {
+	args := m.Called(report)
+	return args.Error(0)
+}
+
+func (m *ModerationRepositoryMock) SaveSystemReport(ctx context.Context, contentType string, contentID uint, reason string) error {
+	args := m.Called(contentType, contentID, reason)
+	return args.Error(0)
+}
+
+func (m *ModerationRepositoryMock) CountOpenUserReports(ctx context.Context, contentType string, contentID uint) (int64, error) {
+	args := m.Called(contentType, contentID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *ModerationRepositoryMock) OpenReports(ctx context.Context, contentType string, ids []uint) ([]models.ContentReport, error) {
+	args := m.Called(contentType, ids)
+
+	var reports []models.ContentReport
+	if args.Get(0) != nil {
+		reports = args.Get(0).([]models.ContentReport)
+	}
+	return reports, args.Error(1)
+}
+
+func (m *ModerationRepositoryMock) ResolveReports(ctx context.Context, contentType string, contentID uint) error {
+	args := m.Called(contentType, contentID)
+	return args.Error(0)
+}
+
+func (m *ModerationRepositoryMock) SetReviewStatus(ctx context.Context, id uint, from, to string) error {
+	args := m.Called(id, from, to)
+	return args.Error(0)
+}
+
+func (m *ModerationRepositoryMock) SetBookStatus(ctx context.Context, id uint, from, to string) error {
+	args := m.Called(id, from, to)
+	return args.Error(0)
+}
+
+func (m *ModerationRepositoryMock) QueueReviews(ctx context.Context, limit int) ([]models.Review, error) {
+	args := m.Called(limit)
+
+	var reviews []models.Review
+	if args.Get(0) != nil {
+		reviews = args.Get(0).([]models.Review)
+	}
+	return reviews, args.Error(1)
+}
+
+func (m *ModerationRepositoryMock) QueueBooks(ctx context.Context, limit int) ([]models.Book, error) {
+	args := m.Called(limit)
+
+	var books []models.Book
+	if args.Get(0) != nil {
+		books = args.Get(0).([]models.Book)
+	}
+	return books, args.Error(1)
+}
@@ -0,0 +1,47 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/moderation"
+	"github.com/stretchr/testify/mock"
+)
+
+type ModerationServiceMock struct {
+	mock.Mock
+}
+
+func (m *ModerationServiceMock) Screen(ctx context.Context, current, text string) (string, moderation.Verdict) {
+	args := m.Called(current, text)
+	return args.String(0), args.Get(1).(moderation.Verdict)
+}
+
+func (m *ModerationServiceMock) Flag(ctx context.Context, contentType string, contentID uint, verdict moderation.Verdict) {
+	m.Called(contentType, contentID, verdict)
+}
+
+func (m *ModerationServiceMock) Report(ctx context.Context, reporterID uint, contentType string, contentID uint, req dto.ReportRequest) error {
+	args := m.Called(reporterID, contentType, contentID, req)
+	return args.Error(0)
+}
+
+func (m *ModerationServiceMock) Queue(ctx context.Context, moderatorID uint, limit int) ([]dto.ModerationQueueItem, error) {
+	args := m.Called(moderatorID, limit)
+
+	var items []dto.ModerationQueueItem
+	if args.Get(0) != nil {
+		items = args.Get(0).([]dto.ModerationQueueItem)
+	}
+	return items, args.Error(1)
+}
+
+func (m *ModerationServiceMock) Approve(ctx context.Context, moderatorID uint, contentType string, contentID uint) error {
+	args := m.Called(moderatorID, contentType, contentID)
+	return args.Error(0)
+}
+
+func (m *ModerationServiceMock) Reject(ctx context.Context, moderatorID uint, contentType string, contentID uint) error {
+	args := m.Called(moderatorID, contentType, contentID)
+	return args.Error(0)
+}
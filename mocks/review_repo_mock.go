@@ -1,6 +1,9 @@
 package mocks
 
 import (
+	"context"
+
+	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/stretchr/testify/mock"
 )
@@ -15,12 +18,12 @@ type ReviewRepositoryMock struct {
 	mock.Mock
 }
 
-func (m *ReviewRepositoryMock) Create(req *models.Review) error {
+func (m *ReviewRepositoryMock) Create(ctx context.Context, req *models.Review) error {
 	args := m.Called(req)
 	return args.Error(0)
 }
 
-func (m *ReviewRepositoryMock) GetByID(id uint) (*models.Review, error) {
+func (m *ReviewRepositoryMock) GetByID(ctx context.Context, id uint) (*models.Review, error) {
 	args := m.Called(id)
 
 	var r *models.Review
@@ -31,22 +34,22 @@ func (m *ReviewRepositoryMock) GetByID(id uint) (*models.Review, error) {
 	return r, args.Error(1)
 }
 
-func (m *ReviewRepositoryMock) Delete(id uint) error {
+func (m *ReviewRepositoryMock) Delete(ctx context.Context, id uint) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
-func (m *ReviewRepositoryMock) GetByTargetUserID(id uint) ([]models.Review, error) {
-	args := m.Called(id)
+func (m *ReviewRepositoryMock) GetByTargetUserID(ctx context.Context, id uint, query dto.ReviewListQuery) ([]models.Review, int64, error) {
+	args := m.Called(id, query)
 	var r []models.Review
 	if args.Get(0) != nil {
 		r = args.Get(0).([]models.Review)
 	}
 
-	return r, args.Error(1)
+	return r, args.Get(1).(int64), args.Error(2)
 }
 
-func (m *ReviewRepositoryMock) GetByTargetBookID(id uint) ([]models.Review, error) {
+func (m *ReviewRepositoryMock) GetByTargetBookID(ctx context.Context, id uint) ([]models.Review, error) {
 	args := m.Called(id)
 	var r []models.Review
 	if args.Get(0) != nil {
@@ -55,3 +58,32 @@ func (m *ReviewRepositoryMock) GetByTargetBookID(id uint) ([]models.Review, erro
 
 	return r, args.Error(1)
 }
+
+func (m *ReviewRepositoryMock) ExistsForExchange(ctx context.Context, exchangeID, authorID uint) (bool, error) {
+	args := m.Called(exchangeID, authorID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *ReviewRepositoryMock) RebuildRatings(ctx context.Context) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *ReviewRepositoryMock) Update(ctx context.Context, review *models.Review, previous models.Review) error {
+	args := m.Called(review, previous)
+	return args.Error(0)
+}
+
+func (m *ReviewRepositoryMock) GetRevisions(ctx context.Context, reviewID uint) ([]models.ReviewRevision, error) {
+	args := m.Called(reviewID)
+	var r []models.ReviewRevision
+	if args.Get(0) != nil {
+		r = args.Get(0).([]models.ReviewRevision)
+	}
+	return r, args.Error(1)
+}
+
+func (m *ReviewRepositoryMock) CreateReply(ctx context.Context, reply *models.ReviewReply) error {
+	args := m.Called(reply)
+	return args.Error(0)
+}
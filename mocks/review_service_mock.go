@@ -1,12 +1,14 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/stretchr/testify/mock"
 )
 
-// Create(authorID uint, req dto.CreateReviewRequest) error
+// Create(authorID uint, req dto.CreateReviewRequest) (*models.Review, error)
 // 	GetByUserID(userID uint) ([]models.Review, error)
 // 	GetByBookID(bookID uint) ([]models.Review, error)
 // 	Delete(reviewID uint, authorID uint) error
@@ -15,23 +17,28 @@ type ReviewServiceMock struct {
 	mock.Mock
 }
 
-func (m *ReviewServiceMock) Create(authorID uint, req dto.CreateReviewRequest) error {
+func (m *ReviewServiceMock) Create(ctx context.Context, authorID uint, req dto.CreateReviewRequest) (*models.Review, error) {
 	args := m.Called(authorID, req)
-	return args.Error(0)
+
+	var rev *models.Review
+	if args.Get(0) != nil {
+		rev = args.Get(0).(*models.Review)
+	}
+	return rev, args.Error(1)
 }
 
-func (m *ReviewServiceMock) GetByUserID(userID uint) ([]models.Review, error) {
-	args := m.Called(userID)
+func (m *ReviewServiceMock) GetByUserID(ctx context.Context, userID uint, query dto.ReviewListQuery) ([]models.Review, int64, error) {
+	args := m.Called(userID, query)
 
 	var r []models.Review
 	if args.Get(0) != nil {
 		r = args.Get(0).([]models.Review)
 	}
 
-	return r, args.Error(1)
+	return r, args.Get(1).(int64), args.Error(2)
 }
 
-func (m *ReviewServiceMock) GetByBookID(bookID uint) ([]models.Review, error) {
+func (m *ReviewServiceMock) GetByBookID(ctx context.Context, bookID uint) ([]models.Review, error) {
 	args := m.Called(bookID)
 
 	var r []models.Review
@@ -42,7 +49,37 @@ func (m *ReviewServiceMock) GetByBookID(bookID uint) ([]models.Review, error) {
 	return r, args.Error(1)
 }
 
-func (m *ReviewServiceMock) Delete(reviewID uint, authorID uint) error {
+func (m *ReviewServiceMock) Delete(ctx context.Context, reviewID uint, authorID uint) error {
 	args := m.Called(reviewID,authorID)
 	return args.Error(0)
 }
+
+func (m *ReviewServiceMock) Update(ctx context.Context, reviewID uint, authorID uint, req dto.UpdateReviewRequest) (*models.Review, error) {
+	args := m.Called(reviewID, authorID, req)
+
+	var rev *models.Review
+	if args.Get(0) != nil {
+		rev = args.Get(0).(*models.Review)
+	}
+	return rev, args.Error(1)
+}
+
+func (m *ReviewServiceMock) GetRevisions(ctx context.Context, reviewID uint, viewerID uint) ([]models.ReviewRevision, error) {
+	args := m.Called(reviewID, viewerID)
+
+	var r []models.ReviewRevision
+	if args.Get(0) != nil {
+		r = args.Get(0).([]models.ReviewRevision)
+	}
+	return r, args.Error(1)
+}
+
+func (m *ReviewServiceMock) Reply(ctx context.Context, reviewID uint, userID uint, req dto.ReviewReplyRequest) (*models.ReviewReply, error) {
+	args := m.Called(reviewID, userID, req)
+
+	var reply *models.ReviewReply
+	if args.Get(0) != nil {
+		reply = args.Get(0).(*models.ReviewReply)
+	}
+	return reply, args.Error(1)
+}
@@ -0,0 +1,35 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type TokenRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *TokenRepositoryMock) Create(ctx context.Context, token *models.UserToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *TokenRepositoryMock) GetByHash(ctx context.Context, purpose string, hash string) (*models.UserToken, error) {
+	args := m.Called(purpose, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserToken), args.Error(1)
+}
+
+func (m *TokenRepositoryMock) MarkUsed(ctx context.Context, id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *TokenRepositoryMock) InvalidateForUser(ctx context.Context, userID uint, purpose string) error {
+	args := m.Called(userID, purpose)
+	return args.Error(0)
+}
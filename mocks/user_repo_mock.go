@@ -1,7 +1,11 @@
 package mocks
 
 import (
+	"context"
+	"time"
+
 	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -9,12 +13,12 @@ type UserRepositoryMock struct {
 	mock.Mock
 }
 
-func (m *UserRepositoryMock) Create(user *models.User) error {
+func (m *UserRepositoryMock) Create(ctx context.Context, user *models.User) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
 
-func (m *UserRepositoryMock) GetByID(id uint) (*models.User, error) {
+func (m *UserRepositoryMock) GetByID(ctx context.Context, id uint) (*models.User, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -22,12 +26,17 @@ func (m *UserRepositoryMock) GetByID(id uint) (*models.User, error) {
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *UserRepositoryMock) Update(user *models.User) error {
+func (m *UserRepositoryMock) Exists(ctx context.Context, id uint) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *UserRepositoryMock) Update(ctx context.Context, user *models.User) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
 
-func (m *UserRepositoryMock) GetByEmail(email string) (*models.User, error) {
+func (m *UserRepositoryMock) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	args := m.Called(email)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -35,17 +44,17 @@ func (m *UserRepositoryMock) GetByEmail(email string) (*models.User, error) {
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *UserRepositoryMock) ListUsers(limit int, lastID uint) ([]models.User, error) {
+func (m *UserRepositoryMock) ListUsers(ctx context.Context, limit int, lastID uint) ([]models.User, error) {
 	args := m.Called(limit, lastID)
 	return args.Get(0).([]models.User), args.Error(1)
 }
 
-func (m *UserRepositoryMock) Delete(id uint) error {
+func (m *UserRepositoryMock) Delete(ctx context.Context, id uint) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
-func (m *UserRepositoryMock) GetUserExchanges(userID uint, status string) ([]models.Exchange, error) {
+func (m *UserRepositoryMock) GetUserExchanges(ctx context.Context, userID uint, status string) ([]models.Exchange, error) {
 	args := m.Called(userID, status)
 
 	if args.Get(0) == nil {
@@ -54,3 +63,63 @@ func (m *UserRepositoryMock) GetUserExchanges(userID uint, status string) ([]mod
 
 	return args.Get(0).([]models.Exchange), args.Error(1)
 }
+
+func (m *UserRepositoryMock) ListWithoutLocation(ctx context.Context, limit int, lastID uint) ([]models.User, error) {
+	args := m.Called(limit, lastID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]models.User), args.Error(1)
+}
+
+func (m *UserRepositoryMock) SetLocation(ctx context.Context, id uint, lat, lon *float64) error {
+	args := m.Called(id, lat, lon)
+	return args.Error(0)
+}
+
+func (m *UserRepositoryMock) PartnerIDs(ctx context.Context, userID uint, others []uint) ([]uint, error) {
+	args := m.Called(userID, others)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]uint), args.Error(1)
+}
+
+func (m *UserRepositoryMock) DeleteAccount(ctx context.Context, id uint) ([]models.Exchange, error) {
+	args := m.Called(id)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]models.Exchange), args.Error(1)
+}
+
+func (m *UserRepositoryMock) ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]uint, error) {
+	args := m.Called(before, limit)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]uint), args.Error(1)
+}
+
+func (m *UserRepositoryMock) Purge(ctx context.Context, id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *UserRepositoryMock) CollectData(ctx context.Context, id uint) (*repository.UserData, error) {
+	args := m.Called(id)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*repository.UserData), args.Error(1)
+}
@@ -1,8 +1,11 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/dasler-fw/bookcrossing/internal/dto"
 	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/services"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -10,15 +13,15 @@ type UserServiceMock struct {
 	mock.Mock
 }
 
-func (m *UserServiceMock) GetProfile(id uint) (*dto.UserProfileResponse, error) {
-	args := m.Called(id)
+func (m *UserServiceMock) GetProfile(ctx context.Context, viewerID, id uint) (*dto.UserProfileResponse, error) {
+	args := m.Called(viewerID, id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*dto.UserProfileResponse), args.Error(1)
 }
 
-func (m *UserServiceMock) GetUserByID(id uint) (*models.User, error) {
+func (m *UserServiceMock) GetUserByID(ctx context.Context, id uint) (*models.User, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -26,7 +29,7 @@ func (m *UserServiceMock) GetUserByID(id uint) (*models.User, error) {
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *UserServiceMock) UpdateUser(id uint, req dto.UserUpdateRequest) (*models.User, error) {
+func (m *UserServiceMock) UpdateUser(ctx context.Context, id uint, req dto.UserUpdateRequest) (*models.User, error) {
 	args := m.Called(id, req)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -34,22 +37,33 @@ func (m *UserServiceMock) UpdateUser(id uint, req dto.UserUpdateRequest) (*model
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *UserServiceMock) DeleteUser(id uint) error {
+func (m *UserServiceMock) DeleteUser(ctx context.Context, id uint, password string) (*dto.DeleteAccountResponse, error) {
+	args := m.Called(id, password)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.DeleteAccountResponse), args.Error(1)
+}
+
+func (m *UserServiceMock) ExportData(ctx context.Context, id uint) (*dto.UserDataExport, error) {
 	args := m.Called(id)
-	return args.Error(0)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.UserDataExport), args.Error(1)
 }
 
-func (m *UserServiceMock) Register(req dto.UserCreateRequest) (string, error) {
+func (m *UserServiceMock) Register(ctx context.Context, req dto.UserCreateRequest) (string, error) {
 	args := m.Called(req)
 	return args.String(0), args.Error(1)
 }
 
-func (m *UserServiceMock) Login(req dto.LoginRequest) (string, error) {
+func (m *UserServiceMock) Login(ctx context.Context, req dto.LoginRequest) (string, error) {
 	args := m.Called(req)
 	return args.String(0), args.Error(1)
 }
 
-func (m *UserServiceMock) GetUserExchanges(userID uint, status string) ([]models.Exchange, error) {
+func (m *UserServiceMock) GetUserExchanges(ctx context.Context, userID uint, status string) ([]models.Exchange, error) {
 	args := m.Called(userID, status)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -57,7 +71,35 @@ func (m *UserServiceMock) GetUserExchanges(userID uint, status string) ([]models
 	return args.Get(0).([]models.Exchange), args.Error(1)
 }
 
-func (m *UserServiceMock) ListUsers(limit int, lastID uint) ([]models.User, uint, error) {
+func (m *UserServiceMock) ListUsers(ctx context.Context, limit int, lastID uint) ([]models.User, uint, error) {
 	args := m.Called(limit, lastID)
 	return args.Get(0).([]models.User), args.Get(1).(uint), args.Error(2)
 }
+
+func (m *UserServiceMock) VerifyEmail(ctx context.Context, token string) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *UserServiceMock) ResendVerification(ctx context.Context, userID uint) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *UserServiceMock) ForgotPassword(ctx context.Context, email string) error {
+	args := m.Called(email)
+	return args.Error(0)
+}
+
+func (m *UserServiceMock) ResetPassword(ctx context.Context, req dto.ResetPasswordRequest) error {
+	args := m.Called(req)
+	return args.Error(0)
+}
+
+func (m *UserServiceMock) Relations(ctx context.Context, viewerID uint, userIDs []uint) (map[uint]services.Relation, error) {
+	args := m.Called(viewerID, userIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uint]services.Relation), args.Error(1)
+}
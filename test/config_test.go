@@ -0,0 +1,98 @@
+package test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dasler-fw/bookcrossing/internal/config"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestConfigLoad_Precedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.env")
+	require.NoError(t, os.WriteFile(file, []byte(strings.Join([]string{
+		"DB_HOST=file-host",
+		"DB_USER=file-user",
+		"DB_NAME=file-db",
+		"PORT=9000",
+		"REDIS_DB=2",
+		"SUPER_SECRET_KEY=" + testSecret,
+	}, "\n")), 0o600))
+
+	// окружение важнее файла, флаги важнее окружения
+	t.Setenv("DB_NAME", "env-db")
+	t.Setenv("HTTP_WRITE_TIMEOUT", "45s")
+	t.Setenv("PORT", "9100")
+
+	cfg, err := config.Load([]string{"-config", file, "-port", "9200"})
+	require.NoError(t, err)
+
+	require.Equal(t, "file-host", cfg.Database.Host)
+	require.Equal(t, "env-db", cfg.Database.Name)
+	require.Equal(t, 9200, cfg.Server.Port)
+	require.Equal(t, ":9200", cfg.Server.Addr())
+	require.Equal(t, 45*time.Second, cfg.Server.WriteTimeout)
+	require.Equal(t, 2, cfg.Redis.DB)
+	// не заданное нигде берётся из значений по умолчанию
+	require.Equal(t, 50, cfg.Database.MaxOpenConns)
+	require.Equal(t, 24*time.Hour, cfg.JWT.TTL)
+}
+
+func TestConfigLoad_Errors(t *testing.T) {
+	_, err := config.Load([]string{"-config", filepath.Join(t.TempDir(), "missing.env")})
+	require.Error(t, err)
+
+	t.Setenv("HTTP_READ_TIMEOUT", "fast")
+	_, err = config.Load(nil)
+	require.ErrorContains(t, err, "HTTP_READ_TIMEOUT")
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.User = "app"
+	cfg.Database.Name = "bookcrossing"
+	cfg.JWT.Secret = testSecret
+	require.NoError(t, cfg.Validate())
+
+	cfg.JWT.Secret = ""
+	cfg.Database.MaxIdleConns = cfg.Database.MaxOpenConns + 1
+	cfg.Redis.DB = 16
+	cfg.Redis.Password = "secret "
+	cfg.Server.ReadHeaderTimeout = cfg.Server.ReadTimeout + time.Second
+	cfg.Moderation.ReportThreshold = 0
+	cfg.Geo.Provider = "yandex"
+	cfg.Account.PurgeInterval = 0
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	for _, key := range []string{"SUPER_SECRET_KEY", "DB_MAX_IDLE_CONNS", "REDIS_DB", "REDIS_PASSWORD", "HTTP_READ_HEADER_TIMEOUT", "MODERATION_REPORT_THRESHOLD", "GEO_PROVIDER", "ACCOUNT_PURGE_INTERVAL"} {
+		require.ErrorContains(t, err, key)
+	}
+}
+
+type brokenMigrationModel struct {
+	ID   uint
+	Name string
+}
+
+func TestMigrate_SchemaErrorIsNotRetried(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db := config.Connect(config.DatabaseConfig{Driver: config.DriverSQLite, Path: filepath.Join(t.TempDir(), "app.db")}, log)
+	// представление с именем таблицы: база доступна, но таблицу не создать
+	require.NoError(t, db.Exec("CREATE VIEW broken_migration_models AS SELECT 1 AS id").Error)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := config.Migrate(ctx, db, log, &brokenMigrationModel{})
+	require.Error(t, err)
+	require.NotErrorIs(t, err, context.DeadlineExceeded)
+}
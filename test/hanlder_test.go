@@ -1,14 +1,32 @@
 package test
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/dasler-fw/bookcrossing/internal/cache"
 	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/health"
+	"github.com/dasler-fw/bookcrossing/internal/idempotency"
+	"github.com/dasler-fw/bookcrossing/internal/jwtutil"
+	"github.com/dasler-fw/bookcrossing/internal/logging"
+	"github.com/dasler-fw/bookcrossing/internal/middleware"
 	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/moderation"
+	"github.com/dasler-fw/bookcrossing/internal/ratelimit"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
+	"github.com/dasler-fw/bookcrossing/internal/services"
 	"github.com/dasler-fw/bookcrossing/internal/transport"
 	"github.com/dasler-fw/bookcrossing/mocks"
 	"github.com/gin-gonic/gin"
@@ -48,7 +66,7 @@ func TestUserHandler_GetProfile_OK(t *testing.T) {
 
 	// 🔹 ожидание вызова сервиса
 	userService.
-		On("GetProfile", uint(1)).
+		On("GetProfile", uint(0), uint(1)).
 		Return(profile, nil)
 
 	// 🔹 HTTP запрос
@@ -130,6 +148,67 @@ func TestUserHandler_Login_OK(t *testing.T) {
 	require.Equal(t, "TOKEN", body["token"])
 }
 
+func TestUserHandler_Login_RateLimited(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userService := new(mocks.UserServiceMock)
+	handler := transport.NewUserHandler(userService)
+	handler.Limiter = ratelimit.NewMemoryLimiter()
+
+	reqBody := dto.LoginRequest{Email: "bob@example.com", Password: "wrong"}
+	userService.On("Login", reqBody).Return("", dto.ErrInvalidCredentials)
+
+	r := gin.New()
+	handler.RegisterRoutes(r)
+
+	b, _ := json.Marshal(reqBody)
+	var w *httptest.ResponseRecorder
+	// лимит по аккаунту — 10 попыток за 15 минут
+	for i := 0; i < 11; i++ {
+		w = httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(b))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+	}
+
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.NotEmpty(t, w.Header().Get("Retry-After"))
+	require.Equal(t, "10", w.Header().Get("X-RateLimit-Limit"))
+	require.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	userService.AssertNumberOfCalls(t, "Login", 10)
+
+	// попытки с другого адреса не расходуют лимит владельца
+	other := reqBody
+	other.ClientIP = "10.0.0.2"
+	userService.On("Login", other).Return("TOKEN", nil)
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "10.0.0.2:4321"
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestUserHandler_Login_Locked(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userService := new(mocks.UserServiceMock)
+	handler := transport.NewUserHandler(userService)
+
+	reqBody := dto.LoginRequest{Email: "bob@example.com", Password: "pass"}
+	userService.On("Login", reqBody).Return("", &dto.AccountLockedError{RetryAfter: 90 * time.Second})
+
+	b, _ := json.Marshal(reqBody)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+
+	r := gin.New()
+	r.POST("/users/login", handler.Login)
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "90", w.Header().Get("Retry-After"))
+}
+
 func TestUserHandler_UpdateProfile_OK(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	userService := new(mocks.UserServiceMock)
@@ -187,6 +266,343 @@ func TestUserHandler_List_OK(t *testing.T) {
 	require.False(t, body.Meta.HasNext)
 }
 
+func TestUserHandler_List_HidesPrivateFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userService := new(mocks.UserServiceMock)
+	handler := transport.NewUserHandler(userService)
+
+	users := []models.User{
+		{ID: 1, Name: "A", Email: "a@example.com", City: "Москва", Address: "ул. Ленина, 1", PasswordHash: "hash",
+			EmailVisibility: models.VisibilityPrivate, AddressVisibility: models.VisibilityPartners, CityVisibility: models.VisibilityPublic},
+		{ID: 2, Name: "B", Email: "b@example.com", City: "Тула",
+			EmailVisibility: models.VisibilityPublic, AddressVisibility: models.VisibilityPartners, CityVisibility: models.VisibilityPrivate},
+	}
+	userService.On("ListUsers", 50, uint(0)).Return(users, uint(0), nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/users", nil)
+
+	r := gin.New()
+	r.GET("/users", handler.List)
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotContains(t, w.Body.String(), "a@example.com")
+	require.NotContains(t, w.Body.String(), "Ленина")
+	require.NotContains(t, w.Body.String(), "visibility")
+
+	var body struct {
+		Data []dto.UserResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Data, 2)
+	require.Equal(t, "Москва", body.Data[0].City)
+	require.Equal(t, "b@example.com", body.Data[1].Email)
+	require.Empty(t, body.Data[1].City)
+}
+
+func TestUserHandler_List_CacheInvalidatedByProfileUpdate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userService := new(mocks.UserServiceMock)
+	handler := transport.NewUserHandler(userService)
+	handler.Cache = cache.NewMemoryCache()
+
+	before := []models.User{{ID: 1, Name: "A", City: "Москва", CityVisibility: models.VisibilityPublic}}
+	after := []models.User{{ID: 1, Name: "A", City: "Москва", CityVisibility: models.VisibilityPrivate}}
+	userService.On("ListUsers", 50, uint(0)).Return(before, uint(0), nil).Once()
+	userService.On("ListUsers", 50, uint(0)).Return(after, uint(0), nil).Once()
+	userService.On("GetUserByID", uint(1)).Return(&models.User{ID: 1}, nil)
+	userService.On("UpdateUser", uint(1), mock.Anything).Return(&after[0], nil)
+
+	r := gin.New()
+	r.GET("/users", handler.List)
+	r.PATCH("/users/:id", func(c *gin.Context) { c.Set("user_id", uint(1)) }, handler.UpdateProfile)
+
+	list := func() string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/users", nil)
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+	require.Contains(t, list(), "Москва")
+	// второй запрос — из кэша
+	require.Contains(t, list(), "Москва")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPatch, "/users/1", strings.NewReader(`{"visibility": {"city": "private"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	require.NotContains(t, list(), "Москва")
+	userService.AssertExpectations(t)
+}
+
+func TestReviewHandler_GetByBook_HidesAuthorContacts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reviewService := new(mocks.ReviewServiceMock)
+	handler := transport.NewReviewHandler(reviewService)
+
+	author := models.User{ID: 3, Name: "Author", Email: "author@example.com", Address: "ул. Мира, 2",
+		EmailVisibility: models.VisibilityPrivate, AddressVisibility: models.VisibilityPartners, CityVisibility: models.VisibilityPublic}
+	reviewService.On("GetByBookID", uint(5)).Return([]models.Review{{AuthorID: 3, Text: "Хорошая книга", Rating: 5, Author: &author}}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/books/5/reviews", nil)
+
+	r := gin.New()
+	r.GET("/books/:id/reviews", handler.GetByBook)
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "Author")
+	require.NotContains(t, w.Body.String(), "author@example.com")
+	require.NotContains(t, w.Body.String(), "Мира")
+}
+
+func TestUserHandler_GetUserExchanges_PartnerSeesAddress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userService := new(mocks.UserServiceMock)
+	handler := transport.NewUserHandler(userService)
+
+	levels := func(u models.User) models.User {
+		u.EmailVisibility, u.AddressVisibility, u.CityVisibility =
+			models.VisibilityPrivate, models.VisibilityPartners, models.VisibilityPublic
+		return u
+	}
+	me := levels(models.User{ID: 1, Name: "Me", Email: "me@example.com", Address: "мой адрес"})
+	partner := levels(models.User{ID: 2, Name: "Partner", Email: "partner@example.com", Address: "адрес партнёра"})
+	exchanges := []models.Exchange{{InitiatorID: 1, RecipientID: 2, Status: "accepted", Initiator: &me, Recipient: &partner}}
+
+	userService.On("GetUserExchanges", uint(1), "").Return(exchanges, nil)
+	userService.On("Relations", uint(1), []uint{1, 2}).
+		Return(map[uint]services.Relation{1: services.RelationSelf, 2: services.RelationPartner}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/users/1/exchanges", nil)
+
+	r := gin.New()
+	r.GET("/users/:id/exchanges", func(c *gin.Context) { c.Set("user_id", uint(1)) }, handler.GetUserExchanges)
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var body []dto.ExchangeResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body, 1)
+	require.Equal(t, "me@example.com", body[0].Initiator.Email)
+	require.Equal(t, "адрес партнёра", body[0].Recipient.Address)
+	require.Empty(t, body[0].Recipient.Email)
+
+	userService.AssertExpectations(t)
+}
+
+func TestUserHandler_DeleteAccount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userService := new(mocks.UserServiceMock)
+	handler := transport.NewUserHandler(userService)
+
+	purgeAfter := time.Now().Add(30 * 24 * time.Hour).UTC()
+	userService.On("DeleteUser", uint(1), "wrong").Return(nil, dto.ErrInvalidCredentials)
+	userService.On("DeleteUser", uint(1), "secret-password").
+		Return(&dto.DeleteAccountResponse{CancelledExchanges: 2, PurgeAfter: purgeAfter}, nil)
+
+	r := gin.New()
+	r.DELETE("/users/:id", func(c *gin.Context) { c.Set("user_id", uint(1)) }, handler.DeleteAccount)
+	send := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodDelete, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// чужой аккаунт, без пароля и с неверным паролем
+	require.Equal(t, http.StatusForbidden, send("/users/2", `{"password":"secret-password"}`).Code)
+	require.Equal(t, http.StatusBadRequest, send("/users/1", `{}`).Code)
+	require.Equal(t, http.StatusForbidden, send("/users/1", `{"password":"wrong"}`).Code)
+
+	w := send("/users/1", `{"password":"secret-password"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp dto.DeleteAccountResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, 2, resp.CancelledExchanges)
+	require.True(t, purgeAfter.Equal(resp.PurgeAfter))
+
+	userService.AssertExpectations(t)
+}
+
+func TestJWTAuth_RejectsDeletedAccount(t *testing.T) {
+	jwtutil.Configure("test-secret-key-with-at-least-32-bytes", time.Hour)
+	userRepo := new(mocks.UserRepositoryMock)
+	userRepo.On("Exists", uint(1)).Return(true, nil)
+	userRepo.On("Exists", uint(2)).Return(false, nil)
+	middleware.SetAccountCheck(userRepo.Exists)
+	defer middleware.SetAccountCheck(nil)
+
+	r := setupGin()
+	r.POST("/books", middleware.JWTAuth(), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	post := func(userID uint) int {
+		token, err := jwtutil.GenerateToken(userID)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/books", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusCreated, post(1))
+	// токен ещё действует, но аккаунт удалён
+	require.Equal(t, http.StatusUnauthorized, post(2))
+	userRepo.AssertExpectations(t)
+}
+
+func TestUserHandler_ExportData_Zip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userService := new(mocks.UserServiceMock)
+	handler := transport.NewUserHandler(userService)
+
+	export := &dto.UserDataExport{
+		ExportedAt: time.Now(),
+		Profile:    dto.ExportProfile{ID: 1, Name: "Alice", Email: "alice@example.com", Address: "ул. Ленина, 1"},
+		Books:      []dto.ExportBook{{ID: 7, Title: "Мастер и Маргарита"}},
+	}
+	userService.On("ExportData", uint(1)).Return(export, nil)
+
+	r := gin.New()
+	r.GET("/users/:id/export", func(c *gin.Context) { c.Set("user_id", uint(1)) }, handler.ExportData)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/users/2/export", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/users/1/export", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	require.Contains(t, w.Header().Get("Content-Disposition"), "bookcrossing-export-1.zip")
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	require.NoError(t, err)
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	require.Contains(t, files, "books.json")
+	require.Contains(t, files, "tokens.json")
+
+	rc, err := files["profile.json"].Open()
+	require.NoError(t, err)
+	defer rc.Close()
+	var profile dto.ExportProfile
+	require.NoError(t, json.NewDecoder(rc).Decode(&profile))
+	require.Equal(t, "alice@example.com", profile.Email)
+	require.Equal(t, "ул. Ленина, 1", profile.Address)
+
+	// тот же документ одним JSON
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/users/1/export?format=json", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var body dto.UserDataExport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Books, 1)
+}
+
+
+func TestRequestID_PropagatedToLogs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	r := gin.New()
+	r.Use(middleware.RequestID(log), middleware.AccessLog())
+	r.GET("/books/:id", func(c *gin.Context) {
+		c.Set("user_id", uint(7))
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", uint(7)))
+		// так пишут сервисы и репозитории
+		ctx := c.Request.Context()
+		logging.From(ctx, nil).ErrorContext(ctx, "error in GetByID function book_repository.go")
+		c.Status(http.StatusNotFound)
+	})
+
+	// входящий X-Request-ID сохраняется
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/books/1", nil)
+	req.Header.Set("X-Request-ID", "req-123")
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, "req-123", w.Header().Get("X-Request-ID"))
+
+	dec := json.NewDecoder(&buf)
+	var repoLine, accessLine map[string]any
+	require.NoError(t, dec.Decode(&repoLine))
+	require.NoError(t, dec.Decode(&accessLine))
+
+	require.Equal(t, "req-123", repoLine["request_id"])
+	require.EqualValues(t, 7, repoLine["user_id"])
+	require.Equal(t, "http request", accessLine["msg"])
+	require.Equal(t, "WARN", accessLine["level"])
+	require.Equal(t, "req-123", accessLine["request_id"])
+	require.EqualValues(t, 7, accessLine["user_id"])
+	require.Equal(t, "/books/:id", accessLine["route"])
+	require.EqualValues(t, http.StatusNotFound, accessLine["status"])
+
+	// без заголовка (или с мусором в нём) генерируется новый
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books/1", nil)
+	req.Header.Set("X-Request-ID", "bad id\n")
+	r.ServeHTTP(w, req)
+	require.Len(t, w.Header().Get("X-Request-ID"), 32)
+}
+
+func TestHealthHandler_Readiness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	redisDown := errors.New("redis: connection refused")
+	checker := health.NewChecker(time.Second)
+	checker.Add("postgres", true, func(context.Context) error { return nil })
+	checker.Add("redis", false, func(context.Context) error { return redisDown })
+
+	r := gin.New()
+	transport.NewHealthHandler(checker).RegisterHealthRoutes(r)
+
+	// без Redis сервис продолжает работать, но в degraded-режиме
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/readyz", nil)
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var report health.Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	require.Equal(t, health.StatusDegraded, report.Status)
+	require.Equal(t, health.StatusUnavailable, report.Checks["redis"].Status)
+	require.Equal(t, redisDown.Error(), report.Checks["redis"].Error)
+
+	// незавершённые миграции — сервис не готов
+	migrations := health.NewFlag("migrations pending")
+	checker.Add("migrations", true, migrations.Check)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	migrations.Done()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/healthz", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+}
 
 // *********************************************************************************
 // *						  Тесты для book								   	   *
@@ -213,4 +629,496 @@ func TestUserHandler_List_OK(t *testing.T) {
 // *						  Тесты для exchange								   *
 // *								  |											   *
 // *								  V									   		   *
-// *********************************************************************************
\ No newline at end of file
+// *********************************************************************************
+// setupAPIRouter собирает полный роутер через transport.RegisterRoutes
+func setupAPIRouter(genreService *mocks.GenreServiceMock, store idempotency.Store) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	r := gin.New()
+	transport.RegisterRoutes(r, log,
+		new(mocks.BookServiceMock),
+		new(mocks.ExchangeServiceMock),
+		genreService,
+		new(mocks.ReviewServiceMock),
+		new(mocks.UserServiceMock),
+		new(mocks.ModerationServiceMock),
+		nil, nil, nil, store,
+		health.NewChecker(time.Second),
+		nil,
+		nil,
+	)
+	return r
+}
+
+func TestRoutes_APIUnavailableUntilMigrated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	genreService := new(mocks.GenreServiceMock)
+	genreService.On("List").Return([]models.Genre{}, nil)
+
+	migrations := health.NewFlag("migrations pending")
+	checker := health.NewChecker(time.Second)
+	checker.Add("migrations", true, migrations.Check)
+
+	r := gin.New()
+	transport.RegisterRoutes(r, log,
+		new(mocks.BookServiceMock),
+		new(mocks.ExchangeServiceMock),
+		genreService,
+		new(mocks.ReviewServiceMock),
+		new(mocks.UserServiceMock),
+		new(mocks.ModerationServiceMock),
+		nil, nil, nil, nil,
+		checker,
+		migrations.Check,
+		nil,
+	)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/api/v1/genres")
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.NotEmpty(t, w.Header().Get("Retry-After"))
+	require.Equal(t, http.StatusServiceUnavailable, get("/genres").Code)
+	// служебные маршруты отвечают и во время миграций
+	require.Equal(t, http.StatusOK, get("/healthz").Code)
+	require.Equal(t, http.StatusServiceUnavailable, get("/readyz").Code)
+	genreService.AssertNotCalled(t, "List")
+
+	// сбой миграций — не «запуск»: повторять запрос бессмысленно
+	migrations.Fail(errors.New("schema error"))
+	w = get("/api/v1/genres")
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Empty(t, w.Header().Get("Retry-After"))
+	require.NotContains(t, w.Body.String(), "starting")
+
+	migrations.Done()
+	require.Equal(t, http.StatusOK, get("/api/v1/genres").Code)
+	genreService.AssertExpectations(t)
+}
+
+func TestOpenAPI_CoversAllRoutes(t *testing.T) {
+	r := setupAPIRouter(new(mocks.GenreServiceMock), nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	require.Equal(t, "3.0.3", spec.OpenAPI)
+
+	// /books/:id → /books/{id}
+	toOpenAPI := func(path string) string {
+		parts := strings.Split(path, "/")
+		for i, p := range parts {
+			if strings.HasPrefix(p, ":") {
+				parts[i] = "{" + p[1:] + "}"
+			}
+		}
+		return strings.Join(parts, "/")
+	}
+
+	registered := map[string]bool{}
+	for _, route := range r.Routes() {
+		path := toOpenAPI(route.Path)
+		method := strings.ToLower(route.Method)
+		registered[method+" "+path] = true
+
+		_, ok := spec.Paths[path][method]
+		require.Truef(t, ok, "маршрут %s %s не описан в OpenAPI", route.Method, route.Path)
+	}
+
+	// и наоборот: в спецификации нет маршрутов, которых нет в роутере
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			require.Truef(t, registered[method+" "+path], "в OpenAPI описан несуществующий маршрут %s %s", method, path)
+		}
+	}
+}
+
+func TestLegacyRoutes_DeprecationHeaders(t *testing.T) {
+	genreService := new(mocks.GenreServiceMock)
+	genreService.On("List").Return([]models.Genre{{Name: "Роман"}}, nil)
+	r := setupAPIRouter(genreService, nil)
+
+	// новый путь — без заголовков устаревания
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/genres", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Header().Get("Deprecation"))
+
+	// старый путь работает, но помечен устаревшим
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/genres", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.True(t, strings.HasPrefix(w.Header().Get("Deprecation"), "@"))
+	sunset, err := http.ParseTime(w.Header().Get("Sunset"))
+	require.NoError(t, err)
+	require.True(t, sunset.After(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)))
+	require.Contains(t, w.Header().Get("Link"), `rel="deprecation"`)
+
+	// заголовки ставятся и когда запрос отклонён до хендлера
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, "/exchanges/1/accept", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.NotEmpty(t, w.Header().Get("Deprecation"))
+
+	// переименованные маршруты: старый путь отзывов остаётся алиасом
+	routes := map[string]bool{}
+	for _, route := range r.Routes() {
+		routes[route.Method+" "+route.Path] = true
+	}
+	require.True(t, routes["GET /api/v1/books/:id/reviews"])
+	require.True(t, routes["GET /book/:id/review"])
+	require.True(t, routes["POST /api/v1/exchanges/:id/accept"])
+	require.False(t, routes["PUT /api/v1/exchanges/:id/accept"])
+
+	// маршруты, появившиеся после v1, алиасов без версии не получают
+	require.True(t, routes["DELETE /api/v1/users/:id"])
+	require.False(t, routes["DELETE /users/:id"])
+	require.False(t, routes["GET /users/:id/export"])
+	require.False(t, routes["GET /genres/tree"])
+	require.True(t, routes["PATCH /books/:id"])
+
+	genreService.AssertExpectations(t)
+}
+
+func TestGenreHandler_GetBySlug(t *testing.T) {
+	genreService := new(mocks.GenreServiceMock)
+	genre := &models.Genre{Name: "Фантастика", Slug: "sci-fi"}
+	genre.ID = 2
+	genreService.On("GetBySlug", "sci-fi").Return(genre, nil)
+	genreService.On("GetBySlug", "missing").Return(nil, dto.ErrNotFound)
+	genreService.On("GetByID", uint(2)).Return(genre, nil)
+	r := setupAPIRouter(genreService, nil)
+
+	for path, code := range map[string]int{
+		"/api/v1/genres/sci-fi":  http.StatusOK,
+		"/api/v1/genres/2":       http.StatusOK,
+		"/api/v1/genres/missing": http.StatusNotFound,
+		"/api/v1/genres/0":       http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		r.ServeHTTP(w, req)
+		require.Equal(t, code, w.Code, path)
+	}
+
+	genreService.AssertExpectations(t)
+}
+
+func TestIdempotency_ReplaysFirstResponse(t *testing.T) {
+	genreService := new(mocks.GenreServiceMock)
+	genre := &models.Genre{Name: "Роман"}
+	genre.ID = 1
+	genreService.On("Create", dto.GenreCreateRequest{Name: "Роман"}).Return(genre, nil).Once()
+	r := setupAPIRouter(genreService, idempotency.NewMemoryStore())
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/genres", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("key-1", `{"name":"Роман"}`)
+	require.Equal(t, http.StatusCreated, first.Code)
+	require.Empty(t, first.Header().Get(middleware.IdempotentReplayedHeader))
+
+	// повтор: сервис не вызывается, ответ тот же
+	retry := send("key-1", `{"name":"Роман"}`)
+	require.Equal(t, http.StatusCreated, retry.Code)
+	require.Equal(t, "true", retry.Header().Get(middleware.IdempotentReplayedHeader))
+	require.Equal(t, first.Body.String(), retry.Body.String())
+
+	// тот же ключ, другое тело
+	other := send("key-1", `{"name":"Фантастика"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, other.Code)
+
+	genreService.AssertExpectations(t)
+}
+
+func TestIdempotency_ServerErrorIsNotStored(t *testing.T) {
+	genreService := new(mocks.GenreServiceMock)
+	req := dto.GenreCreateRequest{Name: "Роман"}
+	genre := &models.Genre{Name: "Роман"}
+	genreService.On("Create", req).Return(nil, errors.New("db down")).Once()
+	genreService.On("Create", req).Return(genre, nil).Once()
+	r := setupAPIRouter(genreService, idempotency.NewMemoryStore())
+
+	codes := make([]int, 0, 2)
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest(http.MethodPost, "/api/v1/genres", strings.NewReader(`{"name":"Роман"}`))
+		httpReq.Header.Set(middleware.IdempotencyKeyHeader, "key-2")
+		r.ServeHTTP(w, httpReq)
+		codes = append(codes, w.Code)
+	}
+
+	// после 5xx ключ освобождается, и повтор выполняется заново
+	require.Equal(t, []int{http.StatusInternalServerError, http.StatusCreated}, codes)
+	genreService.AssertExpectations(t)
+}
+
+func TestIdempotency_ConflictIsNotStored(t *testing.T) {
+	genreService := new(mocks.GenreServiceMock)
+	req := dto.GenreCreateRequest{Name: "Повесть"}
+	genreService.On("Create", req).Return(nil, dto.ErrConflict).Once()
+	genreService.On("Create", req).Return(&models.Genre{Name: "Повесть"}, nil).Once()
+	r := setupAPIRouter(genreService, idempotency.NewMemoryStore())
+
+	codes := make([]int, 0, 3)
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest(http.MethodPost, "/api/v1/genres", strings.NewReader(`{"name":"Повесть"}`))
+		httpReq.Header.Set(middleware.IdempotencyKeyHeader, "key-3")
+		r.ServeHTTP(w, httpReq)
+		codes = append(codes, w.Code)
+	}
+
+	// 409 зависит от момента запроса: повтор выполняется заново, а успех уже сохраняется
+	require.Equal(t, []int{http.StatusConflict, http.StatusCreated, http.StatusCreated}, codes)
+	genreService.AssertExpectations(t)
+}
+
+func TestIdempotency_BodyTooLarge(t *testing.T) {
+	genreService := new(mocks.GenreServiceMock)
+	r := setupAPIRouter(genreService, idempotency.NewMemoryStore())
+
+	w := httptest.NewRecorder()
+	body := `{"name":"` + strings.Repeat("a", 2<<20) + `"}`
+	httpReq, _ := http.NewRequest(http.MethodPost, "/api/v1/genres", strings.NewReader(body))
+	httpReq.Header.Set(middleware.IdempotencyKeyHeader, "key-4")
+	r.ServeHTTP(w, httpReq)
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	genreService.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestIdempotencyMemoryStore_ReleaseKeepsOtherOwner(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+	ctx := context.Background()
+
+	_, reserved, err := store.Reserve(ctx, "key", "fp", "first", time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, reserved)
+	time.Sleep(5 * time.Millisecond)
+
+	// блокировка первого запроса истекла, ключ занял второй
+	_, reserved, err = store.Reserve(ctx, "key", "fp", "second", time.Minute)
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	// запоздавший первый запрос не снимает чужую блокировку
+	require.NoError(t, store.Release(ctx, "key", "first"))
+	_, reserved, err = store.Reserve(ctx, "key", "fp", "third", time.Minute)
+	require.NoError(t, err)
+	require.False(t, reserved)
+
+	require.NoError(t, store.Release(ctx, "key", "second"))
+	_, reserved, err = store.Reserve(ctx, "key", "fp", "third", time.Minute)
+	require.NoError(t, err)
+	require.True(t, reserved)
+}
+
+func TestBookHandler_ETagAndConditionalRequests(t *testing.T) {
+	r := setupGin()
+	bookService := new(mocks.BookServiceMock)
+	handler := transport.NewBookHandler(bookService)
+	handler.RegisterRoutes(r)
+
+	book := &models.Book{Title: "Пикник на обочине", Status: "available", Version: 3}
+	book.ID = 1
+	bookService.On("GetByID", uint(1)).Return(book, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/books/1", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	tag := w.Header().Get("ETag")
+	require.True(t, strings.HasPrefix(tag, `"3.`), tag)
+
+	// ресурс не изменился — 304 без тела
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books/1", nil)
+	req.Header.Set("If-None-Match", tag)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Empty(t, w.Body.String())
+
+	// устаревший ETag — полный ответ
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books/1", nil)
+	req.Header.Set("If-None-Match", `"2.0000000000000000"`)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	bookService.AssertExpectations(t)
+}
+
+func TestBookHandler_GetBookByID_HidesHeldDescription(t *testing.T) {
+	r := setupGin()
+	bookService := new(mocks.BookServiceMock)
+	handler := transport.NewBookHandler(bookService)
+	handler.RegisterRoutes(r)
+
+	book := &models.Book{
+		Title:            "Трудно быть богом",
+		Description:      "звоните 8-900-000-00-00",
+		AISummary:        "краткое содержание",
+		Status:           "available",
+		ModerationStatus: moderation.StatusHeld,
+	}
+	book.ID = 1
+	bookService.On("GetByID", uint(1)).Return(book, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/books/1", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var got models.Book
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	require.Equal(t, "Трудно быть богом", got.Title)
+	require.Empty(t, got.Description)
+	require.Empty(t, got.AISummary)
+	require.NotContains(t, w.Body.String(), "8-900")
+
+	bookService.AssertExpectations(t)
+}
+
+func TestBookHandler_SearchFacetsCached(t *testing.T) {
+	r := setupGin()
+	bookService := new(mocks.BookServiceMock)
+	handler := transport.NewBookHandler(bookService)
+	handler.Cache = cache.NewMemoryCache()
+	handler.RegisterRoutes(r)
+
+	facets := map[string][]dto.FacetValue{
+		dto.FacetGenre: {{Value: "1", Label: "Фэнтези", Count: 120}, {Value: "2", Label: "Детектив", Count: 45}},
+		dto.FacetCity:  {{Value: "Казань", Count: 3}},
+	}
+	bookService.On("SearchBooks", mock.Anything).Return(&repository.BookPage{}, nil)
+	bookService.On("SearchFacets", mock.Anything, []string{dto.FacetGenre, dto.FacetCity}).Return(facets, nil).Once()
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/books?status=available&facets=genre,city,genre&page="+strconv.Itoa(i+1), nil)
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp dto.BookListResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Equal(t, facets, resp.Facets)
+	}
+
+	// без facets фасеты не считаются и в ответ не попадают
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/books", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotContains(t, w.Body.String(), `"facets"`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?facets=price", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// второй запрос с теми же фильтрами взял фасеты из кэша
+	bookService.AssertExpectations(t)
+}
+
+func TestBookHandler_SearchCursor(t *testing.T) {
+	r := setupGin()
+	bookService := new(mocks.BookServiceMock)
+	transport.NewBookHandler(bookService).RegisterRoutes(r)
+
+	book := models.Book{Title: "Солярис", Status: "available"}
+	book.ID = 5
+	bookService.On("SearchBooks", mock.MatchedBy(func(q dto.BookListQuery) bool { return q.Cursor == "next" })).
+		Return(&repository.BookPage{Books: []models.Book{book}, NextCursor: "after-5"}, nil)
+	bookService.On("SearchBooks", mock.MatchedBy(func(q dto.BookListQuery) bool { return q.Cursor == "stale" })).
+		Return(nil, dto.ErrInvalidCursor)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/books?cursor=next&limit=1", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, "after-5", resp["next_cursor"])
+	// с курсором total не считается, а номера страницы нет
+	require.NotContains(t, resp, "total")
+	require.NotContains(t, resp, "page")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?cursor=stale", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?count=approx", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	bookService.AssertExpectations(t)
+}
+
+func TestBookHandler_SearchNear(t *testing.T) {
+	r := setupGin()
+	bookService := new(mocks.BookServiceMock)
+	transport.NewBookHandler(bookService).RegisterRoutes(r)
+
+	book := models.Book{Title: "Пикник на обочине", Status: "available", Location: "Химки"}
+	book.ID = 7
+	page := &repository.BookPage{Books: []models.Book{book}, Distances: map[uint]float64{7: 18.64}}
+	bookService.On("SearchBooks", mock.MatchedBy(func(q dto.BookListQuery) bool {
+		return q.Origin != nil && q.RadiusKm == 30 && q.SortBy == "distance"
+	})).Return(page, nil)
+	bookService.On("SearchBooks", mock.MatchedBy(func(q dto.BookListQuery) bool {
+		return q.Status == "available" && q.RadiusKm == dto.DefaultRadiusKm && q.Limit == dto.MaxLimit
+	})).Return(page, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/books?near=55.7558,37.6173&radius_km=30", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp dto.BookListResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1)
+	require.Equal(t, 18.6, *resp.Data[0].DistanceKm)
+	require.Equal(t, "Химки", resp.Data[0].Location)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books/available?near=55.7558,37.6173", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var available []dto.BookResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &available))
+	require.Len(t, available, 1)
+	require.NotNil(t, available[0].DistanceKm)
+
+	for _, url := range []string{"/books?near=moscow", "/books?near=55,37&radius_km=1000", "/books?radius_km=10", "/books/available?radius_km=ten"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, url, nil)
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusBadRequest, w.Code, url)
+	}
+
+	bookService.AssertExpectations(t)
+}
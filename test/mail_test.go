@@ -0,0 +1,184 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dasler-fw/bookcrossing/internal/mail"
+	"github.com/stretchr/testify/require"
+)
+
+// *********************************************************************************
+// *						  Тесты для mail									   *
+// *								  |											   *
+// *								  V									   		   *
+// *********************************************************************************
+
+func TestMail_Render_Languages(t *testing.T) {
+	data := map[string]string{"Name": "Alice", "Link": "https://example.com/v", "ExpiresAt": "tomorrow"}
+
+	ru, err := mail.Render("ru", mail.TemplateVerifyEmail, "alice@example.com", data)
+	require.NoError(t, err)
+	require.Equal(t, "alice@example.com", ru.To)
+	require.Contains(t, ru.Subject, "Подтвердите")
+	require.Contains(t, ru.Body, "https://example.com/v")
+
+	en, err := mail.Render("EN", mail.TemplateVerifyEmail, "alice@example.com", data)
+	require.NoError(t, err)
+	require.Contains(t, en.Subject, "Confirm")
+
+	// неизвестный язык — шаблон по умолчанию
+	fallback, err := mail.Render("de", mail.TemplateVerifyEmail, "alice@example.com", data)
+	require.NoError(t, err)
+	require.Equal(t, ru.Subject, fallback.Subject)
+}
+
+func TestMail_LogMailer_DoesNotLogBody(t *testing.T) {
+	var buf strings.Builder
+	m := mail.NewLogMailer(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	err := m.Send(context.Background(), mail.Message{
+		To:      "alice@example.com",
+		Subject: "Reset",
+		Body:    "https://example.com/reset?token=secret-token",
+	})
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "alice@example.com")
+	require.NotContains(t, buf.String(), "secret-token")
+}
+
+type flakyMailer struct {
+	failures int32
+	calls    atomic.Int32
+	sent     chan mail.Message
+}
+
+func (m *flakyMailer) Send(_ context.Context, msg mail.Message) error {
+	if m.calls.Add(1) <= m.failures {
+		return errors.New("smtp unavailable")
+	}
+	m.sent <- msg
+	return nil
+}
+
+func TestMail_Queue_RetriesUntilDelivered(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mailer := &flakyMailer{failures: 2, sent: make(chan mail.Message, 1)}
+
+	q := mail.NewQueue(mailer, log, mail.QueueConfig{Workers: 1, Backoff: time.Millisecond})
+	q.Start()
+
+	require.NoError(t, q.Send(context.Background(), mail.Message{To: "bob@example.com", Subject: "hi"}))
+
+	select {
+	case msg := <-mailer.sent:
+		require.Equal(t, "bob@example.com", msg.To)
+	case <-time.After(2 * time.Second):
+		t.Fatal("message was not delivered")
+	}
+	require.Equal(t, int32(3), mailer.calls.Load())
+
+	require.NoError(t, q.Stop(context.Background()))
+	require.ErrorIs(t, q.Send(context.Background(), mail.Message{To: "x@example.com"}), mail.ErrQueueClosed)
+}
+
+// smtpSink — минимальный SMTP-сервер, который сохраняет полученные письма
+type smtpSink struct {
+	ln   net.Listener
+	mu   sync.Mutex
+	data []string
+}
+
+func startSMTPSink(t *testing.T) *smtpSink {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	sink := &smtpSink{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go sink.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+
+	return sink
+}
+
+func (s *smtpSink) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 sink ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 sink")
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 go ahead")
+			var body strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				body.WriteString(l)
+			}
+			s.mu.Lock()
+			s.data = append(s.data, body.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestMail_SMTPMailer_SendsToSink(t *testing.T) {
+	sink := startSMTPSink(t)
+	host, portStr, _ := net.SplitHostPort(sink.ln.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	mailer := mail.NewSMTPMailer(mail.SMTPConfig{
+		Host: host,
+		Port: port,
+		From: "no-reply@bookcrossing.local",
+	})
+
+	msg, err := mail.Render("en", mail.TemplatePasswordReset, "carol@example.com", map[string]string{
+		"Name": "Carol", "Link": "https://example.com/reset", "ExpiresAt": "soon",
+	})
+	require.NoError(t, err)
+	require.NoError(t, mailer.Send(context.Background(), msg))
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	require.Len(t, sink.data, 1)
+	require.Contains(t, sink.data[0], "To: carol@example.com")
+	require.Contains(t, sink.data[0], "Reset your Bookcrossing password")
+}
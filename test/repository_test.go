@@ -1,15 +1,27 @@
 package test
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"log/slog"
+	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/glebarez/sqlite" // драйвер от Глебареза
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"gorm.io/gorm"
+	"gorm.io/plugin/opentelemetry/tracing"
 
+	"github.com/dasler-fw/bookcrossing/internal/config"
 	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/geo"
+	"github.com/dasler-fw/bookcrossing/internal/metrics"
 	"github.com/dasler-fw/bookcrossing/internal/models"
 	"github.com/dasler-fw/bookcrossing/internal/repository"
 )
@@ -26,7 +38,14 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		&models.Genre{},
 		&models.Book{},
 		&models.Review{},
+		&models.ReviewRevision{},
+		&models.ReviewReply{},
+		&models.ContentReport{},
 		&models.Exchange{},
+		&models.UserToken{},
+		&models.BookRating{},
+		&models.UserRating{},
+		&models.DataMigration{},
 	)
 	require.NoError(t, err)
 
@@ -52,39 +71,272 @@ func TestUserRepository_CRUD(t *testing.T) {
 		City:         "Moscow",
 		Address:      "Lenina 1",
 	}
-	err := repo.Create(user)
+	err := repo.Create(context.Background(), user)
 	require.NoError(t, err)
 	require.NotZero(t, user.ID)
 	//  GetByID
-	got, err := repo.GetByID(user.ID)
+	got, err := repo.GetByID(context.Background(), user.ID)
 	require.NoError(t, err)
 	require.Equal(t, user.Email, got.Email)
 
 	//  GetByEmail
-	gotByEmail, err := repo.GetByEmail("alice@example.com")
+	gotByEmail, err := repo.GetByEmail(context.Background(), "alice@example.com")
 	require.NoError(t, err)
 	require.Equal(t, user.ID, gotByEmail.ID)
 
 	//  Update
 	newName := "Alice Updated"
 	user.Name = newName
-	err = repo.Update(user)
+	err = repo.Update(context.Background(), user)
 	require.NoError(t, err)
-	got, _ = repo.GetByID(user.ID)
+	got, _ = repo.GetByID(context.Background(), user.ID)
 	require.Equal(t, newName, got.Name)
 
 	//  ListUsers
-	users, err := repo.ListUsers(10, 0)
+	users, err := repo.ListUsers(context.Background(), 10, 0)
 	require.NoError(t, err)
 	require.Len(t, users, 1)
 
 	//  Delete
-	err = repo.Delete(user.ID)
+	err = repo.Delete(context.Background(), user.ID)
 	require.NoError(t, err)
-	_, err = repo.GetByID(user.ID)
+	_, err = repo.GetByID(context.Background(), user.ID)
 	require.ErrorIs(t, err, repository.ErrUserNotFound)
 }
 
+func TestUserRepository_PartnerIDs(t *testing.T) {
+	db := setupTestDB(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := repository.NewUserRepository(db, log)
+	ctx := context.Background()
+
+	owner := &models.User{Name: "Owner", Email: "partners-owner@example.com", PasswordHash: "hash"}
+	partner := &models.User{Name: "Partner", Email: "partners-accepted@example.com", PasswordHash: "hash"}
+	pending := &models.User{Name: "Pending", Email: "partners-pending@example.com", PasswordHash: "hash"}
+	stranger := &models.User{Name: "Stranger", Email: "partners-stranger@example.com", PasswordHash: "hash"}
+	for _, u := range []*models.User{owner, partner, pending, stranger} {
+		require.NoError(t, db.Create(u).Error)
+	}
+
+	// принятый обмен, где owner — получатель, и ожидающий, где инициатор
+	require.NoError(t, db.Create(&models.Exchange{InitiatorID: partner.ID, RecipientID: owner.ID, Status: "accepted"}).Error)
+	require.NoError(t, db.Create(&models.Exchange{InitiatorID: owner.ID, RecipientID: pending.ID, Status: "pending"}).Error)
+
+	ids, err := repo.PartnerIDs(ctx, owner.ID, []uint{partner.ID, pending.ID, stranger.ID})
+	require.NoError(t, err)
+	require.Equal(t, []uint{partner.ID}, ids)
+
+	// отношение симметрично
+	ids, err = repo.PartnerIDs(ctx, partner.ID, []uint{owner.ID})
+	require.NoError(t, err)
+	require.Equal(t, []uint{owner.ID}, ids)
+
+	// участники обменов подгружаются для ответа
+	exchanges, err := repo.GetUserExchanges(ctx, owner.ID, "")
+	require.NoError(t, err)
+	require.Len(t, exchanges, 2)
+	for _, e := range exchanges {
+		require.NotNil(t, e.Initiator)
+		require.NotNil(t, e.Recipient)
+	}
+}
+
+func TestUserRepository_DeleteAccountAndPurge(t *testing.T) {
+	db := setupTestDB(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := repository.NewUserRepository(db, log)
+	exchanges := repository.NewExchangeRepository(db, log)
+	ctx := context.Background()
+
+	owner := &models.User{Name: "Leaving", Email: "leaving@example.com", PasswordHash: "hash", City: "Тула", Address: "ул. Мира, 2"}
+	partner := &models.User{Name: "Staying", Email: "staying@example.com", PasswordHash: "hash"}
+	require.NoError(t, db.Create(owner).Error)
+	require.NoError(t, db.Create(partner).Error)
+
+	ownBook := &models.Book{Title: "Leaving Book", Author: "A", Status: "available", UserID: owner.ID, Location: "ул. Мира, 2",
+		Description: "Звоните Лене", AISummary: "Резюме"}
+	partnerBook := &models.Book{Title: "Staying Book", Author: "B", Status: "available", UserID: partner.ID}
+	require.NoError(t, db.Create(ownBook).Error)
+	require.NoError(t, db.Create(partnerBook).Error)
+
+	// открытый обмен резервирует обе книги
+	open := &models.Exchange{InitiatorID: owner.ID, RecipientID: partner.ID, InitiatorBookID: ownBook.ID, RecipientBookID: partnerBook.ID, Status: "pending"}
+	require.NoError(t, exchanges.CreateExchange(ctx, open))
+	done := &models.Exchange{InitiatorID: partner.ID, RecipientID: owner.ID, Status: "completed"}
+	require.NoError(t, db.Create(done).Error)
+	review := &models.Review{AuthorID: owner.ID, TargetUserID: partner.ID, TargetBookID: partnerBook.ID, ExchangeID: &done.ID, Text: "Всё прошло отлично", Rating: 5}
+	require.NoError(t, db.Create(review).Error)
+	require.NoError(t, db.Create(&models.UserToken{UserID: owner.ID, Purpose: models.TokenPurposeVerifyEmail, TokenHash: "leaving-token", ExpiresAt: time.Now().Add(time.Hour)}).Error)
+
+	//  CollectData
+	data, err := repo.CollectData(ctx, owner.ID)
+	require.NoError(t, err)
+	require.Equal(t, owner.Email, data.User.Email)
+	require.Len(t, data.Books, 1)
+	require.Len(t, data.Exchanges, 2)
+	require.Len(t, data.ReviewsWritten, 1)
+	require.Empty(t, data.ReviewsReceived)
+	require.Len(t, data.Tokens, 1)
+
+	exists, err := repo.Exists(ctx, owner.ID)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	// книга без обменов и отзывов, ответ, жалоба и прежняя редакция отзыва
+	genre := &models.Genre{Name: "Purge Жанр"}
+	require.NoError(t, db.Create(genre).Error)
+	spareBook := &models.Book{Title: "Spare Book", Author: "C", Status: "available", UserID: owner.ID, Genres: []models.Genre{*genre}}
+	require.NoError(t, db.Create(spareBook).Error)
+	partnerReview := &models.Review{AuthorID: partner.ID, TargetUserID: owner.ID, TargetBookID: ownBook.ID, Text: "Хороший обмен", Rating: 4}
+	require.NoError(t, db.Create(partnerReview).Error)
+	reply := &models.ReviewReply{ReviewID: partnerReview.ID, AuthorID: owner.ID, Text: "Спасибо, пишите на почту"}
+	require.NoError(t, db.Create(reply).Error)
+	report := &models.ContentReport{ContentType: models.ContentBook, ContentID: partnerBook.ID, ReporterID: owner.ID, Reason: "Моё имя в описании"}
+	require.NoError(t, db.Create(report).Error)
+	require.NoError(t, db.Create(&models.ReviewRevision{ReviewID: review.ID, Text: "Первая версия", Rating: 4, ReplacedAt: time.Now()}).Error)
+
+	//  DeleteAccount
+	cancelled, err := repo.DeleteAccount(ctx, owner.ID)
+	require.NoError(t, err)
+	require.Len(t, cancelled, 1)
+	require.Equal(t, open.ID, cancelled[0].ID)
+
+	var gotExchange models.Exchange
+	require.NoError(t, db.First(&gotExchange, open.ID).Error)
+	require.Equal(t, "cancelled", gotExchange.Status)
+	require.Equal(t, owner.ID, *gotExchange.CancelledBy)
+	var gotBook models.Book
+	require.NoError(t, db.First(&gotBook, partnerBook.ID).Error)
+	require.Equal(t, "available", gotBook.Status)
+	// своя книга снята с обмена, отзыв остался
+	require.ErrorIs(t, db.First(&models.Book{}, ownBook.ID).Error, gorm.ErrRecordNotFound)
+	require.NoError(t, db.First(&models.Review{}, review.ID).Error)
+	_, err = repo.GetByID(ctx, owner.ID)
+	require.ErrorIs(t, err, repository.ErrUserNotFound)
+	exists, err = repo.Exists(ctx, owner.ID)
+	require.NoError(t, err)
+	require.False(t, exists)
+	_, err = repo.DeleteAccount(ctx, owner.ID)
+	require.ErrorIs(t, err, repository.ErrUserNotFound)
+
+	// email сразу можно занять заново
+	require.NoError(t, db.Create(&models.User{Name: "New", Email: "leaving@example.com", PasswordHash: "hash"}).Error)
+
+	//  Purge — только после срока ожидания
+	ids, err := repo.ListDeletedBefore(ctx, time.Now().Add(-time.Hour), 100)
+	require.NoError(t, err)
+	require.NotContains(t, ids, owner.ID)
+	ids, err = repo.ListDeletedBefore(ctx, time.Now().Add(time.Minute), 100)
+	require.NoError(t, err)
+	require.Contains(t, ids, owner.ID)
+
+	require.NoError(t, repo.Purge(ctx, owner.ID))
+
+	var purged models.User
+	require.NoError(t, db.Unscoped().First(&purged, owner.ID).Error)
+	require.Empty(t, purged.Name)
+	require.Empty(t, purged.City)
+	require.Empty(t, purged.Address)
+	require.Empty(t, purged.PasswordHash)
+	require.NotContains(t, purged.Email, "leaving")
+	require.NotNil(t, purged.PurgedAt)
+	// книга из обмена остаётся без описания, неиспользуемая удаляется совсем
+	var purgedBook models.Book
+	require.NoError(t, db.Unscoped().First(&purgedBook, ownBook.ID).Error)
+	require.Equal(t, "Leaving Book", purgedBook.Title)
+	require.Empty(t, purgedBook.Location)
+	require.Empty(t, purgedBook.Description)
+	require.Empty(t, purgedBook.AISummary)
+	require.ErrorIs(t, db.Unscoped().First(&models.Book{}, spareBook.ID).Error, gorm.ErrRecordNotFound)
+	var bookGenres int64
+	require.NoError(t, db.Table("book_genres").Where("book_id = ?", spareBook.ID).Count(&bookGenres).Error)
+	require.Zero(t, bookGenres)
+	// отзыв остаётся в рейтинге, но тексты автора стёрты
+	var purgedReview models.Review
+	require.NoError(t, db.Unscoped().First(&purgedReview, review.ID).Error)
+	require.Equal(t, 5, purgedReview.Rating)
+	require.Empty(t, purgedReview.Text)
+	var revisions int64
+	require.NoError(t, db.Model(&models.ReviewRevision{}).Where("review_id = ?", review.ID).Count(&revisions).Error)
+	require.Zero(t, revisions)
+	var purgedReply models.ReviewReply
+	require.NoError(t, db.Unscoped().First(&purgedReply, reply.ID).Error)
+	require.Empty(t, purgedReply.Text)
+	var purgedReport models.ContentReport
+	require.NoError(t, db.Unscoped().First(&purgedReport, report.ID).Error)
+	require.Empty(t, purgedReport.Reason)
+	// чужой отзыв о пользователе не меняется
+	var otherReview models.Review
+	require.NoError(t, db.First(&otherReview, partnerReview.ID).Error)
+	require.Equal(t, "Хороший обмен", otherReview.Text)
+	var tokens int64
+	require.NoError(t, db.Unscoped().Model(&models.UserToken{}).Where("user_id = ?", owner.ID).Count(&tokens).Error)
+	require.Zero(t, tokens)
+
+	ids, err = repo.ListDeletedBefore(ctx, time.Now().Add(time.Minute), 100)
+	require.NoError(t, err)
+	require.NotContains(t, ids, owner.ID)
+}
+
+func TestMigrateLegacyUsers(t *testing.T) {
+	db := setupTestDB(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	legacy := &models.User{Name: "Legacy", Email: " Legacy-Case@Example.com", PasswordHash: "hash"}
+	pending := &models.User{Name: "Pending", Email: "legacy-pending@example.com", PasswordHash: "hash"}
+	lower := &models.User{Name: "Lower", Email: "legacy-dup@example.com", PasswordHash: "hash"}
+	upper := &models.User{Name: "Upper", Email: "Legacy-Dup@example.com", PasswordHash: "hash"}
+	for _, u := range []*models.User{legacy, pending, lower, upper} {
+		require.NoError(t, db.Create(u).Error)
+	}
+	// письмо с подтверждением уже отправлялось — пользователь зарегистрирован после его появления
+	require.NoError(t, db.Create(&models.UserToken{UserID: pending.ID, Purpose: models.TokenPurposeVerifyEmail, TokenHash: "legacy-pending-token", ExpiresAt: time.Now().Add(time.Hour)}).Error)
+
+	require.NoError(t, repository.MigrateLegacyUsers(ctx, db, log))
+
+	get := func(id uint) models.User {
+		var u models.User
+		require.NoError(t, db.First(&u, id).Error)
+		return u
+	}
+	got := get(legacy.ID)
+	require.Equal(t, "legacy-case@example.com", got.Email)
+	require.NotNil(t, got.EmailVerifiedAt)
+	require.Nil(t, get(pending.ID).EmailVerifiedAt)
+	// адрес в нижнем регистре занят — второй аккаунт не трогаем
+	require.Equal(t, "Legacy-Dup@example.com", get(upper.ID).Email)
+
+	// повторный запуск ничего не делает
+	later := &models.User{Name: "Later", Email: "Legacy-Later@example.com", PasswordHash: "hash"}
+	require.NoError(t, db.Create(later).Error)
+	require.NoError(t, repository.MigrateLegacyUsers(ctx, db, log))
+	got = get(later.ID)
+	require.Equal(t, "Legacy-Later@example.com", got.Email)
+	require.Nil(t, got.EmailVerifiedAt)
+}
+
+func TestResetAddressLocations(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	lat, lon := 55.897, 37.4297
+	user := &models.User{Name: "Located", Email: "located-by-address@example.com", PasswordHash: "hash", Lat: &lat, Lon: &lon}
+	require.NoError(t, db.Create(user).Error)
+
+	require.NoError(t, repository.ResetAddressLocations(ctx, db))
+	var got models.User
+	require.NoError(t, db.First(&got, user.ID).Error)
+	require.Nil(t, got.Lat)
+	require.Nil(t, got.Lon)
+
+	// координаты, заполненные после миграции, больше не стираются
+	require.NoError(t, db.Model(&got).Updates(map[string]any{"lat": lat, "lon": lon}).Error)
+	require.NoError(t, repository.ResetAddressLocations(ctx, db))
+	require.NoError(t, db.First(&got, user.ID).Error)
+	require.NotNil(t, got.Lat)
+}
+
 // *********************************************************************************
 // *						  Тесты для book									   *
 // *								  |											   *
@@ -120,11 +372,11 @@ func TestBookRepository_CRUD(t *testing.T) {
 		UserID:      user.ID,
 		Genres:      []models.Genre{*genre},
 	}
-	require.NoError(t, repo.Create(book))
+	require.NoError(t, repo.Create(context.Background(), book))
 	require.NotZero(t, book.ID)
 
 	//  GetByID и проверка полей
-	got, err := repo.GetByID(book.ID)
+	got, err := repo.GetByID(context.Background(), book.ID)
 	require.NoError(t, err)
 	require.Equal(t, "Test Book", got.Title)
 	require.Equal(t, "Description", got.Description)
@@ -134,19 +386,88 @@ func TestBookRepository_CRUD(t *testing.T) {
 
 	//  Update книги
 	book.Title = "Updated Title"
-	require.NoError(t, repo.Update(book))
+	require.NoError(t, repo.Update(context.Background(), book))
 
-	got, err = repo.GetByID(book.ID)
+	got, err = repo.GetByID(context.Background(), book.ID)
 	require.NoError(t, err)
 	require.Equal(t, "Updated Title", got.Title)
 
 	//  Delete книги
-	require.NoError(t, repo.Delete(book.ID))
+	require.NoError(t, repo.Delete(context.Background(), book.ID))
 
-	_, err = repo.GetByID(book.ID)
+	_, err = repo.GetByID(context.Background(), book.ID)
 	require.ErrorIs(t, err, dto.ErrorBookNotFound)
 }
 
+func TestBookRepository_Update_VersionConflict(t *testing.T) {
+	db := setupTestDB(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := repository.NewBookRepository(db, log)
+	ctx := context.Background()
+
+	user := &models.User{Name: "Owner", Email: "versioned-owner@example.com", PasswordHash: "hash"}
+	require.NoError(t, db.Create(user).Error)
+	book := &models.Book{Title: "Versioned", Author: "Author", Status: "available", UserID: user.ID}
+	require.NoError(t, repo.Create(ctx, book))
+	require.Equal(t, uint(1), book.Version)
+
+	// два клиента прочитали одну и ту же версию
+	first, err := repo.GetByID(ctx, book.ID)
+	require.NoError(t, err)
+	second, err := repo.GetByID(ctx, book.ID)
+	require.NoError(t, err)
+
+	first.Description = "first"
+	// связанные строки не перезаписываются вместе с книгой
+	first.User.Name = "changed through book"
+	require.NoError(t, repo.Update(ctx, first))
+	require.Equal(t, uint(2), first.Version)
+
+	second.Description = "second"
+	require.ErrorIs(t, repo.Update(ctx, second), dto.ErrVersionConflict)
+	require.Equal(t, uint(1), second.Version)
+
+	got, err := repo.GetByID(ctx, book.ID)
+	require.NoError(t, err)
+	require.Equal(t, "first", got.Description)
+	require.Equal(t, uint(2), got.Version)
+	require.Equal(t, "Owner", got.User.Name)
+}
+
+func TestBookRepository_Search_SQLiteDriver(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// та же база, что в режиме DB_DRIVER=sqlite: файл, WAL и Unicode-версия lower()
+	cfg := config.Default().Database
+	cfg.Driver = config.DriverSQLite
+	cfg.Path = filepath.Join(t.TempDir(), "bookcrossing.db")
+	db := config.Connect(cfg, log)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Genre{}, &models.Book{}, &models.BookRating{}))
+
+	user := &models.User{Name: "Анна", Email: "anna@example.com", PasswordHash: "hash", City: "Москва"}
+	require.NoError(t, db.Create(user).Error)
+
+	repo := repository.NewBookRepository(db, log)
+	require.NoError(t, repo.Create(context.Background(), &models.Book{Title: "Мастер и Маргарита", Author: "Булгаков", Status: "available", UserID: user.ID}))
+	require.NoError(t, repo.Create(context.Background(), &models.Book{Title: "Dune", Author: "Herbert", Status: "available", UserID: user.ID}))
+
+	// ILIKE в SQLite нет — поиск должен остаться регистронезависимым и для кириллицы
+	page, err := repo.Search(context.Background(), dto.BookListQuery{City: "МОСК", Title: "мастер", Page: 1, Limit: 10})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, *page.Total)
+	require.Len(t, page.Books, 1)
+	require.Equal(t, "Булгаков", page.Books[0].Author)
+
+	page, err = repo.Search(context.Background(), dto.BookListQuery{Author: "HERB", Page: 1, Limit: 10})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, *page.Total)
+	require.Equal(t, "Dune", page.Books[0].Title)
+
+	available, err := repo.GetAvailable(context.Background(), "москва")
+	require.NoError(t, err)
+	require.Len(t, available, 2)
+}
+
 // *********************************************************************************
 // *						  Тесты для review									   *
 // *								  |											   *
@@ -168,39 +489,254 @@ func TestReviewRepository_CRUD(t *testing.T) {
 	book := &models.Book{Title: "Book 1", Author: "Author1", Status: "available", UserID: targetUser.ID}
 	require.NoError(t, db.Create(book).Error)
 
+	exchange := &models.Exchange{InitiatorID: author.ID, RecipientID: targetUser.ID, RecipientBookID: book.ID, Status: "completed"}
+	require.NoError(t, db.Create(exchange).Error)
+
 	//  Create Review
 	review := &models.Review{
 		AuthorID:     author.ID,
 		TargetUserID: targetUser.ID,
 		TargetBookID: book.ID,
+		ExchangeID:   &exchange.ID,
 		Text:         "Great book!",
 		Rating:       5,
 	}
-	require.NoError(t, repo.Create(review))
+	require.NoError(t, repo.Create(context.Background(), review))
 	require.NotZero(t, review.ID)
 
+	//  ExistsForExchange
+	exists, err := repo.ExistsForExchange(context.Background(), exchange.ID, author.ID)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	// второй отзыв по тому же обмену не пропустит уникальный индекс
+	duplicate := &models.Review{AuthorID: author.ID, TargetUserID: targetUser.ID, TargetBookID: book.ID, ExchangeID: &exchange.ID, Text: "Once again", Rating: 4}
+	require.Error(t, repo.Create(context.Background(), duplicate))
+
 	//  GetByID
-	got, err := repo.GetByID(review.ID)
+	got, err := repo.GetByID(context.Background(), review.ID)
 	require.NoError(t, err)
 	require.Equal(t, review.Text, got.Text)
 	require.Equal(t, review.Rating, got.Rating)
 
 	//  GetByTargetUserID
-	reviewsByUser, err := repo.GetByTargetUserID(targetUser.ID)
+	reviewsByUser, total, err := repo.GetByTargetUserID(context.Background(), targetUser.ID, dto.ReviewListQuery{Page: 1, Limit: 10})
 	require.NoError(t, err)
+	require.Equal(t, int64(1), total)
 	require.Len(t, reviewsByUser, 1)
 	require.Equal(t, review.ID, reviewsByUser[0].ID)
 
 	//  GetByTargetBookID
-	reviewsByBook, err := repo.GetByTargetBookID(book.ID)
+	reviewsByBook, err := repo.GetByTargetBookID(context.Background(), book.ID)
 	require.NoError(t, err)
 	require.Len(t, reviewsByBook, 1)
 	require.Equal(t, review.ID, reviewsByBook[0].ID)
 
 	//  Delete
-	require.NoError(t, repo.Delete(review.ID))
-	_, err = repo.GetByID(review.ID)
+	require.NoError(t, repo.Delete(context.Background(), review.ID))
+	_, err = repo.GetByID(context.Background(), review.ID)
 	require.ErrorIs(t, err, dto.ErrReviewNotFound)
+
+	exists, err = repo.ExistsForExchange(context.Background(), exchange.ID, author.ID)
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestReviewRepository_EditHistoryAndReply(t *testing.T) {
+	db := setupTestDB(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := repository.NewReviewRepository(db, log)
+	ctx := context.Background()
+
+	author := &models.User{Name: "Editor", Email: "edit-author@example.com", PasswordHash: "hash"}
+	target := &models.User{Name: "Replier", Email: "edit-target@example.com", PasswordHash: "hash"}
+	require.NoError(t, db.Create(author).Error)
+	require.NoError(t, db.Create(target).Error)
+
+	first := &models.Review{AuthorID: author.ID, TargetUserID: target.ID, Text: "first review text", Rating: 2}
+	second := &models.Review{AuthorID: author.ID, TargetUserID: target.ID, Text: "second review text", Rating: 4}
+	require.NoError(t, repo.Create(ctx, first))
+	require.NoError(t, repo.Create(ctx, second))
+
+	// правка: старая редакция уходит в историю, агрегаты пересчитываются
+	previous := *first
+	editedAt := time.Now()
+	first.Text, first.Rating, first.EditedAt = "edited review text", 5, &editedAt
+	require.NoError(t, repo.Update(ctx, first, previous))
+
+	revisions, err := repo.GetRevisions(ctx, first.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	require.Equal(t, "first review text", revisions[0].Text)
+	require.Equal(t, 2, revisions[0].Rating)
+	require.Equal(t, "approved", revisions[0].ModerationStatus)
+
+	var rating models.UserRating
+	require.NoError(t, db.First(&rating, "user_id = ?", target.ID).Error)
+	require.InDelta(t, 4.5, rating.RatingAvg, 0.001)
+	require.Zero(t, rating.Stars2)
+
+	// правка по устаревшей редакции не проходит
+	stale := *first
+	stale.Text = "stale review text"
+	require.ErrorIs(t, repo.Update(ctx, &stale, previous), dto.ErrVersionConflict)
+
+	// ответ встраивается в отзыв
+	require.NoError(t, repo.CreateReply(ctx, &models.ReviewReply{ReviewID: second.ID, AuthorID: target.ID, Text: "thanks"}))
+	require.Error(t, repo.CreateReply(ctx, &models.ReviewReply{ReviewID: second.ID, AuthorID: target.ID, Text: "again"}))
+
+	list, total, err := repo.GetByTargetUserID(ctx, target.ID, dto.ReviewListQuery{Page: 1, Limit: 1, SortBy: "rating", SortOrder: "asc"})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), total)
+	require.Len(t, list, 1)
+	require.Equal(t, second.ID, list[0].ID)
+	require.NotNil(t, list[0].Reply)
+	require.Equal(t, "thanks", list[0].Reply.Text)
+}
+
+func TestReviewRepository_RatingAggregates(t *testing.T) {
+	db := setupTestDB(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := repository.NewReviewRepository(db, log)
+	bookRepo := repository.NewBookRepository(db, log)
+	ctx := context.Background()
+
+	owner := &models.User{Name: "Owner", Email: "rating-owner@example.com", PasswordHash: "hash"}
+	require.NoError(t, db.Create(owner).Error)
+	good := &models.Book{Title: "Rated good", Author: "A", Status: "available", UserID: owner.ID}
+	poor := &models.Book{Title: "Rated poor", Author: "A", Status: "available", UserID: owner.ID}
+	require.NoError(t, db.Create(good).Error)
+	require.NoError(t, db.Create(poor).Error)
+
+	var reviews []*models.Review
+	for i, r := range []struct {
+		book   uint
+		rating int
+	}{{good.ID, 5}, {good.ID, 4}, {poor.ID, 2}} {
+		author := &models.User{Name: "Reader", Email: fmt.Sprintf("rating-reader-%d@example.com", i), PasswordHash: "hash"}
+		require.NoError(t, db.Create(author).Error)
+		review := &models.Review{AuthorID: author.ID, TargetUserID: owner.ID, TargetBookID: r.book, Text: "rated review", Rating: r.rating}
+		require.NoError(t, repo.Create(ctx, review))
+		reviews = append(reviews, review)
+	}
+
+	var userRating models.UserRating
+	require.NoError(t, db.First(&userRating, "user_id = ?", owner.ID).Error)
+	require.Equal(t, int64(3), userRating.ReviewsCount)
+	require.InDelta(t, 11.0/3, userRating.RatingAvg, 0.001)
+	require.Equal(t, int64(1), userRating.Stars2)
+	require.Equal(t, int64(1), userRating.Stars5)
+
+	got, err := bookRepo.GetByID(ctx, good.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.Rating)
+	require.Equal(t, int64(2), got.Rating.ReviewsCount)
+	require.InDelta(t, 4.5, got.Rating.RatingAvg, 0.001)
+
+	// сортировка по рейтингу
+	page, err := bookRepo.Search(ctx, dto.BookListQuery{Title: "Rated", Page: 1, Limit: 10, SortBy: "rating", SortOrder: "desc"})
+	require.NoError(t, err)
+	require.Len(t, page.Books, 2)
+	require.Equal(t, good.ID, page.Books[0].ID)
+
+	// удаление отзыва вычитает оценку
+	require.NoError(t, repo.Delete(ctx, reviews[0].ID))
+	require.NoError(t, db.First(&userRating, "user_id = ?", owner.ID).Error)
+	require.Equal(t, int64(2), userRating.ReviewsCount)
+	require.InDelta(t, 3.0, userRating.RatingAvg, 0.001)
+	require.Zero(t, userRating.Stars5)
+
+	// пересчёт с нуля даёт те же значения
+	require.NoError(t, repo.RebuildRatings(ctx))
+	var rebuilt models.UserRating
+	require.NoError(t, db.First(&rebuilt, "user_id = ?", owner.ID).Error)
+	require.Equal(t, userRating.RatingSummary, rebuilt.RatingSummary)
+
+	// разовое заполнение при запуске: выполняется, пока не отмечено успешным
+	require.NoError(t, db.Exec("DELETE FROM user_ratings").Error)
+	require.NoError(t, repository.BackfillRatings(ctx, db))
+	rebuilt = models.UserRating{}
+	require.NoError(t, db.First(&rebuilt, "user_id = ?", owner.ID).Error)
+	require.Equal(t, userRating.RatingSummary, rebuilt.RatingSummary)
+
+	require.NoError(t, db.Exec("DELETE FROM user_ratings WHERE user_id = ?", owner.ID).Error)
+	require.NoError(t, repository.BackfillRatings(ctx, db))
+	require.ErrorIs(t, db.First(&models.UserRating{}, "user_id = ?", owner.ID).Error, gorm.ErrRecordNotFound)
+}
+
+func TestModerationRepository_StatusAndReports(t *testing.T) {
+	db := setupTestDB(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := repository.NewModerationRepository(db, log)
+	reviewRepo := repository.NewReviewRepository(db, log)
+	ctx := context.Background()
+
+	owner := &models.User{Name: "Owner", Email: "moderation-owner@example.com", PasswordHash: "hash"}
+	author := &models.User{Name: "Author", Email: "moderation-author@example.com", PasswordHash: "hash"}
+	require.NoError(t, db.Create(owner).Error)
+	require.NoError(t, db.Create(author).Error)
+	book := &models.Book{Title: "Moderated", Author: "A", Status: "available", UserID: owner.ID}
+	require.NoError(t, db.Create(book).Error)
+
+	review := &models.Review{AuthorID: author.ID, TargetUserID: owner.ID, TargetBookID: book.ID, Text: "moderated review", Rating: 4}
+	require.NoError(t, reviewRepo.Create(ctx, review))
+	require.Equal(t, "approved", review.ModerationStatus)
+
+	rating := func() int64 {
+		var r models.UserRating
+		require.NoError(t, db.First(&r, "user_id = ?", owner.ID).Error)
+		return r.ReviewsCount
+	}
+	require.Equal(t, int64(1), rating())
+
+	// скрытый отзыв выпадает из рейтинга и из списков
+	require.NoError(t, repo.SetReviewStatus(ctx, review.ID, "approved", "held"))
+	require.Zero(t, rating())
+	list, total, err := reviewRepo.GetByTargetUserID(ctx, owner.ID, dto.ReviewListQuery{Page: 1, Limit: 10})
+	require.NoError(t, err)
+	require.Zero(t, total)
+	require.Empty(t, list)
+
+	// устаревший from — конфликт, рейтинг не трогаем
+	require.ErrorIs(t, repo.SetReviewStatus(ctx, review.ID, "approved", "rejected"), dto.ErrVersionConflict)
+	require.Zero(t, rating())
+
+	// жалобы: повторная от того же пользователя отклоняется, системная не считается
+	report := &models.ContentReport{ContentType: models.ContentReview, ContentID: review.ID, ReporterID: owner.ID, Reason: "spam"}
+	require.NoError(t, repo.CreateReport(ctx, report))
+	require.ErrorIs(t, repo.CreateReport(ctx, &models.ContentReport{
+		ContentType: models.ContentReview, ContentID: review.ID, ReporterID: owner.ID, Reason: "again",
+	}), dto.ErrReportAlreadyExists)
+	require.NoError(t, repo.SaveSystemReport(ctx, models.ContentReview, review.ID, "auto: email"))
+	count, err := repo.CountOpenUserReports(ctx, models.ContentReview, review.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	queue, err := repo.QueueReviews(ctx, 100)
+	require.NoError(t, err)
+	found := false
+	for _, r := range queue {
+		found = found || r.ID == review.ID
+	}
+	require.True(t, found)
+
+	// одобрение возвращает оценку в рейтинг
+	require.NoError(t, repo.SetReviewStatus(ctx, review.ID, "held", "approved"))
+	require.NoError(t, repo.ResolveReports(ctx, models.ContentReview, review.ID))
+	require.Equal(t, int64(1), rating())
+	count, err = repo.CountOpenUserReports(ctx, models.ContentReview, review.ID)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	// решение по книге меняет версию: правка владельца по старой версии не вернёт статус
+	stale := *book
+	require.NoError(t, repo.SetBookStatus(ctx, book.ID, "approved", "held"))
+	stale.Description = "new description"
+	require.ErrorIs(t, repository.NewBookRepository(db, log).Update(ctx, &stale), dto.ErrVersionConflict)
+	var gotBook models.Book
+	require.NoError(t, db.First(&gotBook, book.ID).Error)
+	require.Equal(t, "held", gotBook.ModerationStatus)
+	require.Equal(t, book.Version+1, gotBook.Version)
 }
 
 // *********************************************************************************
@@ -216,28 +752,334 @@ func TestGenreRepository_CRUD(t *testing.T) {
 
 	// Create
 	genre := &models.Genre{Name: "classic"}
-	require.NoError(t, repo.Create(genre))
+	require.NoError(t, repo.Create(context.Background(), genre))
 	require.NotZero(t, genre.ID)
 
 	// GetByID
-	got, err := repo.GetByID(genre.ID)
+	got, err := repo.GetByID(context.Background(), genre.ID)
 	require.NoError(t, err)
 	require.Equal(t, genre.Name, got.Name)
 
 	// GetByName
-	gotByName, err := repo.GetByName("classic")
+	gotByName, err := repo.GetByName(context.Background(), "classic")
 	require.NoError(t, err)
 	require.Equal(t, genre.ID, gotByName.ID)
 
 	// Delete
-	require.NoError(t, repo.Delete(genre.ID))
+	require.NoError(t, repo.Delete(context.Background(), genre.ID))
 
 	// After delete → not found
-	_, err = repo.GetByID(genre.ID)
+	_, err = repo.GetByID(context.Background(), genre.ID)
 	require.Error(t, err)
 }
 
 
+func TestGenreRepository_HierarchyAndMerge(t *testing.T) {
+	db := setupTestDB(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := repository.NewGenreRepository(db, log)
+	bookRepo := repository.NewBookRepository(db, log)
+	ctx := context.Background()
+
+	fiction := &models.Genre{Name: "Художественная литература", NameEn: "Hierarchy Fiction"}
+	require.NoError(t, repo.Create(ctx, fiction))
+	require.Equal(t, "hierarchy-fiction", fiction.Slug)
+	scifi := &models.Genre{Name: "Фантастика", ParentID: &fiction.ID}
+	require.NoError(t, repo.Create(ctx, scifi))
+	require.Equal(t, "fantastika", scifi.Slug)
+	cyberpunk := &models.Genre{Name: "Киберпанк", ParentID: &scifi.ID}
+	require.NoError(t, repo.Create(ctx, cyberpunk))
+
+	// одноимённый соседний жанр — конфликт, slug занятого имени получает суффикс
+	require.ErrorIs(t, repo.Create(ctx, &models.Genre{Name: "Фантастика", ParentID: &fiction.ID}), dto.ErrConflict)
+	other := &models.Genre{Name: "Фантастика"}
+	require.NoError(t, repo.Create(ctx, other))
+	require.Equal(t, "fantastika-2", other.Slug)
+	require.ErrorIs(t, repo.Create(ctx, &models.Genre{Name: "Другой", Slug: "fantastika"}), dto.ErrConflict)
+	missing := uint(999999)
+	require.ErrorIs(t, repo.Create(ctx, &models.Genre{Name: "Сирота", ParentID: &missing}), dto.ErrGenreParentNotFound)
+
+	got, err := repo.GetBySlug(ctx, "fantastika")
+	require.NoError(t, err)
+	require.Equal(t, scifi.ID, got.ID)
+	require.ErrorIs(t, repo.Delete(ctx, scifi.ID), dto.ErrGenreHasChildren)
+
+	owner := &models.User{Name: "Owner", Email: "genre-owner@example.com", PasswordHash: "hash"}
+	require.NoError(t, db.Create(owner).Error)
+	newBook := func(title string, genres ...models.Genre) *models.Book {
+		b := &models.Book{Title: title, Author: "A", Status: "available", UserID: owner.ID, Genres: genres}
+		require.NoError(t, db.Create(b).Error)
+		return b
+	}
+	deep := newBook("Genre tree deep", *cyberpunk)
+	dup := newBook("Genre tree dup", *other)
+	both := newBook("Genre tree both", *scifi, *other)
+
+	// фильтр по корню находит книги всех поджанров
+	page, err := bookRepo.Search(ctx, dto.BookListQuery{GenreID: &fiction.ID, Title: "Genre tree", Page: 1, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), *page.Total)
+	require.Len(t, page.Books, 2)
+
+	// цель внутри поддерева дубликата — цикл
+	_, err = repo.Merge(ctx, cyberpunk.ID, []uint{scifi.ID})
+	require.ErrorIs(t, err, dto.ErrGenreMergeInvalid)
+
+	moved, err := repo.Merge(ctx, scifi.ID, []uint{other.ID})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), moved)
+	_, err = repo.GetByID(ctx, other.ID)
+	require.ErrorIs(t, err, dto.ErrNotFound)
+
+	for _, b := range []*models.Book{dup, both} {
+		var ids []uint
+		require.NoError(t, db.Table("book_genres").Where("book_id = ?", b.ID).Pluck("genre_id", &ids).Error)
+		require.Equal(t, []uint{scifi.ID}, ids)
+	}
+	page, err = bookRepo.Search(ctx, dto.BookListQuery{GenreID: &fiction.ID, Title: "Genre tree", Page: 1, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), *page.Total)
+	require.NotZero(t, deep.ID)
+
+	// slug удалённого дубликата снова свободен
+	again := &models.Genre{Name: "Фантастика", NameEn: "fantastika 2"}
+	require.NoError(t, repo.Create(ctx, again))
+	require.Equal(t, "fantastika-2", again.Slug)
+}
+
+func TestBookRepository_Facets(t *testing.T) {
+	db := setupTestDB(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := repository.NewBookRepository(db, log)
+	ctx := context.Background()
+
+	fantasy := &models.Genre{Name: "Facet Фэнтези"}
+	detective := &models.Genre{Name: "Facet Детектив"}
+	require.NoError(t, db.Create(fantasy).Error)
+	require.NoError(t, db.Create(detective).Error)
+	kazan := &models.User{Name: "K", Email: "facet-kazan@example.com", PasswordHash: "hash", City: "Казань"}
+	perm := &models.User{Name: "P", Email: "facet-perm@example.com", PasswordHash: "hash", City: "Пермь"}
+	require.NoError(t, db.Create(kazan).Error)
+	require.NoError(t, db.Create(perm).Error)
+
+	for _, b := range []models.Book{
+		{Title: "Facet one", Author: "Толкин", Status: "available", UserID: kazan.ID, Genres: []models.Genre{*fantasy}},
+		{Title: "Facet two", Author: "Толкин", Status: "reserved", UserID: kazan.ID, Genres: []models.Genre{*fantasy, *detective}},
+		{Title: "Facet three", Author: "Кристи", Status: "available", UserID: perm.ID, Genres: []models.Genre{*detective}},
+	} {
+		require.NoError(t, db.Create(&b).Error)
+	}
+
+	facets, err := repo.Facets(ctx, dto.BookListQuery{Title: "Facet"},
+		[]string{dto.FacetGenre, dto.FacetCity, dto.FacetStatus, dto.FacetAuthor})
+	require.NoError(t, err)
+	require.Len(t, facets[dto.FacetGenre], 2)
+	require.Equal(t, int64(2), facets[dto.FacetGenre][0].Count)
+	require.Equal(t, []dto.FacetValue{{Value: "Казань", Count: 2}, {Value: "Пермь", Count: 1}}, facets[dto.FacetCity])
+	require.Equal(t, []dto.FacetValue{{Value: "available", Count: 2}, {Value: "reserved", Count: 1}}, facets[dto.FacetStatus])
+	require.Equal(t, []dto.FacetValue{{Value: "Толкин", Count: 2}, {Value: "Кристи", Count: 1}}, facets[dto.FacetAuthor])
+
+	// фильтры поиска действуют и на фасеты
+	facets, err = repo.Facets(ctx, dto.BookListQuery{Title: "Facet", Status: "available"}, []string{dto.FacetGenre})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []dto.FacetValue{
+		{Value: strconv.FormatUint(uint64(fantasy.ID), 10), Label: fantasy.Name, Count: 1},
+		{Value: strconv.FormatUint(uint64(detective.ID), 10), Label: detective.Name, Count: 1},
+	}, facets[dto.FacetGenre])
+}
+
+func TestBookRepository_PrivateCityIsNotSearchable(t *testing.T) {
+	db := setupTestDB(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := repository.NewBookRepository(db, log)
+	ctx := context.Background()
+
+	tver := geo.Point{Lat: 56.8587, Lon: 35.9176}
+	open := &models.User{Name: "O", Email: "city-open@example.com", PasswordHash: "hash", City: "Тверь", Lat: &tver.Lat, Lon: &tver.Lon}
+	hidden := &models.User{Name: "H", Email: "city-hidden@example.com", PasswordHash: "hash", City: "Тверь",
+		Lat: &tver.Lat, Lon: &tver.Lon, CityVisibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(open).Error)
+	require.NoError(t, db.Create(hidden).Error)
+	visible := &models.Book{Title: "Private city book", Author: "A", Status: "available", UserID: open.ID}
+	require.NoError(t, db.Create(visible).Error)
+	require.NoError(t, db.Create(&models.Book{Title: "Private city book", Author: "A", Status: "available", UserID: hidden.ID}).Error)
+	// своя точка выдачи не зависит от города владельца
+	pickup := &models.Book{Title: "Private city book", Author: "A", Status: "available", UserID: hidden.ID, Lat: &tver.Lat, Lon: &tver.Lon}
+	require.NoError(t, db.Create(pickup).Error)
+
+	page, err := repo.Search(ctx, dto.BookListQuery{Title: "Private city", City: "Тверь", Page: 1, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Books, 1)
+	require.Equal(t, visible.ID, page.Books[0].ID)
+
+	facets, err := repo.Facets(ctx, dto.BookListQuery{Title: "Private city"}, []string{dto.FacetCity})
+	require.NoError(t, err)
+	require.Equal(t, []dto.FacetValue{{Value: "Тверь", Count: 1}}, facets[dto.FacetCity])
+
+	page, err = repo.Search(ctx, dto.BookListQuery{Title: "Private city", Origin: &tver, RadiusKm: 10, SortBy: "distance", Page: 1, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Books, 2)
+	require.ElementsMatch(t, []uint{visible.ID, pickup.ID}, []uint{page.Books[0].ID, page.Books[1].ID})
+
+	books, err := repo.GetAvailable(ctx, "Тверь")
+	require.NoError(t, err)
+	require.Len(t, books, 1)
+	require.Equal(t, visible.ID, books[0].ID)
+}
+
+func TestBookRepository_Search_Cursor(t *testing.T) {
+	db := setupTestDB(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := repository.NewBookRepository(db, log)
+	ctx := context.Background()
+	require.NoError(t, repository.EnsureBookSearchIndexes(ctx, db))
+
+	owner := &models.User{Name: "Owner", Email: "cursor-owner@example.com", PasswordHash: "hash"}
+	require.NoError(t, db.Create(owner).Error)
+	// одинаковые названия, даты и рейтинги — порядок внутри них задаёт id
+	created := time.Now().Add(-time.Hour)
+	for i := 0; i < 7; i++ {
+		b := &models.Book{Title: fmt.Sprintf("Cursor %d", i%3), Author: "A", Status: "available", UserID: owner.ID}
+		b.CreatedAt = created.Add(time.Duration(i/2) * time.Minute)
+		require.NoError(t, db.Create(b).Error)
+		if i%2 == 0 {
+			require.NoError(t, db.Create(&models.BookRating{BookID: b.ID, RatingSummary: models.RatingSummary{
+				ReviewsCount: int64(i % 3), RatingAvg: float64(i%3) + 0.5,
+			}}).Error)
+		}
+	}
+
+	for _, sortBy := range []string{"created_at", "title", "rating", "reviews"} {
+		for _, order := range []string{"asc", "desc"} {
+			query := dto.BookListQuery{Title: "Cursor", SortBy: sortBy, SortOrder: order, Page: 1, Limit: 100}
+			all, err := repo.Search(ctx, query)
+			require.NoError(t, err)
+			require.Len(t, all.Books, 7)
+			require.Empty(t, all.NextCursor)
+
+			var walked []uint
+			query.Limit, query.Count = 3, dto.CountNone
+			for pages := 0; ; pages++ {
+				require.Less(t, pages, 5, "%s %s: cursor does not advance", sortBy, order)
+				page, err := repo.Search(ctx, query)
+				require.NoError(t, err)
+				require.Nil(t, page.Total)
+				for _, b := range page.Books {
+					walked = append(walked, b.ID)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+
+			var want []uint
+			for _, b := range all.Books {
+				want = append(want, b.ID)
+			}
+			require.Equal(t, want, walked, "%s %s", sortBy, order)
+		}
+	}
+
+	// курсор другой сортировки или испорченный токен отклоняются
+	first, err := repo.Search(ctx, dto.BookListQuery{Title: "Cursor", SortBy: "title", SortOrder: "asc", Page: 1, Limit: 2})
+	require.NoError(t, err)
+	_, err = repo.Search(ctx, dto.BookListQuery{Title: "Cursor", SortBy: "title", SortOrder: "desc", Limit: 2, Cursor: first.NextCursor})
+	require.ErrorIs(t, err, dto.ErrInvalidCursor)
+	_, err = repo.Search(ctx, dto.BookListQuery{Title: "Cursor", Limit: 2, Cursor: "not-a-cursor"})
+	require.ErrorIs(t, err, dto.ErrInvalidCursor)
+
+	// оценка в SQLite считается точно
+	estimated, err := repo.Search(ctx, dto.BookListQuery{Title: "Cursor", Page: 1, Limit: 2, Count: dto.CountEstimate})
+	require.NoError(t, err)
+	require.EqualValues(t, 7, *estimated.Total)
+	require.False(t, estimated.TotalEstimated)
+}
+
+func TestBookRepository_Search_Near(t *testing.T) {
+	db := setupTestDB(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := repository.NewBookRepository(db, log)
+	ctx := context.Background()
+
+	moscow := geo.Point{Lat: 55.7558, Lon: 37.6173}
+	khimki := geo.Point{Lat: 55.8970, Lon: 37.4297}
+	podolsk := geo.Point{Lat: 55.4311, Lon: 37.5445}
+	spb := geo.Point{Lat: 59.9386, Lon: 30.3141}
+
+	newOwner := func(email string, p *geo.Point) *models.User {
+		u := &models.User{Name: "Near", Email: email, PasswordHash: "hash"}
+		if p != nil {
+			u.Lat, u.Lon = &p.Lat, &p.Lon
+		}
+		require.NoError(t, db.Create(u).Error)
+		return u
+	}
+	newBook := func(owner *models.User, p *geo.Point) *models.Book {
+		b := &models.Book{Title: "Nearby book", Author: "A", Status: "available", UserID: owner.ID}
+		if p != nil {
+			b.Lat, b.Lon = &p.Lat, &p.Lon
+		}
+		require.NoError(t, db.Create(b).Error)
+		return b
+	}
+
+	inMoscow := newOwner("near-moscow@example.com", &moscow)
+	inKhimki := newOwner("near-khimki@example.com", &khimki)
+	inSpb := newOwner("near-spb@example.com", &spb)
+	unknown := newOwner("near-unknown@example.com", nil)
+
+	m1, m2 := newBook(inMoscow, nil), newBook(inMoscow, nil)
+	k := newBook(inKhimki, nil)
+	newBook(inSpb, nil)
+	// своя точка выдачи важнее города владельца
+	p := newBook(inSpb, &podolsk)
+	newBook(unknown, nil)
+
+	query := dto.BookListQuery{Title: "Nearby", Origin: &moscow, RadiusKm: 25, SortBy: "distance", SortOrder: "asc", Page: 1, Limit: 10}
+	page, err := repo.Search(ctx, query)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, *page.Total)
+	var ids []uint
+	for _, b := range page.Books {
+		ids = append(ids, b.ID)
+	}
+	// одинаковое расстояние — по id
+	require.Equal(t, []uint{m1.ID, m2.ID, k.ID}, ids)
+	require.InDelta(t, 0, page.Distances[m1.ID], 0.01)
+	require.InEpsilon(t, geo.Distance(moscow, khimki), page.Distances[k.ID], 0.01)
+
+	query.RadiusKm = 50
+	page, err = repo.Search(ctx, query)
+	require.NoError(t, err)
+	require.Len(t, page.Books, 4)
+	require.Equal(t, p.ID, page.Books[3].ID)
+	require.InEpsilon(t, geo.Distance(moscow, podolsk), page.Distances[p.ID], 0.01)
+
+	// курсор по расстоянию проходит те же книги в том же порядке
+	var walked []uint
+	query.Limit, query.Count = 1, dto.CountNone
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 6, "cursor does not advance")
+		next, err := repo.Search(ctx, query)
+		require.NoError(t, err)
+		for _, b := range next.Books {
+			walked = append(walked, b.ID)
+		}
+		if next.NextCursor == "" {
+			break
+		}
+		query.Cursor = next.NextCursor
+	}
+	require.Equal(t, []uint{m1.ID, m2.ID, k.ID, p.ID}, walked)
+
+	// курсор от другой точки не подходит
+	first, err := repo.Search(ctx, dto.BookListQuery{Title: "Nearby", Origin: &moscow, RadiusKm: 50, SortBy: "distance", SortOrder: "asc", Limit: 1})
+	require.NoError(t, err)
+	_, err = repo.Search(ctx, dto.BookListQuery{Title: "Nearby", Origin: &khimki, RadiusKm: 50, SortBy: "distance", SortOrder: "asc", Limit: 1, Cursor: first.NextCursor})
+	require.ErrorIs(t, err, dto.ErrInvalidCursor)
+}
+
 // *********************************************************************************
 // *						  Тесты для exchange								   *
 // *								  |											   *
@@ -269,7 +1111,7 @@ func TestExchangeRepository_CRUD(t *testing.T) {
 		RecipientBookID: book2.ID,
 		Status:          "pending",
 	}
-	require.NoError(t, repo.CreateExchange(exchange))
+	require.NoError(t, repo.CreateExchange(context.Background(), exchange))
 	require.NotZero(t, exchange.ID)
 
 	// Проверяем статус книг после создания обмена
@@ -280,13 +1122,13 @@ func TestExchangeRepository_CRUD(t *testing.T) {
 	require.Equal(t, "reserved", b2.Status)
 
 	//  GetByID
-	got, err := repo.GetByID(exchange.ID)
+	got, err := repo.GetByID(context.Background(), exchange.ID)
 	require.NoError(t, err)
 	require.Equal(t, exchange.ID, got.ID)
 	require.Equal(t, exchange.Status, got.Status)
 
 	//  CompleteExchange
-	require.NoError(t, repo.CompleteExchange(got))
+	require.NoError(t, repo.CompleteExchange(context.Background(), got))
 
 	// Проверяем, что книги обновились
 	require.NoError(t, db.First(&b1, book1.ID).Error)
@@ -304,17 +1146,87 @@ func TestExchangeRepository_CRUD(t *testing.T) {
 		RecipientBookID: book2.ID,
 		Status:          "pending",
 	}
-	require.NoError(t, repo.CreateExchange(exchange2))
-	require.NoError(t, repo.CancelExchange(exchange2))
+	require.NoError(t, repo.CreateExchange(context.Background(), exchange2))
+	require.NoError(t, repo.CancelExchange(context.Background(), exchange2))
 
 	// Проверка, что статус отменён
-	got2, err := repo.GetByID(exchange2.ID)
+	got2, err := repo.GetByID(context.Background(), exchange2.ID)
 	require.NoError(t, err)
 	require.Equal(t, "cancelled", got2.Status)
 	require.Nil(t, got2.CompletedAt)
 
 	//  Delete Exchange
 	require.NoError(t, db.Delete(&models.Exchange{}, exchange.ID).Error)
-	_, err = repo.GetByID(exchange.ID)
+	_, err = repo.GetByID(context.Background(), exchange.ID)
 	require.Error(t, err)
-}
\ No newline at end of file
+}
+
+func TestTokenRepository_SingleUse(t *testing.T) {
+	db := setupTestDB(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := repository.NewTokenRepository(db, log)
+
+	token := &models.UserToken{
+		UserID:    1,
+		Purpose:   models.TokenPurposePasswordReset,
+		TokenHash: "hash-single-use",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	require.NoError(t, repo.Create(context.Background(), token))
+
+	got, err := repo.GetByHash(context.Background(), models.TokenPurposePasswordReset, "hash-single-use")
+	require.NoError(t, err)
+	require.Equal(t, token.ID, got.ID)
+
+	// второй раз использовать токен нельзя
+	require.NoError(t, repo.MarkUsed(context.Background(), token.ID))
+	require.ErrorIs(t, repo.MarkUsed(context.Background(), token.ID), dto.ErrTokenInvalid)
+
+	// токен с другим назначением не находится
+	_, err = repo.GetByHash(context.Background(), models.TokenPurposeVerifyEmail, "hash-single-use")
+	require.ErrorIs(t, err, dto.ErrTokenInvalid)
+}
+
+func TestGormMetricsPlugin_RecordsQueries(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.Use(metrics.GormPlugin{}))
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := repository.NewGenreRepository(db, log)
+
+	before := testutil.CollectAndCount(metrics.DBQueryDuration)
+	require.NoError(t, repo.Create(context.Background(), &models.Genre{Name: "metrics-genre"}))
+	_, err := repo.List(context.Background())
+	require.NoError(t, err)
+
+	// появились серии для insert и select по таблице genres
+	require.Greater(t, testutil.CollectAndCount(metrics.DBQueryDuration), before)
+}
+
+func TestGormTracingPlugin_SpansBelongToRequest(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	db := setupTestDB(t)
+	require.NoError(t, db.Use(tracing.NewPlugin(tracing.WithTracerProvider(provider), tracing.WithoutMetrics())))
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := repository.NewGenreRepository(db, log)
+
+	// ctx запроса с активным спаном — так его передаёт otelgin
+	ctx, span := provider.Tracer("test").Start(context.Background(), "GET /genres")
+	_, err := repo.List(ctx)
+	require.NoError(t, err)
+	span.End()
+
+	var queries int
+	for _, s := range recorder.Ended() {
+		if s.Name() == "GET /genres" {
+			continue
+		}
+		queries++
+		require.Equal(t, span.SpanContext().TraceID(), s.SpanContext().TraceID())
+		require.Equal(t, span.SpanContext().SpanID(), s.Parent().SpanID())
+	}
+	require.Positive(t, queries)
+}
@@ -1,17 +1,31 @@
 package test
 
 import (
+	"context"
 	"io"
 	"log/slog"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/dasler-fw/bookcrossing/internal/config"
 	"github.com/dasler-fw/bookcrossing/internal/dto"
+	"github.com/dasler-fw/bookcrossing/internal/etag"
+	"github.com/dasler-fw/bookcrossing/internal/events"
+	"github.com/dasler-fw/bookcrossing/internal/geo"
+	"github.com/dasler-fw/bookcrossing/internal/jwtutil"
+	"github.com/dasler-fw/bookcrossing/internal/mail"
 	"github.com/dasler-fw/bookcrossing/internal/models"
+	"github.com/dasler-fw/bookcrossing/internal/moderation"
+	"github.com/dasler-fw/bookcrossing/internal/ratelimit"
+	"github.com/dasler-fw/bookcrossing/internal/repository"
 	"github.com/dasler-fw/bookcrossing/internal/services"
+	"github.com/dasler-fw/bookcrossing/internal/slug"
 	"github.com/dasler-fw/bookcrossing/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -27,7 +41,7 @@ func TestUserService_GetUserByID_OK(t *testing.T) {
 	userRepo := new(mocks.UserRepositoryMock)
 	bookRepo := new(mocks.BookRepositoryMock)
 
-	svc := services.NewServiceUser(nil, userRepo, bookRepo, log)
+	svc := services.NewServiceUser(nil, userRepo, bookRepo, nil, nil, nil, nil, nil, "http://localhost:8080", 0, log)
 
 	user := &models.User{
 		ID:           1,
@@ -42,7 +56,7 @@ func TestUserService_GetUserByID_OK(t *testing.T) {
 		On("GetByID", uint(1)).
 		Return(user, nil)
 
-	got, err := svc.GetUserByID(1)
+	got, err := svc.GetUserByID(context.Background(), 1)
 
 	require.NoError(t, err)
 	require.Equal(t, user.ID, got.ID)
@@ -55,7 +69,7 @@ func TestUserService_UpdateUser_OK(t *testing.T) {
 	log := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	userRepo := new(mocks.UserRepositoryMock)
-	svc := services.NewServiceUser(nil, userRepo, nil, log)
+	svc := services.NewServiceUser(nil, userRepo, nil, nil, nil, nil, nil, nil, "http://localhost:8080", 0, log)
 
 	// Исходный пользователь
 	user := &models.User{
@@ -79,7 +93,7 @@ func TestUserService_UpdateUser_OK(t *testing.T) {
 	// Моки: Update возвращает nil (успех)
 	userRepo.On("Update", user).Return(nil)
 
-	got, err := svc.UpdateUser(1, req)
+	got, err := svc.UpdateUser(context.Background(), 1, req)
 
 	require.NoError(t, err)
 	require.Equal(t, "Alice Updated", got.Name)
@@ -87,30 +101,191 @@ func TestUserService_UpdateUser_OK(t *testing.T) {
 	userRepo.AssertExpectations(t)
 }
 
+func TestUserService_UpdateUser_Geocodes(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	userRepo := new(mocks.UserRepositoryMock)
+	svc := services.NewServiceUser(nil, userRepo, nil, nil, nil, nil, nil, geo.NewGazetteer(), "http://localhost:8080", 0, log)
+
+	user := &models.User{ID: 1, Name: "Alice", Email: "alice@example.com", City: "Москва"}
+	userRepo.On("GetByID", uint(1)).Return(user, nil)
+	userRepo.On("Update", mock.Anything).Return(nil)
+
+	// адрес скрыт правилами приватности и в координаты не попадает
+	address := "ул. Ленина, 5, Химки"
+	got, err := svc.UpdateUser(context.Background(), 1, dto.UserUpdateRequest{Address: &address})
+	require.NoError(t, err)
+	require.Nil(t, got.Lat)
+
+	// координаты берутся из города
+	city := "Химки"
+	got, err = svc.UpdateUser(context.Background(), 1, dto.UserUpdateRequest{City: &city})
+	require.NoError(t, err)
+	require.InDelta(t, 55.897, *got.Lat, 0.01)
+	require.InDelta(t, 37.4297, *got.Lon, 0.01)
+
+	userRepo.AssertExpectations(t)
+}
+
+func TestUserService_UpdateUser_Visibility(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	userRepo := new(mocks.UserRepositoryMock)
+	svc := services.NewServiceUser(nil, userRepo, nil, nil, nil, nil, nil, nil, "http://localhost:8080", 0, log)
+
+	user := &models.User{ID: 1, Name: "Alice", Email: "alice@example.com",
+		EmailVisibility: models.VisibilityPrivate, AddressVisibility: models.VisibilityPartners, CityVisibility: models.VisibilityPublic}
+	userRepo.On("GetByID", uint(1)).Return(user, nil)
+	userRepo.On("Update", user).Return(nil).Once()
+
+	// неизвестный уровень отклоняется до записи
+	bad := "friends"
+	_, err := svc.UpdateUser(context.Background(), 1, dto.UserUpdateRequest{
+		Visibility: &dto.UserVisibilityUpdate{Email: &bad},
+	})
+	require.ErrorIs(t, err, dto.ErrInvalidVisibility)
+
+	public, private := models.VisibilityPublic, models.VisibilityPrivate
+	got, err := svc.UpdateUser(context.Background(), 1, dto.UserUpdateRequest{
+		Visibility: &dto.UserVisibilityUpdate{Email: &public, City: &private},
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.VisibilityPublic, got.EmailVisibility)
+	require.Equal(t, models.VisibilityPartners, got.AddressVisibility)
+	require.Equal(t, models.VisibilityPrivate, got.CityVisibility)
+
+	userRepo.AssertExpectations(t)
+}
+
+func TestNewUserResponse_Visibility(t *testing.T) {
+	user := models.User{
+		ID: 7, Name: "Alice", Email: "alice@example.com", City: "Москва", Address: "ул. Ленина, 1", Language: "ru",
+		EmailVisibility: models.VisibilityPrivate, AddressVisibility: models.VisibilityPartners, CityVisibility: models.VisibilityPublic,
+	}
+
+	cases := []struct {
+		name                 string
+		rel                  services.Relation
+		email, address, city string
+	}{
+		{"stranger", services.RelationStranger, "", "", "Москва"},
+		{"partner", services.RelationPartner, "", "ул. Ленина, 1", "Москва"},
+		{"self", services.RelationSelf, "alice@example.com", "ул. Ленина, 1", "Москва"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := services.NewUserResponse(user, tc.rel)
+			require.Equal(t, tc.email, resp.Email)
+			require.Equal(t, tc.address, resp.Address)
+			require.Equal(t, tc.city, resp.City)
+			// настройки видимости и язык видит только владелец
+			require.Equal(t, tc.rel == services.RelationSelf, resp.Visibility != nil)
+			require.Equal(t, tc.rel == services.RelationSelf, resp.Language != "")
+		})
+	}
+
+	// пустой уровень (старые строки без значения) считается private
+	legacy := services.RedactUser(models.User{Email: "old@example.com", City: "Тула"}, services.RelationPartner)
+	require.Empty(t, legacy.Email)
+	require.Empty(t, legacy.City)
+}
+
 func TestUserService_Delete_OK(t *testing.T) {
 	log := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	userRepo := new(mocks.UserRepositoryMock)
 	bookRepo := new(mocks.BookRepositoryMock)
+	publisher := new(mocks.EventPublisherMock)
 
-	svc := services.NewServiceUser(nil, userRepo, bookRepo, log)
+	svc := services.NewServiceUser(nil, userRepo, bookRepo, nil, nil, publisher, nil, nil, "http://localhost:8080", 30*24*time.Hour, log)
 
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct-password"), bcrypt.MinCost)
+	require.NoError(t, err)
 	user := &models.User{
 		ID:           1,
 		Name:         "Alice",
 		Email:        "alice@example.com",
-		PasswordHash: "hashedpassword",
+		PasswordHash: string(hash),
 		City:         "Moscow",
 		Address:      "Lenina 1",
 	}
 
 	// мок на GetByID
 	userRepo.On("GetByID", uint(1)).Return(user, nil)
-	// мок на Delete
-	userRepo.On("Delete", uint(1)).Return(nil)
 
-	err := svc.DeleteUser(1)
+	// неверный пароль не удаляет аккаунт
+	_, err = svc.DeleteUser(context.Background(), 1, "wrong-password")
+	require.ErrorIs(t, err, dto.ErrInvalidCredentials)
+	userRepo.AssertNotCalled(t, "DeleteAccount", uint(1))
+
+	// мок на DeleteAccount: отменены два открытых обмена, в одном пользователь — получатель
+	userRepo.On("DeleteAccount", uint(1)).Return([]models.Exchange{
+		{InitiatorID: 1, RecipientID: 2, Status: "cancelled"},
+		{InitiatorID: 3, RecipientID: 1, Status: "cancelled"},
+	}, nil)
+	// событие получает только второй участник
+	for _, counterparty := range []uint{2, 3} {
+		publisher.On("Publish", mock.Anything, counterparty, events.TypeExchangeCancelled, mock.MatchedBy(func(p dto.ExchangeResponse) bool {
+			return p.Status == "cancelled"
+		})).Return(nil).Once()
+	}
+
+	resp, err := svc.DeleteUser(context.Background(), 1, "correct-password")
+	require.NoError(t, err)
+	require.Equal(t, 2, resp.CancelledExchanges)
+	require.WithinDuration(t, time.Now().Add(30*24*time.Hour), resp.PurgeAfter, time.Minute)
+
+	userRepo.AssertExpectations(t)
+	publisher.AssertExpectations(t)
+	publisher.AssertNotCalled(t, "Publish", mock.Anything, uint(1), mock.Anything, mock.Anything)
+}
+
+func TestUserService_GetProfile_ReputationCountsOwnCancellations(t *testing.T) {
+	db := setupTestDB(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := services.NewServiceUser(db, repository.NewUserRepository(db, log), repository.NewBookRepository(db, log),
+		nil, nil, nil, nil, nil, "http://localhost:8080", 0, log)
+	ctx := context.Background()
+
+	victim := &models.User{Name: "Victim", Email: "rep-victim@example.com", PasswordHash: "hash"}
+	other := &models.User{Name: "Other", Email: "rep-other@example.com", PasswordHash: "hash"}
+	require.NoError(t, db.Create(victim).Error)
+	require.NoError(t, db.Create(other).Error)
+
+	for _, e := range []models.Exchange{
+		{InitiatorID: victim.ID, RecipientID: other.ID, Status: "completed"},
+		{InitiatorID: victim.ID, RecipientID: other.ID, Status: "cancelled", CancelledBy: &victim.ID},
+		// чужие отмены и открытые обмены в репутацию не идут
+		{InitiatorID: other.ID, RecipientID: victim.ID, Status: "cancelled", CancelledBy: &other.ID},
+		{InitiatorID: other.ID, RecipientID: victim.ID, Status: "cancelled", CancelledBy: &other.ID},
+		{InitiatorID: other.ID, RecipientID: victim.ID, Status: "cancelled"},
+		{InitiatorID: other.ID, RecipientID: victim.ID, Status: "pending"},
+		{InitiatorID: other.ID, RecipientID: victim.ID, Status: "accepted"},
+	} {
+		require.NoError(t, db.Create(&e).Error)
+	}
+
+	profile, err := svc.GetProfile(ctx, 0, victim.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, profile.SuccessfulExchangesCount)
+	require.EqualValues(t, 2, profile.Reputation.ExchangesCount)
+	require.InDelta(t, 0.5, profile.Reputation.CompletionRate, 0.001)
+	require.InDelta(t, 0.5, profile.Reputation.CancellationRate, 0.001)
+}
+
+func TestPurgeDeletedAccounts(t *testing.T) {
+	userRepo := new(mocks.UserRepositoryMock)
+
+	userRepo.On("ListDeletedBefore", mock.MatchedBy(func(before time.Time) bool {
+		// стираются только аккаунты, удалённые раньше срока ожидания
+		return time.Since(before) >= 7*24*time.Hour
+	}), 100).Return([]uint{3, 5}, nil)
+	userRepo.On("Purge", uint(3)).Return(nil)
+	userRepo.On("Purge", uint(5)).Return(nil)
+
+	purged, err := services.PurgeDeletedAccounts(context.Background(), userRepo, 7*24*time.Hour)
 	require.NoError(t, err)
+	require.Equal(t, 2, purged)
 
 	userRepo.AssertExpectations(t)
 }
@@ -121,7 +296,7 @@ func TestUserService_GetUserExchanges_OK(t *testing.T) {
 	userRepo := new(mocks.UserRepositoryMock)
 	bookRepo := new(mocks.BookRepositoryMock)
 
-	svc := services.NewServiceUser(nil, userRepo, bookRepo, log)
+	svc := services.NewServiceUser(nil, userRepo, bookRepo, nil, nil, nil, nil, nil, "http://localhost:8080", 0, log)
 
 	exchanges := []models.Exchange{
 		{
@@ -134,7 +309,7 @@ func TestUserService_GetUserExchanges_OK(t *testing.T) {
 		On("GetUserExchanges", uint(1), "pending").
 		Return(exchanges, nil)
 
-	result, err := svc.GetUserExchanges(1, "pending")
+	result, err := svc.GetUserExchanges(context.Background(), 1, "pending")
 
 	require.NoError(t, err)
 	require.Len(t, result, 1)
@@ -143,6 +318,153 @@ func TestUserService_GetUserExchanges_OK(t *testing.T) {
 	userRepo.AssertExpectations(t)
 }
 
+func TestUserService_Register_InvalidEmail(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	userRepo := new(mocks.UserRepositoryMock)
+	svc := services.NewServiceUser(nil, userRepo, nil, nil, nil, nil, nil, nil, "http://localhost:8080", 0, log)
+
+	_, err := svc.Register(context.Background(), dto.UserCreateRequest{Name: "Bob", Email: "not-an-email", Password: "password"})
+	require.ErrorIs(t, err, dto.ErrInvalidEmail)
+
+	userRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestUserService_ShortPasswordRejected(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	userRepo := new(mocks.UserRepositoryMock)
+	svc := services.NewServiceUser(nil, userRepo, nil, nil, nil, nil, nil, nil, "http://localhost:8080", 0, log)
+
+	_, err := svc.Register(context.Background(), dto.UserCreateRequest{Name: "Bob", Email: "bob@example.com", Password: "1"})
+	require.ErrorIs(t, err, dto.ErrPasswordTooShort)
+
+	userRepo.On("GetByID", uint(1)).Return(&models.User{ID: 1, Email: "bob@example.com", Version: 1}, nil)
+	short := "1234567"
+	_, err = svc.UpdateUser(context.Background(), 1, dto.UserUpdateRequest{Password: &short})
+	require.ErrorIs(t, err, dto.ErrPasswordTooShort)
+
+	userRepo.AssertNotCalled(t, "Create", mock.Anything)
+	userRepo.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestUserService_Login_ProgressiveLockout(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	userRepo := new(mocks.UserRepositoryMock)
+	guard := ratelimit.NewMemoryLoginGuard(ratelimit.LockoutPolicy{
+		Threshold:  3,
+		BaseLock:   time.Minute,
+		MaxLock:    time.Hour,
+		FailureTTL: time.Hour,
+	})
+	svc := services.NewServiceUser(nil, userRepo, nil, nil, nil, nil, guard, nil, "http://localhost:8080", 0, log)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	userRepo.On("GetByEmail", "bob@example.com").Return(&models.User{ID: 1, PasswordHash: string(hash)}, nil)
+
+	for i := 0; i < 3; i++ {
+		_, err := svc.Login(context.Background(), dto.LoginRequest{Email: "Bob@Example.com", Password: "wrong", ClientIP: "203.0.113.7"})
+		require.ErrorIs(t, err, dto.ErrInvalidCredentials)
+	}
+
+	// после порога даже верный пароль не принимается, пока действует блокировка
+	_, err = svc.Login(context.Background(), dto.LoginRequest{Email: "bob@example.com", Password: "correct-password", ClientIP: "203.0.113.7"})
+	require.ErrorIs(t, err, dto.ErrAccountLocked)
+
+	var locked *dto.AccountLockedError
+	require.ErrorAs(t, err, &locked)
+	require.Greater(t, locked.RetryAfter, 50*time.Second)
+
+	userRepo.AssertNumberOfCalls(t, "GetByEmail", 3)
+
+	// перебор с чужого адреса не блокирует владельца на его адресе
+	jwtutil.Configure("test-secret-key-with-at-least-32-bytes", time.Hour)
+	token, err := svc.Login(context.Background(), dto.LoginRequest{Email: "bob@example.com", Password: "correct-password", ClientIP: "198.51.100.1"})
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+}
+
+func TestUserService_VerifyEmail_OK(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	userRepo := new(mocks.UserRepositoryMock)
+	tokenRepo := new(mocks.TokenRepositoryMock)
+	svc := services.NewServiceUser(nil, userRepo, nil, tokenRepo, nil, nil, nil, nil, "http://localhost:8080", 0, log)
+
+	token := &models.UserToken{
+		Model:     gorm.Model{ID: 7},
+		UserID:    1,
+		Purpose:   models.TokenPurposeVerifyEmail,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	user := &models.User{ID: 1, Email: "alice@example.com"}
+
+	// в базе лежит только хэш, сам токен не сохраняется
+	tokenRepo.On("GetByHash", models.TokenPurposeVerifyEmail, mock.MatchedBy(func(h string) bool {
+		return len(h) == 64 && h != "raw-token"
+	})).Return(token, nil)
+	tokenRepo.On("MarkUsed", uint(7)).Return(nil)
+	userRepo.On("GetByID", uint(1)).Return(user, nil)
+	userRepo.On("Update", mock.MatchedBy(func(u *models.User) bool {
+		return u.EmailVerifiedAt != nil
+	})).Return(nil)
+
+	require.NoError(t, svc.VerifyEmail(context.Background(), "raw-token"))
+
+	tokenRepo.AssertExpectations(t)
+	userRepo.AssertExpectations(t)
+}
+
+func TestUserService_UpdateUser_EmailChangeSendsVerification(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	userRepo := new(mocks.UserRepositoryMock)
+	tokenRepo := new(mocks.TokenRepositoryMock)
+	mailer := &flakyMailer{sent: make(chan mail.Message, 1)}
+	svc := services.NewServiceUser(nil, userRepo, nil, tokenRepo, mailer, nil, nil, nil, "http://localhost:8080", 0, log)
+
+	verified := time.Now()
+	user := &models.User{ID: 1, Name: "Alice", Email: "alice@example.com", Language: "ru", EmailVerifiedAt: &verified}
+	userRepo.On("GetByID", uint(1)).Return(user, nil)
+	userRepo.On("Update", mock.MatchedBy(func(u *models.User) bool {
+		return u.EmailVerifiedAt == nil
+	})).Return(nil)
+	tokenRepo.On("InvalidateForUser", uint(1), models.TokenPurposeVerifyEmail).Return(nil)
+	tokenRepo.On("Create", mock.Anything).Return(nil)
+
+	email := "Alice.New@Example.com"
+	got, err := svc.UpdateUser(context.Background(), 1, dto.UserUpdateRequest{Email: &email})
+	require.NoError(t, err)
+	require.Equal(t, "alice.new@example.com", got.Email)
+
+	msg := <-mailer.sent
+	require.Equal(t, "alice.new@example.com", msg.To)
+	tokenRepo.AssertExpectations(t)
+}
+
+func TestUserService_ResetPassword_ExpiredToken(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	userRepo := new(mocks.UserRepositoryMock)
+	tokenRepo := new(mocks.TokenRepositoryMock)
+	svc := services.NewServiceUser(nil, userRepo, nil, tokenRepo, nil, nil, nil, nil, "http://localhost:8080", 0, log)
+
+	tokenRepo.On("GetByHash", models.TokenPurposePasswordReset, mock.Anything).Return(&models.UserToken{
+		Model:     gorm.Model{ID: 8},
+		UserID:    1,
+		Purpose:   models.TokenPurposePasswordReset,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}, nil)
+
+	err := svc.ResetPassword(context.Background(), dto.ResetPasswordRequest{Token: "raw-token", Password: "new-password"})
+	require.ErrorIs(t, err, dto.ErrTokenInvalid)
+
+	tokenRepo.AssertNotCalled(t, "MarkUsed", mock.Anything)
+	userRepo.AssertNotCalled(t, "Update", mock.Anything)
+}
+
 // *********************************************************************************
 // *						  Тесты для book								       *
 // *								  |											   *
@@ -151,7 +473,7 @@ func TestUserService_GetUserExchanges_OK(t *testing.T) {
 func TestBookService_Create_OK(t *testing.T) {
 	log := slog.New(slog.NewTextHandler(io.Discard, nil))
 	bookRepo := new(mocks.BookRepositoryMock)
-	service := services.NewServiceBook(bookRepo, log)
+	service := services.NewServiceBook(bookRepo, config.AIConfig{}, nil, nil, log)
 
 	userID := uint(10)
 	req := dto.CreateBookRequest{
@@ -166,7 +488,7 @@ func TestBookService_Create_OK(t *testing.T) {
 		Return(nil).
 		Once()
 
-	book, err := service.CreateBook(userID, req)
+	book, err := service.CreateBook(context.Background(), userID, req)
 
 	require.NoError(t, err)
 	require.NotNil(t, book)
@@ -181,7 +503,7 @@ func TestBookService_GetByID_OK(t *testing.T) {
 	log := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	bookRepo := new(mocks.BookRepositoryMock)
-	svc := services.NewServiceBook(bookRepo, log)
+	svc := services.NewServiceBook(bookRepo, config.AIConfig{}, nil, nil, log)
 
 	book := &models.Book{
 		Model:       gorm.Model{ID: 1},
@@ -194,7 +516,7 @@ func TestBookService_GetByID_OK(t *testing.T) {
 	}
 
 	bookRepo.On("GetByID", uint(1)).Return(book, nil)
-	got, err := svc.GetByID(1)
+	got, err := svc.GetByID(context.Background(), 1)
 	require.NoError(t, err)
 	require.Equal(t, book.ID, got.ID)
 	require.Equal(t, "summer", got.Title)
@@ -206,7 +528,7 @@ func TestBookService_UpdateBook_OK(t *testing.T) {
 	log := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	bookRepo := new(mocks.BookRepositoryMock)
-	svc := services.NewServiceBook(bookRepo, log)
+	svc := services.NewServiceBook(bookRepo, config.AIConfig{}, nil, nil, log)
 
 	book := &models.Book{
 		Model:       gorm.Model{ID: 1},
@@ -225,7 +547,7 @@ func TestBookService_UpdateBook_OK(t *testing.T) {
 
 	bookRepo.On("GetByID", uint(1)).Return(book, nil)
 	bookRepo.On("Update", mock.Anything).Return(nil)
-	got, err := svc.Update(1, 1, *req)
+	got, err := svc.Update(context.Background(), 1, 1, *req)
 
 	require.NoError(t, err)
 	require.Equal(t, descr, got.Description)
@@ -234,12 +556,39 @@ func TestBookService_UpdateBook_OK(t *testing.T) {
 
 }
 
+func TestBookService_UpdateBook_IfMatchMismatch(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	bookRepo := new(mocks.BookRepositoryMock)
+	svc := services.NewServiceBook(bookRepo, config.AIConfig{}, nil, nil, log)
+
+	book := &models.Book{Model: gorm.Model{ID: 1}, UserID: 1, Version: 3}
+	bookRepo.On("GetByID", uint(1)).Return(book, nil)
+
+	descr := "новое описание"
+	req := dto.UpdateBookRequest{Description: &descr}
+
+	// клиент редактировал версию 2, а в базе уже 3
+	ctx := etag.WithIfMatch(context.Background(), `"2.abcdef"`)
+	_, err := svc.Update(ctx, 1, 1, req)
+	require.ErrorIs(t, err, dto.ErrVersionConflict)
+	bookRepo.AssertNotCalled(t, "Update", mock.Anything)
+
+	// совпавшая версия пропускается
+	bookRepo.On("Update", mock.Anything).Return(nil)
+	ctx = etag.WithIfMatch(context.Background(), `"3"`)
+	_, err = svc.Update(ctx, 1, 1, req)
+	require.NoError(t, err)
+
+	bookRepo.AssertExpectations(t)
+}
+
 // Delete(bookID uint, userID uint) error
 func TestBookService_DeleteBook_OK(t *testing.T) {
 	log := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	bookRepo := new(mocks.BookRepositoryMock)
-	svc := services.NewServiceBook(bookRepo, log)
+	svc := services.NewServiceBook(bookRepo, config.AIConfig{}, nil, nil, log)
 
 	book := &models.Book{
 		Model:       gorm.Model{ID: 1},
@@ -253,7 +602,7 @@ func TestBookService_DeleteBook_OK(t *testing.T) {
 
 	bookRepo.On("GetByID", uint(1)).Return(book, nil)
 	bookRepo.On("Delete", uint(1)).Return(nil)
-	err := svc.Delete(1, 1)
+	err := svc.Delete(context.Background(), 1, 1)
 	require.NoError(t, err)
 	bookRepo.AssertExpectations(t)
 }
@@ -262,7 +611,7 @@ func TestBookService_SearchBooks_OK(t *testing.T) {
 	log := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	bookRepo := new(mocks.BookRepositoryMock)
-	svc := services.NewServiceBook(bookRepo, log)
+	svc := services.NewServiceBook(bookRepo, config.AIConfig{}, nil, nil, log)
 
 	books := []models.Book{
 		{
@@ -295,16 +644,48 @@ func TestBookService_SearchBooks_OK(t *testing.T) {
 		SortOrder: "desc",
 	}
 
-	bookRepo.On("Search", query).Return(books, total, nil)
+	// сервис подставляет режим подсчёта по умолчанию
+	normalized := query
+	normalized.Count = dto.CountExact
+	bookRepo.On("Search", normalized).Return(&repository.BookPage{Books: books, Total: &total}, nil)
 
 	// 5️⃣ Вызываем метод сервиса
-	gotBooks, gotTotal, err := svc.SearchBooks(query)
+	page, err := svc.SearchBooks(context.Background(), query)
 
 	// 6️⃣ Проверяем результат
 	require.NoError(t, err)
-	require.Equal(t, total, gotTotal)
-	require.Len(t, gotBooks, 2)
-	require.Equal(t, "summer", gotBooks[0].Title)
+	require.Equal(t, total, *page.Total)
+	require.Len(t, page.Books, 2)
+	require.Equal(t, "summer", page.Books[0].Title)
+
+	bookRepo.AssertExpectations(t)
+}
+
+func TestBookService_SearchBooks_CityRadius(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	bookRepo := new(mocks.BookRepositoryMock)
+	svc := services.NewServiceBook(bookRepo, config.AIConfig{}, nil, geo.NewGazetteer(), log)
+
+	// город с радиусом превращается в точку поиска и сортировку по расстоянию
+	bookRepo.On("Search", mock.MatchedBy(func(q dto.BookListQuery) bool {
+		return q.Origin != nil && q.City == "" && q.SortBy == "distance" && q.SortOrder == "asc" &&
+			geo.Distance(*q.Origin, geo.Point{Lat: 55.897, Lon: 37.4297}) < 1
+	})).Return(&repository.BookPage{}, nil).Once()
+	_, err := svc.SearchBooks(context.Background(), dto.BookListQuery{City: "г. Химки", RadiusKm: 10})
+	require.NoError(t, err)
+
+	// неизвестный город — обычный поиск по подстроке без радиуса
+	bookRepo.On("Search", mock.MatchedBy(func(q dto.BookListQuery) bool {
+		return q.Origin == nil && q.City == "Нетгород" && q.RadiusKm == 0
+	})).Return(&repository.BookPage{}, nil).Once()
+	_, err = svc.SearchBooks(context.Background(), dto.BookListQuery{City: "Нетгород", RadiusKm: 10})
+	require.NoError(t, err)
+
+	_, err = svc.SearchBooks(context.Background(), dto.BookListQuery{Near: "91,37"})
+	require.ErrorIs(t, err, dto.ErrInvalidLocation)
+	_, err = svc.SearchBooks(context.Background(), dto.BookListQuery{RadiusKm: 10})
+	require.ErrorIs(t, err, dto.ErrInvalidLocation)
 
 	bookRepo.AssertExpectations(t)
 }
@@ -313,7 +694,7 @@ func TestBookService_GetBooksByUserID_OK(t *testing.T) {
 	log := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	bookRepo := new(mocks.BookRepositoryMock)
-	svc := services.NewServiceBook(bookRepo, log)
+	svc := services.NewServiceBook(bookRepo, config.AIConfig{}, nil, nil, log)
 
 	books := []models.Book{
 		{
@@ -338,7 +719,7 @@ func TestBookService_GetBooksByUserID_OK(t *testing.T) {
 
 	bookRepo.On("GetByUserID", uint(1), "available").Return(books, nil)
 
-	got, err := svc.GetBooksByUserID(1, "available")
+	got, err := svc.GetBooksByUserID(context.Background(), 1, "available")
 
 	require.NoError(t, err)
 	require.Len(t, got, 2)
@@ -352,7 +733,7 @@ func TestBookService_GetAvailableBooks_OK(t *testing.T) {
 	log := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	bookRepo := new(mocks.BookRepositoryMock)
-	svc := services.NewServiceBook(bookRepo, log)
+	svc := services.NewServiceBook(bookRepo, config.AIConfig{}, nil, nil, log)
 
 	books := []models.Book{
 		{
@@ -377,7 +758,7 @@ func TestBookService_GetAvailableBooks_OK(t *testing.T) {
 
 	bookRepo.On("GetAvailable", "Moscow").Return(books, nil)
 
-	got, err := svc.GetAvailableBooks("Moscow")
+	got, err := svc.GetAvailableBooks(context.Background(), "Moscow")
 	require.NoError(t, err)
 	require.Len(t, got, 2)
 	require.Equal(t, "summer", got[0].Title)
@@ -394,7 +775,7 @@ func TestBookService_GetAvailableBooks_OK(t *testing.T) {
 
 func TestGenreService_CreateGenre_OK(t *testing.T) {
 	genreRepo := new(mocks.GenreRepositoryMock)
-	svc := services.NewGenreService(genreRepo)
+	svc := services.NewGenreService(genreRepo, new(mocks.UserRepositoryMock))
 
 	req := &dto.GenreCreateRequest{
 		Name: "Classic",
@@ -402,7 +783,7 @@ func TestGenreService_CreateGenre_OK(t *testing.T) {
 
 	genreRepo.On("Create", mock.Anything).Return(nil)
 
-	got, err := svc.Create(*req)
+	got, err := svc.Create(context.Background(), *req)
 
 	require.NoError(t, err)
 	require.Equal(t, req.Name, got.Name)
@@ -412,7 +793,7 @@ func TestGenreService_CreateGenre_OK(t *testing.T) {
 
 func TestGenreService_GetByIDGenre_OK(t *testing.T) {
 	genreRepo := new(mocks.GenreRepositoryMock)
-	svc := services.NewGenreService(genreRepo)
+	svc := services.NewGenreService(genreRepo, new(mocks.UserRepositoryMock))
 
 	genr := &models.Genre{
 		Model: gorm.Model{ID: 1},
@@ -421,7 +802,7 @@ func TestGenreService_GetByIDGenre_OK(t *testing.T) {
 
 	genreRepo.On("GetByID", uint(1)).Return(genr, nil)
 
-	got, err := svc.GetByID(1)
+	got, err := svc.GetByID(context.Background(), 1)
 	require.NoError(t, err)
 	require.Equal(t, genr.Name, got.Name)
 
@@ -431,6 +812,96 @@ func TestGenreService_GetByIDGenre_OK(t *testing.T) {
 
 
 
+func TestSlug_Make(t *testing.T) {
+	require.Equal(t, "nauchnaya-fantastika", slug.Make("Научная фантастика"))
+	require.Equal(t, "sci-fi-cyberpunk", slug.Make("  Sci-Fi > Cyberpunk "))
+	require.Equal(t, "podezd", slug.Make("Подъезд"))
+	require.Equal(t, "", slug.Make("!!!"))
+	require.True(t, slug.Valid("sci-fi"))
+	require.False(t, slug.Valid("Sci Fi"))
+}
+
+func TestGazetteer_Geocode(t *testing.T) {
+	g := geo.NewGazetteer()
+	ctx := context.Background()
+
+	moscow, err := g.Geocode(ctx, "Москва")
+	require.NoError(t, err)
+	for _, query := range []string{"г. Москва", "MOSCOW", "Масква", "Россия, Москва, 101000"} {
+		p, err := g.Geocode(ctx, query)
+		require.NoError(t, err, query)
+		require.Equal(t, moscow, p, query)
+	}
+
+	spb, err := g.Geocode(ctx, "Питер")
+	require.NoError(t, err)
+	require.InDelta(t, 634, geo.Distance(moscow, spb), 5)
+
+	orel, err := g.Geocode(ctx, "город Орел")
+	require.NoError(t, err)
+	require.InDelta(t, 52.97, orel.Lat, 0.01)
+
+	_, err = g.Geocode(ctx, "Нетгород")
+	require.ErrorIs(t, err, geo.ErrNotFound)
+
+	// свой справочник дополняет встроенный
+	require.NoError(t, g.Load(strings.NewReader("# посёлки\nНетгород;50.5;40.25;Netgorod\n")))
+	p, err := g.Geocode(ctx, "netgorod")
+	require.NoError(t, err)
+	require.Equal(t, geo.Point{Lat: 50.5, Lon: 40.25}, p)
+	require.Error(t, g.Load(strings.NewReader("Сломанный;north;east\n")))
+
+	p, err = geo.ParsePoint(" 55.75, 37.62 ")
+	require.NoError(t, err)
+	require.Equal(t, geo.Point{Lat: 55.75, Lon: 37.62}, p)
+	for _, bad := range []string{"", "55.75", "55.75,abc", "91,0", "0,181"} {
+		_, err := geo.ParsePoint(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestGenreService_Tree(t *testing.T) {
+	genreRepo := new(mocks.GenreRepositoryMock)
+	svc := services.NewGenreService(genreRepo, new(mocks.UserRepositoryMock))
+
+	root, child := uint(1), uint(2)
+	genreRepo.On("List").Return([]models.Genre{
+		{Model: gorm.Model{ID: 1}, Name: "Художественная литература", Slug: "fiction"},
+		{Model: gorm.Model{ID: 2}, Name: "Фантастика", Slug: "sci-fi", ParentID: &root},
+		{Model: gorm.Model{ID: 3}, Name: "Киберпанк", Slug: "cyberpunk", ParentID: &child},
+		{Model: gorm.Model{ID: 4}, Name: "Поэзия", Slug: "poetry"},
+	}, nil)
+
+	tree, err := svc.Tree(context.Background())
+	require.NoError(t, err)
+	require.Len(t, tree, 2)
+	require.Equal(t, "fiction", tree[0].Slug)
+	require.Equal(t, "cyberpunk", tree[0].Children[0].Children[0].Slug)
+	require.Empty(t, tree[1].Children)
+}
+
+func TestGenreService_Merge(t *testing.T) {
+	genreRepo := new(mocks.GenreRepositoryMock)
+	userRepo := new(mocks.UserRepositoryMock)
+	svc := services.NewGenreService(genreRepo, userRepo)
+
+	userRepo.On("GetByID", uint(1)).Return(&models.User{ID: 1, Role: models.RoleModerator}, nil)
+	userRepo.On("GetByID", uint(2)).Return(&models.User{ID: 2, Role: models.RoleAdmin}, nil)
+	genreRepo.On("Merge", uint(10), []uint{11, 12}).Return(int64(4), nil).Once()
+
+	ctx := context.Background()
+	_, err := svc.Merge(ctx, 1, 10, dto.GenreMergeRequest{SourceIDs: []uint{11}})
+	require.ErrorIs(t, err, dto.ErrAdminOnly)
+	_, err = svc.Merge(ctx, 2, 10, dto.GenreMergeRequest{SourceIDs: []uint{10}})
+	require.ErrorIs(t, err, dto.ErrGenreMergeInvalid)
+
+	res, err := svc.Merge(ctx, 2, 10, dto.GenreMergeRequest{SourceIDs: []uint{11, 12, 11}})
+	require.NoError(t, err)
+	require.Equal(t, int64(4), res.BooksMoved)
+	require.Equal(t, []uint{11, 12}, res.MergedIDs)
+	genreRepo.AssertExpectations(t)
+}
+
 // *********************************************************************************
 // *						  Тесты для Review								       *
 // *								  |											   *
@@ -442,32 +913,144 @@ func TestGenreService_GetByIDGenre_OK(t *testing.T) {
 
 func TestReviewService_Create_OK(t *testing.T) {
 	reviewRepo := new(mocks.ReviewRepositoryMock)
-	svc := services.NewReviewService(reviewRepo)
+	exchangeRepo := new(mocks.ExchangeRepositoryMock)
+	userRepo := new(mocks.UserRepositoryMock)
+	bookRepo := new(mocks.BookRepositoryMock)
+	svc := services.NewReviewService(reviewRepo, exchangeRepo, userRepo, bookRepo, nil)
 
 	authorID := uint(1)
 	req := dto.CreateReviewRequest{
-		TargetUserID: 2,
-		TargetBookID: 2,
-		Text:         "bad bookjdjfjsdfj",
-		Rating:       5,
+		ExchangeID: 7,
+		Text:       "bad bookjdjfjsdfj",
+		Rating:     5,
 	}
 
+	exchangeRepo.On("GetByID", uint(7)).Return(&models.Exchange{
+		Model:           gorm.Model{ID: 7},
+		InitiatorID:     1,
+		RecipientID:     2,
+		InitiatorBookID: 10,
+		RecipientBookID: 20,
+		Status:          "completed",
+	}, nil)
+	userRepo.On("GetByID", uint(2)).Return(&models.User{ID: 2}, nil)
+	bookRepo.On("GetByID", uint(20)).Return(&models.Book{Model: gorm.Model{ID: 20}}, nil)
+	reviewRepo.On("ExistsForExchange", uint(7), authorID).Return(false, nil)
 	reviewRepo.On("Create", mock.Anything).Return(nil)
 
-	got, err := svc.Create(authorID, req)
+	got, err := svc.Create(context.Background(), authorID, req)
 	require.NoError(t, err)
 	require.Equal(t, authorID, got.AuthorID)
-	require.Equal(t, req.TargetUserID, got.TargetUserID)
-	require.Equal(t, req.TargetBookID, got.TargetBookID)
+	// цель отзыва берётся из обмена: второй участник и полученная от него книга
+	require.Equal(t, uint(2), got.TargetUserID)
+	require.Equal(t, uint(20), got.TargetBookID)
+	require.NotNil(t, got.ExchangeID)
+	require.Equal(t, uint(7), *got.ExchangeID)
 	require.Equal(t, req.Text, got.Text)
 	require.Equal(t, req.Rating, got.Rating)
 
 	reviewRepo.AssertExpectations(t)
 }
 
+func TestReviewService_Create_Rejected(t *testing.T) {
+	completed := &models.Exchange{
+		Model:           gorm.Model{ID: 7},
+		InitiatorID:     1,
+		RecipientID:     2,
+		InitiatorBookID: 10,
+		RecipientBookID: 20,
+		Status:          "completed",
+	}
+	accepted := *completed
+	accepted.Status = "accepted"
+
+	tests := []struct {
+		name     string
+		authorID uint
+		exchange *models.Exchange
+		req      dto.CreateReviewRequest
+		setup    func(users *mocks.UserRepositoryMock, books *mocks.BookRepositoryMock, reviews *mocks.ReviewRepositoryMock)
+		wantErr  error
+	}{
+		{
+			name: "exchange not completed", authorID: 1, exchange: &accepted,
+			wantErr: dto.ErrReviewExchangeNotCompleted,
+		},
+		{
+			name: "not a participant", authorID: 3, exchange: completed,
+			wantErr: dto.ErrReviewNotParticipant,
+		},
+		{
+			name: "own book", authorID: 1, exchange: completed,
+			req:     dto.CreateReviewRequest{TargetBookID: 10},
+			wantErr: dto.ErrReviewTargetMismatch,
+		},
+		{
+			name: "target user deleted", authorID: 2, exchange: completed,
+			setup: func(users *mocks.UserRepositoryMock, _ *mocks.BookRepositoryMock, _ *mocks.ReviewRepositoryMock) {
+				users.On("GetByID", uint(1)).Return(nil, repository.ErrUserNotFound)
+			},
+			wantErr: dto.ErrReviewTargetNotFound,
+		},
+		{
+			name: "already reviewed", authorID: 2, exchange: completed,
+			setup: func(users *mocks.UserRepositoryMock, books *mocks.BookRepositoryMock, reviews *mocks.ReviewRepositoryMock) {
+				users.On("GetByID", uint(1)).Return(&models.User{}, nil)
+				books.On("GetByID", uint(10)).Return(&models.Book{}, nil)
+				reviews.On("ExistsForExchange", uint(7), uint(2)).Return(true, nil)
+			},
+			wantErr: dto.ErrReviewAlreadyExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reviewRepo := new(mocks.ReviewRepositoryMock)
+			exchangeRepo := new(mocks.ExchangeRepositoryMock)
+			userRepo := new(mocks.UserRepositoryMock)
+			bookRepo := new(mocks.BookRepositoryMock)
+			svc := services.NewReviewService(reviewRepo, exchangeRepo, userRepo, bookRepo, nil)
+
+			exchangeRepo.On("GetByID", uint(7)).Return(tt.exchange, nil)
+			if tt.setup != nil {
+				tt.setup(userRepo, bookRepo, reviewRepo)
+			}
+
+			req := tt.req
+			req.ExchangeID = 7
+			req.Text = "обмен прошёл отлично"
+			req.Rating = 5
+
+			_, err := svc.Create(context.Background(), tt.authorID, req)
+			require.ErrorIs(t, err, tt.wantErr)
+			reviewRepo.AssertNotCalled(t, "Create", mock.Anything)
+		})
+	}
+}
+
+func TestComputeReputation(t *testing.T) {
+	// новичок без отзывов и обменов получает средний балл
+	newcomer := services.ComputeReputation(models.RatingSummary{}, services.ExchangeCounts{})
+	require.InDelta(t, 55.0, newcomer.Score, 0.1)
+	require.Zero(t, newcomer.CompletionRate)
+
+	reliable := services.ComputeReputation(
+		models.RatingSummary{ReviewsCount: 20, RatingSum: 96},
+		services.ExchangeCounts{Total: 20, Completed: 19, Cancelled: 1},
+	)
+	flaky := services.ComputeReputation(
+		models.RatingSummary{ReviewsCount: 20, RatingSum: 96},
+		services.ExchangeCounts{Total: 20, Completed: 5, Cancelled: 15},
+	)
+	require.Greater(t, reliable.Score, flaky.Score)
+	require.InDelta(t, 0.95, reliable.CompletionRate, 0.001)
+	require.InDelta(t, 0.75, flaky.CancellationRate, 0.001)
+	require.LessOrEqual(t, reliable.Score, 100.0)
+}
+
 func TestReviewService_GetByTargetUserID_OK(t *testing.T) {
 	reviewRepo := new(mocks.ReviewRepositoryMock)
-	svc := services.NewReviewService(reviewRepo)
+	svc := services.NewReviewService(reviewRepo, new(mocks.ExchangeRepositoryMock), new(mocks.UserRepositoryMock), new(mocks.BookRepositoryMock), nil)
 
 	review := []models.Review{
 		{
@@ -488,19 +1071,85 @@ func TestReviewService_GetByTargetUserID_OK(t *testing.T) {
 		},
 	}
 
-	reviewRepo.On("GetByTargetUserID", uint(1)).Return(review, nil)
+	reviewRepo.On("GetByTargetUserID", uint(1), dto.ReviewListQuery{Page: dto.DefaultPage, Limit: dto.DefaultLimit, SortBy: "rating"}).
+		Return(review, int64(2), nil)
 
-	got, err := svc.GetByUserID(uint(1))
+	got, total, err := svc.GetByUserID(context.Background(), uint(1), dto.ReviewListQuery{SortBy: " Rating "})
 	require.NoError(t, err)
+	require.Equal(t, int64(2), total)
 	require.Len(t, got, 2)
 	require.Equal(t, review[0].Text, got[0].Text)
 	require.Equal(t, review[1].Text, got[1].Text)
 	require.Equal(t, review[0].Rating, got[0].Rating)
 	require.Equal(t, review[1].Rating, got[1].Rating)
 }
+func TestReviewService_Update(t *testing.T) {
+	text := "исправленный текст отзыва"
+	rating := 3
+
+	t.Run("ok", func(t *testing.T) {
+		reviewRepo := new(mocks.ReviewRepositoryMock)
+		svc := services.NewReviewService(reviewRepo, new(mocks.ExchangeRepositoryMock), new(mocks.UserRepositoryMock), new(mocks.BookRepositoryMock), nil)
+
+		review := &models.Review{Model: gorm.Model{ID: 1, CreatedAt: time.Now().Add(-time.Hour)}, AuthorID: 1, Text: "старый текст отзыва", Rating: 5}
+		reviewRepo.On("GetByID", uint(1)).Return(review, nil)
+		reviewRepo.On("Update", mock.Anything, mock.MatchedBy(func(prev models.Review) bool {
+			return prev.Text == "старый текст отзыва" && prev.Rating == 5
+		})).Return(nil)
+
+		got, err := svc.Update(context.Background(), 1, 1, dto.UpdateReviewRequest{Text: &text, Rating: &rating})
+		require.NoError(t, err)
+		require.Equal(t, text, got.Text)
+		require.Equal(t, rating, got.Rating)
+		require.NotNil(t, got.EditedAt)
+		reviewRepo.AssertExpectations(t)
+	})
+
+	t.Run("not author", func(t *testing.T) {
+		reviewRepo := new(mocks.ReviewRepositoryMock)
+		svc := services.NewReviewService(reviewRepo, new(mocks.ExchangeRepositoryMock), new(mocks.UserRepositoryMock), new(mocks.BookRepositoryMock), nil)
+
+		reviewRepo.On("GetByID", uint(1)).Return(&models.Review{Model: gorm.Model{ID: 1, CreatedAt: time.Now()}, AuthorID: 2}, nil)
+
+		_, err := svc.Update(context.Background(), 1, 1, dto.UpdateReviewRequest{Text: &text})
+		require.ErrorIs(t, err, dto.ErrReviewEditForbidden)
+	})
+
+	t.Run("window closed", func(t *testing.T) {
+		reviewRepo := new(mocks.ReviewRepositoryMock)
+		svc := services.NewReviewService(reviewRepo, new(mocks.ExchangeRepositoryMock), new(mocks.UserRepositoryMock), new(mocks.BookRepositoryMock), nil)
+
+		reviewRepo.On("GetByID", uint(1)).Return(&models.Review{Model: gorm.Model{ID: 1, CreatedAt: time.Now().Add(-8 * 24 * time.Hour)}, AuthorID: 1}, nil)
+
+		_, err := svc.Update(context.Background(), 1, 1, dto.UpdateReviewRequest{Text: &text})
+		require.ErrorIs(t, err, dto.ErrReviewEditWindowClosed)
+		reviewRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+}
+
+func TestReviewService_Reply(t *testing.T) {
+	reviewRepo := new(mocks.ReviewRepositoryMock)
+	svc := services.NewReviewService(reviewRepo, new(mocks.ExchangeRepositoryMock), new(mocks.UserRepositoryMock), new(mocks.BookRepositoryMock), nil)
+
+	reviewRepo.On("GetByID", uint(1)).Return(&models.Review{Model: gorm.Model{ID: 1}, AuthorID: 1, TargetUserID: 2}, nil)
+	reviewRepo.On("GetByID", uint(2)).Return(&models.Review{Model: gorm.Model{ID: 2}, AuthorID: 1, TargetUserID: 2, Reply: &models.ReviewReply{}}, nil)
+	reviewRepo.On("CreateReply", mock.Anything).Return(nil)
+
+	_, err := svc.Reply(context.Background(), 1, 3, dto.ReviewReplyRequest{Text: "спасибо"})
+	require.ErrorIs(t, err, dto.ErrReviewReplyForbidden)
+
+	_, err = svc.Reply(context.Background(), 2, 2, dto.ReviewReplyRequest{Text: "спасибо"})
+	require.ErrorIs(t, err, dto.ErrReviewReplyExists)
+
+	reply, err := svc.Reply(context.Background(), 1, 2, dto.ReviewReplyRequest{Text: "  спасибо  "})
+	require.NoError(t, err)
+	require.Equal(t, "спасибо", reply.Text)
+	require.Equal(t, uint(2), reply.AuthorID)
+}
+
 func TestReviewService_GetByTargetBookID_OK(t *testing.T) {
 	reviewRepo := new(mocks.ReviewRepositoryMock)
-	svc := services.NewReviewService(reviewRepo)
+	svc := services.NewReviewService(reviewRepo, new(mocks.ExchangeRepositoryMock), new(mocks.UserRepositoryMock), new(mocks.BookRepositoryMock), nil)
 
 	review := []models.Review{
 		{
@@ -523,7 +1172,7 @@ func TestReviewService_GetByTargetBookID_OK(t *testing.T) {
 
 	reviewRepo.On("GetByTargetBookID", uint(1)).Return(review, nil)
 
-	got, err := svc.GetByBookID(uint(1))
+	got, err := svc.GetByBookID(context.Background(), uint(1))
 	require.NoError(t, err)
 	require.Len(t, got, 2)
 	require.Equal(t, review[0].Text, got[0].Text)
@@ -534,7 +1183,7 @@ func TestReviewService_GetByTargetBookID_OK(t *testing.T) {
 
 func TestReviewService_DeleteReview_OK(t *testing.T) {
 	reviewRepo := new(mocks.ReviewRepositoryMock)
-	svc := services.NewReviewService(reviewRepo)
+	svc := services.NewReviewService(reviewRepo, new(mocks.ExchangeRepositoryMock), new(mocks.UserRepositoryMock), new(mocks.BookRepositoryMock), nil)
 
 	authorID := uint(1)
 	review := &models.Review{
@@ -549,7 +1198,7 @@ func TestReviewService_DeleteReview_OK(t *testing.T) {
 	reviewRepo.On("GetByID", uint(1)).Return(review, nil)
 	reviewRepo.On("Delete", uint(1)).Return(nil)
 
-	err := svc.Delete(uint(1), authorID)
+	err := svc.Delete(context.Background(), uint(1), authorID)
 	require.NoError(t, err)
 }
 
@@ -577,7 +1226,7 @@ func TestExchangeService_Create_OK(t *testing.T) {
 	exchangeRepo := new(mocks.ExchangeRepositoryMock)
 	bookRepo := new(mocks.BookRepositoryMock)
 
-	svc := services.NewExchangeService(exchangeRepo, bookRepo, log)
+	svc := services.NewExchangeService(exchangeRepo, bookRepo, nil, nil, log)
 
 	req := dto.CreateExchangeRequest{
 		RecipientID:     2,
@@ -585,11 +1234,13 @@ func TestExchangeService_Create_OK(t *testing.T) {
 		RecipientBookID: 20,
 	}
 
-	// 📘 Книга инициатора
+	// 📘 Книга инициатора (email подтверждён)
+	verifiedAt := time.Now()
 	initiatorBook := &models.Book{
 		Model:  gorm.Model{ID: 10},
 		UserID: 1,
 		Status: "available",
+		User:   &models.User{ID: 1, EmailVerifiedAt: &verifiedAt},
 	}
 
 	// 📕 Книга получателя
@@ -605,7 +1256,7 @@ func TestExchangeService_Create_OK(t *testing.T) {
 	exchangeRepo.On("CreateExchange", mock.Anything).Return(nil)
 
 	// ACT
-	exchange, err := svc.CreateExchange(&req, 1)
+	exchange, err := svc.CreateExchange(context.Background(), &req, 1)
 
 	// ASSERT
 	require.NoError(t, err)
@@ -618,13 +1269,96 @@ func TestExchangeService_Create_OK(t *testing.T) {
 	exchangeRepo.AssertExpectations(t)
 }
 
+func TestExchangeService_Create_UnverifiedEmail(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	exchangeRepo := new(mocks.ExchangeRepositoryMock)
+	bookRepo := new(mocks.BookRepositoryMock)
+
+	svc := services.NewExchangeService(exchangeRepo, bookRepo, nil, nil, log)
+
+	bookRepo.On("GetByID", uint(10)).Return(&models.Book{
+		Model: gorm.Model{ID: 10}, UserID: 1, Status: "available", User: &models.User{ID: 1},
+	}, nil)
+	bookRepo.On("GetByID", uint(20)).Return(&models.Book{
+		Model: gorm.Model{ID: 20}, UserID: 2, Status: "available",
+	}, nil)
+
+	_, err := svc.CreateExchange(context.Background(), &dto.CreateExchangeRequest{RecipientID: 2, InitiatorBookID: 10, RecipientBookID: 20}, 1)
+	require.ErrorIs(t, err, dto.ErrEmailNotVerified)
+
+	exchangeRepo.AssertNotCalled(t, "CreateExchange", mock.Anything)
+}
+
+func TestExchangeService_AcceptExchange_PublishesEvents(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	exchangeRepo := new(mocks.ExchangeRepositoryMock)
+	bookRepo := new(mocks.BookRepositoryMock)
+	publisher := new(mocks.EventPublisherMock)
+
+	svc := services.NewExchangeService(exchangeRepo, bookRepo, publisher, nil, log)
+
+	exch := &models.Exchange{
+		Model:       gorm.Model{ID: 4},
+		InitiatorID: 1,
+		RecipientID: 2,
+		Status:      "pending",
+	}
+
+	exchangeRepo.On("GetByID", uint(4)).Return(exch, nil)
+	exchangeRepo.On("Update", exch).Return(nil)
+
+	// событие получают обе стороны обмена
+	publisher.On("Publish", mock.Anything, uint(1), events.TypeExchangeAccepted, mock.Anything).Return(nil)
+	publisher.On("Publish", mock.Anything, uint(2), events.TypeExchangeAccepted, mock.Anything).Return(nil)
+
+	err := svc.AcceptExchange(context.Background(), 4, 2)
+	require.NoError(t, err)
+
+	exchangeRepo.AssertExpectations(t)
+	publisher.AssertExpectations(t)
+}
+
+func TestMemoryBroker_ReplaysAfterLastEventID(t *testing.T) {
+	broker := events.NewMemoryBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, broker.Publish(ctx, 1, events.TypeExchangeCreated, map[string]int{"n": 1}))
+	require.NoError(t, broker.Publish(ctx, 1, events.TypeExchangeAccepted, map[string]int{"n": 2}))
+	require.NoError(t, broker.Publish(ctx, 2, events.TypeExchangeCreated, map[string]int{"n": 3}))
+
+	// без Last-Event-ID — только новые события
+	first, err := broker.Subscribe(ctx, 1, "")
+	require.NoError(t, err)
+	require.NoError(t, broker.Publish(ctx, 1, events.TypeExchangeCompleted, nil))
+	lastSeen := <-first
+
+	// с Last-Event-ID — сначала история пользователя, без чужих событий
+	stream, err := broker.Subscribe(ctx, 1, "0-0")
+	require.NoError(t, err)
+	var types []string
+	for i := 0; i < 3; i++ {
+		types = append(types, (<-stream).Type)
+	}
+	require.Equal(t, []string{events.TypeExchangeCreated, events.TypeExchangeAccepted, events.TypeExchangeCompleted}, types)
+	require.Equal(t, events.TypeExchangeCompleted, lastSeen.Type)
+
+	// переподключение после последнего события — история пуста, ждём новые
+	replay, err := broker.Subscribe(ctx, 1, lastSeen.ID)
+	require.NoError(t, err)
+	require.NoError(t, broker.Publish(ctx, 1, events.TypeExchangeCancelled, nil))
+	require.Equal(t, events.TypeExchangeCancelled, (<-replay).Type)
+}
+
 func TestExchangeService_CompleteExchange_OK(t *testing.T) {
 	log := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	exchangeRepo := new(mocks.ExchangeRepositoryMock)
 	bookRepo := new(mocks.BookRepositoryMock)
 
-	svc := services.NewExchangeService(exchangeRepo, bookRepo, log)
+	svc := services.NewExchangeService(exchangeRepo, bookRepo, nil, nil, log)
 
 	// Готовим обмен со статусом accepted
 	exch := &models.Exchange{
@@ -641,7 +1375,7 @@ func TestExchangeService_CompleteExchange_OK(t *testing.T) {
 	exchangeRepo.On("CompleteExchange", exch).Return(nil)
 
 	// Действие: инициатор завершает обмен
-	err := svc.CompleteExchange(1, 1)
+	err := svc.CompleteExchange(context.Background(), 1, 1)
 
 	// Проверка
 	require.NoError(t, err)
@@ -653,7 +1387,7 @@ func TestExchangeService_CancelExchange_OK(t *testing.T) {
 	exchangeRepo := new(mocks.ExchangeRepositoryMock)
 	bookRepo := new(mocks.BookRepositoryMock)
 
-	svc := services.NewExchangeService(exchangeRepo, bookRepo, log)
+	svc := services.NewExchangeService(exchangeRepo, bookRepo, nil, nil, log)
 	// Обмен в статусе pending может отменить только инициатор
 	exch := &models.Exchange{
 		Model:           gorm.Model{ID: 2},
@@ -667,8 +1401,9 @@ func TestExchangeService_CancelExchange_OK(t *testing.T) {
 	exchangeRepo.On("GetByID", uint(2)).Return(exch, nil)
 	exchangeRepo.On("CancelExchange", exch).Return(nil)
 
-	err := svc.CancelExchange(2, 5)
+	err := svc.CancelExchange(context.Background(), 2, 5)
 	require.NoError(t, err)
+	require.Equal(t, uint(5), *exch.CancelledBy)
 
 	exchangeRepo.AssertExpectations(t)
 }
@@ -679,7 +1414,7 @@ func TestExchangeService_AcceptExchange_OK(t *testing.T) {
 	exchangeRepo := new(mocks.ExchangeRepositoryMock)
 	bookRepo := new(mocks.BookRepositoryMock)
 
-	svc := services.NewExchangeService(exchangeRepo, bookRepo, log)
+	svc := services.NewExchangeService(exchangeRepo, bookRepo, nil, nil, log)
 
 	// Принять pending может только получатель
 	exch := &models.Exchange{
@@ -697,7 +1432,7 @@ func TestExchangeService_AcceptExchange_OK(t *testing.T) {
 		return e.ID == 3 && e.Status == "accepted"
 	})).Return(nil)
 
-	err := svc.AcceptExchange(3, 22)
+	err := svc.AcceptExchange(context.Background(), 3, 22)
 	require.NoError(t, err)
 
 	exchangeRepo.AssertExpectations(t)
@@ -709,12 +1444,12 @@ func TestExchangeService_GetByID_OK(t *testing.T) {
 	exchangeRepo := new(mocks.ExchangeRepositoryMock)
 	bookRepo := new(mocks.BookRepositoryMock)
 
-	svc := services.NewExchangeService(exchangeRepo, bookRepo, log)
+	svc := services.NewExchangeService(exchangeRepo, bookRepo, nil, nil, log)
 
 	exch := &models.Exchange{Model: gorm.Model{ID: 99}, InitiatorID: 1, RecipientID: 2, Status: "pending"}
 	exchangeRepo.On("GetByID", uint(99)).Return(exch, nil)
 
-	got, err := svc.GetByID(99)
+	got, err := svc.GetByID(context.Background(), 99)
 	require.NoError(t, err)
 	require.Equal(t, uint(99), got.ID)
 
@@ -727,7 +1462,7 @@ func TestExchangeService_GetAll_OK(t *testing.T) {
 	exchangeRepo := new(mocks.ExchangeRepositoryMock)
 	bookRepo := new(mocks.BookRepositoryMock)
 
-	svc := services.NewExchangeService(exchangeRepo, bookRepo, log)
+	svc := services.NewExchangeService(exchangeRepo, bookRepo, nil, nil, log)
 
 	list := []models.Exchange{
 		{Model: gorm.Model{ID: 1}, InitiatorID: 1, RecipientID: 2, Status: "pending"},
@@ -736,7 +1471,7 @@ func TestExchangeService_GetAll_OK(t *testing.T) {
 
 	exchangeRepo.On("GetAll").Return(list, nil)
 
-	got, err := svc.GetAll()
+	got, err := svc.GetAll(context.Background())
 	require.NoError(t, err)
 	require.Len(t, got, 2)
 	require.Equal(t, uint(1), got[0].ID)
@@ -744,3 +1479,179 @@ func TestExchangeService_GetAll_OK(t *testing.T) {
 
 	exchangeRepo.AssertExpectations(t)
 }
+
+// *********************************************************************************
+// *						  Тесты для moderation								   *
+// *								  |											   *
+// *								  V									   		   *
+// *********************************************************************************
+
+func TestWordlistChecker_Check(t *testing.T) {
+	checker := moderation.NewWordlistChecker(append(moderation.DefaultWords, "спойлер"))
+
+	cases := []struct {
+		text    string
+		reasons []string
+	}{
+		{"Отличная книга, читается на одном дыхании", nil},
+		// имена авторов и названия книг не считаются руганью
+		{"Philip K. Dick, «Do Androids Dream of Electric Sheep?» и Moby-Dick", nil},
+		{"Автор — мудаки, не читайте", []string{"profanity"}},
+		{"Там в конце СПОЙЛЕР!", []string{"profanity"}},
+		{"Пишите на reader@example.com", []string{"email"}},
+		{"Звоните +7 (912) 345-67-89", []string{"phone"}},
+		{"Переведите на 4111 1111 1111 1111", []string{"card"}},
+		// номер не проходит проверку Луна — не карта
+		{"ISBN 1234 5678 9012 3456", nil},
+	}
+	for _, tc := range cases {
+		v, err := checker.Check(context.Background(), tc.text)
+		require.NoError(t, err)
+		require.Equal(t, tc.reasons, v.Reasons, tc.text)
+		require.Equal(t, len(tc.reasons) > 0, v.Flagged, tc.text)
+	}
+}
+
+func TestModeration_NextStatus(t *testing.T) {
+	flagged := moderation.Verdict{Flagged: true, Reasons: []string{"email"}}
+
+	require.Equal(t, moderation.StatusApproved, moderation.NextStatus("", moderation.Verdict{}))
+	require.Equal(t, moderation.StatusHeld, moderation.NextStatus("", flagged))
+	require.Equal(t, moderation.StatusHeld, moderation.NextStatus(moderation.StatusApproved, flagged))
+	require.Equal(t, moderation.StatusHeld, moderation.NextStatus(moderation.StatusHeld, moderation.Verdict{}))
+	require.Equal(t, moderation.StatusHeld, moderation.NextStatus(moderation.StatusRejected, moderation.Verdict{}))
+	require.Equal(t, moderation.StatusApproved, moderation.NextStatus(moderation.StatusApproved, moderation.Verdict{}))
+}
+
+func TestModerationService_Report_HoldsAtThreshold(t *testing.T) {
+	repo := new(mocks.ModerationRepositoryMock)
+	reviewRepo := new(mocks.ReviewRepositoryMock)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := services.NewModerationService(repo, reviewRepo, new(mocks.BookRepositoryMock), new(mocks.UserRepositoryMock),
+		moderation.NewWordlistChecker(nil), 2, log)
+
+	reviewRepo.On("GetByID", uint(1)).Return(&models.Review{Model: gorm.Model{ID: 1}, AuthorID: 10, ModerationStatus: moderation.StatusApproved}, nil)
+	repo.On("CreateReport", mock.Anything).Return(nil)
+	repo.On("CountOpenUserReports", models.ContentReview, uint(1)).Return(int64(1), nil).Once()
+	repo.On("CountOpenUserReports", models.ContentReview, uint(1)).Return(int64(2), nil).Once()
+	repo.On("SetReviewStatus", uint(1), moderation.StatusApproved, moderation.StatusHeld).Return(nil).Once()
+
+	ctx := context.Background()
+	err := svc.Report(ctx, 10, models.ContentReview, 1, dto.ReportRequest{Reason: "спам"})
+	require.ErrorIs(t, err, dto.ErrReportOwnContent)
+	err = svc.Report(ctx, 11, models.ContentReview, 1, dto.ReportRequest{Reason: "   "})
+	require.ErrorIs(t, err, dto.ErrReportReasonRequired)
+
+	// первая жалоба ниже порога — отзыв остаётся опубликованным
+	require.NoError(t, svc.Report(ctx, 11, models.ContentReview, 1, dto.ReportRequest{Reason: "спам"}))
+	repo.AssertNotCalled(t, "SetReviewStatus", mock.Anything, mock.Anything, mock.Anything)
+
+	require.NoError(t, svc.Report(ctx, 12, models.ContentReview, 1, dto.ReportRequest{Reason: "оскорбления"}))
+	repo.AssertExpectations(t)
+}
+
+func TestModerationService_Decide_ModeratorOnly(t *testing.T) {
+	repo := new(mocks.ModerationRepositoryMock)
+	bookRepo := new(mocks.BookRepositoryMock)
+	userRepo := new(mocks.UserRepositoryMock)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := services.NewModerationService(repo, new(mocks.ReviewRepositoryMock), bookRepo, userRepo,
+		moderation.NewWordlistChecker(nil), 3, log)
+
+	userRepo.On("GetByID", uint(1)).Return(&models.User{ID: 1, Role: models.RoleUser}, nil)
+	userRepo.On("GetByID", uint(2)).Return(&models.User{ID: 2, Role: models.RoleModerator}, nil)
+	bookRepo.On("GetByID", uint(5)).Return(&models.Book{Model: gorm.Model{ID: 5}, UserID: 3, ModerationStatus: moderation.StatusHeld}, nil)
+	repo.On("SetBookStatus", uint(5), moderation.StatusHeld, moderation.StatusApproved).Return(nil)
+	repo.On("ResolveReports", models.ContentBook, uint(5)).Return(nil)
+
+	ctx := context.Background()
+	require.ErrorIs(t, svc.Approve(ctx, 1, models.ContentBook, 5), dto.ErrModeratorOnly)
+	_, err := svc.Queue(ctx, 1, 10)
+	require.ErrorIs(t, err, dto.ErrModeratorOnly)
+
+	require.NoError(t, svc.Approve(ctx, 2, models.ContentBook, 5))
+	repo.AssertExpectations(t)
+}
+
+func TestReviewService_Create_HeldByModeration(t *testing.T) {
+	reviewRepo := new(mocks.ReviewRepositoryMock)
+	exchangeRepo := new(mocks.ExchangeRepositoryMock)
+	userRepo := new(mocks.UserRepositoryMock)
+	bookRepo := new(mocks.BookRepositoryMock)
+	moderator := new(mocks.ModerationServiceMock)
+	svc := services.NewReviewService(reviewRepo, exchangeRepo, userRepo, bookRepo, moderator)
+
+	userRepo.On("GetByID", uint(2)).Return(&models.User{ID: 2}, nil)
+	bookRepo.On("GetByID", uint(20)).Return(&models.Book{Model: gorm.Model{ID: 20}}, nil)
+	exchangeRepo.On("GetByID", uint(7)).Return(&models.Exchange{
+		Model: gorm.Model{ID: 7}, InitiatorID: 1, RecipientID: 2, InitiatorBookID: 10, RecipientBookID: 20, Status: "completed",
+	}, nil)
+	reviewRepo.On("ExistsForExchange", uint(7), uint(1)).Return(false, nil)
+	verdict := moderation.Verdict{Flagged: true, Reasons: []string{"phone"}}
+	moderator.On("Screen", "", "звоните +7 912 345 67 89").Return(moderation.StatusHeld, verdict)
+	reviewRepo.On("Create", mock.MatchedBy(func(r *models.Review) bool {
+		return r.ModerationStatus == moderation.StatusHeld
+	})).Return(nil)
+	moderator.On("Flag", models.ContentReview, mock.Anything, verdict).Return()
+
+	rev, err := svc.Create(context.Background(), 1, dto.CreateReviewRequest{ExchangeID: 7, Rating: 4, Text: "звоните +7 912 345 67 89"})
+	require.NoError(t, err)
+	require.Equal(t, moderation.StatusHeld, rev.ModerationStatus)
+	moderator.AssertExpectations(t)
+}
+
+func TestReviewService_GetRevisions_Moderation(t *testing.T) {
+	reviewRepo := new(mocks.ReviewRepositoryMock)
+	userRepo := new(mocks.UserRepositoryMock)
+	svc := services.NewReviewService(reviewRepo, new(mocks.ExchangeRepositoryMock), userRepo, new(mocks.BookRepositoryMock), nil)
+	ctx := context.Background()
+
+	revisions := []models.ReviewRevision{
+		{ID: 2, ReviewID: 1, Text: "звоните +7 912 345 67 89", ModerationStatus: moderation.StatusHeld},
+		{ID: 1, ReviewID: 1, Text: "хорошая книга", ModerationStatus: moderation.StatusApproved},
+	}
+	reviewRepo.On("GetByID", uint(1)).Return(&models.Review{Model: gorm.Model{ID: 1}, AuthorID: 10, ModerationStatus: moderation.StatusApproved}, nil)
+	reviewRepo.On("GetByID", uint(2)).Return(&models.Review{Model: gorm.Model{ID: 2}, AuthorID: 10, ModerationStatus: moderation.StatusHeld}, nil)
+	reviewRepo.On("GetRevisions", uint(1)).Return(revisions, nil)
+	reviewRepo.On("GetRevisions", uint(2)).Return([]models.ReviewRevision{}, nil)
+	userRepo.On("GetByID", uint(20)).Return(&models.User{ID: 20, Role: models.RoleUser}, nil)
+	userRepo.On("GetByID", uint(30)).Return(&models.User{ID: 30, Role: models.RoleModerator}, nil)
+
+	// посторонним не показываем скрытые редакции
+	list, err := svc.GetRevisions(ctx, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, uint(1), list[0].ID)
+
+	// автор и модератор видят всю историю
+	list, err = svc.GetRevisions(ctx, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	list, err = svc.GetRevisions(ctx, 1, 30)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	// скрытый отзыв для посторонних не существует
+	_, err = svc.GetRevisions(ctx, 2, 0)
+	require.ErrorIs(t, err, dto.ErrReviewNotFound)
+	_, err = svc.GetRevisions(ctx, 2, 20)
+	require.ErrorIs(t, err, dto.ErrReviewNotFound)
+	_, err = svc.GetRevisions(ctx, 2, 10)
+	require.NoError(t, err)
+	_, err = svc.GetRevisions(ctx, 2, 30)
+	require.NoError(t, err)
+}
+
+func TestReviewService_Reply_Flagged(t *testing.T) {
+	reviewRepo := new(mocks.ReviewRepositoryMock)
+	moderator := new(mocks.ModerationServiceMock)
+	svc := services.NewReviewService(reviewRepo, new(mocks.ExchangeRepositoryMock), new(mocks.UserRepositoryMock), new(mocks.BookRepositoryMock), moderator)
+
+	reviewRepo.On("GetByID", uint(1)).Return(&models.Review{Model: gorm.Model{ID: 1}, AuthorID: 1, TargetUserID: 2}, nil)
+	moderator.On("Screen", "", "пишите на me@example.com").
+		Return(moderation.StatusHeld, moderation.Verdict{Flagged: true, Reasons: []string{"email"}})
+
+	_, err := svc.Reply(context.Background(), 1, 2, dto.ReviewReplyRequest{Text: "пишите на me@example.com"})
+	require.ErrorIs(t, err, dto.ErrReviewReplyFlagged)
+	reviewRepo.AssertNotCalled(t, "CreateReply", mock.Anything)
+}